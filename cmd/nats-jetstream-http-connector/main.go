@@ -2,269 +2,438 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
-	"maps"
 	"net/http"
-	"strings"
-	"time"
+	"os"
+	"os/signal"
+	"runtime"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/amqpsink"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/awssink"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/connector"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/connector/web"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/k8sevents"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/largemsg"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/loadgen"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/luahook"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/pgsink"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/profile"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/rediskv"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/resolver"
 	"github.com/glassflow/nats-jetstream-http-connector/pkg/service"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service/limits"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service/server"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/wasmhook"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/webhook"
 )
 
-//nolint:govet // General config of the service with focus on human readability.
-type Config struct {
-	NatsServer string        `env:"NATS_SERVER"`
-	Consumer   string        `env:"CONSUMER"`
-	AckWait    time.Duration `env:"ACKWAIT" default:"1m"`
-
-	Topic         string `env:"TOPIC" required:""`
-	HTTPEndpoint  string `env:"HTTP_ENDPOINT" required:""`
-	MaxRetries    int    `env:"MAX_RETRIES" required:""`
-	ContentType   string `env:"CONTENT_TYPE" required:""`
-	ResponseTopic string `env:"RESPONSE_TOPIC"`
-	ErrorTopic    string `env:"ERROR_TOPIC"`
-	SourceName    string `env:"SOURCE_NAME" default:"KEDAConnector"`
-
-	Concurrent int `env:"CONCURRENT" default:"1"`
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		os.Exit(loadgenMain(os.Args[2:]))
+	}
+
+	check, args := checkFlag(os.Args)
+	cfg, err := service.Load[connector.Config](args)
+	if errors.Is(err, service.ErrPrintHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if check {
+		os.Exit(checkMain(cfg.Service(), cfg.Logger()))
+	}
+
+	service.Run(cfg, mainErr)
 }
 
-func main() {
-	service.Main[Config](mainErr)
+// checkFlag parses the --check flag of the check mode, which precedes the config flags, and returns the args without it.
+// It isn't a config flag: the bool config flags require a value, e.g. --acksync=true.
+func checkFlag(args []string) (bool, []string) {
+	if len(args) < 2 {
+		return false, args
+	}
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	check := fs.Bool("check", false, "check the config against NATS and the endpoint and exit")
+	if err := fs.Parse(args[1:2]); err != nil {
+		return false, args // a config flag
+	}
+	return *check, append([]string{args[0]}, args[2:]...)
 }
 
-func mainErr(ctx context.Context, cfg Config, log *slog.Logger, base service.Base) error {
-	nc, err := nats.Connect(cfg.NatsServer)
+func loadgenMain(args []string) int {
+	cfg, err := loadgen.ParseFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
 	if err != nil {
-		return fmt.Errorf("cannot connect to nats: %w", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 2
 	}
 
-	js, err := jetstream.New(nc)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report, err := loadgen.Run(ctx, cfg, os.Stdout)
+	fmt.Print(report)
 	if err != nil {
-		return fmt.Errorf("error while getting jetstream context: %w", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	return 0
+}
 
-	conn := jetstreamConnector{
-		host:          cfg.NatsServer,
-		connectordata: cfg,
-		jsContext:     js,
-		logger:        log,
-		consumer:      cfg.Consumer,
-		concurrentSem: make(chan int, cfg.Concurrent),
+// checkMain runs the check mode before the servers of the service are started: it prints the report
+// of the config checks against live NATS and the endpoint and returns the exit code.
+func checkMain(cfg connector.Config, log *slog.Logger) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cfg, nc, js, err := connect(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	defer nc.Close()
 
-	base.AddGracefulService("consumer", func() {
-		err = conn.consumeMessage(ctx)
-	}, nil)
+	report := connector.New(cfg, nc, js, nil, log).Check(ctx)
+	report.Print(os.Stdout)
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
 
-	base.ListenAndServe(nil, nil)
+// connect validates the config, sets its defaults resolved at runtime and connects to NATS.
+func connect(cfg connector.Config) (connector.Config, *nats.Conn, jetstream.JetStream, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return cfg, nil, nil, fmt.Errorf("validate config: %w", err)
+	}
+
+	if cfg.Concurrent == connector.ConcurrencyAuto {
+		cfg.Concurrent = autoConcurrency()
+	}
 
+	pod := connector.NewPodIdentity(os.Getenv)
+	consumer, err := connector.ConsumerName(cfg.Consumer, pod)
 	if err != nil {
-		return fmt.Errorf("error occurred while parsing metadata: %w", err)
+		return cfg, nil, nil, fmt.Errorf("resolve consumer name: %w", err)
+	}
+	cfg.Consumer = consumer
+
+	nc, err := nats.Connect(cfg.NatsServer)
+	if err != nil {
+		return cfg, nil, nil, fmt.Errorf("cannot connect to nats: %w", err)
 	}
-	return nil
-}
 
-type jetstreamConnector struct {
-	host          string
-	connectordata Config
-	jsContext     jetstream.JetStream
-	logger        *slog.Logger
-	consumer      string
-	concurrentSem chan int
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return cfg, nil, nil, fmt.Errorf("error while getting jetstream context: %w", err)
+	}
+	return cfg, nc, js, nil
 }
 
-func (conn jetstreamConnector) consumeMessage(ctx context.Context) error {
-	log := conn.logger
-	var askWait time.Duration = conn.connectordata.AckWait
+// autoConcurrency returns CONCURRENT of 'auto' value: GOMAXPROCS limited by the container CPU quota,
+// so the quota applies even if RUNTIME_AUTOMAXPROCS is disabled. GOMAXPROCS env is used as is.
+func autoConcurrency() connector.Concurrency {
+	procs := runtime.GOMAXPROCS(0)
+	if _, ok := os.LookupEnv("GOMAXPROCS"); ok {
+		return connector.Concurrency(procs)
+	}
+	if quota, err := limits.CPUQuota(); err == nil {
+		return connector.Concurrency(min(procs, limits.MaxProcs(quota)))
+	}
+	return connector.Concurrency(procs)
+}
 
-	cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+func mainErr(ctx context.Context, cfg connector.Config, log *slog.Logger, base service.Base) error {
+	cfg, nc, js, err := connect(cfg)
 	if err != nil {
-		log.Error("Error on new consumer (will be ignored)", slog.Any("error", err))
-		jconf := jetstream.ConsumerConfig{
-			Durable:       conn.consumer,
-			AckPolicy:     jetstream.AckExplicitPolicy,
-			FilterSubject: conn.connectordata.Topic + ".input",
-			AckWait:       askWait + time.Second,
+		return err
+	}
+
+	var objStore nats.ObjectStore
+	if cfg.LargeResponseMode == largemsg.ModeObjectStore || cfg.ClaimCheck || (cfg.BodyEncoding == connector.BodyMultipart && cfg.ObjectStoreBucket != "") {
+		if cfg.ObjectStoreBucket == "" {
+			return fmt.Errorf("object store bucket is required for large response mode %q or claim check", cfg.LargeResponseMode)
 		}
-		cs, err = conn.jsContext.CreateConsumer(ctx, conn.connectordata.Topic, jconf)
+
+		legacyJS, err := nc.JetStream()
 		if err != nil {
-			return fmt.Errorf("create consumer: %w", err)
-		} else {
-			log.Info("New consumer is created", slog.String("topic", conn.connectordata.Topic), slog.String("consumer", conn.consumer), slog.String("filter_subject", jconf.FilterSubject))
+			return fmt.Errorf("error while getting legacy jetstream context: %w", err)
 		}
-	} else {
-		log.Info("Use consumer", slog.String("topic", conn.connectordata.Topic), slog.String("consumer", conn.consumer))
-	}
 
-	log.Info("Start receiving messages")
-
-	_, err = cs.Consume(func(msg jetstream.Msg) {
-		log.Info("Got a message", slog.String("message", string(msg.Data())))
-		conn.concurrentSem <- 1
+		objStore, err = legacyJS.ObjectStore(cfg.ObjectStoreBucket)
+		if err != nil {
+			return fmt.Errorf("cannot bind object store %q: %w", cfg.ObjectStoreBucket, err)
+		}
+	}
 
-		log.Info("Start processing", slog.String("message", string(msg.Data())))
-		go func() {
-			goCtx, cancel := context.WithTimeout(ctx, askWait)
-			defer cancel()
+	var profileStore nats.ObjectStore
+	if cfg.ProfileBucket != "" {
+		legacyJS, err := nc.JetStream()
+		if err != nil {
+			return fmt.Errorf("error while getting legacy jetstream context: %w", err)
+		}
 
-			conn.handleHTTPRequest(goCtx, msg)
-			<-conn.concurrentSem
-		}()
-	})
-	if err != nil {
-		log.Debug("error occurred while parsing metadata", slog.Any("error", err))
-		return err
+		profileStore, err = legacyJS.ObjectStore(cfg.ProfileBucket)
+		if err != nil {
+			return fmt.Errorf("cannot bind profile object store %q: %w", cfg.ProfileBucket, err)
+		}
 	}
 
-	<-ctx.Done()
+	conn := connector.New(cfg, nc, js, objStore, log)
 
-	log.Info("closing connection...")
+	if cfg.ExactlyOnceHint {
+		kv, err := js.KeyValue(ctx, cfg.CheckpointBucket)
+		if errors.Is(err, jetstream.ErrBucketNotFound) {
+			kv, err = js.CreateKeyValue(ctx, jetstream.KeyValueConfig{Bucket: cfg.CheckpointBucket}) //nolint:exhaustruct // defaults
+		}
+		if err != nil {
+			return fmt.Errorf("cannot bind checkpoint bucket %q: %w", cfg.CheckpointBucket, err)
+		}
+		err = conn.SetCheckpoint(ctx, kv)
+		if err != nil {
+			return fmt.Errorf("load checkpoint: %w", err)
+		}
+	}
 
-	return nil
-}
+	// closers release the resources used by the message processing once the consumer is drained.
+	var closers []func()
+	closeResources := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
 
-func (conn jetstreamConnector) handleHTTPRequest(ctx context.Context, msg jetstream.Msg) {
-	log := conn.logger
-	message := string(msg.Data())
+	if cfg.ResponseSink == connector.SinkAMQP || cfg.ErrorSink == connector.SinkAMQP {
+		amqpSink, err := amqpsink.New(cfg.AMQPURL, cfg.AMQPExchange, cfg.AMQPCAFile)
+		if err != nil {
+			return fmt.Errorf("create amqp sink: %w", err)
+		}
+		closers = append(closers, func() { amqpSink.Close() })
+		conn.SetSink(connector.SinkAMQP, amqpSink)
+	}
 
-	headers := http.Header{
-		"Topic":        {conn.connectordata.Topic},
-		"RespTopic":    {conn.connectordata.ResponseTopic},
-		"ErrorTopic":   {conn.connectordata.ErrorTopic},
-		"Content-Type": {conn.connectordata.ContentType},
-		"Source-Name":  {conn.connectordata.SourceName},
+	if cfg.ResponseSink == connector.SinkPostgres {
+		pgSink, err := pgsink.New(ctx, cfg.PostgresURL, cfg.PostgresTable, cfg.PostgresColumns, cfg.PostgresBatchSize, cfg.PostgresBatchInterval)
+		if err != nil {
+			return fmt.Errorf("create postgres sink: %w", err)
+		}
+		closers = append(closers, pgSink.Close)
+		conn.SetSink(connector.SinkPostgres, pgSink)
 	}
 
-	maps.Copy(headers, msg.Headers()) // Add and overwrite headers from Jetstream
+	if cfg.RedisURL != "" {
+		kv, err := rediskv.New(cfg.RedisURL, cfg.RedisKeyPrefix)
+		if err != nil {
+			return fmt.Errorf("create redis client: %w", err)
+		}
+		closers = append(closers, kv.Close)
+		conn.SetKV(kv)
+	}
 
-	resp, err := HandleHTTPRequest(ctx, string(msg.Data()), headers, conn.connectordata, log)
-	if err != nil {
-		conn.logger.Info(err.Error())
-		conn.errorHandler(err)
-		return
+	if cfg.WASMHook != "" {
+		hook, err := wasmhook.Load(ctx, cfg.WASMHook, int(cfg.Concurrent))
+		if err != nil {
+			return fmt.Errorf("load wasm hook: %w", err)
+		}
+		closers = append(closers, func() { hook.Close(context.Background()) })
+		conn.SetTransformHook(hook)
 	}
 
-	if resp.Body != nil {
-		defer resp.Body.Close()
+	if cfg.LuaScript != "" {
+		script, err := luahook.Load(cfg.LuaScript, int(cfg.Concurrent))
+		if err != nil {
+			return fmt.Errorf("load lua script: %w", err)
+		}
+		closers = append(closers, script.Close)
+		conn.SetScriptHook(script)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		conn.logger.Info(err.Error())
-		conn.errorHandler(err)
-		return
+	if cfg.K8SEvents {
+		pod := connector.NewPodIdentity(os.Getenv)
+		rec, err := k8sevents.NewInCluster(k8sevents.Pod{Name: pod.PodName, Namespace: pod.Namespace, UID: os.Getenv("POD_UID"), NodeName: pod.NodeName})
+		if err != nil {
+			return fmt.Errorf("create kubernetes event recorder: %w", err)
+		}
+		conn.SetEventRecorder(rec)
 	}
 
-	success := conn.responseHandler(body)
-	if !success {
-		return
+	aws := &awssink.Client{
+		Region: cfg.AWSRegion,
+		Credentials: awssink.NewProvider(
+			awssink.Credentials{
+				AccessKeyID:     cfg.AWSAccessKeyID,
+				SecretAccessKey: cfg.AWSSecretAccessKey,
+				SessionToken:    cfg.AWSSessionToken,
+			},
+			awssink.WebIdentity{
+				RoleARN:     cfg.AWSRoleARN,
+				SessionName: cfg.AWSRoleSessionName,
+				TokenFile:   cfg.AWSWebIdentityTokenFile,
+				Endpoint:    cfg.AWSEndpointURL,
+				Region:      cfg.AWSRegion,
+				HTTP:        nil,
+			},
+			awssink.InstanceRole{Endpoint: "", HTTP: nil},
+		),
+		Endpoint: cfg.AWSEndpointURL,
+		HTTP:     nil,
+	}
+	conn.SetSink(connector.SinkSQS, awssink.SQS{Client: aws})
+	conn.SetSink(connector.SinkSNS, awssink.SNS{Client: aws})
+	if cfg.ArchiveBucket != "" {
+		conn.SetArchiver(awssink.S3{Client: aws, Bucket: cfg.ArchiveBucket})
 	}
 
-	select {
-	case <-ctx.Done():
-		log.Error("Context is canceled - message won't be acked", slog.String("message", message))
-		return
-	default:
+	conn.SetWebhookSinks(
+		&webhook.Sink{URLs: cfg.WebhookURLs, ContentType: cfg.ContentType, Secret: cfg.WebhookSecret, MaxRetries: cfg.WebhookMaxRetries, Timeout: cfg.WebhookTimeout},
+		&webhook.Sink{URLs: cfg.WebhookErrorURLs, ContentType: "application/json", Secret: cfg.WebhookSecret, MaxRetries: cfg.WebhookMaxRetries, Timeout: cfg.WebhookTimeout},
+	)
+
+	if cfg.DNSResolver != "" || len(cfg.DNSPin) > 0 {
+		res := resolver.New(cfg.DNSResolver, cfg.DNSPin, cfg.DNSRefreshInterval, log)
+		conn.SetResolver(res)
+		base.AddGracefulService("dns-refresh", func() error {
+			res.Run(ctx)
+			return nil
+		}, nil)
 	}
 
-	err = msg.Ack()
+	err = conn.Preflight(ctx)
 	if err != nil {
-		log.Info(err.Error())
-		conn.errorHandler(err)
+		return fmt.Errorf("preflight check: %w", err)
 	}
-	log.Info("done processing message", slog.String("message", string(body)))
-}
 
-func (conn jetstreamConnector) responseHandler(response []byte) bool {
-	log := conn.logger
+	base.AddDependencyCheck("nats", conn.NATSCheck)
 
-	if len(conn.connectordata.ResponseTopic) == 0 {
-		log.Warn("Response topic not set")
-		return false
+	if cfg.HealthProbePath != "" {
+		base.AddGracefulService("health-probe", func() error {
+			conn.RunHealthProbe(ctx)
+			return nil
+		}, nil)
+		base.AddReadinessCheck("endpoint", conn.HealthCheck)
+		base.AddDependencyCheck("endpoint", conn.HealthCheck)
 	}
 
-	_, err := conn.jsContext.Publish(context.Background(), conn.connectordata.ResponseTopic, response)
-	if err != nil {
-		log.Error("failed to publish response body from http request to topic",
-			slog.Any("error", err),
-			slog.String("topic", conn.connectordata.ResponseTopic),
-			slog.String("source", conn.connectordata.SourceName),
-			slog.String("http endpoint", conn.connectordata.HTTPEndpoint),
-		)
-		return false
-	} else {
-		log.Info("Response is sent", slog.String("topic", conn.connectordata.ResponseTopic), slog.String("response", string(response)))
+	if cfg.KeepWarmPath != "" {
+		base.AddGracefulService("keep-warm", func() error {
+			conn.RunKeepWarm(ctx)
+			return nil
+		}, nil)
 	}
-	return true
-}
 
-func (conn jetstreamConnector) errorHandler(err error) {
-	log := conn.logger
+	if cfg.ResponseFlowControl && cfg.ResponseTopic != "" {
+		base.AddGracefulService("response-flow-control", func() error {
+			conn.RunResponseFlowControl(ctx)
+			return nil
+		}, nil)
+	}
 
-	if len(conn.connectordata.ErrorTopic) == 0 {
-		log.Warn("error topic not set")
-		return
+	if cfg.SSESourceURL != "" {
+		base.AddGracefulService("sse-source", func() error {
+			conn.RunSSESource(ctx)
+			return nil
+		}, nil)
 	}
 
-	_, publishErr := conn.jsContext.Publish(context.Background(), conn.connectordata.ErrorTopic, []byte(err.Error()))
-	if publishErr != nil {
-		log.Error("failed to publish message to error topic",
-			slog.Any("error", publishErr),
-			slog.String("source", conn.connectordata.SourceName),
-			slog.String("message", publishErr.Error()),
-			slog.String("topic", conn.connectordata.ErrorTopic))
-	} else {
-		log.Info("Error is sent to fallback topic", slog.String("topic", conn.connectordata.ErrorTopic), slog.String("error", err.Error()))
+	if cfg.StreamInfoInterval > 0 {
+		base.AddGracefulService("stream-info-metrics", func() error {
+			conn.RunStreamInfoMetrics(ctx)
+			return nil
+		}, nil)
 	}
-}
 
-// HandleHTTPRequest sends message and headers data to HTTP endpoint using POST method and returns response on success or error in case of failure
-func HandleHTTPRequest(ctx context.Context, message string, headers http.Header, cfg Config, log *slog.Logger) (*http.Response, error) {
+	if cfg.AggregateSubject != "" {
+		base.AddGracefulService("aggregation", func() error {
+			conn.RunAggregation(ctx)
+			return nil
+		}, nil)
+	}
 
-	var resp *http.Response
-	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
-		// Create request
-		req, err := http.NewRequestWithContext(ctx, "POST", cfg.HTTPEndpoint, strings.NewReader(message))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create HTTP request to invoke function. http_endpoint: %v, source: %v: %w", cfg.HTTPEndpoint, cfg.SourceName, err)
-		}
+	if cfg.ErrorRateLimit > 0 {
+		base.AddGracefulService("error-summary", func() error {
+			conn.RunErrorSummary(ctx)
+			return nil
+		}, nil)
+	}
 
-		// Add headers
-		for key, vals := range headers {
-			for _, val := range vals {
-				req.Header.Add(key, val)
-			}
-		}
+	if cfg.ExactlyOnceHint {
+		base.AddGracefulService("checkpoint", func() error {
+			conn.RunCheckpoint(ctx)
+			return nil
+		}, nil)
+	}
 
-		// Make the request
-		resp, err = http.DefaultClient.Do(req)
-		if err != nil {
-			log.Error("sending function invocation request failed",
-				slog.Any("error", err),
-				slog.String("http_endpoint", cfg.HTTPEndpoint),
-				slog.String("source", cfg.SourceName))
-			continue
-		}
-		if resp == nil {
-			continue
-		}
-		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			// Success, quit retrying
-			return resp, nil
-		}
+	if cfg.ArchiveBucket != "" {
+		base.AddGracefulService("archive", func() error {
+			conn.RunArchiver(ctx)
+			return nil
+		}, nil)
 	}
 
-	if resp == nil {
-		return nil, fmt.Errorf("every function invocation retry failed; final retry gave empty response. http_endpoint: %v, source: %v", cfg.HTTPEndpoint, cfg.SourceName)
+	if cfg.AlertSubject != "" {
+		base.AddGracefulService("alerts", func() error {
+			conn.RunAlerts(ctx)
+			return nil
+		}, nil)
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 300 {
-		return nil, fmt.Errorf("request returned failure: %v. http_endpoint: %v, source: %v", resp.StatusCode, cfg.HTTPEndpoint, cfg.SourceName)
+	if cfg.Backfill {
+		base.AddGracefulService("backfill", func() error {
+			defer closeResources()
+			return conn.Backfill(ctx)
+		}, nil)
+	} else if cfg.ConsumerMode == connector.ConsumerModePushLegacy {
+		base.AddGracefulService("consumer", func() error {
+			defer closeResources()
+			return conn.ConsumePushLegacy(ctx)
+		}, conn.WaitDrained)
+		base.AddReadinessCheck("consume", conn.ConsumeCheck)
+	} else {
+		base.AddGracefulService("consumer", func() error {
+			defer closeResources()
+			return conn.Consume(ctx)
+		}, conn.WaitDrained)
+		base.AddReadinessCheck("consume", conn.ConsumeCheck)
+		if cfg.ReadyAfterConsuming {
+			base.AddReadinessCheck("consumer", conn.ConsumingCheck)
+		}
+	}
+
+	base.Handle(http.MethodGet, "/backfill", conn.BackfillHandler())
+	base.Handle(http.MethodGet, "/status", conn.StatusHandler())
+	if cfg.AsyncCallback {
+		base.Handle(http.MethodPost, "/callbacks/{token}", conn.CallbacksHandler())
+	}
+	if cfg.JobLease {
+		base.Handle(http.MethodPost, "/jobs/{token}/extend", conn.JobsHandler())
 	}
-	return resp, nil
+	base.Handle(http.MethodGet, "/dashboard", web.Dashboard())
+	if cfg.ControlToken != "" {
+		base.Handle(http.MethodPost, "/pause", server.BearerAuth(cfg.ControlToken, conn.PauseHandler(true)))
+		base.Handle(http.MethodPost, "/resume", server.BearerAuth(cfg.ControlToken, conn.PauseHandler(false)))
+	}
+	if cfg.Chaos {
+		base.Handle(http.MethodPost, "/chaos/echo", conn.ChaosHandler())
+	}
+	if profileStore != nil {
+		base.Handle(http.MethodPost, "/debug/profile/capture", server.BearerAuth(cfg.ProfileToken, profile.Handler(profileStore, cfg.Consumer, log)))
+	}
+
+	base.ListenAndServe(nil, nil)
+
+	return nil
 }
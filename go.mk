@@ -1,6 +1,7 @@
 APP?=
 VERSION?=$(shell git describe --tag --always --dirty)
 COMMIT_HASH?=$(shell git rev-parse --short HEAD)
+BUILD_DATE?=$(shell date -u +%Y-%m-%dT%H:%M:%SZ)
 
 GOVERSION?=
 CI_GOLANGCI_LINT_VERSION?=
@@ -41,12 +42,13 @@ build: go-build
 GO?=go
 GO_MOD?=readonly
 GO_BUILD_ENV_PREFIX?=GOWORK=off GOEXPERIMENT=loopvar
+GO_BUILD_TAGS?=
 BUILD_OUTPUT?=/tmp/${APP}
 GO_LDFLAGS_VERSION_COMMIT_PATH?=github.com/glassflow/${APP}/pkg/service
 
 go-build:
-	${GO_BUILD_ENV_PREFIX} ${GO} build -mod=${GO_MOD} -o ${BUILD_OUTPUT} \
-		-ldflags "-X ${GO_LDFLAGS_VERSION_COMMIT_PATH}.version=${VERSION} -X ${GO_LDFLAGS_VERSION_COMMIT_PATH}.commit=${COMMIT_HASH}" \
+	${GO_BUILD_ENV_PREFIX} ${GO} build -mod=${GO_MOD} -tags "${GO_BUILD_TAGS}" -o ${BUILD_OUTPUT} \
+		-ldflags "-X ${GO_LDFLAGS_VERSION_COMMIT_PATH}.version=${VERSION} -X ${GO_LDFLAGS_VERSION_COMMIT_PATH}.commit=${COMMIT_HASH} -X ${GO_LDFLAGS_VERSION_COMMIT_PATH}.buildDate=${BUILD_DATE}" \
 		cmd/${APP}/main.go
 
 test:
@@ -0,0 +1,145 @@
+// Package amqpsink publishes messages to an AMQP 0-9-1 (RabbitMQ) exchange with publisher confirms.
+package amqpsink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var ErrNack = errors.New("message is nacked by the broker")
+
+// Sink publishes to the exchange. The connection is dialed on the first publish and redialed after a failure.
+type Sink struct {
+	url      string
+	exchange string
+	tls      *tls.Config
+
+	mx sync.Mutex
+	cn *amqp.Connection
+	ch *amqp.Channel
+}
+
+// New creates the sink. The user and the password are set in the URL, 'amqps://' URL enables TLS.
+// If caFile is set, the broker certificate is verified with it.
+func New(url, exchange, caFile string) (*Sink, error) {
+	s := &Sink{url: url, exchange: exchange} //nolint:exhaustruct // connection is dialed lazily
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in ca file %q", caFile)
+		}
+		s.tls = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12} //nolint:exhaustruct // optional parameters
+	}
+	return s, nil
+}
+
+// Publish publishes the message with the routing key and waits for the broker confirmation.
+// The lock is held only to get the channel: the confirmations of concurrent publishes are awaited in parallel.
+func (s *Sink) Publish(ctx context.Context, routingKey string, data []byte, headers map[string][]string) error {
+	s.mx.Lock()
+	ch, err := s.channel()
+	s.mx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	table := amqp.Table{}
+	for k, v := range headers {
+		table[k] = strings.Join(v, ",")
+	}
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, s.exchange, routingKey, false, false, amqp.Publishing{ //nolint:exhaustruct // optional parameters
+		Headers:      table,
+		DeliveryMode: amqp.Persistent,
+		Body:         data,
+	})
+	if err != nil {
+		s.resetChannel(ch)
+		return fmt.Errorf("publish to exchange %q: %w", s.exchange, err)
+	}
+
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		s.resetChannel(ch)
+		return fmt.Errorf("wait for confirmation from exchange %q: %w", s.exchange, err)
+	}
+	if !ok {
+		return fmt.Errorf("exchange %q: %w", s.exchange, ErrNack)
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (s *Sink) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.cn == nil {
+		return nil
+	}
+	err := s.cn.Close()
+	s.cn, s.ch = nil, nil
+	return err //nolint:wrapcheck // amqp errors are descriptive
+}
+
+func (s *Sink) channel() (*amqp.Channel, error) {
+	if s.ch != nil && !s.ch.IsClosed() {
+		return s.ch, nil
+	}
+	s.reset()
+
+	var cn *amqp.Connection
+	var err error
+	if s.tls != nil {
+		cn, err = amqp.DialTLS(s.url, s.tls)
+	} else {
+		cn, err = amqp.Dial(s.url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial amqp: %w", err)
+	}
+
+	ch, err := cn.Channel()
+	if err != nil {
+		cn.Close()
+		return nil, fmt.Errorf("open amqp channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		cn.Close()
+		return nil, fmt.Errorf("enable publisher confirms: %w", err)
+	}
+
+	s.cn, s.ch = cn, ch
+	return ch, nil
+}
+
+// resetChannel resets the connection if the failed channel is still in use: it isn't redialed twice
+// when several publishes fail on the same channel.
+func (s *Sink) resetChannel(ch *amqp.Channel) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.ch == ch {
+		s.reset()
+	}
+}
+
+func (s *Sink) reset() {
+	if s.cn != nil {
+		s.cn.Close()
+	}
+	s.cn, s.ch = nil, nil
+}
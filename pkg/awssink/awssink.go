@@ -0,0 +1,212 @@
+// Package awssink publishes messages to AWS SQS queues and SNS topics using the Query API
+// and puts objects to S3 compatible storages, requests are signed with Signature Version 4.
+package awssink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials are AWS access keys. SessionToken is set for temporary credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Client sends signed Query API requests.
+type Client struct {
+	Region string
+	// Credentials are static Credentials or a provider of temporary ones, see NewProvider.
+	Credentials Provider
+	// Endpoint overrides 'https://<service>.<region>.amazonaws.com', e.g. for LocalStack. Empty uses the AWS endpoint.
+	Endpoint string
+	HTTP     *http.Client
+}
+
+// SQS publishes to queues by queue URL.
+type SQS struct{ *Client }
+
+// Publish sends the message to the queue. Headers are not sent.
+func (s SQS) Publish(ctx context.Context, queueURL string, data []byte, _ map[string][]string) error {
+	return s.call(ctx, "sqs", url.Values{
+		"Action":      {"SendMessage"},
+		"Version":     {"2012-11-05"},
+		"QueueUrl":    {queueURL},
+		"MessageBody": {string(data)},
+	})
+}
+
+// SNS publishes to topics by topic ARN.
+type SNS struct{ *Client }
+
+// Publish publishes the message to the topic. Headers are not sent.
+func (s SNS) Publish(ctx context.Context, topicARN string, data []byte, _ map[string][]string) error {
+	return s.call(ctx, "sns", url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {topicARN},
+		"Message":  {string(data)},
+	})
+}
+
+// S3 puts objects to the bucket. Objects are addressed path-style, so Endpoint may point to MinIO.
+type S3 struct {
+	*Client
+	Bucket string
+}
+
+// Put puts the object with the key to the bucket.
+func (s S3) Put(ctx context.Context, key string, data []byte) error {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.Region)
+	}
+	u := strings.TrimSuffix(endpoint, "/") + "/" + s.Bucket + "/" + (&url.URL{Path: key}).EscapedPath()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create s3 request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Amz-Content-Sha256", hexSHA256(data))
+	if err := s.sign(req, "s3", data, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	client := s.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 put %s/%s: %w", s.Bucket, key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = xml.Unmarshal(data, &e)
+		return fmt.Errorf("s3 put %s/%s: status %d: %s: %s", s.Bucket, key, resp.StatusCode, e.Code, e.Message)
+	}
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, service string, form url.Values) error {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com/", service, c.Region)
+	}
+
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", service, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := c.sign(req, service, []byte(body), time.Now().UTC()); err != nil {
+		return err
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", service, form.Get("Action"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = xml.Unmarshal(data, &e)
+		return fmt.Errorf("%s %s: status %d: %s: %s", service, form.Get("Action"), resp.StatusCode, e.Code, e.Message)
+	}
+	return nil
+}
+
+// sign adds Signature Version 4 headers to the request.
+func (c *Client) sign(req *http.Request, service string, body []byte, now time.Time) error {
+	creds, _, err := c.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("retrieve aws credentials: %w", err)
+	}
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signed := []string{"content-type", "host"}
+	for h := range req.Header {
+		if h := strings.ToLower(h); strings.HasPrefix(h, "x-amz-") {
+			signed = append(signed, h)
+		}
+	}
+	sort.Strings(signed)
+	var canonicalHeaders strings.Builder
+	for _, h := range signed {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,211 @@
+package awssink
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIMDSEndpoint is the EC2 instance metadata service endpoint.
+	DefaultIMDSEndpoint = "http://169.254.169.254"
+	// refreshBefore is how long before the expiration temporary credentials are refreshed.
+	refreshBefore = 5 * time.Minute
+	// imdsTokenTTL is the lifetime in seconds of the IMDSv2 session token.
+	imdsTokenTTL = "21600"
+)
+
+var ErrNoCredentials = errors.New("aws credentials are not available")
+
+// Provider retrieves the credentials and their expiration, zero for the credentials which don't expire.
+type Provider interface {
+	Retrieve(ctx context.Context) (Credentials, time.Time, error)
+}
+
+// Retrieve returns the static credentials.
+func (c Credentials) Retrieve(context.Context) (Credentials, time.Time, error) {
+	return c, time.Time{}, nil
+}
+
+// WebIdentity assumes the role with the web identity token, e.g. the service account token
+// of an EKS pod with IAM Roles for Service Accounts (IRSA). The token file is re-read on every retrieval,
+// it is rotated by the kubelet.
+type WebIdentity struct {
+	RoleARN     string
+	SessionName string
+	TokenFile   string
+	// Endpoint overrides 'https://sts.<region>.amazonaws.com/'.
+	Endpoint string
+	Region   string
+	HTTP     *http.Client
+}
+
+func (w WebIdentity) Retrieve(ctx context.Context) (Credentials, time.Time, error) {
+	token, err := os.ReadFile(w.TokenFile)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("read web identity token: %w", err)
+	}
+
+	endpoint := w.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", w.Region)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {w.RoleARN},
+		"RoleSessionName":  {w.SessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("create sts request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	body, err := do(w.HTTP, req)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("sts AssumeRoleWithWebIdentity: %w", err)
+	}
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("parse sts AssumeRoleWithWebIdentity response: %w", err)
+	}
+	c := resp.Credentials
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken}, c.Expiration, nil
+}
+
+// InstanceRole retrieves the credentials of the role of the EC2 instance from the instance metadata service (IMDSv2).
+type InstanceRole struct {
+	// Endpoint overrides DefaultIMDSEndpoint.
+	Endpoint string
+	HTTP     *http.Client
+}
+
+func (r InstanceRole) Retrieve(ctx context.Context) (Credentials, time.Time, error) {
+	endpoint := r.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultIMDSEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("create imds token request: %w", err)
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", imdsTokenTTL)
+	token, err := do(r.HTTP, req)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("get imds token: %w", err)
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create imds request: %w", err)
+		}
+		req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+		return do(r.HTTP, req)
+	}
+
+	const credentialsPath = "/latest/meta-data/iam/security-credentials/"
+	role, err := get(credentialsPath)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("get instance role: %w", err)
+	}
+	name, _, _ := strings.Cut(strings.TrimSpace(string(role)), "\n")
+	if name == "" {
+		return Credentials{}, time.Time{}, fmt.Errorf("instance has no role: %w", ErrNoCredentials)
+	}
+	body, err := get(credentialsPath + name)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("get instance role %s credentials: %w", name, err)
+	}
+	var c struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(body, &c); err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("parse instance role %s credentials: %w", name, err)
+	}
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.Token}, c.Expiration, nil
+}
+
+// NewProvider returns the provider of the first available source: the static credentials if the access key is set,
+// the web identity (IRSA) if the token file and the role are set, the EC2 instance role otherwise.
+func NewProvider(static Credentials, web WebIdentity, imds InstanceRole) Provider {
+	switch {
+	case static.AccessKeyID != "":
+		return static
+	case web.TokenFile != "" && web.RoleARN != "":
+		return &cachedProvider{provider: web} //nolint:exhaustruct // zero cache
+	default:
+		return &cachedProvider{provider: imds} //nolint:exhaustruct // zero cache
+	}
+}
+
+// cachedProvider keeps the temporary credentials until refreshBefore their expiration.
+type cachedProvider struct {
+	provider Provider
+
+	mx      sync.Mutex
+	creds   Credentials
+	expires time.Time
+}
+
+func (p *cachedProvider) Retrieve(ctx context.Context) (Credentials, time.Time, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.creds.AccessKeyID != "" && (p.expires.IsZero() || time.Until(p.expires) > refreshBefore) {
+		return p.creds, p.expires, nil
+	}
+	creds, expires, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, time.Time{}, err //nolint:wrapcheck // providers wrap errors with the source
+	}
+	if creds.AccessKeyID == "" {
+		return Credentials{}, time.Time{}, ErrNoCredentials
+	}
+	p.creds, p.expires = creds, expires
+	return creds, expires, nil
+}
+
+// do sends the request and returns the body of the 200 response.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the callers
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,178 @@
+package awssink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebIdentity(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("RoleArn") != "arn:aws:iam::1:role/connector" ||
+			r.Form.Get("WebIdentityToken") != "jwt" || r.Form.Get("RoleSessionName") != "test" {
+			http.Error(w, "wrong request: "+r.Form.Encode(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>AKID</AccessKeyId><SecretAccessKey>SECRET</SecretAccessKey><SessionToken>TOKEN</SessionToken>
+<Expiration>%s</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`, expires.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("jwt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		tokenFile string
+		role      string
+		err       bool
+	}{
+		{name: "assumed", tokenFile: tokenFile, role: "arn:aws:iam::1:role/connector"},
+		{name: "rejected", tokenFile: tokenFile, role: "arn:aws:iam::1:role/other", err: true},
+		{name: "no token file", tokenFile: filepath.Join(t.TempDir(), "missing"), role: "arn:aws:iam::1:role/connector", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := WebIdentity{RoleARN: tt.role, SessionName: "test", TokenFile: tt.tokenFile, Endpoint: srv.URL, Region: "us-east-1", HTTP: nil}
+			creds, exp, err := w.Retrieve(context.Background())
+			if tt.err {
+				if err == nil {
+					t.Fatalf("credentials = %+v, want error", creds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("retrieve: %v", err)
+			}
+			if creds != (Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}) || !exp.Equal(expires) {
+				t.Errorf("credentials = %+v expiring at %v", creds, exp)
+			}
+		})
+	}
+}
+
+func TestInstanceRole(t *testing.T) {
+	tests := []struct {
+		name string
+		role string // empty - the instance has no role
+		err  bool
+	}{
+		{name: "role", role: "connector"},
+		{name: "no role", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/latest/api/token", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds") == "" {
+					http.Error(w, "imdsv2 token request is expected", http.StatusBadRequest)
+					return
+				}
+				fmt.Fprint(w, "imds-token")
+			})
+			mux.HandleFunc("/latest/meta-data/iam/security-credentials/", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token" {
+					http.Error(w, "no token", http.StatusUnauthorized)
+					return
+				}
+				switch strings.TrimPrefix(r.URL.Path, "/latest/meta-data/iam/security-credentials/") {
+				case "":
+					fmt.Fprint(w, tt.role)
+				case tt.role:
+					fmt.Fprint(w, `{"Code":"Success","AccessKeyId":"AKID","SecretAccessKey":"SECRET","Token":"TOKEN","Expiration":"2030-01-01T00:00:00Z"}`)
+				default:
+					http.NotFound(w, r)
+				}
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			creds, exp, err := InstanceRole{Endpoint: srv.URL, HTTP: nil}.Retrieve(context.Background())
+			if tt.err {
+				if err == nil {
+					t.Fatalf("credentials = %+v, want error", creds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("retrieve: %v", err)
+			}
+			if creds != (Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}) || exp.Year() != 2030 {
+				t.Errorf("credentials = %+v expiring at %v", creds, exp)
+			}
+		})
+	}
+}
+
+// countingProvider returns the credentials expiring after the lifetime and counts the retrievals.
+type countingProvider struct {
+	lifetime time.Duration
+	n        atomic.Int64
+}
+
+func (p *countingProvider) Retrieve(context.Context) (Credentials, time.Time, error) {
+	n := p.n.Add(1)
+	return Credentials{AccessKeyID: fmt.Sprintf("AKID%d", n), SecretAccessKey: "SECRET", SessionToken: ""}, time.Now().Add(p.lifetime), nil
+}
+
+func TestCachedProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		lifetime   time.Duration
+		retrievals int64
+	}{
+		{name: "cached", lifetime: time.Hour, retrievals: 1},
+		{name: "refreshed before expiration", lifetime: refreshBefore / 2, retrievals: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &countingProvider{lifetime: tt.lifetime} //nolint:exhaustruct // zero counter
+			p := &cachedProvider{provider: src}             //nolint:exhaustruct // zero cache
+			for i := 0; i < 3; i++ {
+				if _, _, err := p.Retrieve(context.Background()); err != nil {
+					t.Fatalf("retrieve: %v", err)
+				}
+			}
+			if n := src.n.Load(); n != tt.retrievals {
+				t.Errorf("retrievals = %d, want %d", n, tt.retrievals)
+			}
+		})
+	}
+}
+
+func TestSignTemporaryCredentials(t *testing.T) {
+	var auth, token atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		token.Store(r.Header.Get("X-Amz-Security-Token"))
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		Region:      "us-east-1",
+		Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"},
+		Endpoint:    srv.URL,
+		HTTP:        nil,
+	}
+	if err := (SQS{Client: c}).Publish(context.Background(), "https://sqs/queue", []byte("{}"), nil); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if a, _ := auth.Load().(string); !strings.HasPrefix(a, "AWS4-HMAC-SHA256 Credential=AKID/") {
+		t.Errorf("authorization = %q", a)
+	}
+	if tok, _ := token.Load().(string); tok != "TOKEN" {
+		t.Errorf("security token = %q, want %q", tok, "TOKEN")
+	}
+}
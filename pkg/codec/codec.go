@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const HeaderContentEncoding = "Content-Encoding"
+
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// ErrTooLarge is returned by Decoder if the decompressed data exceeds its maximum size.
+var ErrTooLarge = errors.New("decompressed data is too large")
+
+// Decoder decompresses data up to the maximum size, so a small compressed "bomb" can't exhaust the memory.
+// It is safe for concurrent use: one zstd decoder is shared by all calls.
+type Decoder struct {
+	maxSize int64
+
+	zstdOnce sync.Once
+	zstd     *zstd.Decoder
+	zstdErr  error
+}
+
+// NewDecoder creates the decoder of data decompressed up to maxSize bytes.
+func NewDecoder(maxSize int64) *Decoder {
+	return &Decoder{maxSize: maxSize} //nolint:exhaustruct // zstd decoder is created on first use
+}
+
+// Decode decompresses data encoded with the given content encoding.
+// Empty and "identity" encodings return data as is.
+func (d *Decoder) Decode(encoding string, data []byte) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", "identity":
+		return data, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("new gzip reader: %w", err)
+		}
+		defer r.Close()
+
+		out, err := io.ReadAll(io.LimitReader(r, d.maxSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("read gzip: %w", err)
+		}
+		if int64(len(out)) > d.maxSize {
+			return nil, fmt.Errorf("read gzip: %w: more than %d bytes", ErrTooLarge, d.maxSize)
+		}
+		return out, nil
+	case EncodingZstd:
+		dec, err := d.zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := dec.DecodeAll(data, nil)
+		// The window of the frame is limited by the max size as well.
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, fmt.Errorf("decode zstd: %w: more than %d bytes", ErrTooLarge, d.maxSize)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode zstd: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+func (d *Decoder) zstdDecoder() (*zstd.Decoder, error) {
+	d.zstdOnce.Do(func() {
+		d.zstd, d.zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(d.maxSize)), zstd.WithDecoderConcurrency(0))
+		if d.zstdErr != nil {
+			d.zstdErr = fmt.Errorf("new zstd reader: %w", d.zstdErr)
+		}
+	})
+	return d.zstd, d.zstdErr
+}
+
+// Encode compresses data with the given content encoding.
+// Empty and "identity" encodings return data as is.
+func Encode(encoding string, data []byte) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", "identity":
+		return data, nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("write gzip: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("close gzip: %w", err)
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		e, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("new zstd writer: %w", err)
+		}
+		defer e.Close()
+
+		return e.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
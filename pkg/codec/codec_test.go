@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	data := bytes.Repeat([]byte("payload "), 8<<10) // 64 KiB
+
+	encoded := func(encoding string, data []byte) []byte {
+		t.Helper()
+		b, err := Encode(encoding, data)
+		if err != nil {
+			t.Fatalf("encode %s: %v", encoding, err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		maxSize  int64
+		want     []byte
+		err      error // nil if any error is expected with wantErr
+		wantErr  bool
+	}{
+		{name: "identity", encoding: "", data: data, maxSize: 1, want: data},
+		{name: "gzip", encoding: "gzip", data: encoded(EncodingGzip, data), maxSize: 64 << 10, want: data},
+		{name: "zstd", encoding: "zstd", data: encoded(EncodingZstd, data), maxSize: 64 << 10, want: data},
+		{name: "encoding case", encoding: "GZIP", data: encoded(EncodingGzip, data), maxSize: 64 << 10, want: data},
+		{name: "unknown encoding", encoding: "br", data: data, maxSize: 64 << 10, wantErr: true},
+		{name: "corrupt gzip", encoding: "gzip", data: []byte("not gzip"), maxSize: 64 << 10, wantErr: true},
+		{name: "corrupt zstd", encoding: "zstd", data: []byte("not zstd"), maxSize: 64 << 10, wantErr: true},
+		{name: "truncated gzip", encoding: "gzip", data: encoded(EncodingGzip, data)[:20], maxSize: 64 << 10, wantErr: true},
+		{name: "oversize gzip", encoding: "gzip", data: encoded(EncodingGzip, data), maxSize: 64<<10 - 1, err: ErrTooLarge, wantErr: true},
+		{name: "oversize zstd", encoding: "zstd", data: encoded(EncodingZstd, data), maxSize: 64<<10 - 1, err: ErrTooLarge, wantErr: true},
+		{
+			name:     "gzip bomb",
+			encoding: "gzip",
+			data:     encoded(EncodingGzip, make([]byte, 64<<20)),
+			maxSize:  1 << 20,
+			err:      ErrTooLarge,
+			wantErr:  true,
+		},
+		{
+			name:     "zstd bomb",
+			encoding: "zstd",
+			data:     encoded(EncodingZstd, make([]byte, 64<<20)),
+			maxSize:  1 << 20,
+			err:      ErrTooLarge,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDecoder(tt.maxSize).Decode(tt.encoding, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decoded %d bytes, want error", len(got))
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderReused(t *testing.T) {
+	d := NewDecoder(64 << 10)
+	for _, s := range []string{"first", "second"} {
+		data, err := Encode(EncodingZstd, []byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := d.Decode(EncodingZstd, data)
+		if err != nil || string(got) != s {
+			t.Errorf("decoded %q, %v, want %q", got, err, s)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Summary is the aggregation of the messages processed within AGGREGATE_WINDOW published to AGGREGATE_SUBJECT.
+type Summary struct {
+	Stream      string            `json:"stream"`
+	Consumer    string            `json:"consumer"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Count       uint64            `json:"count"`
+	Results     map[string]uint64 `json:"results"`
+
+	// Statistics of AGGREGATE_FIELD, omitted if it is not set or no message has a numeric value of it.
+	Field  string   `json:"field,omitempty"`
+	Values uint64   `json:"values,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	Sum    *float64 `json:"sum,omitempty"`
+}
+
+// aggregator accumulates the summary of the current window.
+type aggregator struct {
+	mu      sync.Mutex
+	summary Summary
+}
+
+func newAggregator(cfg Config) *aggregator {
+	a := &aggregator{summary: Summary{Stream: cfg.Topic, Consumer: cfg.Consumer, Field: ""}} //nolint:exhaustruct // empty window
+	if cfg.AggregateField.Enabled() {
+		a.summary.Field = cfg.AggregateField.String()
+	}
+	a.reset(time.Now().UTC())
+	return a
+}
+
+// observeAggregate adds the processed message with the result to the summary.
+func (conn *Connector) observeAggregate(msg Message, result string) {
+	a := conn.aggregator
+	if a == nil {
+		return
+	}
+
+	field := conn.connectordata.AggregateField
+	var value float64
+	var hasValue bool
+	if field.Enabled() {
+		if v, ok := field.Value(msg.Data(), http.Header(msg.Headers())); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			value, hasValue = f, err == nil
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := &a.summary
+	s.Count++
+	s.Results[result]++
+	if !hasValue {
+		return
+	}
+	s.Values++
+	if s.Min == nil {
+		s.Min, s.Max, s.Sum = &value, new(float64), new(float64)
+		*s.Max = value
+	}
+	*s.Min = min(*s.Min, value)
+	*s.Max = max(*s.Max, value)
+	*s.Sum += value
+}
+
+// reset returns the summary of the window ended at now and starts a new window.
+func (a *aggregator) reset(now time.Time) Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.summary
+	s.WindowEnd = now
+	a.summary = Summary{ //nolint:exhaustruct // empty window
+		Stream:      s.Stream,
+		Consumer:    s.Consumer,
+		Field:       s.Field,
+		WindowStart: now,
+		Results:     map[string]uint64{},
+	}
+	return s
+}
+
+// RunAggregation publishes the summary of the processed messages to AGGREGATE_SUBJECT every AGGREGATE_WINDOW
+// until the context is done. Messages are forwarded to the endpoint as usual.
+func (conn *Connector) RunAggregation(ctx context.Context) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("subject", cfg.AggregateSubject))
+
+	ticker := time.NewTicker(cfg.AggregateWindow)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		summary := conn.aggregator.reset(time.Now().UTC())
+		data, err := json.Marshal(summary)
+		if err == nil {
+			err = conn.nc.Publish(cfg.AggregateSubject, data)
+		}
+		if err != nil {
+			log.Error("Failed to publish summary", slog.Any("error", err))
+			continue
+		}
+		log.Debug("Summary is published", slog.Uint64("count", summary.Count))
+	}
+}
@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Alert is published to ALERT_SUBJECT when a threshold is breached (firing) and when the value is back within it (resolved).
+type Alert struct {
+	Alert     string    `json:"alert"` // error_rate|lag|dlq_rate
+	State     string    `json:"state"` // firing|resolved
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Stream    string    `json:"stream"`
+	Consumer  string    `json:"consumer"`
+	Source    string    `json:"source"`
+	Time      time.Time `json:"time"`
+}
+
+// failedResults are the processing results counted by the error rate.
+//
+//nolint:gochecknoglobals // constant list
+var failedResults = []string{"term", "timeout", "redeliver", "ack_error", "panic"}
+
+// RunAlerts evaluates the alert thresholds every ALERT_INTERVAL until the context is done:
+// the error rate of the processed messages, the consumer lag and the number of terminated messages per minute.
+// Terminated messages are dead letters: they are not redelivered and their errors are sent to the error topic,
+// errors of the messages to be redelivered are not counted by the DLQ rate.
+// Alerts are published on state changes only.
+func (conn *Connector) RunAlerts(ctx context.Context) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("subject", cfg.AlertSubject))
+
+	firingGauge := promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alert_firing",
+		Help: "Whether the alert threshold is breached (1) or not (0) by alert (error_rate|lag|dlq_rate)",
+	}, []string{"alert"})
+
+	firing := map[string]bool{}
+	check := func(name string, value, threshold float64) {
+		if threshold <= 0 {
+			return
+		}
+		breached := value > threshold
+		if breached == firing[name] {
+			return
+		}
+		firing[name] = breached
+
+		alert := Alert{
+			Alert:     name,
+			State:     "resolved",
+			Value:     value,
+			Threshold: threshold,
+			Stream:    cfg.Topic,
+			Consumer:  conn.consumer,
+			Source:    cfg.SourceName,
+			Time:      time.Now().UTC(),
+		}
+		if breached {
+			alert.State = "firing"
+			firingGauge.WithLabelValues(name).Set(1)
+			log.Warn("Alert is firing", slog.String("alert", name), slog.Float64("value", value), slog.Float64("threshold", threshold))
+		} else {
+			firingGauge.WithLabelValues(name).Set(0)
+			log.Info("Alert is resolved", slog.String("alert", name), slog.Float64("value", value))
+		}
+
+		data, err := json.Marshal(alert)
+		if err == nil {
+			err = conn.nc.Publish(cfg.AlertSubject, data)
+		}
+		if err != nil {
+			log.Error("Failed to publish alert", slog.String("alert", name), slog.Any("error", err))
+		}
+	}
+	for _, name := range []string{"error_rate", "lag", "dlq_rate"} {
+		firingGauge.WithLabelValues(name).Set(0)
+	}
+
+	ticker := time.NewTicker(cfg.AlertInterval)
+	defer ticker.Stop()
+
+	prevResults, _ := conn.stats.counts()
+	prevAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		results, _ := conn.stats.counts()
+		var total, failed uint64
+		for result, n := range results {
+			if result == "canceled" {
+				continue
+			}
+			total += n - prevResults[result]
+		}
+		for _, result := range failedResults {
+			failed += results[result] - prevResults[result]
+		}
+		if total > 0 {
+			check("error_rate", float64(failed)/float64(total), cfg.AlertErrorRate)
+		}
+
+		now := time.Now()
+		check("dlq_rate", dlqRate(prevResults, results, now.Sub(prevAt)), cfg.AlertDLQRate)
+		prevResults, prevAt = results, now
+
+		if cfg.AlertLag > 0 {
+			lag, err := conn.backlog(ctx)
+			if err != nil {
+				log.Warn("Failed to get consumer lag for alerts", slog.Any("error", err))
+			} else {
+				check("lag", float64(lag), float64(cfg.AlertLag))
+			}
+		}
+	}
+}
+
+// dlqRate returns the number of messages terminated per minute between the result counts.
+func dlqRate(prev, cur map[string]uint64, elapsed time.Duration) float64 {
+	return float64(cur["term"]-prev["term"]) / elapsed.Minutes()
+}
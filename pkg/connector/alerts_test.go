@@ -0,0 +1,44 @@
+package connector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDLQRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur map[string]uint64
+		elapsed   time.Duration
+		want      float64
+	}{
+		{
+			name:    "terminated messages",
+			prev:    map[string]uint64{"term": 2},
+			cur:     map[string]uint64{"term": 8},
+			elapsed: 2 * time.Minute,
+			want:    3,
+		},
+		{
+			name:    "redelivered and timed out messages are not dead letters",
+			prev:    map[string]uint64{"redeliver": 1},
+			cur:     map[string]uint64{"redeliver": 50, "timeout": 10, "ack": 100},
+			elapsed: time.Minute,
+			want:    0,
+		},
+		{
+			name:    "first terminated message",
+			prev:    map[string]uint64{},
+			cur:     map[string]uint64{"term": 1},
+			elapsed: 30 * time.Second,
+			want:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dlqRate(tt.prev, tt.cur, tt.elapsed); got != tt.want {
+				t.Errorf("dlq rate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+)
+
+const (
+	// archiveQueueSize limits the messages waiting to be archived, the messages beyond it are not archived.
+	archiveQueueSize = 1000
+	// archivePutTimeout limits the put of one archived object.
+	archivePutTimeout = 30 * time.Second
+	// archiveFlushTimeout limits archiving of the queued messages on shutdown after the drain.
+	archiveFlushTimeout = 30 * time.Second
+)
+
+// Archiver stores objects by key, e.g. in S3.
+type Archiver interface {
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// archiveJob is an object queued to be archived.
+type archiveJob struct {
+	key  string
+	data []byte
+}
+
+// SetArchiver sets the archive of processed messages and responses. Archiving is disabled without it.
+// The objects are put by RunArchiver.
+func (conn *Connector) SetArchiver(a Archiver) {
+	conn.archiver = a
+	conn.archiveQueue = make(chan archiveJob, archiveQueueSize)
+}
+
+// archive queues the processed message payload and the response to be stored as
+// '<prefix>/<yyyy>/<mm>/<dd>/<subject>/<stream>-<seq>.payload|response'. It doesn't block the processing:
+// the objects are put by RunArchiver, and they are dropped if the queue is full.
+// Failures are logged and counted only: the message is already processed.
+func (conn *Connector) archive(msg Message, payload, response []byte) {
+	if conn.archiver == nil {
+		return
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		conn.logger.Error("failed to archive message", slog.Any("error", err))
+		conn.metrics.archivedObjects("error")
+		return
+	}
+	name := fmt.Sprintf("%s-%d", meta.Stream, meta.Sequence.Stream)
+	dir := path.Join(conn.connectordata.ArchivePrefix, meta.Timestamp.UTC().Format("2006/01/02"), msg.Subject())
+
+	for _, ext := range []string{"payload", "response"} {
+		job := archiveJob{key: path.Join(dir, name+"."+ext), data: payload}
+		if ext == "response" {
+			job.data = response
+		}
+		select {
+		case conn.archiveQueue <- job:
+		default:
+			conn.logger.Warn("Archive queue is full - object is not archived", slog.String("key", job.key))
+			conn.metrics.archivedObjects("dropped")
+		}
+	}
+}
+
+// RunArchiver puts the queued objects to the archive until the context is done. Then it waits
+// for the in-flight messages to be drained and archives the rest of the queue up to 30s.
+func (conn *Connector) RunArchiver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), conn.connectordata.DrainTimeout+archiveFlushTimeout)
+			defer cancel()
+			if err := conn.WaitDrained(flushCtx); err != nil {
+				conn.logger.Warn("Consumer is not drained - archiving the queued objects", slog.Any("error", err))
+			}
+			for {
+				select {
+				case job := <-conn.archiveQueue:
+					if flushCtx.Err() != nil {
+						conn.metrics.archivedObjects("dropped")
+						continue
+					}
+					conn.putArchive(flushCtx, job)
+				default:
+					return
+				}
+			}
+		case job := <-conn.archiveQueue:
+			conn.putArchive(ctx, job)
+		}
+	}
+}
+
+// putArchive puts the object with its own timeout: the processing context of the message is already done.
+func (conn *Connector) putArchive(ctx context.Context, job archiveJob) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), archivePutTimeout)
+	defer cancel()
+
+	if err := conn.archiver.Put(ctx, job.key, job.data); err != nil {
+		conn.logger.Error("failed to archive message", slog.Any("error", err), slog.String("key", job.key))
+		conn.metrics.archivedObjects("error")
+		return
+	}
+	conn.metrics.archivedObjects("stored")
+}
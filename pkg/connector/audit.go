@@ -0,0 +1,63 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+type AuditEvent struct {
+	Subject     string    `json:"subject"`
+	Stream      string    `json:"stream,omitempty"`
+	Consumer    string    `json:"consumer,omitempty"`
+	StreamSeq   uint64    `json:"stream_seq,omitempty"`
+	ConsumerSeq uint64    `json:"consumer_seq,omitempty"`
+	Delivered   uint64    `json:"delivered,omitempty"`
+	Result      string    `json:"result"`
+	DurationMs  int64     `json:"duration_ms"`
+	Source      string    `json:"source"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// audit publishes the processing outcome of the message to AUDIT_TOPIC.
+func (conn *Connector) audit(msg Message, result string, duration time.Duration) {
+	topic := conn.connectordata.AuditTopic
+	if topic == "" {
+		return
+	}
+
+	event := AuditEvent{
+		Subject:     msg.Subject(),
+		Stream:      "",
+		Consumer:    "",
+		StreamSeq:   0,
+		ConsumerSeq: 0,
+		Delivered:   0,
+		Result:      result,
+		DurationMs:  duration.Milliseconds(),
+		Source:      conn.connectordata.SourceName,
+		Timestamp:   time.Now(),
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		event.Stream = meta.Stream
+		event.Consumer = meta.Consumer
+		event.StreamSeq = meta.Sequence.Stream
+		event.ConsumerSeq = meta.Sequence.Consumer
+		event.Delivered = meta.NumDelivered
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		conn.logger.Error("failed to marshal audit event", slog.Any("error", err))
+		return
+	}
+
+	_, err = conn.jsContext.Publish(context.Background(), topic, data)
+	if err != nil {
+		conn.logger.Error("failed to publish audit event",
+			slog.Any("error", err),
+			slog.String("topic", topic),
+			slog.String("source", conn.connectordata.SourceName))
+	}
+}
@@ -0,0 +1,176 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type BackfillProgress struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to,omitempty"`
+	Processed uint64    `json:"processed"`
+	Pending   uint64    `json:"pending"`
+	Done      bool      `json:"done"`
+}
+
+type backfillState struct {
+	processed atomic.Uint64
+	pending   atomic.Uint64
+	done      atomic.Bool
+}
+
+// Backfill processes messages from BackfillFrom to BackfillTo (or until the consumer is caught up)
+// through an ephemeral consumer and returns when all of them are processed.
+// The durable consumer is not touched.
+func (conn *Connector) Backfill(ctx context.Context) error {
+	defer conn.markDrained()
+	log := conn.logger.With(slog.String("mode", "backfill"))
+	cfg := conn.connectordata
+
+	processedTotal := promauto.NewCounter(prometheus.CounterOpts{
+		Name: "backfill_processed_total",
+		Help: "Counts messages dispatched by the backfill",
+	})
+	pendingGauge := promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "backfill_pending",
+		Help: "Number of messages left to the end of the stream in the backfill",
+	})
+
+	cs, err := conn.jsContext.CreateConsumer(ctx, cfg.Topic, jetstream.ConsumerConfig{
+		AckPolicy:         jetstream.AckExplicitPolicy,
+		FilterSubject:     conn.filterSubject(),
+		AckWait:           cfg.AckWait + time.Second,
+		DeliverPolicy:     jetstream.DeliverByStartTimePolicy,
+		OptStartTime:      &cfg.BackfillFrom,
+		InactiveThreshold: time.Minute,
+	})
+	if err != nil {
+		return fmt.Errorf("create backfill consumer: %w", err)
+	}
+
+	info, err := cs.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("get backfill consumer info: %w", err)
+	}
+	conn.backfill.pending.Store(info.NumPending)
+	pendingGauge.Set(float64(info.NumPending))
+
+	log.Info("Backfill is started",
+		slog.Time("from", cfg.BackfillFrom),
+		slog.Time("to", cfg.BackfillTo),
+		slog.Uint64("pending", info.NumPending))
+
+	if info.NumPending > 0 {
+		err = conn.backfillMessages(ctx, cs, func(pending uint64) {
+			conn.backfill.processed.Add(1)
+			conn.backfill.pending.Store(pending)
+			processedTotal.Inc()
+			pendingGauge.Set(float64(pending))
+		})
+		if err != nil {
+			conn.wait() // the resources closed after the backfill are used by the in-flight messages
+			return err
+		}
+	}
+
+	conn.wait()
+	conn.backfill.done.Store(true)
+
+	log.Info("Backfill is done", slog.Uint64("processed", conn.backfill.processed.Load()))
+
+	if cfg.BackfillDoneSubject != "" {
+		progress, _ := json.Marshal(conn.BackfillProgress()) //nolint:errchkjson // plain struct
+		err = conn.nc.Publish(cfg.BackfillDoneSubject, progress)
+		if err != nil {
+			return fmt.Errorf("publish backfill completion to %s: %w", cfg.BackfillDoneSubject, err)
+		}
+		err = conn.nc.Flush()
+		if err != nil {
+			return fmt.Errorf("flush backfill completion: %w", err)
+		}
+	}
+	return nil
+}
+
+func (conn *Connector) backfillMessages(ctx context.Context, cs jetstream.Consumer, onDispatch func(pending uint64)) error {
+	cfg := conn.connectordata
+
+	it, err := cs.Messages()
+	if err != nil {
+		return fmt.Errorf("backfill messages: %w", err)
+	}
+	defer it.Stop()
+
+	stop := context.AfterFunc(ctx, it.Stop)
+	defer stop()
+
+	var rate <-chan time.Time
+	if cfg.BackfillRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.BackfillRate))
+		defer ticker.Stop()
+		rate = ticker.C
+	}
+
+	for {
+		msg, err := it.Next()
+		if err != nil {
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return fmt.Errorf("backfill is interrupted: %w", ctx.Err())
+			}
+			return fmt.Errorf("backfill next message: %w", err)
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			return fmt.Errorf("backfill message metadata: %w", err)
+		}
+
+		if !cfg.BackfillTo.IsZero() && meta.Timestamp.After(cfg.BackfillTo) {
+			return nil
+		}
+
+		if rate != nil {
+			select {
+			case <-rate:
+			case <-ctx.Done():
+				return fmt.Errorf("backfill is interrupted: %w", ctx.Err())
+			}
+		}
+
+		conn.waitResumed(ctx)
+		conn.dispatch(ctx, msg)
+		onDispatch(meta.NumPending)
+
+		if meta.NumPending == 0 {
+			return nil
+		}
+	}
+}
+
+func (conn *Connector) BackfillProgress() BackfillProgress {
+	return BackfillProgress{
+		From:      conn.connectordata.BackfillFrom,
+		To:        conn.connectordata.BackfillTo,
+		Processed: conn.backfill.processed.Load(),
+		Pending:   conn.backfill.pending.Load(),
+		Done:      conn.backfill.done.Load(),
+	}
+}
+
+// BackfillHandler serves the backfill progress as JSON.
+func (conn *Connector) BackfillHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conn.BackfillProgress()) //nolint:errcheck,errchkjson // best effort response
+	})
+}
@@ -0,0 +1,48 @@
+package connector
+
+import "sync"
+
+// byteLimiter limits the total size of messages being processed.
+// A message larger than the whole budget is processed alone, so it can't block the dispatch forever.
+type byteLimiter struct {
+	max  int64
+	used int64
+
+	mx   sync.Mutex
+	cond *sync.Cond
+}
+
+func newByteLimiter(limit int64) *byteLimiter {
+	l := &byteLimiter{max: limit} //nolint:exhaustruct // zero value initialization
+	l.cond = sync.NewCond(&l.mx)
+	return l
+}
+
+// acquire blocks until n bytes fit into the budget and returns the amount of bytes to release.
+func (l *byteLimiter) acquire(n int64) int64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	n = min(n, l.max)
+	for l.used > 0 && l.used+n > l.max {
+		l.cond.Wait()
+	}
+	l.used += n
+	return n
+}
+
+func (l *byteLimiter) release(n int64) {
+	if l == nil {
+		return
+	}
+
+	l.mx.Lock()
+	l.used -= n
+	l.mx.Unlock()
+
+	l.cond.Broadcast()
+}
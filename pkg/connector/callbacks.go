@@ -0,0 +1,300 @@
+package connector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrAsyncJob = errors.New("async job failed")
+
+// HeaderCallbackURL is the URL the endpoint posts the result of an accepted job to, set if CALLBACK_URL is set.
+const HeaderCallbackURL = "X-Callback-Url"
+
+// maxCallbackBody limits the body of a callback request, larger requests are rejected with 413.
+const maxCallbackBody = 16 << 20
+
+// newToken returns a random 128-bit token. Tokens authorize the requests of the endpoint,
+// so they are unguessable unlike sequential NUIDs.
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("read random token: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// callbacks are the messages of the jobs accepted by the endpoint with 202 in ASYNC_CALLBACK mode.
+// The endpoint posts the result of the job to /callbacks/<token>, meanwhile the message is kept in progress.
+// Jobs polled in ASYNC_POLL mode are tracked the same way.
+type callbacks struct {
+	mu      sync.Mutex
+	pending map[string]*callback
+	wg      sync.WaitGroup // accepted jobs
+}
+
+type callback struct {
+	token    string
+	msg      Message
+	message  []byte
+	encoding string
+
+	ready    chan struct{} // closed when the invocation result is known
+	accepted bool          // guarded by callbacks.mu, set before ready is closed
+	done     chan struct{} // closed when the job is completed
+	started  time.Time
+}
+
+// awaitCallback registers the callback of the message before the invocation, so a fast callback is not missed,
+// and adds its token to the request headers.
+func (conn *Connector) awaitCallback(msg Message, message []byte, encoding string, headers http.Header) *callback {
+	cfg := conn.connectordata
+	cb := conn.newCallback(msg, message, encoding)
+
+	headers[cfg.CallbackTokenHeader] = []string{cb.token}
+	if cfg.CallbackURL != "" {
+		headers[HeaderCallbackURL] = []string{strings.TrimSuffix(cfg.CallbackURL, "/") + "/callbacks/" + cb.token}
+	}
+	return cb
+}
+
+// newCallback registers the job of the message.
+func (conn *Connector) newCallback(msg Message, message []byte, encoding string) *callback {
+	cb := &callback{
+		token:    newToken(),
+		msg:      msg,
+		message:  message,
+		encoding: encoding,
+		ready:    make(chan struct{}),
+		accepted: false,
+		done:     make(chan struct{}),
+		started:  time.Now(),
+	}
+
+	conn.callbacks.mu.Lock()
+	conn.callbacks.pending[cb.token] = cb
+	conn.callbacks.mu.Unlock()
+	return cb
+}
+
+// reject unregisters the callback of the message which was not accepted by the endpoint.
+func (conn *Connector) reject(cb *callback) {
+	if cb == nil {
+		return
+	}
+	conn.callbacks.mu.Lock()
+	delete(conn.callbacks.pending, cb.token)
+	conn.callbacks.mu.Unlock()
+	close(cb.ready)
+}
+
+// accept keeps the message of the accepted job in progress until the callback or CALLBACK_TIMEOUT.
+func (conn *Connector) accept(cb *callback) Outcome {
+	conn.markAccepted(cb)
+
+	go conn.keepInProgress(cb, conn.connectordata.CallbackTimeout)
+	return OutcomePending
+}
+
+// markAccepted marks the job accepted, so its callback is handled and the shutdown waits for it.
+func (conn *Connector) markAccepted(cb *callback) {
+	conn.metrics.callbacks("accepted")
+	conn.callbacks.wg.Add(1)
+
+	conn.callbacks.mu.Lock()
+	cb.accepted = true
+	conn.callbacks.mu.Unlock()
+	close(cb.ready)
+}
+
+// keepInProgress keeps the message of the accepted job in progress until the job is completed.
+// The message is nacked if the job is not completed within the timeout.
+func (conn *Connector) keepInProgress(cb *callback, timeout time.Duration) {
+	ctx := conn.jobContext(context.Background(), cb)
+	log := conn.log(ctx).With(slog.String("callback_token", cb.token))
+
+	ticker := time.NewTicker(conn.connectordata.AckWait / 2)
+	defer ticker.Stop()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-cb.done:
+			return
+		case <-ticker.C:
+			if err := cb.msg.InProgress(); err != nil {
+				log.Warn("Failed to mark message of accepted job in progress", slog.Any("error", err))
+			}
+		case <-deadline.C:
+			if !conn.complete(cb) {
+				return
+			}
+			conn.metrics.callbacks("timeout")
+			conn.observeSLO(cb.msg, "timeout")
+			conn.publishReceipt(ctx, cb.msg, "timeout", time.Since(cb.started))
+			log.Warn("Accepted job is timed out - message is nacked")
+			conn.errorHandler(ctx, fmt.Errorf("job is not completed within %v. source: %v: %w", timeout, conn.connectordata.SourceName, ErrAsyncJob))
+			if err := cb.msg.Nak(); err != nil {
+				log.Error("failed to nak message of timed out job", slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
+
+// jobContext returns the context of the accepted job with the logger of its message.
+// The endpoint status of its receipt is 202.
+func (conn *Connector) jobContext(ctx context.Context, cb *callback) context.Context {
+	ctx = conn.withEndpointStatus(conn.withMessageLogger(ctx, cb.msg))
+	setEndpointStatus(ctx, http.StatusAccepted, nil)
+	return ctx
+}
+
+// complete unregisters the callback. It returns false if the job is already completed.
+func (conn *Connector) complete(cb *callback) bool {
+	conn.callbacks.mu.Lock()
+	_, ok := conn.callbacks.pending[cb.token]
+	delete(conn.callbacks.pending, cb.token)
+	conn.callbacks.mu.Unlock()
+
+	if ok {
+		close(cb.done)
+		conn.callbacks.wg.Done()
+	}
+	return ok
+}
+
+// acceptedJobs returns the accepted jobs which are not completed yet.
+func (conn *Connector) acceptedJobs() []*callback {
+	conn.callbacks.mu.Lock()
+	defer conn.callbacks.mu.Unlock()
+
+	var pending []*callback
+	for _, cb := range conn.callbacks.pending {
+		if cb.accepted {
+			pending = append(pending, cb)
+		}
+	}
+	return pending
+}
+
+// nakCallbacks nacks the messages of the accepted jobs still waiting for the callbacks, e.g. on shutdown,
+// so another replica gets them at once. It returns the number of nacked messages.
+func (conn *Connector) nakCallbacks() int {
+	var nacked int
+	for _, cb := range conn.acceptedJobs() {
+		if !conn.complete(cb) {
+			continue
+		}
+		conn.metrics.callbacks("shutdown")
+		nacked++
+		if err := cb.msg.Nak(); err != nil {
+			conn.logger.Error("failed to nak message of accepted job", slog.Any("error", err))
+		}
+	}
+	return nacked
+}
+
+// CallbacksHandler serves POST /callbacks/<token>?result=success|retry|fail requests with the result of the accepted jobs.
+// On success the body is published as the response and the message is acked, on retry the message is nacked
+// and on fail it is terminated, the body is sent to the error topic in both cases.
+// The response is 204 if the result is handled, 404 if the job is unknown or already completed
+// and 413 if the body is larger than 16 MiB (the job keeps waiting for the callback).
+func (conn *Connector) CallbacksHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/callbacks/")
+		if token == "" || strings.Contains(token, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		result := r.URL.Query().Get("result")
+		switch result {
+		case "":
+			result = "success"
+		case "success", "retry", "fail":
+		default:
+			http.Error(w, "result should be one of 'success|retry|fail'", http.StatusBadRequest)
+			return
+		}
+
+		conn.callbacks.mu.Lock()
+		cb, ok := conn.callbacks.pending[token]
+		conn.callbacks.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		select {
+		case <-cb.ready:
+		case <-r.Context().Done():
+			return
+		}
+		if !cb.accepted {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackBody))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			conn.logger.Warn("Failed to read callback body", slog.Any("error", err))
+		}
+		if !conn.complete(cb) {
+			http.NotFound(w, r)
+			return
+		}
+		conn.metrics.callbacks(result)
+		conn.completeJob(cb, result, body, r.Header)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// completeJob handles the result of the job and settles its message.
+func (conn *Connector) completeJob(cb *callback, result string, body []byte, hdr http.Header) {
+	ctx, cancel := context.WithTimeout(conn.jobContext(context.Background(), cb), conn.connectordata.AckWait)
+	defer cancel()
+	log := conn.log(ctx).With(slog.String("callback_token", cb.token), slog.String("result", result))
+
+	switch result {
+	case "retry", "fail":
+		conn.errorHandler(ctx, fmt.Errorf("%s. source: %v: %w", body, conn.connectordata.SourceName, ErrAsyncJob))
+		settle, settled := cb.msg.Nak, "redeliver"
+		if result == "fail" {
+			settle, settled = cb.msg.Term, "term"
+		}
+		conn.observeSLO(cb.msg, settled)
+		conn.publishReceipt(ctx, cb.msg, settled, time.Since(cb.started))
+		if err := settle(); err != nil {
+			log.Error("failed to settle message of failed job", slog.Any("error", err))
+		}
+		return
+	}
+
+	if err := cb.msg.InProgress(); err != nil {
+		log.Warn("Failed to mark message of completed job in progress", slog.Any("error", err))
+	}
+	o := conn.respond(ctx, cb.msg, cb.message, cb.encoding, body, http.StatusOK, hdr)
+	settled := conn.settle(ctx, cb.msg, o)
+	log.Info("Accepted job is completed", slog.String("settle", settled))
+	conn.observeSLO(cb.msg, settled)
+	conn.publishReceipt(ctx, cb.msg, settled, time.Since(cb.started))
+	conn.flushLog(ctx, settled)
+}
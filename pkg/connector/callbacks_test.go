@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newJobConnector returns the test connector publishing the responses and the errors to the sink.
+func newJobConnector(cfg Config, sink *fakeSink) *Connector {
+	cfg.PublishMaxAttempts = 1
+	cfg.ResponseMerge = MergeNone
+	cfg.AMQPResponseRoutingKey = "responses"
+	cfg.AMQPErrorRoutingKey = "errors"
+	conn := newTestConnector(cfg, nil)
+	conn.connectordata.ResponseSink = SinkAMQP
+	conn.connectordata.ErrorSink = SinkAMQP
+	conn.SetSink(SinkAMQP, sink)
+	return conn
+}
+
+// waitSettled waits until the message is settled with one of ack, nak or term.
+func waitSettled(t *testing.T, msg *fakeMsg) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		settles := msg.settles()
+		if slices.ContainsFunc(settles, func(s string) bool { return s == "ack" || s == "nak" || s == "term" }) {
+			return settles
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("message is not settled: %v", msg.settles())
+	return nil
+}
+
+// settledWith returns the settles without the in progress marks.
+func settledWith(settles []string) []string {
+	return slices.DeleteFunc(slices.Clone(settles), func(s string) bool { return s == "in_progress" })
+}
+
+func TestCallbacksHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		path      string // empty - the path of the job token
+		query     string
+		body      []byte
+		status    int
+		settles   []string
+		published int
+		errors    int
+		pending   bool // the job keeps waiting for the callback
+	}{
+		{name: "success", query: "?result=success", body: []byte(`{"ok":true}`), status: http.StatusNoContent, settles: []string{"ack"}, published: 1},
+		{name: "default result is success", body: []byte(`{"ok":true}`), status: http.StatusNoContent, settles: []string{"ack"}, published: 1},
+		{name: "retry", query: "?result=retry", body: []byte("try later"), status: http.StatusNoContent, settles: []string{"nak"}, errors: 1},
+		{name: "fail", query: "?result=fail", body: []byte("bad input"), status: http.StatusNoContent, settles: []string{"term"}, errors: 1},
+		{name: "unknown token", path: "/callbacks/unknown", status: http.StatusNotFound, pending: true},
+		{name: "empty token", path: "/callbacks/", status: http.StatusNotFound, pending: true},
+		{name: "wrong result", query: "?result=maybe", status: http.StatusBadRequest, pending: true},
+		{name: "wrong method", method: http.MethodGet, status: http.StatusMethodNotAllowed, pending: true},
+		{name: "too large body", body: make([]byte, maxCallbackBody+1), status: http.StatusRequestEntityTooLarge, pending: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &fakeSink{}                                                 //nolint:exhaustruct // no error
+			conn := newJobConnector(Config{CallbackTimeout: time.Minute}, sink) //nolint:exhaustruct // test config
+			msg := newFakeMsg(`{"id":1}`)
+
+			cb := conn.newCallback(msg, msg.Data(), "")
+			if o := conn.accept(cb); o != OutcomePending {
+				t.Fatalf("outcome = %v, want %v", o, OutcomePending)
+			}
+			defer conn.complete(cb)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			path := tt.path
+			if path == "" {
+				path = "/callbacks/" + cb.token
+			}
+			rec := httptest.NewRecorder()
+			conn.CallbacksHandler().ServeHTTP(rec, httptest.NewRequest(method, path+tt.query, bytes.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := settledWith(msg.settles()); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+			if len(sink.published) != tt.published {
+				t.Errorf("published %d responses, want %d", len(sink.published), tt.published)
+			}
+			if len(sink.errors) != tt.errors {
+				t.Errorf("published %d errors, want %d", len(sink.errors), tt.errors)
+			}
+			if pending := len(conn.acceptedJobs()) == 1; pending != tt.pending {
+				t.Errorf("job is pending: %v, want %v", pending, tt.pending)
+			}
+		})
+	}
+}
+
+func TestCallbacksHandlerCompletedJob(t *testing.T) {
+	conn := newJobConnector(Config{CallbackTimeout: time.Minute}, &fakeSink{}) //nolint:exhaustruct // test config
+	msg := newFakeMsg(`{"id":1}`)
+	cb := conn.newCallback(msg, msg.Data(), "")
+	conn.accept(cb)
+
+	for i, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		conn.CallbacksHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/callbacks/"+cb.token, nil))
+		if rec.Code != want {
+			t.Errorf("callback %d: status = %d, want %d", i+1, rec.Code, want)
+		}
+	}
+	if got := settledWith(msg.settles()); !slices.Equal(got, []string{"ack"}) {
+		t.Errorf("settles = %v, want the only ack", got)
+	}
+}
+
+func TestCallbacksHandlerRejectedJob(t *testing.T) {
+	conn := newJobConnector(Config{CallbackTimeout: time.Minute}, &fakeSink{}) //nolint:exhaustruct // test config
+	msg := newFakeMsg(`{"id":1}`)
+	cb := conn.awaitCallback(msg, msg.Data(), "", http.Header{})
+
+	// The callback waits for the invocation result: the endpoint didn't accept the job.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		conn.reject(cb)
+	}()
+
+	rec := httptest.NewRecorder()
+	conn.CallbacksHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/callbacks/"+cb.token, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if got := msg.settles(); len(got) != 0 {
+		t.Errorf("settles = %v, want none", got)
+	}
+}
+
+func TestCallbackTimeout(t *testing.T) {
+	sink := &fakeSink{}                                                           //nolint:exhaustruct // no error
+	conn := newJobConnector(Config{CallbackTimeout: 10 * time.Millisecond}, sink) //nolint:exhaustruct // test config
+	msg := newFakeMsg(`{"id":1}`)
+
+	conn.accept(conn.newCallback(msg, msg.Data(), ""))
+
+	if got := settledWith(waitSettled(t, msg)); !slices.Equal(got, []string{"nak"}) {
+		t.Errorf("settles = %v, want nak", got)
+	}
+	if len(sink.errors) != 1 {
+		t.Errorf("published %d errors, want 1", len(sink.errors))
+	}
+	if n := len(conn.acceptedJobs()); n != 0 {
+		t.Errorf("%d jobs are pending after the timeout", n)
+	}
+}
+
+func TestNakCallbacks(t *testing.T) {
+	conn := newJobConnector(Config{CallbackTimeout: time.Minute}, &fakeSink{}) //nolint:exhaustruct // test config
+	accepted := newFakeMsg(`{"id":1}`)
+	conn.accept(conn.newCallback(accepted, accepted.Data(), ""))
+	invoking := newFakeMsg(`{"id":2}`)
+	cb := conn.newCallback(invoking, invoking.Data(), "")
+	defer conn.reject(cb)
+
+	if n := conn.nakCallbacks(); n != 1 {
+		t.Errorf("nacked %d messages, want 1", n)
+	}
+	if got := settledWith(accepted.settles()); !slices.Equal(got, []string{"nak"}) {
+		t.Errorf("settles of the accepted job = %v, want nak", got)
+	}
+	if got := invoking.settles(); len(got) != 0 {
+		t.Errorf("settles of the job being invoked = %v, want none", got)
+	}
+}
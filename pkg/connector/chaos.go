@@ -0,0 +1,63 @@
+//go:build chaos
+
+package connector
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// chaosAvailable is true in the builds with 'chaos' tag: the chaos mode is not compiled into release builds.
+const chaosAvailable = true
+
+// ChaosHandler is the built-in test endpoint for resilience testing: it echoes the request body after CHAOS_LATENCY
+// and fails with 500 status with CHAOS_ERROR_RATE probability.
+func (conn *Connector) ChaosHandler() http.Handler {
+	cfg := conn.connectordata
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ChaosLatency > 0 {
+			select {
+			case <-time.After(cfg.ChaosLatency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if rand.Float64() < cfg.ChaosErrorRate { //nolint:gosec // not a security random
+			http.Error(w, "chaos: injected failure", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		io.Copy(w, r.Body) //nolint:errcheck // best effort response
+	})
+}
+
+// chaosDropAck reports whether the ack should be dropped with CHAOS_DROP_ACK_RATE probability,
+// so the message is redelivered after AckWait as if the ack was lost.
+func (conn *Connector) chaosDropAck() bool {
+	rate := conn.connectordata.ChaosDropAckRate
+	if !conn.connectordata.Chaos || rate <= 0 || rand.Float64() >= rate { //nolint:gosec // not a security random
+		return false
+	}
+
+	conn.logger.Warn("Chaos: ack is dropped - message will be redelivered")
+	return true
+}
+
+// warnChaos logs the enabled chaos mode: it should never be enabled in production.
+func (conn *Connector) warnChaos() {
+	cfg := conn.connectordata
+	if !cfg.Chaos {
+		return
+	}
+
+	conn.logger.Warn("Chaos mode is enabled - failures are injected, don't use it in production",
+		slog.Float64("error_rate", cfg.ChaosErrorRate),
+		slog.Duration("latency", cfg.ChaosLatency),
+		slog.Float64("drop_ack_rate", cfg.ChaosDropAckRate))
+}
@@ -0,0 +1,19 @@
+//go:build !chaos
+
+package connector
+
+import "net/http"
+
+// chaosAvailable is false in release builds: the chaos mode requires 'chaos' build tag.
+const chaosAvailable = false
+
+// ChaosHandler responds with 404 status: the chaos mode is not available without 'chaos' build tag.
+func (conn *Connector) ChaosHandler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+func (conn *Connector) chaosDropAck() bool {
+	return false
+}
+
+func (conn *Connector) warnChaos() {}
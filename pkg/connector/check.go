@@ -0,0 +1,152 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// CheckResult is the result of one check of the check mode.
+type CheckResult struct {
+	Name    string
+	Err     error
+	Warning bool // Err doesn't fail the check
+	Detail  string
+}
+
+// CheckReport is the report of the check mode.
+type CheckReport []CheckResult
+
+// Failed reports whether any check failed.
+func (r CheckReport) Failed() bool {
+	for _, res := range r {
+		if res.Err != nil && !res.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the report, one line per check.
+func (r CheckReport) Print(w io.Writer) {
+	for _, res := range r {
+		status, detail := "OK", res.Detail
+		if res.Err != nil {
+			status, detail = "FAIL", res.Err.Error()
+			if res.Warning {
+				status = "WARN"
+			}
+		}
+		fmt.Fprintf(w, "%-4s  %-15s  %s\n", status, res.Name, detail)
+	}
+	if r.Failed() {
+		fmt.Fprintln(w, "check failed")
+	} else {
+		fmt.Fprintln(w, "check passed")
+	}
+}
+
+// Check verifies the config against the live NATS server and the endpoint without consuming:
+// the stream and its subjects, the consumer compatibility, the streams of the response and error topics
+// and, if HEALTH_PROBE_PATH is set, the endpoint health.
+func (conn *Connector) Check(ctx context.Context) CheckReport {
+	cfg := conn.connectordata
+	filter := conn.filterSubject()
+	var report CheckReport
+
+	stream, err := conn.jsContext.Stream(ctx, cfg.Topic)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			err = fmt.Errorf("stream %s is not found - check TOPIC", cfg.Topic)
+		}
+		return append(report, CheckResult{Name: "stream", Err: err, Warning: false, Detail: ""})
+	}
+	report = append(report, CheckResult{Name: "stream", Err: nil, Warning: false, Detail: cfg.Topic})
+
+	subjects := stream.CachedInfo().Config.Subjects
+	res := CheckResult{Name: "subject", Err: nil, Warning: false, Detail: filter}
+	if !anySubjectOverlaps(subjects, filter) {
+		res.Err = fmt.Errorf("stream %s has no subject matching %s (stream subjects: %s)", cfg.Topic, filter, strings.Join(subjects, ", "))
+	}
+	report = append(report, res, conn.checkConsumer(ctx, stream, subjects))
+
+	for _, t := range []struct{ name, topic string }{{"response_topic", cfg.ResponseTopic}, {"error_topic", cfg.ErrorTopic}} {
+		if t.topic == "" {
+			continue
+		}
+		res := CheckResult{Name: t.name, Err: nil, Warning: false, Detail: ""}
+		streamName, err := conn.jsContext.StreamNameBySubject(ctx, t.topic)
+		if err != nil {
+			res.Err = fmt.Errorf("no stream captures %s - publishing will fail: %w", t.topic, err)
+		} else {
+			res.Detail = fmt.Sprintf("%s is captured by stream %s", t.topic, streamName)
+		}
+		report = append(report, res)
+	}
+
+	if cfg.HealthProbePath != "" {
+		res := CheckResult{Name: "endpoint", Err: nil, Warning: false, Detail: ""}
+		probeURL, err := endpointURL(cfg.HTTPEndpoint, cfg.HealthProbePath)
+		if err == nil {
+			err = conn.probe(ctx, probeURL)
+		}
+		res.Err, res.Detail = err, probeURL
+		report = append(report, res)
+	}
+
+	return report
+}
+
+func (conn *Connector) checkConsumer(ctx context.Context, stream jetstream.Stream, subjects []string) CheckResult {
+	cfg := conn.connectordata
+	res := CheckResult{Name: "consumer", Err: nil, Warning: false, Detail: conn.consumer}
+
+	if cfg.RecreateConsumer || cfg.Backfill {
+		res.Detail += " will be created"
+		return res
+	}
+
+	cs, err := stream.Consumer(ctx, conn.consumer)
+	if errors.Is(err, jetstream.ErrConsumerNotFound) {
+		res.Detail += " is not found - it will be created"
+		return res
+	}
+	if err != nil {
+		res.Err = fmt.Errorf("get consumer %s info: %w", conn.consumer, err)
+		return res
+	}
+
+	// The new JetStream API doesn't expose the deliver subject of push consumers.
+	var push bool
+	legacyJS, err := conn.nc.JetStream()
+	if err == nil {
+		var info *nats.ConsumerInfo
+		info, err = legacyJS.ConsumerInfo(cfg.Topic, conn.consumer, nats.Context(ctx))
+		push = err == nil && info.Config.DeliverSubject != ""
+	}
+	if err != nil {
+		res.Err = fmt.Errorf("get consumer %s info: %w", conn.consumer, err)
+		return res
+	}
+
+	ccfg := cs.CachedInfo().Config
+	switch {
+	case ccfg.AckPolicy != jetstream.AckExplicitPolicy:
+		res.Err = fmt.Errorf("consumer %s has ack policy %s - %s is required", conn.consumer, ccfg.AckPolicy, jetstream.AckExplicitPolicy)
+	case ccfg.FilterSubject != "" && !anySubjectOverlaps(subjects, ccfg.FilterSubject):
+		res.Err = fmt.Errorf("consumer %s filter subject %s doesn't match any subject of stream %s", conn.consumer, ccfg.FilterSubject, cfg.Topic)
+	case cfg.ConsumerMode == ConsumerModePushLegacy && !push:
+		res.Err = fmt.Errorf("consumer %s is a pull consumer - a push consumer is required by %q consumer mode", conn.consumer, ConsumerModePushLegacy)
+	case cfg.ConsumerMode == ConsumerModePull && push:
+		res.Err = fmt.Errorf("consumer %s is a push consumer - set CONSUMER_MODE=%s", conn.consumer, ConsumerModePushLegacy)
+	case ccfg.AckWait < cfg.AckWait:
+		res.Err = fmt.Errorf("consumer ack wait %s is less than ACKWAIT %s - messages may be redelivered while being processed", ccfg.AckWait, cfg.AckWait)
+		res.Warning = true
+	}
+	return res
+}
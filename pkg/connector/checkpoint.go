@@ -0,0 +1,155 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// checkpointSaveTimeout limits the save of the checkpoint on shutdown after the drain.
+const checkpointSaveTimeout = 5 * time.Second
+
+// checkpoint is the highest stream sequence fully processed by the consumer, persisted in CHECKPOINT_BUCKET KV bucket
+// with EXACTLY_ONCE_HINT. It is the ack floor of the consumer: all messages at or below it are settled.
+type checkpoint struct {
+	kv      jetstream.KeyValue
+	key     string
+	created time.Time // creation time of the stream the sequence belongs to
+	seq     atomic.Uint64
+}
+
+// checkpointValue is the checkpoint saved in the KV bucket. The sequence is valid only for the stream
+// created at StreamCreated: the sequences start over when the stream is recreated.
+type checkpointValue struct {
+	StreamSeq     uint64    `json:"stream_seq"`
+	StreamCreated time.Time `json:"stream_created"`
+}
+
+// SetCheckpoint loads the checkpoint of the consumer from the KV bucket. Messages at or below it are acked
+// without processing, e.g. replayed after the consumer is recreated. The checkpoint is saved by RunCheckpoint.
+//
+// The loaded checkpoint is discarded if it belongs to another incarnation of the stream or is above the last
+// sequence of the stream, and it is ignored if the consumer starts from START_SEQUENCE, START_TIME
+// or is recreated with RECREATE_CONSUMER: the messages replayed on purpose are processed again.
+func (conn *Connector) SetCheckpoint(ctx context.Context, kv jetstream.KeyValue) error {
+	cfg := conn.connectordata
+	log := conn.logger
+
+	stream, err := conn.jsContext.Stream(ctx, cfg.Topic)
+	if err != nil {
+		return fmt.Errorf("get stream of checkpoint: %w", err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("get stream info of checkpoint: %w", err)
+	}
+
+	cp := &checkpoint{kv: kv, key: cfg.Topic + "." + conn.consumer, created: info.Created} //nolint:exhaustruct // zero sequence
+	log = log.With(slog.String("key", cp.key))
+
+	entry, err := kv.Get(ctx, cp.key)
+	switch {
+	case errors.Is(err, jetstream.ErrKeyNotFound):
+	case err != nil:
+		return fmt.Errorf("get checkpoint %q: %w", cp.key, err)
+	default:
+		var v checkpointValue
+		if err := json.Unmarshal(entry.Value(), &v); err != nil {
+			return fmt.Errorf("parse checkpoint %q: %w", cp.key, err)
+		}
+		switch {
+		case cfg.StartSequence > 0 || !cfg.StartTime.IsZero() || cfg.RecreateConsumer:
+			log.Info("Checkpoint is ignored - consumer start position is set explicitly", slog.Uint64("stream_seq", v.StreamSeq))
+		case !v.StreamCreated.Equal(info.Created):
+			log.Warn("Checkpoint is discarded - stream is recreated",
+				slog.Uint64("stream_seq", v.StreamSeq),
+				slog.Time("checkpoint_stream_created", v.StreamCreated),
+				slog.Time("stream_created", info.Created))
+		case v.StreamSeq > info.State.LastSeq:
+			log.Warn("Checkpoint is discarded - it is above the last sequence of the stream",
+				slog.Uint64("stream_seq", v.StreamSeq),
+				slog.Uint64("last_seq", info.State.LastSeq))
+		default:
+			cp.seq.Store(v.StreamSeq)
+		}
+	}
+
+	conn.checkpoint = cp
+	log.Info("Checkpoint is loaded", slog.Uint64("stream_seq", cp.seq.Load()))
+	return nil
+}
+
+// RunCheckpoint saves the ack floor of the consumer as the checkpoint every CHECKPOINT_INTERVAL
+// and once more when the context is done and the in-flight messages are drained.
+func (conn *Connector) RunCheckpoint(ctx context.Context) {
+	ticker := time.NewTicker(conn.connectordata.CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), conn.connectordata.DrainTimeout+checkpointSaveTimeout)
+			defer cancel()
+			if err := conn.WaitDrained(saveCtx); err != nil {
+				conn.logger.Warn("Consumer is not drained - last checkpoint is not saved", slog.Any("error", err))
+				return
+			}
+			conn.saveCheckpoint(saveCtx)
+			return
+		case <-ticker.C:
+			conn.saveCheckpoint(ctx)
+		}
+	}
+}
+
+func (conn *Connector) saveCheckpoint(ctx context.Context) {
+	cp := conn.checkpoint
+	log := conn.logger.With(slog.String("key", cp.key))
+
+	cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+	if err != nil {
+		log.Warn("Failed to get consumer for checkpoint", slog.Any("error", err))
+		return
+	}
+	info, err := cs.Info(ctx)
+	if err != nil {
+		log.Warn("Failed to get consumer info for checkpoint", slog.Any("error", err))
+		return
+	}
+
+	seq := info.AckFloor.Stream
+	if seq <= cp.seq.Load() {
+		return
+	}
+	value, _ := json.Marshal(checkpointValue{StreamSeq: seq, StreamCreated: cp.created}) //nolint:errchkjson // plain struct
+	if _, err := cp.kv.Put(ctx, cp.key, value); err != nil {
+		log.Warn("Failed to save checkpoint", slog.Any("error", err))
+		return
+	}
+	cp.seq.Store(seq)
+	log.Debug("Checkpoint is saved", slog.Uint64("stream_seq", seq))
+}
+
+// replayed reports whether the message is at or below the checkpoint, so it was already processed.
+func (conn *Connector) replayed(ctx context.Context, msg Message) bool {
+	if conn.checkpoint == nil {
+		return false
+	}
+	meta, err := msg.Metadata()
+	if err != nil {
+		return false
+	}
+	cp := conn.checkpoint.seq.Load()
+	if meta.Sequence.Stream > cp {
+		return false
+	}
+
+	conn.log(ctx).Info("Message is at or below the checkpoint - it is acked without processing", slog.Uint64("checkpoint", cp))
+	return true
+}
@@ -0,0 +1,483 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/encryption"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/headerfilter"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/jsonpointer"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/jsonschema"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/largemsg"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/pgsink"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/resolver"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/unixsock"
+)
+
+// Concurrency is the number of messages processed at one time.
+type Concurrency int
+
+// ConcurrencyAuto is set by 'auto' value. It is resolved by the main package to the number of CPUs
+// available to the container.
+const ConcurrencyAuto Concurrency = 0
+
+func (c *Concurrency) SetString(s string) error {
+	if strings.EqualFold(s, "auto") {
+		*c = ConcurrencyAuto
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return fmt.Errorf("wrong concurrency %q: a positive number or 'auto' is accepted", s)
+	}
+	*c = Concurrency(n)
+	return nil
+}
+
+//nolint:govet // General config of the service with focus on human readability.
+type Config struct {
+	NatsServer string        `env:"NATS_SERVER"`
+	Consumer   string        `env:"CONSUMER"`
+	AckWait    time.Duration `env:"ACKWAIT" default:"1m"`
+
+	ConsumerMode   ConsumerMode `env:"CONSUMER_MODE" default:"pull"`
+	DeliverSubject string       `env:"DELIVER_SUBJECT"`
+	QueueGroup     string       `env:"QUEUE_GROUP"`
+	MaxAckPending  int          `env:"MAX_ACK_PENDING"`
+
+	Topic         string `env:"TOPIC" required:""`
+	HTTPEndpoint  string `env:"HTTP_ENDPOINT" required:""`
+	HTTPMethod    string `env:"HTTP_METHOD" default:"POST"`
+	MaxRetries    int    `env:"MAX_RETRIES" required:""`
+	RetryPolicy   string `env:"RETRY_POLICY"`
+	ContentType   string `env:"CONTENT_TYPE" required:""`
+	ResponseTopic string `env:"RESPONSE_TOPIC"`
+	ErrorTopic    string `env:"ERROR_TOPIC"`
+	AuditTopic    string `env:"AUDIT_TOPIC"`
+	SourceName    string `env:"SOURCE_NAME" default:"KEDAConnector"`
+
+	NoResponseTopic NoResponseTopicMode `env:"NO_RESPONSE_TOPIC" default:"ack-and-drop"`
+
+	Receipts        bool   `env:"RECEIPTS"`
+	ReceiptsSubject string `env:"RECEIPTS_SUBJECT"`
+
+	ExactlyOnceHint    bool          `env:"EXACTLY_ONCE_HINT"`
+	CheckpointBucket   string        `env:"CHECKPOINT_BUCKET" default:"checkpoints"`
+	CheckpointInterval time.Duration `env:"CHECKPOINT_INTERVAL" default:"5s"`
+
+	HeaderTopic         string `env:"HEADER_TOPIC" default:"Topic"`
+	HeaderResponseTopic string `env:"HEADER_RESPONSE_TOPIC" default:"RespTopic"`
+	HeaderErrorTopic    string `env:"HEADER_ERROR_TOPIC" default:"ErrorTopic"`
+	HeaderSourceName    string `env:"HEADER_SOURCE_NAME" default:"Source-Name"`
+
+	ForwardHeadersAllow  headerfilter.Patterns `env:"FORWARD_HEADERS_ALLOW"`
+	ForwardHeadersDeny   headerfilter.Patterns `env:"FORWARD_HEADERS_DENY"`
+	ResponseHeadersAllow headerfilter.Patterns `env:"RESPONSE_HEADERS_ALLOW"`
+	ResponseHeadersDeny  headerfilter.Patterns `env:"RESPONSE_HEADERS_DENY"`
+
+	ResponseSchema jsonschema.Schema `env:"RESPONSE_SCHEMA"`
+
+	DNSResolver        string        `env:"DNS_RESOLVER"`
+	DNSPin             resolver.Pins `env:"DNS_PIN"`
+	DNSRefreshInterval time.Duration `env:"DNS_REFRESH_INTERVAL" default:"30s"`
+
+	HTTPCacheTTL  time.Duration `env:"HTTP_CACHE_TTL"`
+	HTTPCacheSize int           `env:"HTTP_CACHE_SIZE" default:"10000"`
+
+	InvokeProtocol     Protocol `env:"INVOKE_PROTOCOL" default:"http"`
+	GraphQLQuery       string   `env:"GRAPHQL_QUERY"`
+	GraphQLRetryErrors bool     `env:"GRAPHQL_RETRY_ERRORS"`
+
+	BodyEncoding BodyEncoding `env:"BODY_ENCODING" default:"raw"`
+
+	WebSocketBinary         bool  `env:"WEBSOCKET_BINARY"`
+	WebSocketMaxMessageSize int64 `env:"WEBSOCKET_MAX_MESSAGE_SIZE" default:"16777216"`
+
+	SOAPVersion  SOAPVersion      `env:"SOAP_VERSION" default:"1.1"`
+	SOAPEnvelope EnvelopeTemplate `env:"SOAP_ENVELOPE"`
+	SOAPAction   string           `env:"SOAP_ACTION"`
+
+	Routes Routes `env:"ROUTES"`
+
+	GroupKey     Key           `env:"GROUP_KEY"`
+	GroupWindow  time.Duration `env:"GROUP_WINDOW" default:"1s"`
+	GroupMaxSize int           `env:"GROUP_MAX_SIZE" default:"100"`
+
+	DebounceKey    Key           `env:"DEBOUNCE_KEY"`
+	DebounceWindow time.Duration `env:"DEBOUNCE_WINDOW" default:"1s"`
+
+	RetryPolicies RetryPolicies `env:"RETRY_POLICIES"`
+
+	PublishMaxAttempts int           `env:"PUBLISH_MAX_ATTEMPTS" default:"3"`
+	PublishBackoff     time.Duration `env:"PUBLISH_BACKOFF" default:"100ms"`
+	PublishMaxBackoff  time.Duration `env:"PUBLISH_MAX_BACKOFF" default:"2s"`
+
+	CorrelationIDHeader string `env:"CORRELATION_ID_HEADER" default:"Nats-Msg-Id"`
+	LogSampleRate       uint64 `env:"LOG_SAMPLE_RATE"`
+
+	ErrorRateLimit       uint64        `env:"ERROR_RATE_LIMIT"`
+	ErrorSummaryInterval time.Duration `env:"ERROR_SUMMARY_INTERVAL" default:"1m"`
+	ErrorSummarySamples  int           `env:"ERROR_SUMMARY_SAMPLES" default:"5"`
+
+	StageEndpoints Endpoints           `env:"STAGE_ENDPOINTS"`
+	StageTransform jsonpointer.Pointer `env:"STAGE_TRANSFORM"`
+
+	ResponseMerge     MergeMode           `env:"RESPONSE_MERGE" default:"none"`
+	ResponseMergePath jsonpointer.Pointer `env:"RESPONSE_MERGE_PATH"`
+
+	DiscardStatus StatusCodes `env:"DISCARD_STATUS"`
+	DiscardEmpty  bool        `env:"DISCARD_EMPTY"`
+	DiscardRule   Rule        `env:"DISCARD_RULE"`
+
+	DeduplicateResponses         bool          `env:"DEDUPLICATE_RESPONSES"`
+	ResponseFlowControl          bool          `env:"RESPONSE_FLOW_CONTROL"`
+	ResponseFlowControlInterval  time.Duration `env:"RESPONSE_FLOW_CONTROL_INTERVAL" default:"5s"`
+	ResponseFlowControlThreshold int           `env:"RESPONSE_FLOW_CONTROL_THRESHOLD" default:"95"`
+
+	ProcessingGuarantee Guarantee `env:"PROCESSING_GUARANTEE" default:"at_least_once"`
+	AckSync             bool      `env:"ACK_SYNC"`
+
+	Concurrent       Concurrency `env:"CONCURRENT" default:"1"`
+	MaxInflightBytes int64       `env:"MAX_INFLIGHT_BYTES"`
+
+	RampUpDuration     time.Duration `env:"RAMP_UP_DURATION"`
+	RampUpStartPercent int           `env:"RAMP_UP_START_PERCENT" default:"10"`
+
+	K8SEvents               bool `env:"K8S_EVENTS"`
+	K8SEventsErrorThreshold int  `env:"K8S_EVENTS_ERROR_THRESHOLD"`
+
+	HealthProbePath     string        `env:"HEALTH_PROBE_PATH"`
+	HealthProbeStatus   int           `env:"HEALTH_PROBE_STATUS" default:"200"`
+	HealthProbeInterval time.Duration `env:"HEALTH_PROBE_INTERVAL" default:"10s"`
+	HealthProbeTimeout  time.Duration `env:"HEALTH_PROBE_TIMEOUT" default:"3s"`
+
+	KeepWarmPath          string        `env:"KEEP_WARM_PATH"`
+	KeepWarmInterval      time.Duration `env:"KEEP_WARM_INTERVAL" default:"30s"`
+	KeepWarmColdThreshold time.Duration `env:"KEEP_WARM_COLD_THRESHOLD" default:"1s"`
+	KeepWarmColdCount     int           `env:"KEEP_WARM_COLD_COUNT" default:"3"`
+
+	ReadyAfterConsuming bool          `env:"READY_AFTER_CONSUMING"`
+	ConsumeHeartbeat    time.Duration `env:"CONSUME_HEARTBEAT" default:"5s"`
+
+	StartSequence    uint64    `env:"START_SEQUENCE"`
+	StartTime        time.Time `env:"START_TIME"`
+	RecreateConsumer bool      `env:"RECREATE_CONSUMER"`
+
+	Backfill            bool      `env:"BACKFILL"`
+	BackfillFrom        time.Time `env:"BACKFILL_FROM"`
+	BackfillTo          time.Time `env:"BACKFILL_TO"`
+	BackfillRate        float64   `env:"BACKFILL_RATE"`
+	BackfillDoneSubject string    `env:"BACKFILL_DONE_SUBJECT"`
+
+	MetricsMaxSubjects int           `env:"METRICS_MAX_SUBJECTS" default:"100"`
+	StreamInfoInterval time.Duration `env:"STREAM_INFO_INTERVAL" default:"30s"`
+	SLOExemplars       bool          `env:"SLO_EXEMPLARS"`
+
+	AggregateSubject string        `env:"AGGREGATE_SUBJECT"`
+	AggregateWindow  time.Duration `env:"AGGREGATE_WINDOW" default:"1m"`
+	AggregateField   Key           `env:"AGGREGATE_FIELD"`
+
+	AlertSubject   string        `env:"ALERT_SUBJECT"`
+	AlertInterval  time.Duration `env:"ALERT_INTERVAL" default:"1m"`
+	AlertErrorRate float64       `env:"ALERT_ERROR_RATE"`
+	AlertLag       uint64        `env:"ALERT_LAG"`
+	AlertDLQRate   float64       `env:"ALERT_DLQ_RATE"`
+
+	DelayedMessages    bool          `env:"DELAYED_MESSAGES"`
+	DeliverAtHeader    string        `env:"DELIVER_AT_HEADER" default:"Nats-Deliver-At"`
+	DelayHeader        string        `env:"DELAY_HEADER" default:"X-Delay"`
+	ClockSkewTolerance time.Duration `env:"CLOCK_SKEW_TOLERANCE" default:"1s"`
+
+	JobLease       bool   `env:"JOB_LEASE"`
+	JobTokenHeader string `env:"JOB_TOKEN_HEADER" default:"X-Job-Token"`
+
+	AsyncCallback       bool          `env:"ASYNC_CALLBACK"`
+	CallbackTokenHeader string        `env:"CALLBACK_TOKEN_HEADER" default:"X-Callback-Token"`
+	CallbackURL         string        `env:"CALLBACK_URL"`
+	CallbackTimeout     time.Duration `env:"CALLBACK_TIMEOUT" default:"10m"`
+
+	AsyncPoll       bool          `env:"ASYNC_POLL"`
+	PollInterval    time.Duration `env:"POLL_INTERVAL" default:"1s"`
+	PollMaxInterval time.Duration `env:"POLL_MAX_INTERVAL" default:"30s"`
+	PollTimeout     time.Duration `env:"POLL_TIMEOUT" default:"10m"`
+
+	SlowRequestThreshold time.Duration `env:"SLOW_REQUEST_THRESHOLD"`
+	TimeoutNakDelay      time.Duration `env:"TIMEOUT_NAK_DELAY" default:"10s"`
+	TimeoutHeader        string        `env:"TIMEOUT_HEADER"`
+	DrainTimeout         time.Duration `env:"DRAIN_TIMEOUT" default:"20s"`
+
+	MaxMessageAge  time.Duration `env:"MAX_MESSAGE_AGE"`
+	ExpiredSubject string        `env:"EXPIRED_SUBJECT"`
+
+	LargeResponseMode largemsg.Mode `env:"LARGE_RESPONSE_MODE" default:"fail"`
+	ObjectStoreBucket string        `env:"OBJECT_STORE_BUCKET"`
+	ClaimCheck        bool          `env:"CLAIM_CHECK"`
+
+	Decompress        bool  `env:"DECOMPRESS"`
+	DecompressMaxSize int64 `env:"DECOMPRESS_MAX_SIZE" default:"16777216"`
+
+	PayloadPath           jsonpointer.Pointer `env:"PAYLOAD_PATH"`
+	PayloadEnvelopeHeader string              `env:"PAYLOAD_ENVELOPE_HEADER"`
+
+	WASMHook  string `env:"WASM_HOOK"`
+	LuaScript string `env:"LUA_SCRIPT"`
+
+	Chaos            bool          `env:"CHAOS"`
+	ChaosErrorRate   float64       `env:"CHAOS_ERROR_RATE"`
+	ChaosLatency     time.Duration `env:"CHAOS_LATENCY"`
+	ChaosDropAckRate float64       `env:"CHAOS_DROP_ACK_RATE"`
+
+	ResponseSink SinkKind `env:"RESPONSE_SINK" default:"nats"`
+	ErrorSink    SinkKind `env:"ERROR_SINK" default:"nats"`
+
+	AMQPURL                string `env:"AMQP_URL"`
+	AMQPExchange           string `env:"AMQP_EXCHANGE"`
+	AMQPResponseRoutingKey string `env:"AMQP_RESPONSE_ROUTING_KEY"`
+	AMQPErrorRoutingKey    string `env:"AMQP_ERROR_ROUTING_KEY"`
+	AMQPCAFile             string `env:"AMQP_CA_FILE"`
+
+	SQSResponseQueueURL string `env:"SQS_RESPONSE_QUEUE_URL"`
+	SQSErrorQueueURL    string `env:"SQS_ERROR_QUEUE_URL"`
+	SNSResponseTopicARN string `env:"SNS_RESPONSE_TOPIC_ARN"`
+	SNSErrorTopicARN    string `env:"SNS_ERROR_TOPIC_ARN"`
+
+	AWSRegion               string `env:"AWS_REGION"`
+	AWSAccessKeyID          string `env:"AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey      string `env:"AWS_SECRET_ACCESS_KEY"`
+	AWSSessionToken         string `env:"AWS_SESSION_TOKEN"`
+	AWSEndpointURL          string `env:"AWS_ENDPOINT_URL"`
+	AWSWebIdentityTokenFile string `env:"AWS_WEB_IDENTITY_TOKEN_FILE"`
+	AWSRoleARN              string `env:"AWS_ROLE_ARN"`
+	AWSRoleSessionName      string `env:"AWS_ROLE_SESSION_NAME" default:"http-connector"`
+
+	WebhookURLs       Endpoints     `env:"WEBHOOK_URLS"`
+	WebhookErrorURLs  Endpoints     `env:"WEBHOOK_ERROR_URLS"`
+	WebhookSecret     string        `env:"WEBHOOK_SECRET"`
+	WebhookMaxRetries int           `env:"WEBHOOK_MAX_RETRIES" default:"3"`
+	WebhookTimeout    time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s"`
+
+	PostgresURL           string         `env:"POSTGRES_URL"`
+	PostgresTable         string         `env:"POSTGRES_TABLE"`
+	PostgresColumns       pgsink.Columns `env:"POSTGRES_COLUMNS"`
+	PostgresBatchSize     int            `env:"POSTGRES_BATCH_SIZE" default:"100"`
+	PostgresBatchInterval time.Duration  `env:"POSTGRES_BATCH_INTERVAL" default:"100ms"`
+
+	RedisURL            string        `env:"REDIS_URL"`
+	RedisKeyPrefix      string        `env:"REDIS_KEY_PREFIX"`
+	RedisCacheTTL       time.Duration `env:"REDIS_CACHE_TTL"`
+	RedisCacheKeyHeader string        `env:"REDIS_CACHE_KEY_HEADER" default:"Nats-Msg-Id"`
+	RedisEnrich         Enrichments   `env:"REDIS_ENRICH"`
+
+	ArchiveBucket string `env:"ARCHIVE_BUCKET"`
+	ArchivePrefix string `env:"ARCHIVE_PREFIX"`
+
+	SSESourceURL     string `env:"SSE_SOURCE_URL"`
+	SSESourceSubject string `env:"SSE_SOURCE_SUBJECT"`
+
+	ProfileBucket string `env:"PROFILE_BUCKET"`
+	ProfileToken  string `env:"PROFILE_TOKEN"`
+
+	// ControlToken enables the pause API with the bearer token.
+	ControlToken string `env:"CONTROL_TOKEN"`
+
+	EncryptionKeys  encryption.Keys `env:"ENCRYPTION_KEYS"`
+	EncryptionKeyID string          `env:"ENCRYPTION_KEY_ID"`
+}
+
+func (c Config) Validate() error {
+	switch c.HTTPMethod {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return fmt.Errorf("wrong http method %q: only 'GET|POST|PUT|PATCH|DELETE' are accepted", c.HTTPMethod)
+	}
+	for _, endpoint := range append(Endpoints{c.HTTPEndpoint}, c.StageEndpoints...) {
+		if err := unixsock.Validate(endpoint); err != nil {
+			return err //nolint:wrapcheck // error has the endpoint
+		}
+	}
+	if (c.DNSResolver != "" || len(c.DNSPin) > 0) && c.DNSRefreshInterval <= 0 {
+		return errors.New("dns refresh interval must be positive")
+	}
+	if c.HTTPCacheTTL > 0 && c.HTTPCacheSize <= 0 {
+		return errors.New("http cache size must be positive")
+	}
+	if c.InvokeProtocol == ProtocolWebSocket && c.WebSocketMaxMessageSize <= 0 {
+		return errors.New("websocket max message size must be positive")
+	}
+	if c.Decompress && c.DecompressMaxSize <= 0 {
+		return errors.New("decompress max size must be positive")
+	}
+
+	if c.AggregateSubject != "" && c.AggregateWindow <= 0 {
+		return errors.New("aggregate window must be positive")
+	}
+
+	if c.AlertSubject != "" && c.AlertInterval <= 0 {
+		return errors.New("alert interval must be positive")
+	}
+
+	if c.GroupKey.Enabled() && (c.GroupWindow <= 0 || c.GroupWindow >= c.AckWait || c.GroupMaxSize <= 0) {
+		return errors.New("group window must be positive and less than ack wait, group max size must be positive")
+	}
+	if c.DebounceKey.Enabled() && (c.DebounceWindow <= 0 || c.DebounceWindow >= c.AckWait) {
+		return errors.New("debounce window must be positive and less than ack wait")
+	}
+	if c.GroupKey.Enabled() && c.DebounceKey.Enabled() {
+		return errors.New("only one of group key and debounce key can be set")
+	}
+
+	if c.AsyncCallback && c.CallbackTimeout <= 0 {
+		return errors.New("callback timeout must be positive")
+	}
+	if c.AsyncPoll && (c.PollInterval <= 0 || c.PollMaxInterval < c.PollInterval || c.PollTimeout <= 0) {
+		return errors.New("poll interval and timeout must be positive, poll max interval must not be less than poll interval")
+	}
+	if c.AsyncPoll && c.AsyncCallback {
+		return errors.New("only one of async callback and async poll can be set")
+	}
+
+	if c.ConsumeHeartbeat <= 0 {
+		return errors.New("consume heartbeat must be positive")
+	}
+
+	if c.MaxAckPending < 0 {
+		return errors.New("max ack pending must not be negative")
+	}
+
+	if c.KeepWarmPath != "" && (c.KeepWarmInterval <= 0 || c.KeepWarmColdThreshold <= 0) {
+		return errors.New("keep-warm interval and cold threshold must be positive")
+	}
+
+	if c.PublishMaxAttempts <= 0 || c.PublishBackoff < 0 || c.PublishMaxBackoff < 0 {
+		return errors.New("publish max attempts must be positive, publish backoff can't be negative")
+	}
+
+	if c.ErrorRateLimit > 0 && (c.ErrorSummaryInterval <= 0 || c.ErrorSummarySamples < 0) {
+		return errors.New("error summary interval must be positive, error summary samples can't be negative")
+	}
+
+	if c.RetryPolicy != "" {
+		if _, ok := c.RetryPolicies[c.RetryPolicy]; !ok {
+			return fmt.Errorf("retry policy %q is not found in retry policies", c.RetryPolicy)
+		}
+	}
+	for _, route := range c.Routes {
+		if _, ok := c.RetryPolicies[route.RetryPolicy]; route.RetryPolicy != "" && !ok {
+			return fmt.Errorf("retry policy %q of route %q is not found in retry policies", route.RetryPolicy, route.Name)
+		}
+	}
+
+	if c.EncryptionKeyID != "" {
+		if _, ok := c.EncryptionKeys[c.EncryptionKeyID]; !ok {
+			return fmt.Errorf("encryption key id %q is not found in encryption keys", c.EncryptionKeyID)
+		}
+		if c.LargeResponseMode == largemsg.ModeTruncate {
+			return errors.New("encrypted responses can't be truncated: large response mode 'truncate' can't be used with encryption")
+		}
+	}
+
+	if c.StartSequence > 0 && !c.StartTime.IsZero() {
+		return errors.New("only one of start sequence and start time can be set")
+	}
+
+	if c.Backfill && c.ConsumerMode == ConsumerModePushLegacy {
+		return errors.New("backfill is not supported in 'push-legacy' consumer mode")
+	}
+
+	if c.ExactlyOnceHint && c.Backfill {
+		return errors.New("exactly once hint is not supported in backfill mode")
+	}
+	if c.ExactlyOnceHint && c.CheckpointInterval <= 0 {
+		return errors.New("checkpoint interval must be positive")
+	}
+
+	if c.Backfill && c.BackfillFrom.IsZero() {
+		return errors.New("backfill from time is required in backfill mode")
+	}
+
+	if c.InvokeProtocol == ProtocolGraphQL && c.GraphQLQuery == "" {
+		return errors.New("graphql query is required in 'graphql' invoke protocol")
+	}
+
+	if c.ResponseMerge == MergeField && len(c.ResponseMergePath) == 0 {
+		return errors.New("response merge path is required in 'field' response merge mode")
+	}
+
+	if c.Chaos && !chaosAvailable {
+		return errors.New("chaos mode requires the connector built with 'chaos' build tag")
+	}
+
+	for name, rate := range map[string]float64{"chaos error rate": c.ChaosErrorRate, "chaos drop ack rate": c.ChaosDropAckRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s should be between 0 and 1", name)
+		}
+	}
+
+	if (c.ResponseSink == SinkAMQP || c.ErrorSink == SinkAMQP) && c.AMQPURL == "" {
+		return errors.New("amqp url is required by amqp response or error sink")
+	}
+
+	for _, kind := range []SinkKind{c.ResponseSink, c.ErrorSink} {
+		if (kind == SinkSQS || kind == SinkSNS) && c.AWSRegion == "" {
+			return fmt.Errorf("aws region is required by %s sink", kind)
+		}
+	}
+	if (c.AWSAccessKeyID == "") != (c.AWSSecretAccessKey == "") {
+		return errors.New("aws access key id and secret access key should be set together")
+	}
+	if (c.AWSWebIdentityTokenFile == "") != (c.AWSRoleARN == "") {
+		return errors.New("aws web identity token file and role arn should be set together")
+	}
+	for dest, missing := range map[string]bool{
+		"sqs response queue url is required by sqs response sink": c.ResponseSink == SinkSQS && c.SQSResponseQueueURL == "",
+		"sqs error queue url is required by sqs error sink":       c.ErrorSink == SinkSQS && c.SQSErrorQueueURL == "",
+		"sns response topic arn is required by sns response sink": c.ResponseSink == SinkSNS && c.SNSResponseTopicARN == "",
+		"sns error topic arn is required by sns error sink":       c.ErrorSink == SinkSNS && c.SNSErrorTopicARN == "",
+	} {
+		if missing {
+			return errors.New(dest)
+		}
+	}
+
+	if c.ExpiredSubject != "" && c.MaxMessageAge <= 0 {
+		return errors.New("max message age is required by expired subject")
+	}
+	if c.ResponseSink == SinkWebhook && len(c.WebhookURLs) == 0 {
+		return errors.New("webhook urls are required by webhook response sink")
+	}
+	if c.ErrorSink == SinkWebhook && len(c.WebhookErrorURLs) == 0 {
+		return errors.New("webhook error urls are required by webhook error sink")
+	}
+
+	if c.ErrorSink == SinkPostgres {
+		return errors.New("postgres sink can't be error sink")
+	}
+	if c.ResponseSink == SinkPostgres && (c.PostgresURL == "" || c.PostgresTable == "" || len(c.PostgresColumns) == 0) {
+		return errors.New("postgres url, table and columns are required by postgres sink")
+	}
+	if c.ResponseSink == SinkPostgres && c.PostgresBatchInterval <= 0 {
+		return errors.New("postgres batch interval must be positive")
+	}
+
+	if c.RedisURL == "" && (c.RedisCacheTTL > 0 || len(c.RedisEnrich) > 0) {
+		return errors.New("redis url is required by response cache and enrichment")
+	}
+
+	if c.ArchiveBucket != "" && c.AWSRegion == "" {
+		return errors.New("aws region is required by archive")
+	}
+
+	if c.SSESourceURL != "" && c.SSESourceSubject == "" {
+		return errors.New("sse source subject is required to publish events of sse source url")
+	}
+
+	if c.ProfileBucket != "" && c.ProfileToken == "" {
+		return errors.New("profile token is required to capture profiles to profile bucket")
+	}
+
+	return nil
+}
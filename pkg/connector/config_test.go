@@ -0,0 +1,31 @@
+package connector
+
+import "testing"
+
+func TestConcurrencySetString(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Concurrency
+		err  bool
+	}{
+		{s: "1", want: 1},
+		{s: "16", want: 16},
+		{s: "auto", want: ConcurrencyAuto},
+		{s: "AUTO", want: ConcurrencyAuto},
+		{s: "0", err: true},
+		{s: "-1", err: true},
+		{s: "many", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			var c Concurrency
+			err := c.SetString(tt.s)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if c != tt.want {
+				t.Errorf("concurrency = %d, want %d", c, tt.want)
+			}
+		})
+	}
+}
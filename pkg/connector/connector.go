@@ -0,0 +1,281 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/codec"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/resolver"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/unixsock"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type Connector struct {
+	connectordata Config
+	nc            *nats.Conn
+	jsContext     jetstream.JetStream
+	logger        *slog.Logger
+	consumer      string
+	concurrentSem chan int
+	inflightBytes *byteLimiter
+	maxPayload    int
+	objStore      nats.ObjectStore
+	decoder       *codec.Decoder // nil if DECOMPRESS is disabled
+	wsPool        chan *wsSession
+	dial          func(ctx context.Context, network, address string) (net.Conn, error)
+	transport     *http.Transport
+	httpClient    *http.Client
+	sinks         map[SinkKind]Sink
+	archiver      Archiver
+	archiveQueue  chan archiveJob
+	kv            KV
+	hook          TransformHook
+	script        ScriptHook
+	events        EventRecorder
+	errorRate     *errorRate
+	getCache      *getCache
+	metrics       connectorMetrics
+	backfill      *backfillState
+	stats         *connectorStats
+	coldStreak    *coldStreak
+	drained       chan struct{}
+	drainedOnce   sync.Once
+	handler       Handler
+	middlewares   []Middleware
+	groups        *groups
+	aggregator    *aggregator
+	errorLimiter  *errorLimiter
+	jobs          *jobs
+	callbacks     *callbacks
+	checkpoint    *checkpoint
+	unacked       *unacked
+
+	endpointHealth   *gate
+	responseCapacity *gate
+	ramping          *atomic.Bool
+	consuming        *atomic.Bool
+	consumeHealth    *consumeHealth
+	shutdownReport   atomic.Pointer[ShutdownReport]
+	manualPause      atomic.Bool
+}
+
+// New creates the connector. The object store is required by the claim check and the 'objectstore' large response mode only.
+func New(cfg Config, nc *nats.Conn, js jetstream.JetStream, objStore nats.ObjectStore, log *slog.Logger) *Connector {
+	var inflightBytes *byteLimiter
+	if cfg.MaxInflightBytes > 0 {
+		inflightBytes = newByteLimiter(cfg.MaxInflightBytes)
+	}
+
+	var cache *getCache
+	if cfg.HTTPCacheTTL > 0 {
+		cache = newGetCache(cfg.HTTPCacheTTL, cfg.HTTPCacheSize)
+	}
+
+	// Endpoint calls have their own transport, so the resolver and the unix sockets don't affect other HTTP clients.
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // default transport
+	unixsock.Register(transport)
+
+	unacked := newUnacked()
+
+	conn := &Connector{
+		connectordata: cfg,
+		nc:            nc,
+		jsContext:     js,
+		logger:        log,
+		consumer:      cfg.Consumer,
+		concurrentSem: make(chan int, cfg.Concurrent),
+		inflightBytes: inflightBytes,
+		maxPayload:    int(nc.MaxPayload()),
+		objStore:      objStore,
+		wsPool:        make(chan *wsSession, cfg.Concurrent),
+		transport:     transport,
+		httpClient:    &http.Client{Transport: transport}, //nolint:exhaustruct // timeouts are set by the requests
+		sinks:         map[SinkKind]Sink{},
+		getCache:      cache,
+		metrics:       newConnectorMetrics(int(cfg.Concurrent), cfg.MetricsMaxSubjects, unacked),
+		unacked:       unacked,
+		backfill:      &backfillState{},
+		stats:         newConnectorStats(int(cfg.Concurrent)),
+		coldStreak:    &coldStreak{threshold: cfg.KeepWarmColdThreshold}, //nolint:exhaustruct // zero counter
+		drained:       make(chan struct{}),
+		jobs:          &jobs{leases: map[string]*lease{}},                 //nolint:exhaustruct // zero mutex
+		callbacks:     &callbacks{pending: map[string]*callback{}},        //nolint:exhaustruct // zero mutex
+		errorRate:     &errorRate{threshold: cfg.K8SEventsErrorThreshold}, //nolint:exhaustruct // zero window
+
+		endpointHealth:   newGate(cfg.HealthProbePath == ""),
+		responseCapacity: newGate(true),
+		ramping:          &atomic.Bool{},
+		consuming:        &atomic.Bool{},
+		consumeHealth:    &consumeHealth{heartbeat: cfg.ConsumeHeartbeat}, //nolint:exhaustruct // zero state
+	}
+	conn.handler = conn.handle
+	if cfg.AggregateSubject != "" {
+		conn.aggregator = newAggregator(cfg)
+	}
+	if cfg.ErrorRateLimit > 0 {
+		conn.errorLimiter = newErrorLimiter(cfg)
+	}
+	if cfg.GroupKey.Enabled() || cfg.DebounceKey.Enabled() {
+		conn.groups = newGroups(conn)
+	}
+	if cfg.Decompress {
+		conn.decoder = codec.NewDecoder(cfg.DecompressMaxSize)
+	}
+	return conn
+}
+
+// SetResolver resolves the hosts of the endpoint calls (HTTP requests and WebSocket connections) with the resolver.
+// Idle connections are closed when the addresses of a host change. Other HTTP clients keep the system resolver.
+func (conn *Connector) SetResolver(res *resolver.Resolver) {
+	conn.transport.DialContext = res.DialContext
+	conn.dial = res.DialContext
+	res.OnChange = func(string) { conn.transport.CloseIdleConnections() }
+}
+
+// consumerConfig is the config of the auto-created durable consumer.
+func (conn *Connector) consumerConfig() jetstream.ConsumerConfig {
+	jconf := jetstream.ConsumerConfig{
+		Durable:       conn.consumer,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubject: conn.filterSubject(),
+		AckWait:       conn.connectordata.AckWait + time.Second,
+	}
+
+	switch {
+	case conn.connectordata.StartSequence > 0:
+		jconf.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		jconf.OptStartSeq = conn.connectordata.StartSequence
+	case !conn.connectordata.StartTime.IsZero():
+		jconf.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		jconf.OptStartTime = &conn.connectordata.StartTime
+	}
+	return jconf
+}
+
+// deleteStaleConsumer deletes the existing consumer if it doesn't start from the position set by START_SEQUENCE
+// or START_TIME, so it is created again from it. The consumer already starting from it is kept:
+// the replicas sharing the durable consumer and the restarts don't replay the messages again.
+func (conn *Connector) deleteStaleConsumer(ctx context.Context) error {
+	log := conn.logger.With(slog.String("topic", conn.connectordata.Topic), slog.String("consumer", conn.consumer))
+
+	cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+	if errors.Is(err, jetstream.ErrConsumerNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get consumer to recreate: %w", err)
+	}
+
+	want, got := conn.consumerConfig(), cs.CachedInfo().Config
+	if startOf(want.FilterSubject, int(want.DeliverPolicy), want.OptStartSeq, want.OptStartTime) ==
+		startOf(got.FilterSubject, int(got.DeliverPolicy), got.OptStartSeq, got.OptStartTime) {
+		log.Info("Consumer is not recreated - it starts from the configured position")
+		return nil
+	}
+
+	err = conn.jsContext.DeleteConsumer(ctx, conn.connectordata.Topic, conn.consumer)
+	if err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		return fmt.Errorf("delete consumer to recreate: %w", err)
+	}
+	log.Info("Consumer is deleted to be recreated")
+	return nil
+}
+
+// consumerStart is the filter subject and the start position of a consumer compared by RECREATE_CONSUMER,
+// the same for the configs of the jetstream and the legacy JetStream API.
+type consumerStart struct {
+	filter string
+	policy int
+	seq    uint64
+	time   int64 // unix nanoseconds, 0 if not set
+}
+
+func startOf(filter string, policy int, seq uint64, t *time.Time) consumerStart {
+	s := consumerStart{filter: filter, policy: policy, seq: seq, time: 0}
+	if t != nil {
+		s.time = t.UnixNano()
+	}
+	return s
+}
+
+func (conn *Connector) Consume(ctx context.Context) error {
+	defer conn.markDrained() // WaitDrained is released on every return path
+	log := conn.logger
+
+	if conn.connectordata.RecreateConsumer {
+		if err := conn.deleteStaleConsumer(ctx); err != nil {
+			return err
+		}
+	}
+
+	cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+	if err != nil {
+		log.Error("Error on new consumer (will be ignored)", slog.Any("error", err))
+		jconf := conn.consumerConfig()
+		cs, err = conn.jsContext.CreateConsumer(ctx, conn.connectordata.Topic, jconf)
+		if err != nil {
+			return fmt.Errorf("create consumer: %w", err)
+		} else {
+			log.Info("New consumer is created", slog.String("topic", conn.connectordata.Topic), slog.String("consumer", conn.consumer), slog.String("filter_subject", jconf.FilterSubject))
+		}
+	} else {
+		log.Info("Use consumer", slog.String("topic", conn.connectordata.Topic), slog.String("consumer", conn.consumer))
+	}
+
+	if !conn.endpointHealth.IsOpen() {
+		log.Info("Waiting for the HTTP endpoint to be healthy")
+		conn.endpointHealth.Wait(ctx)
+	}
+
+	go conn.rampUp(ctx)
+
+	log.Info("Start receiving messages")
+
+	// Processing outlives the shutdown signal up to DRAIN_TIMEOUT.
+	procCtx, cancelProc := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelProc()
+
+	cc, terminal, err := conn.subscribe(procCtx, cs)
+	if err != nil {
+		log.Debug("error occurred while parsing metadata", slog.Any("error", err))
+		return err
+	}
+
+	if conn.connectordata.ReadyAfterConsuming {
+		go conn.confirmConsuming(ctx)
+	}
+
+	pauseTicker := time.NewTicker(pauseCheckInterval)
+	defer pauseTicker.Stop()
+
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-pauseTicker.C:
+			cc, terminal = conn.applyPause(ctx, procCtx, cc, terminal)
+		case err := <-terminal:
+			log.Warn("Consuming is stopped - it will be re-established", slog.Any("error", err))
+			cc.Stop()
+			cc, terminal, err = conn.resubscribe(ctx, procCtx)
+			if err != nil {
+				cc = nil
+			}
+		}
+	}
+
+	log.Info("closing connection...")
+	if cc != nil {
+		cc.Stop()
+	}
+	conn.drain(cancelProc)
+
+	return nil
+}
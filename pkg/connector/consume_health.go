@@ -0,0 +1,147 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var (
+	ErrConsumeStopped = errors.New("consuming is stopped and being re-established")
+	ErrNoHeartbeat    = errors.New("consumer heartbeats are missed")
+)
+
+// consumeHealth tracks errors of the consume subscription reported by JetStream.
+type consumeHealth struct {
+	heartbeat    time.Duration
+	errAt        atomic.Int64 // unix nanoseconds of the last missed heartbeat
+	subscribedAt atomic.Int64 // unix nanoseconds of the start of the consume subscription
+	stopped      atomic.Bool
+}
+
+// ConsumeCheck returns an error while the consume subscription is being re-established
+// or heartbeats were missed within the last 3 heartbeat intervals (missed heartbeats are reported every 2 intervals).
+func (conn *Connector) ConsumeCheck() error {
+	if conn.consumeHealth.stopped.Load() {
+		return ErrConsumeStopped
+	}
+	if errAt := conn.consumeHealth.errAt.Load(); errAt > 0 && time.Since(time.Unix(0, errAt)) < 3*conn.consumeHealth.heartbeat {
+		return ErrNoHeartbeat
+	}
+	return nil
+}
+
+// subscribe starts consuming messages of the consumer with CONSUME_HEARTBEAT heartbeats.
+// Terminal errors, after which JetStream stops the delivery (e.g. the consumer is deleted), are sent to the returned channel.
+func (conn *Connector) subscribe(procCtx context.Context, cs jetstream.Consumer) (jetstream.ConsumeContext, <-chan error, error) {
+	log := conn.logger
+	terminal := make(chan error, 1)
+
+	cc, err := cs.Consume(func(msg jetstream.Msg) {
+		if !conn.consuming.Load() {
+			conn.consuming.Store(true)
+		}
+		if conn.deferPaused(procCtx, msg) {
+			return
+		}
+		conn.log(conn.withMessageLogger(procCtx, msg)).Info("Got a message", slog.String("message", string(msg.Data())))
+		conn.dispatch(procCtx, msg)
+	},
+		jetstream.PullHeartbeat(conn.connectordata.ConsumeHeartbeat),
+		jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+			reason := consumeErrReason(err)
+			conn.metrics.consumeErrors(reason)
+
+			switch reason {
+			case "no_heartbeat":
+				conn.consumeHealth.errAt.Store(time.Now().UnixNano())
+				log.Warn("Consumer heartbeats are missed", slog.Any("error", err))
+			case "consumer_deleted", "bad_request":
+				conn.consumeHealth.stopped.Store(true)
+				conn.metrics.consumeHealthy.Set(0)
+				log.Error("Consuming is stopped by JetStream", slog.Any("error", err))
+				select {
+				case terminal <- err:
+				default:
+				}
+			default:
+				log.Warn("Consume error", slog.Any("error", err))
+			}
+		}),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("consume: %w", err)
+	}
+
+	conn.consumeHealth.subscribedAt.Store(time.Now().UnixNano())
+	conn.consumeHealth.stopped.Store(false)
+	conn.metrics.consumeHealthy.Set(1)
+	return cc, terminal, nil
+}
+
+// resubscribe gets the consumer, or recreates it from the config if it was deleted, and starts consuming again
+// with backoff (1s to 30s) until it succeeds or the context is done.
+func (conn *Connector) resubscribe(ctx, procCtx context.Context) (jetstream.ConsumeContext, <-chan error, error) {
+	backoff := time.Second
+	for {
+		cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			cs, err = conn.recreateConsumer(ctx)
+		}
+		if err == nil {
+			var cc jetstream.ConsumeContext
+			var terminal <-chan error
+			cc, terminal, err = conn.subscribe(procCtx, cs)
+			if err == nil {
+				conn.logger.Info("Consuming is re-established", slog.String("consumer", conn.consumer))
+				return cc, terminal, nil
+			}
+		}
+		conn.logger.Warn("Failed to re-establish consuming", slog.Any("error", err), slog.Duration("retry_in", backoff))
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err() //nolint:wrapcheck // shutdown
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// recreateConsumer creates the consumer deleted externally (e.g. by ops cleanup) with the config of the auto-created consumer.
+func (conn *Connector) recreateConsumer(ctx context.Context) (jetstream.Consumer, error) {
+	jconf := conn.consumerConfig()
+	cs, err := conn.jsContext.CreateConsumer(ctx, conn.connectordata.Topic, jconf)
+	if err != nil {
+		return nil, fmt.Errorf("recreate deleted consumer: %w", err)
+	}
+	conn.consumerRecreated(jconf.FilterSubject)
+	return cs, nil
+}
+
+// consumerRecreated reports the recreation of the consumer deleted externally.
+func (conn *Connector) consumerRecreated(filterSubject string) {
+	conn.metrics.consumerRecreations.Inc()
+	conn.event(EventWarning, "ConsumerRecreated", fmt.Sprintf("Consumer %s of stream %s was deleted and is recreated", conn.consumer, conn.connectordata.Topic))
+	conn.logger.Warn("Deleted consumer is recreated",
+		slog.String("topic", conn.connectordata.Topic),
+		slog.String("consumer", conn.consumer),
+		slog.String("filter_subject", filterSubject))
+}
+
+func consumeErrReason(err error) string {
+	switch {
+	case errors.Is(err, jetstream.ErrNoHeartbeat):
+		return "no_heartbeat"
+	case errors.Is(err, jetstream.ErrConsumerDeleted):
+		return "consumer_deleted"
+	case errors.Is(err, jetstream.ErrBadRequest):
+		return "bad_request"
+	}
+	return "other"
+}
@@ -0,0 +1,55 @@
+package connector
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// PodIdentity is the data available in the CONSUMER template.
+// It is filled from the env variables provided by the Kubernetes downward API.
+type PodIdentity struct {
+	PodName   string // POD_NAME, or HOSTNAME if it is not set
+	Namespace string // POD_NAMESPACE
+	NodeName  string // NODE_NAME
+	Ordinal   string // StatefulSet ordinal - the last '-' separated part of the pod name
+}
+
+func NewPodIdentity(getenv func(string) string) PodIdentity {
+	podName := getenv("POD_NAME")
+	if podName == "" {
+		podName = getenv("HOSTNAME")
+	}
+
+	var ordinal string
+	if i := strings.LastIndex(podName, "-"); i >= 0 {
+		ordinal = podName[i+1:]
+	}
+
+	return PodIdentity{
+		PodName:   podName,
+		Namespace: getenv("POD_NAMESPACE"),
+		NodeName:  getenv("NODE_NAME"),
+		Ordinal:   ordinal,
+	}
+}
+
+// ConsumerName resolves the consumer name template like 'connector-{{.Ordinal}}'.
+// Names without template actions are returned as is.
+func ConsumerName(name string, id PodIdentity) (string, error) {
+	if !strings.Contains(name, "{{") {
+		return name, nil
+	}
+
+	tmpl, err := template.New("consumer").Option("missingkey=error").Parse(name)
+	if err != nil {
+		return "", fmt.Errorf("parse consumer name template: %w", err)
+	}
+
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, id)
+	if err != nil {
+		return "", fmt.Errorf("execute consumer name template: %w", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,47 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotConsuming = errors.New("consumer delivery is not confirmed yet")
+
+// confirmConsuming waits until the delivery to this consume subscription is confirmed: it got a message,
+// or no heartbeats of its pull requests were missed within 3 heartbeat intervals since it started
+// (missed heartbeats are reported every 2 intervals, so the server has sent heartbeats to the subscription).
+// The consumer info can't confirm it: its NumWaiting and NumAckPending are of all subscriptions of the durable
+// consumer, e.g. of the old replicas during a rolling deploy.
+func (conn *Connector) confirmConsuming(ctx context.Context) {
+	heartbeat := conn.connectordata.ConsumeHeartbeat
+	ticker := time.NewTicker(min(time.Second, heartbeat))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if conn.consuming.Load() {
+			conn.logger.Info("Consumer delivery is confirmed by a message")
+			return
+		}
+		since := max(conn.consumeHealth.subscribedAt.Load(), conn.consumeHealth.errAt.Load())
+		if since > 0 && !conn.consumeHealth.stopped.Load() && time.Since(time.Unix(0, since)) >= 3*heartbeat {
+			conn.consuming.Store(true)
+			conn.logger.Info("Consumer delivery is confirmed by heartbeats")
+			return
+		}
+	}
+}
+
+// ConsumingCheck returns an error until the delivery of messages to the connector is confirmed.
+func (conn *Connector) ConsumingCheck() error {
+	if !conn.consuming.Load() {
+		return ErrNotConsuming
+	}
+	return nil
+}
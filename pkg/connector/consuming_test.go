@@ -0,0 +1,72 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfirmConsuming(t *testing.T) {
+	const heartbeat = 20 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		setup     func(conn *Connector)
+		confirmed bool
+	}{
+		{
+			name:      "not subscribed",
+			setup:     func(*Connector) {},
+			confirmed: false,
+		},
+		{
+			name: "message delivered",
+			setup: func(conn *Connector) {
+				conn.consuming.Store(true) // set by the consume handler
+			},
+			confirmed: true,
+		},
+		{
+			name: "heartbeats are not missed",
+			setup: func(conn *Connector) {
+				conn.consumeHealth.subscribedAt.Store(time.Now().UnixNano())
+			},
+			confirmed: true,
+		},
+		{
+			name: "heartbeats are missed",
+			setup: func(conn *Connector) {
+				conn.consumeHealth.subscribedAt.Store(time.Now().UnixNano())
+				go func() {
+					for i := 0; i < 10; i++ {
+						conn.consumeHealth.errAt.Store(time.Now().UnixNano())
+						time.Sleep(2 * heartbeat)
+					}
+				}()
+			},
+			confirmed: false,
+		},
+		{
+			name: "consuming is stopped",
+			setup: func(conn *Connector) {
+				conn.consumeHealth.subscribedAt.Store(time.Now().UnixNano())
+				conn.consumeHealth.stopped.Store(true)
+			},
+			confirmed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{ConsumeHeartbeat: heartbeat}, nil) //nolint:exhaustruct // test config
+			tt.setup(conn)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*heartbeat)
+			defer cancel()
+			conn.confirmConsuming(ctx)
+
+			if err := conn.ConsumingCheck(); (err == nil) != tt.confirmed {
+				t.Errorf("consuming check error = %v, want confirmed %v", err, tt.confirmed)
+			}
+		})
+	}
+}
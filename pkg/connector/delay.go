@@ -0,0 +1,62 @@
+package connector
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// dueIn returns how long the message should wait before being processed according to DELIVER_AT_HEADER
+// (RFC 3339 time or unix seconds) or DELAY_HEADER (duration like '30s' or seconds since the message was published).
+// Messages due within CLOCK_SKEW_TOLERANCE are processed at once, so small clock differences
+// between the producer, the server and the connector don't cause extra redeliveries.
+func (conn *Connector) dueIn(msg Message) time.Duration {
+	cfg := conn.connectordata
+	if !cfg.DelayedMessages {
+		return 0
+	}
+
+	var dueAt time.Time
+	hdr := msg.Headers()
+	if v := hdr.Get(cfg.DeliverAtHeader); v != "" && cfg.DeliverAtHeader != "" {
+		t, err := parseDeliverAt(v)
+		if err != nil {
+			conn.logger.Warn("Wrong deliver-at header value - message is processed at once", slog.String("header", cfg.DeliverAtHeader), slog.String("value", v))
+			return 0
+		}
+		dueAt = t
+	} else if v := hdr.Get(cfg.DelayHeader); v != "" && cfg.DelayHeader != "" {
+		d, err := parseDelay(v)
+		if err != nil {
+			conn.logger.Warn("Wrong delay header value - message is processed at once", slog.String("header", cfg.DelayHeader), slog.String("value", v))
+			return 0
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return 0
+		}
+		dueAt = meta.Timestamp.Add(d)
+	} else {
+		return 0
+	}
+
+	wait := time.Until(dueAt)
+	if wait <= cfg.ClockSkewTolerance {
+		return 0
+	}
+	return wait
+}
+
+func parseDeliverAt(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339Nano, v) //nolint:wrapcheck // caller logs the value
+}
+
+func parseDelay(v string) (time.Duration, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+	return time.ParseDuration(v) //nolint:wrapcheck // caller logs the value
+}
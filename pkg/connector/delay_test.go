@@ -0,0 +1,86 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDueIn(t *testing.T) {
+	now := time.Now()
+	cfg := Config{ //nolint:exhaustruct // test config
+		DelayedMessages:    true,
+		DeliverAtHeader:    "Nats-Deliver-At",
+		DelayHeader:        "X-Delay",
+		ClockSkewTolerance: time.Second,
+	}
+	tests := []struct {
+		name      string
+		disabled  bool
+		headers   map[string]string
+		published time.Time // zero - now
+		want      time.Duration
+	}{
+		{name: "no headers"},
+		{name: "disabled", disabled: true, headers: map[string]string{"X-Delay": "1m"}},
+		{name: "deliver at rfc 3339", headers: map[string]string{"Nats-Deliver-At": now.Add(time.Minute).Format(time.RFC3339Nano)}, want: time.Minute},
+		{name: "deliver at unix seconds", headers: map[string]string{"Nats-Deliver-At": strconv.FormatInt(now.Add(time.Hour).Unix(), 10)}, want: time.Hour},
+		{name: "deliver at in the past", headers: map[string]string{"Nats-Deliver-At": now.Add(-time.Minute).Format(time.RFC3339)}},
+		{name: "deliver at within clock skew", headers: map[string]string{"Nats-Deliver-At": now.Add(500 * time.Millisecond).Format(time.RFC3339Nano)}},
+		{name: "wrong deliver at", headers: map[string]string{"Nats-Deliver-At": "tomorrow"}},
+		{name: "deliver at wins over delay", headers: map[string]string{"Nats-Deliver-At": now.Add(time.Minute).Format(time.RFC3339Nano), "X-Delay": "1h"}, want: time.Minute},
+		{name: "delay duration", headers: map[string]string{"X-Delay": "30s"}, want: 30 * time.Second},
+		{name: "delay seconds", headers: map[string]string{"X-Delay": "30"}, want: 30 * time.Second},
+		{name: "delay since publishing", headers: map[string]string{"X-Delay": "1m"}, published: now.Add(-50 * time.Second), want: 10 * time.Second},
+		{name: "delay elapsed", headers: map[string]string{"X-Delay": "1m"}, published: now.Add(-time.Hour)},
+		{name: "wrong delay", headers: map[string]string{"X-Delay": "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cfg
+			c.DelayedMessages = !tt.disabled
+			conn := newTestConnector(c, nil)
+			msg := newFakeMsg("{}")
+			msg.meta.Timestamp = now
+			if !tt.published.IsZero() {
+				msg.meta.Timestamp = tt.published
+			}
+			for k, v := range tt.headers {
+				msg.headers.Set(k, v)
+			}
+
+			got := conn.dueIn(msg)
+			if tt.want == 0 {
+				if got != 0 {
+					t.Errorf("due in %v, want now", got)
+				}
+				return
+			}
+			// Seconds of the unix timestamps are truncated.
+			if got <= tt.want-2*time.Second || got > tt.want {
+				t.Errorf("due in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleDeferred(t *testing.T) {
+	conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+		DelayedMessages: true,
+		DelayHeader:     "X-Delay",
+	}, nil)
+	msg := newFakeMsg("{}").withHeader("X-Delay", "1m")
+
+	o := conn.handle(context.Background(), msg)
+	if o != OutcomeDeferred {
+		t.Fatalf("outcome = %v, want %v", o, OutcomeDeferred)
+	}
+	if result := conn.settle(context.Background(), msg, o); result != "deferred" {
+		t.Errorf("result = %q, want deferred", result)
+	}
+	if got := msg.settles(); !slices.Equal(got, []string{"nak_delay"}) {
+		t.Errorf("settles = %v, want nak_delay", got)
+	}
+}
@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// observeDelivery records redeliveries and the backlog of the consumer at the moment the message is received.
+// The returned message is tracked as unacked until it is settled.
+func (conn *Connector) observeDelivery(msg Message) Message {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return msg
+	}
+
+	if meta.NumDelivered > 1 {
+		conn.metrics.redeliveries(conn.metrics.subjects.Value(msg.Subject()))
+		conn.stats.redeliveries.Add(1)
+	}
+	conn.metrics.deliveryCount.Observe(float64(meta.NumDelivered))
+	conn.metrics.messageAge.Observe(time.Since(meta.Timestamp).Seconds())
+	conn.metrics.backlogPending.Set(float64(meta.NumPending))
+
+	if conn.unacked == nil {
+		return msg
+	}
+	conn.unacked.add(meta.Sequence.Stream, meta.Timestamp, conn.connectordata.AckWait)
+	return unackedMsg{Message: msg, seq: meta.Sequence.Stream, ackWait: conn.connectordata.AckWait, unacked: conn.unacked}
+}
+
+// unacked tracks the stream timestamps of the received messages until they are settled, for the backlog age.
+// A message which is not settled (e.g. left for the redelivery) or is nacked with a delay is tracked until
+// it is expected to be redelivered.
+type unacked struct {
+	mx       sync.Mutex
+	messages map[uint64]unackedEntry // by stream sequence
+}
+
+type unackedEntry struct {
+	timestamp time.Time // stored in the stream
+	expires   time.Time // expected redelivery
+}
+
+func newUnacked() *unacked {
+	return &unacked{messages: map[uint64]unackedEntry{}} //nolint:exhaustruct // zero mutex
+}
+
+func (u *unacked) add(seq uint64, timestamp time.Time, redeliverIn time.Duration) {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	u.messages[seq] = unackedEntry{timestamp: timestamp, expires: time.Now().Add(redeliverIn)}
+}
+
+// extend sets the expected redelivery of the tracked message.
+func (u *unacked) extend(seq uint64, redeliverIn time.Duration) {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	if e, ok := u.messages[seq]; ok {
+		e.expires = time.Now().Add(redeliverIn)
+		u.messages[seq] = e
+	}
+}
+
+func (u *unacked) remove(seq uint64) {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	delete(u.messages, seq)
+}
+
+// age returns the age of the oldest unacked message in seconds, 0 if there are none.
+func (u *unacked) age(now time.Time) float64 {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	var oldest time.Time
+	for seq, e := range u.messages {
+		if !e.expires.After(now) {
+			delete(u.messages, seq)
+			continue
+		}
+		if oldest.IsZero() || e.timestamp.Before(oldest) {
+			oldest = e.timestamp
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return now.Sub(oldest).Seconds()
+}
+
+// unackedMsg stops tracking the message when it is settled.
+type unackedMsg struct {
+	Message
+	seq     uint64
+	ackWait time.Duration
+	unacked *unacked
+}
+
+func (m unackedMsg) Ack() error {
+	m.unacked.remove(m.seq)
+	return m.Message.Ack() //nolint:wrapcheck // the wrapped message
+}
+
+func (m unackedMsg) DoubleAck(ctx context.Context) error {
+	m.unacked.remove(m.seq)
+	return m.Message.DoubleAck(ctx) //nolint:wrapcheck // the wrapped message
+}
+
+func (m unackedMsg) Nak() error {
+	m.unacked.remove(m.seq)
+	return m.Message.Nak() //nolint:wrapcheck // the wrapped message
+}
+
+func (m unackedMsg) NakWithDelay(delay time.Duration) error {
+	m.unacked.extend(m.seq, delay)
+	return m.Message.NakWithDelay(delay) //nolint:wrapcheck // the wrapped message
+}
+
+func (m unackedMsg) Term() error {
+	m.unacked.remove(m.seq)
+	return m.Message.Term() //nolint:wrapcheck // the wrapped message
+}
+
+func (m unackedMsg) InProgress() error {
+	m.unacked.extend(m.seq, m.ackWait)
+	return m.Message.InProgress() //nolint:wrapcheck // the wrapped message
+}
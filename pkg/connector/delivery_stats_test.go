@@ -0,0 +1,57 @@
+package connector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBacklogAge(t *testing.T) {
+	now := time.Now()
+	msg := func(seq uint64, age time.Duration) *fakeMsg {
+		m := newFakeMsg("{}")
+		m.meta.Sequence.Stream = seq
+		m.meta.Timestamp = now.Add(-age)
+		return m
+	}
+
+	tests := []struct {
+		name   string
+		settle func(old, recent Message)
+		want   time.Duration
+	}{
+		{name: "oldest unacked", settle: func(Message, Message) {}, want: time.Minute},
+		{name: "oldest acked", settle: func(old, _ Message) { _ = old.Ack() }, want: time.Second},
+		{name: "oldest terminated", settle: func(old, _ Message) { _ = old.Term() }, want: time.Second},
+		{name: "oldest nacked", settle: func(old, _ Message) { _ = old.Nak() }, want: time.Second},
+		{name: "oldest nacked with delay", settle: func(old, _ Message) { _ = old.NakWithDelay(time.Hour) }, want: time.Minute},
+		{name: "all acked", settle: func(old, recent Message) { _, _ = old.Ack(), recent.Ack() }, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{AckWait: time.Hour}, nil) //nolint:exhaustruct // test config
+			conn.unacked = newUnacked()
+
+			old := conn.observeDelivery(msg(1, time.Minute))
+			recent := conn.observeDelivery(msg(2, time.Second))
+			tt.settle(old, recent)
+
+			if age := conn.unacked.age(now); age != tt.want.Seconds() {
+				t.Errorf("backlog age = %vs, want %v", age, tt.want)
+			}
+		})
+	}
+}
+
+func TestBacklogAgeAckWaitExpired(t *testing.T) {
+	conn := newTestConnector(Config{AckWait: time.Millisecond}, nil) //nolint:exhaustruct // test config
+	conn.unacked = newUnacked()
+
+	m := newFakeMsg("{}")
+	m.meta.Timestamp = time.Now().Add(-time.Minute)
+	conn.observeDelivery(m)
+
+	// The message left for the redelivery is not tracked after AckWait.
+	if age := conn.unacked.age(time.Now().Add(time.Second)); age != 0 {
+		t.Errorf("backlog age = %vs, want 0", age)
+	}
+}
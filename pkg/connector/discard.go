@@ -0,0 +1,54 @@
+package connector
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StatusCodes is a list of HTTP status codes.
+type StatusCodes []int
+
+// SetString parses status codes separated by comma.
+func (c *StatusCodes) SetString(s string) error {
+	var codes StatusCodes
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("wrong status code %q: %w", v, err)
+		}
+		codes = append(codes, code)
+	}
+	*c = codes
+	return nil
+}
+
+// discard reports whether the response should not be published according to DISCARD_STATUS, DISCARD_EMPTY and DISCARD_RULE.
+// The message of a discarded response is acked.
+func (conn *Connector) discard(msg Message, status int, body []byte, hdr http.Header) bool {
+	cfg := conn.connectordata
+
+	var reason string
+	switch {
+	case slices.Contains(cfg.DiscardStatus, status):
+		reason = "status"
+	case cfg.DiscardEmpty && len(bytes.TrimSpace(body)) == 0:
+		reason = "empty"
+	case cfg.DiscardRule.Enabled() && cfg.DiscardRule.Match(body, hdr):
+		reason = "rule"
+	default:
+		return false
+	}
+
+	conn.metrics.discardedResponses(reason)
+	conn.logger.Info("Response is discarded", slog.String("subject", msg.Subject()), slog.Int("status", status), slog.String("reason", reason))
+	return true
+}
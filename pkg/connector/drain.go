@@ -0,0 +1,119 @@
+package connector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// outboxFlushTimeout limits the wait for the buffered publishes to be flushed to the NATS server on shutdown.
+const outboxFlushTimeout = 5 * time.Second
+
+// ShutdownReport summarizes the run on graceful shutdown, so operators can verify clean deploys.
+type ShutdownReport struct {
+	Uptime      string            `json:"uptime"`
+	Processed   uint64            `json:"processed"`
+	Results     map[string]uint64 `json:"results"`
+	Errors      uint64            `json:"errors"`       // errors sent to the error topic
+	InFlight    int64             `json:"in_flight"`    // messages in flight when the consumption is stopped
+	PendingJobs int               `json:"pending_jobs"` // accepted async jobs when the consumption is stopped
+	Nacked      int64             `json:"nacked"`       // messages and jobs nacked back at DRAIN_TIMEOUT
+	OutboxBytes int               `json:"outbox_bytes"` // publishes buffered when the drain is over
+	OutboxError string            `json:"outbox_error,omitempty"`
+}
+
+// drain waits for in-flight messages and accepted async jobs up to DRAIN_TIMEOUT after the consumption is stopped.
+// The messages waiting in GROUP_KEY or DEBOUNCE_KEY windows are nacked at once, they are not processed.
+// The messages still in flight after the deadline are canceled with cancelProcessing and nacked,
+// so another replica gets them immediately instead of after AckWait. The shutdown report is logged at the end.
+func (conn *Connector) drain(cancelProcessing context.CancelFunc) {
+	defer conn.markDrained()
+
+	report := ShutdownReport{ //nolint:exhaustruct // filled in reportShutdown
+		InFlight:    conn.stats.inFlight.Load(),
+		PendingJobs: len(conn.acceptedJobs()),
+	}
+	defer conn.reportShutdown(&report)
+
+	if conn.groups != nil {
+		report.Nacked += int64(conn.groups.nakAll())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.wait()
+		conn.callbacks.wg.Wait()
+	}()
+
+	if report.InFlight > 0 || report.PendingJobs > 0 {
+		conn.logger.Info("Draining in-flight messages",
+			slog.Int64("in_flight", report.InFlight),
+			slog.Int("pending_jobs", report.PendingJobs),
+			slog.Duration("deadline", conn.connectordata.DrainTimeout))
+	}
+
+	timer := time.NewTimer(conn.connectordata.DrainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	inFlight := conn.stats.inFlight.Load()
+	conn.logger.Warn("Drain deadline is exceeded - in-flight messages are nacked", slog.Int64("in_flight", inFlight))
+	cancelProcessing()
+	report.Nacked += inFlight + int64(conn.nakCallbacks())
+	<-done
+}
+
+// reportShutdown flushes the buffered publishes, completes the shutdown report and logs it.
+// The report is also served by the status endpoint and the shutdown metrics until the process exits.
+func (conn *Connector) reportShutdown(report *ShutdownReport) {
+	if conn.nc != nil {
+		report.OutboxBytes, _ = conn.nc.Buffered()
+		if err := conn.nc.FlushTimeout(outboxFlushTimeout); err != nil {
+			report.OutboxError = err.Error()
+		}
+	}
+
+	results, errs := conn.stats.counts()
+	report.Uptime = time.Since(conn.stats.startedAt).Round(time.Second).String()
+	report.Results = results
+	report.Errors = errs
+	for result, n := range results {
+		if result != "canceled" {
+			report.Processed += n
+		}
+	}
+	conn.shutdownReport.Store(report)
+
+	conn.metrics.shutdownMessages.WithLabelValues("processed").Set(float64(report.Processed))
+	conn.metrics.shutdownMessages.WithLabelValues("in_flight").Set(float64(report.InFlight))
+	conn.metrics.shutdownMessages.WithLabelValues("pending_jobs").Set(float64(report.PendingJobs))
+	conn.metrics.shutdownMessages.WithLabelValues("nacked").Set(float64(report.Nacked))
+
+	log := conn.logger.With(slog.Any("report", report))
+	if report.Nacked > 0 || report.OutboxError != "" {
+		log.Warn("Shutdown report - the shutdown is not clean")
+		return
+	}
+	log.Info("Shutdown report")
+}
+
+// markDrained releases WaitDrained. It is called when the drain is over, or when the consumption ends without it.
+func (conn *Connector) markDrained() {
+	conn.drainedOnce.Do(func() { close(conn.drained) })
+}
+
+// WaitDrained blocks until in-flight messages are drained after the consumption is stopped, or the context is done.
+func (conn *Connector) WaitDrained(ctx context.Context) error {
+	select {
+	case <-conn.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // shutdown context error
+	}
+}
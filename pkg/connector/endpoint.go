@@ -0,0 +1,121 @@
+package connector
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/jsonpointer"
+)
+
+// expandEndpoint replaces '{/json/pointer}' placeholders of the endpoint with the path escaped fields of the JSON message.
+func expandEndpoint(endpoint string, data []byte) (string, error) {
+	if !strings.Contains(endpoint, "{/") {
+		return endpoint, nil
+	}
+
+	var doc any
+	if err := decodeJSON(data, &doc); err != nil {
+		return "", fmt.Errorf("parse message as JSON to expand http endpoint: %w", err)
+	}
+
+	var err error
+	expanded := keyPlaceholder.ReplaceAllStringFunc(endpoint, func(m string) string {
+		p, parseErr := jsonpointer.Parse(m[1 : len(m)-1])
+		if parseErr != nil {
+			err = parseErr
+			return ""
+		}
+		v, getErr := p.Get(doc)
+		if getErr != nil {
+			err = getErr
+			return ""
+		}
+		s, ok := v.(string)
+		if !ok {
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		return url.PathEscape(s)
+	})
+	if err != nil {
+		return "", fmt.Errorf("expand http endpoint %q: %w", endpoint, err)
+	}
+	return expanded, nil
+}
+
+// getCache caches responses of GET invocations by URL in memory, the least recently used responses are evicted.
+type getCache struct {
+	ttl  time.Duration
+	size int
+
+	mx      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type getCacheEntry struct {
+	url      string
+	response cachedResponse
+	expires  time.Time
+}
+
+func newGetCache(ttl time.Duration, size int) *getCache {
+	return &getCache{ttl: ttl, size: size, lru: list.New(), entries: map[string]*list.Element{}} //nolint:exhaustruct // zero mutex
+}
+
+func (c *getCache) get(url string) (cachedResponse, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	el, ok := c.entries[url]
+	if !ok {
+		return cachedResponse{}, false //nolint:exhaustruct // no response
+	}
+	e := el.Value.(*getCacheEntry) //nolint:forcetypeassert // only entries are stored
+	if time.Now().After(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, url)
+		return cachedResponse{}, false //nolint:exhaustruct // no response
+	}
+	c.lru.MoveToFront(el)
+	return e.response, true
+}
+
+func (c *getCache) put(url string, response cachedResponse) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if el, ok := c.entries[url]; ok {
+		c.lru.Remove(el)
+	}
+	c.entries[url] = c.lru.PushFront(&getCacheEntry{url: url, response: response, expires: time.Now().Add(c.ttl)})
+
+	for c.lru.Len() > c.size {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*getCacheEntry).url) //nolint:forcetypeassert // only entries are stored
+	}
+}
+
+// invokeGetCached returns the cached response of the URL, or invokes the endpoint and caches the successful response for HTTP_CACHE_TTL.
+func (conn *Connector) invokeGetCached(ctx context.Context, cfg Config, data []byte, headers http.Header) ([]byte, int, http.Header, error) {
+	if cached, ok := conn.getCache.get(cfg.HTTPEndpoint); ok {
+		conn.metrics.responseCache("hit")
+		return cached.Body, cached.Status, cached.Header.Clone(), nil
+	}
+	conn.metrics.responseCache("miss")
+
+	body, status, respHeader, err := conn.invoke(ctx, cfg, data, headers)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	conn.getCache.put(cfg.HTTPEndpoint, cachedResponse{Status: status, Header: respHeader.Clone(), Body: body})
+	return body, status, respHeader, nil
+}
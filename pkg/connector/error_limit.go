@@ -0,0 +1,96 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrorSummary is published to the error topic instead of the errors suppressed by ERROR_RATE_LIMIT
+// within ERROR_SUMMARY_INTERVAL.
+type ErrorSummary struct {
+	Source      string    `json:"source"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	Published   uint64    `json:"published"`
+	Suppressed  uint64    `json:"suppressed"`
+	Samples     []string  `json:"samples"` // the first suppressed errors
+}
+
+// errorLimiter publishes up to ERROR_RATE_LIMIT errors per ERROR_SUMMARY_INTERVAL, the rest are summarized,
+// so the error stream doesn't balloon during outages.
+type errorLimiter struct {
+	limit   uint64
+	samples int
+
+	mu      sync.Mutex
+	summary ErrorSummary
+}
+
+func newErrorLimiter(cfg Config) *errorLimiter {
+	l := &errorLimiter{limit: cfg.ErrorRateLimit, samples: cfg.ErrorSummarySamples} //nolint:exhaustruct // empty window
+	l.reset(time.Now().UTC())
+	return l
+}
+
+// allow reports whether the error is published, otherwise it is added to the summary.
+func (l *errorLimiter) allow(message string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.summary.Published < l.limit {
+		l.summary.Published++
+		return true
+	}
+	l.summary.Suppressed++
+	if len(l.summary.Samples) < l.samples {
+		l.summary.Samples = append(l.summary.Samples, message)
+	}
+	return false
+}
+
+// reset returns the summary of the window ended at now and starts a new window.
+func (l *errorLimiter) reset(now time.Time) ErrorSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := l.summary
+	s.WindowEnd = now
+	l.summary = ErrorSummary{Source: s.Source, WindowStart: now} //nolint:exhaustruct // empty window
+	return s
+}
+
+// RunErrorSummary publishes the summary of the errors suppressed by ERROR_RATE_LIMIT to the error topic
+// every ERROR_SUMMARY_INTERVAL until the context is done, then the last summary is published.
+// Windows without suppressed errors are not published.
+func (conn *Connector) RunErrorSummary(ctx context.Context) {
+	ticker := time.NewTicker(conn.connectordata.ErrorSummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		var done bool
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+		}
+
+		summary := conn.errorLimiter.reset(time.Now().UTC())
+		if summary.Suppressed > 0 {
+			summary.Source = conn.connectordata.SourceName
+			data, err := json.Marshal(summary)
+			if err != nil {
+				conn.logger.Error("Failed to marshal error summary", slog.Any("error", err))
+			} else {
+				conn.metrics.errorsSuppressed.Add(float64(summary.Suppressed))
+				conn.logger.Warn("Errors are suppressed by error rate limit", slog.Uint64("suppressed", summary.Suppressed))
+				conn.publishError(ctx, string(data))
+			}
+		}
+		if done {
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event types of EventRecorder.
+const (
+	EventNormal  = "Normal"
+	EventWarning = "Warning"
+)
+
+// EventRecorder reports significant state changes of the connector, e.g. as Kubernetes Events on the pod.
+type EventRecorder interface {
+	Event(ctx context.Context, eventType, reason, message string) error
+}
+
+// SetEventRecorder sets the recorder of the state change events.
+func (conn *Connector) SetEventRecorder(r EventRecorder) {
+	conn.events = r
+}
+
+// event records the event in background, so a slow API server doesn't delay the processing.
+func (conn *Connector) event(eventType, reason, message string) {
+	if conn.events == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := conn.events.Event(ctx, eventType, reason, message)
+		if err != nil {
+			conn.metrics.events("error")
+			conn.logger.Warn("Failed to record event", slog.String("reason", reason), slog.Any("error", err))
+			return
+		}
+		conn.metrics.events("ok")
+	}()
+}
+
+// errorRate counts errors sent to the error topic per minute and reports when K8S_EVENTS_ERROR_THRESHOLD is reached,
+// once per minute.
+type errorRate struct {
+	threshold int
+
+	mu     sync.Mutex
+	window time.Time
+	count  int
+}
+
+func (r *errorRate) add(now time.Time) bool {
+	if r.threshold <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.window) >= time.Minute {
+		r.window, r.count = now, 0
+	}
+	r.count++
+	return r.count == r.threshold
+}
+
+// countError records an event when the error threshold is exceeded.
+func (conn *Connector) countError() {
+	if conn.events != nil && conn.errorRate.add(time.Now()) {
+		conn.event(EventWarning, "ErrorThresholdExceeded",
+			fmt.Sprintf("%d messages were sent to the error topic within a minute", conn.errorRate.threshold))
+	}
+}
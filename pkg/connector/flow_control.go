@@ -0,0 +1,73 @@
+package connector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// RunResponseFlowControl polls the state of the response stream every RESPONSE_FLOW_CONTROL_INTERVAL until the context is done.
+// The consumption is paused (see pauseReason) while the usage of the stream with DiscardNew policy is above
+// RESPONSE_FLOW_CONTROL_THRESHOLD percent of its limits: the publishes to such stream fail when it is full.
+func (conn *Connector) RunResponseFlowControl(ctx context.Context) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("topic", cfg.ResponseTopic))
+
+	ticker := time.NewTicker(cfg.ResponseFlowControlInterval)
+	defer ticker.Stop()
+
+	for {
+		full, err := conn.responseStreamFull(ctx)
+		if err != nil {
+			log.Warn("Failed to get response stream info for flow control", slog.Any("error", err))
+		} else {
+			if full == conn.responseCapacity.IsOpen() {
+				if full {
+					log.Warn("Response stream is full - consumption is paused")
+				} else {
+					log.Info("Response stream has capacity - consumption is resumed")
+				}
+			}
+			conn.responseCapacity.Set(!full)
+			if full {
+				conn.metrics.responseStreamFull.Set(1)
+			} else {
+				conn.metrics.responseStreamFull.Set(0)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (conn *Connector) responseStreamFull(ctx context.Context) (bool, error) {
+	name, err := conn.jsContext.StreamNameBySubject(ctx, conn.connectordata.ResponseTopic)
+	if err != nil {
+		return false, err //nolint:wrapcheck // caller logs the error
+	}
+
+	stream, err := conn.jsContext.Stream(ctx, name)
+	if err != nil {
+		return false, err //nolint:wrapcheck // caller logs the error
+	}
+
+	info := stream.CachedInfo()
+	if info.Config.Discard != jetstream.DiscardNew {
+		return false, nil // old messages are discarded to store the new ones, the publishes don't fail
+	}
+	threshold := float64(conn.connectordata.ResponseFlowControlThreshold) / 100
+
+	if info.Config.MaxMsgs > 0 && float64(info.State.Msgs) >= float64(info.Config.MaxMsgs)*threshold {
+		return true, nil
+	}
+	if info.Config.MaxBytes > 0 && float64(info.State.Bytes) >= float64(info.Config.MaxBytes)*threshold {
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,150 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// errObjectStore is wrapped by failures to get an object from the object store, which are retried
+// unlike missing objects and wrong payloads.
+var errObjectStore = errors.New("object store failure")
+
+// BodyEncoding defines how the JSON message is encoded as the HTTP body in 'http' invoke protocol.
+type BodyEncoding string
+
+const (
+	// BodyRaw sends the message as is.
+	BodyRaw BodyEncoding = "raw"
+	// BodyForm sends the fields of the JSON message as application/x-www-form-urlencoded form.
+	BodyForm BodyEncoding = "form"
+	// BodyMultipart sends the fields of the JSON message as multipart/form-data parts.
+	BodyMultipart BodyEncoding = "multipart"
+)
+
+func (e *BodyEncoding) SetString(s string) error {
+	switch encoding := BodyEncoding(strings.ToLower(s)); encoding {
+	case BodyRaw, BodyForm, BodyMultipart:
+		*e = encoding
+	default:
+		return fmt.Errorf("wrong body encoding: only 'raw|form|multipart' are accepted")
+	}
+	return nil
+}
+
+// objectRefField is the key of the JSON object which refers to an Object Store object: {"$object": "<name>"}.
+const objectRefField = "$object"
+
+// formFields returns the fields of the JSON object message in a stable order. String values are used as is,
+// arrays of strings and numbers become repeated fields, other values are encoded as JSON.
+func formFields(data []byte) ([]string, map[string]any, error) {
+	var obj map[string]any
+	if err := decodeJSON(data, &obj); err != nil {
+		return nil, nil, fmt.Errorf("message is not a JSON object to be encoded as form: %w", err)
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, obj, nil
+}
+
+func formValues(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return []string{jsonString(v)}
+			}
+			values = append(values, formValues(item)...)
+		}
+		return values
+	case nil:
+		return []string{""}
+	}
+	return []string{jsonString(v)}
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// encodeForm encodes the JSON message as application/x-www-form-urlencoded form.
+func encodeForm(data []byte) ([]byte, string, error) {
+	names, obj, err := formFields(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	form := url.Values{}
+	for _, name := range names {
+		form[name] = formValues(obj[name])
+	}
+	return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+// encodeMultipart encodes the JSON message as multipart/form-data. Fields with {"$object": "<name>"} value
+// become file parts with the content of the object from OBJECT_STORE_BUCKET.
+func (conn *Connector) encodeMultipart(data []byte) ([]byte, string, error) {
+	names, obj, err := formFields(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, name := range names {
+		if ref, ok := objectRef(obj[name]); ok {
+			if conn.objStore == nil {
+				return nil, "", fmt.Errorf("field %q refers to object %q but object store bucket is not set", name, ref)
+			}
+			content, err := conn.objStore.GetBytes(ref)
+			if errors.Is(err, nats.ErrObjectNotFound) {
+				return nil, "", fmt.Errorf("get object %q from object store %q: %w", ref, conn.connectordata.ObjectStoreBucket, err)
+			}
+			if err != nil {
+				return nil, "", fmt.Errorf("get object %q from object store %q: %w: %w", ref, conn.connectordata.ObjectStoreBucket, errObjectStore, err)
+			}
+			part, err := w.CreateFormFile(name, ref)
+			if err != nil {
+				return nil, "", fmt.Errorf("create file part %q: %w", name, err)
+			}
+			if _, err := part.Write(content); err != nil {
+				return nil, "", fmt.Errorf("write file part %q: %w", name, err)
+			}
+			continue
+		}
+
+		for _, value := range formValues(obj[name]) {
+			if err := w.WriteField(name, value); err != nil {
+				return nil, "", fmt.Errorf("write field %q: %w", name, err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("close multipart body: %w", err)
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func objectRef(v any) (string, bool) {
+	obj, ok := v.(map[string]any)
+	if !ok || len(obj) != 1 {
+		return "", false
+	}
+	ref, ok := obj[objectRefField].(string)
+	return ref, ok
+}
@@ -0,0 +1,237 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	HeaderGroupKey  = "Nats-Group-Key"
+	HeaderGroupSize = "Nats-Group-Size"
+)
+
+// groups collects messages sharing GROUP_KEY for GROUP_WINDOW (or up to GROUP_MAX_SIZE messages)
+// and dispatches every group as one message. In conflate mode (DEBOUNCE_KEY) only the latest message of the key
+// (of the highest stream sequence) within DEBOUNCE_WINDOW is dispatched, the superseded ones are acked.
+type groups struct {
+	conn     *Connector
+	key      Key
+	window   time.Duration
+	maxSize  int
+	conflate bool
+
+	mu      sync.Mutex
+	pending map[string]*pendingGroup
+	flushes sync.WaitGroup
+}
+
+type pendingGroup struct {
+	ctx   context.Context //nolint:containedctx // processing context of the first message
+	key   string
+	msgs  []Message
+	timer *time.Timer
+}
+
+func newGroups(conn *Connector) *groups {
+	cfg := conn.connectordata
+	gs := &groups{conn: conn, pending: map[string]*pendingGroup{}} //nolint:exhaustruct // zero mutex and wait group
+	if cfg.DebounceKey.Enabled() {
+		gs.key, gs.window, gs.conflate = cfg.DebounceKey, cfg.DebounceWindow, true
+	} else {
+		gs.key, gs.window, gs.maxSize = cfg.GroupKey, cfg.GroupWindow, cfg.GroupMaxSize
+	}
+	return gs
+}
+
+// add adds the message to the group of its key. Messages without the key are dispatched at once.
+func (gs *groups) add(ctx context.Context, msg Message) {
+	key, ok := gs.key.Value(msg.Data(), http.Header(msg.Headers()))
+	if !ok {
+		gs.conn.start(ctx, msg)
+		return
+	}
+
+	gs.mu.Lock()
+	g, ok := gs.pending[key]
+	if !ok {
+		g = &pendingGroup{ctx: ctx, key: key, msgs: nil, timer: nil}
+		g.timer = time.AfterFunc(gs.window, func() { gs.flush(g) })
+		gs.pending[key] = g
+	}
+	if gs.conflate && len(g.msgs) > 0 {
+		// Redelivered messages can arrive after newer ones, so the latest is the one of the highest stream sequence.
+		superseded := msg
+		if streamSeq(msg) > streamSeq(g.msgs[0]) {
+			superseded, g.msgs[0] = g.msgs[0], msg
+		}
+		gs.mu.Unlock()
+		gs.supersede(key, superseded)
+		return
+	}
+	g.msgs = append(g.msgs, msg)
+	full := !gs.conflate && len(g.msgs) >= gs.maxSize
+	if full {
+		g.timer.Stop()
+		delete(gs.pending, key)
+		gs.flushes.Add(1)
+	}
+	gs.mu.Unlock()
+
+	if full {
+		defer gs.flushes.Done()
+		gs.dispatch(g)
+	}
+}
+
+// flush dispatches the group when its window is over, unless it was dispatched as full.
+func (gs *groups) flush(g *pendingGroup) {
+	gs.mu.Lock()
+	if gs.pending[g.key] != g {
+		gs.mu.Unlock()
+		return
+	}
+	delete(gs.pending, g.key)
+	gs.flushes.Add(1)
+	gs.mu.Unlock()
+
+	defer gs.flushes.Done()
+	gs.dispatch(g)
+}
+
+// nakAll nacks the members of the pending groups without waiting for their windows, e.g. on drain,
+// so another replica gets them at once instead of the shutdown waiting for their processing.
+// It waits until the groups being flushed are dispatched and returns the number of nacked messages.
+func (gs *groups) nakAll() int {
+	gs.mu.Lock()
+	pending := gs.pending
+	gs.pending = map[string]*pendingGroup{}
+	for _, g := range pending {
+		g.timer.Stop()
+	}
+	gs.mu.Unlock()
+
+	var nacked int
+	for _, g := range pending {
+		for _, m := range g.msgs {
+			if err := m.Nak(); err != nil {
+				gs.conn.logger.Error("failed to nak pending group message", slog.String("group_key", g.key), slog.Any("error", err))
+				continue
+			}
+			nacked++
+		}
+	}
+	gs.flushes.Wait()
+	return nacked
+}
+
+// streamSeq returns the stream sequence of the message, 0 if its metadata cannot be parsed.
+func streamSeq(msg Message) uint64 {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return 0
+	}
+	return meta.Sequence.Stream
+}
+
+// supersede acks the message replaced by a newer one of the same key.
+func (gs *groups) supersede(key string, msg Message) {
+	gs.conn.metrics.debouncedMessages.Inc()
+	if err := msg.Ack(); err != nil {
+		gs.conn.logger.Error("Failed to ack superseded message", slog.String("debounce_key", key), slog.Any("error", err))
+		return
+	}
+	gs.conn.logger.Debug("Message is superseded by a newer one", slog.String("debounce_key", key))
+}
+
+func (gs *groups) dispatch(g *pendingGroup) {
+	log := gs.conn.logger.With(slog.String("group_key", g.key), slog.Int("group_size", len(g.msgs)))
+	if !gs.conflate {
+		gs.conn.metrics.groupSize.Observe(float64(len(g.msgs)))
+	}
+
+	if len(g.msgs) == 1 {
+		gs.conn.start(g.ctx, g.msgs[0])
+		return
+	}
+
+	msg := newGroupMsg(g.key, g.msgs)
+	// The members waited for the window, so the processing gets the full AckWait.
+	if err := msg.InProgress(); err != nil {
+		log.Warn("Failed to extend ack wait of grouped messages", slog.Any("error", err))
+	}
+	log.Debug("Message group is dispatched")
+	gs.conn.start(g.ctx, msg)
+}
+
+// groupMsg is a group of messages processed as one message: the data is a JSON array of the member messages
+// (messages which are not JSON are added as JSON strings), the headers are the headers of the first member
+// with the group key and size. All members are settled with the same outcome.
+type groupMsg struct {
+	msgs    []Message
+	data    []byte
+	headers nats.Header
+}
+
+func newGroupMsg(key string, msgs []Message) *groupMsg {
+	items := make([]json.RawMessage, 0, len(msgs))
+	for _, m := range msgs {
+		data := m.Data()
+		if !json.Valid(data) {
+			data, _ = json.Marshal(string(data)) //nolint:errchkjson // string is always encoded
+		}
+		items = append(items, data)
+	}
+	data, _ := json.Marshal(items) //nolint:errchkjson // valid JSON items
+
+	hdr := nats.Header{}
+	for k, v := range msgs[0].Headers() {
+		hdr[k] = append([]string(nil), v...)
+	}
+	hdr.Set(HeaderGroupKey, key)
+	hdr.Set(HeaderGroupSize, strconv.Itoa(len(msgs)))
+
+	return &groupMsg{msgs: msgs, data: data, headers: hdr}
+}
+
+func (g *groupMsg) Data() []byte         { return g.data }
+func (g *groupMsg) Headers() nats.Header { return g.headers }
+func (g *groupMsg) Subject() string      { return g.msgs[0].Subject() }
+
+// Metadata returns the metadata of the first (the oldest) member.
+func (g *groupMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return g.msgs[0].Metadata() //nolint:wrapcheck // member error
+}
+
+func (g *groupMsg) each(fn func(Message) error) error {
+	var errs []error
+	for _, m := range g.msgs {
+		if err := fn(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (g *groupMsg) Ack() error { return g.each(Message.Ack) }
+
+func (g *groupMsg) DoubleAck(ctx context.Context) error {
+	return g.each(func(m Message) error { return m.DoubleAck(ctx) })
+}
+
+func (g *groupMsg) Nak() error { return g.each(Message.Nak) }
+
+func (g *groupMsg) NakWithDelay(delay time.Duration) error {
+	return g.each(func(m Message) error { return m.NakWithDelay(delay) })
+}
+
+func (g *groupMsg) InProgress() error { return g.each(Message.InProgress) }
+func (g *groupMsg) Term() error       { return g.each(Message.Term) }
@@ -0,0 +1,265 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dispatched records the messages dispatched to the handler.
+type dispatched struct {
+	outcome Outcome
+
+	mx   sync.Mutex
+	msgs []Message
+	ch   chan Message
+}
+
+func newDispatched(outcome Outcome) *dispatched {
+	return &dispatched{outcome: outcome, ch: make(chan Message, 10)} //nolint:exhaustruct // zero mutex and messages
+}
+
+func (d *dispatched) handle(_ context.Context, msg Message) Outcome {
+	d.mx.Lock()
+	d.msgs = append(d.msgs, msg)
+	d.mx.Unlock()
+	d.ch <- msg
+	return d.outcome
+}
+
+// next waits for the next dispatched message.
+func (d *dispatched) next(t *testing.T) Message {
+	t.Helper()
+
+	select {
+	case msg := <-d.ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not dispatched")
+		return nil
+	}
+}
+
+func (d *dispatched) count() int {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return len(d.msgs)
+}
+
+func newTestKey(t *testing.T, s string) Key {
+	t.Helper()
+
+	var k Key
+	if err := k.SetString(s); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// groupMembers returns the data of the members of the dispatched message.
+func groupMembers(t *testing.T, msg Message) []string {
+	t.Helper()
+
+	if msg.Headers().Get(HeaderGroupSize) == "" {
+		return []string{string(msg.Data())}
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(msg.Data(), &items); err != nil {
+		t.Fatalf("group data %s: %v", msg.Data(), err)
+	}
+	members := make([]string, 0, len(items))
+	for _, item := range items {
+		members = append(members, string(item))
+	}
+	return members
+}
+
+func TestGroupsDispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  time.Duration
+		maxSize int
+		msgs    []string
+		groups  [][]string // dispatched members in order
+	}{
+		{
+			name:    "window end",
+			window:  20 * time.Millisecond,
+			maxSize: 10,
+			msgs:    []string{`{"k":1,"n":1}`, `{"k":1,"n":2}`, `{"k":1,"n":3}`},
+			groups:  [][]string{{`{"k":1,"n":1}`, `{"k":1,"n":2}`, `{"k":1,"n":3}`}},
+		},
+		{
+			name:    "full group",
+			window:  time.Minute,
+			maxSize: 2,
+			msgs:    []string{`{"k":1,"n":1}`, `{"k":1,"n":2}`},
+			groups:  [][]string{{`{"k":1,"n":1}`, `{"k":1,"n":2}`}},
+		},
+		{
+			name:    "full group and the rest at window end",
+			window:  20 * time.Millisecond,
+			maxSize: 2,
+			msgs:    []string{`{"k":1,"n":1}`, `{"k":1,"n":2}`, `{"k":1,"n":3}`},
+			groups:  [][]string{{`{"k":1,"n":1}`, `{"k":1,"n":2}`}, {`{"k":1,"n":3}`}},
+		},
+		{
+			name:    "message without key",
+			window:  time.Minute,
+			maxSize: 10,
+			msgs:    []string{`{"n":1}`},
+			groups:  [][]string{{`{"n":1}`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDispatched(OutcomeAck)
+			conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+				Concurrent:   4,
+				GroupKey:     newTestKey(t, ".k"),
+				GroupWindow:  tt.window,
+				GroupMaxSize: tt.maxSize,
+			}, d.handle)
+			gs := newGroups(conn)
+
+			for _, data := range tt.msgs {
+				gs.add(context.Background(), newFakeMsg(data))
+			}
+			for i, want := range tt.groups {
+				msg := d.next(t)
+				if got := groupMembers(t, msg); !slices.Equal(got, want) {
+					t.Errorf("group %d: members = %v, want %v", i+1, got, want)
+				}
+				if len(want) > 1 && msg.Headers().Get(HeaderGroupKey) != "1" {
+					t.Errorf("group %d: key header = %q, want 1", i+1, msg.Headers().Get(HeaderGroupKey))
+				}
+			}
+			conn.wait()
+			if n := d.count(); n != len(tt.groups) {
+				t.Errorf("dispatched %d messages, want %d", n, len(tt.groups))
+			}
+		})
+	}
+}
+
+func TestGroupSettlesMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome Outcome
+		settles []string
+	}{
+		{name: "ack", outcome: OutcomeAck, settles: []string{"in_progress", "ack"}},
+		{name: "term", outcome: OutcomeTerm, settles: []string{"in_progress", "term"}},
+		{name: "redeliver", outcome: OutcomeRedeliver, settles: []string{"in_progress"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDispatched(tt.outcome)
+			conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+				GroupKey:     newTestKey(t, "header:Group"),
+				GroupWindow:  time.Minute,
+				GroupMaxSize: 3,
+			}, d.handle)
+			gs := newGroups(conn)
+
+			msgs := []*fakeMsg{
+				newFakeMsg(`{"n":1}`).withHeader("Group", "g"),
+				newFakeMsg("not json").withHeader("Group", "g"),
+				newFakeMsg(`{"n":3}`).withHeader("Group", "g"),
+			}
+			for _, m := range msgs {
+				gs.add(context.Background(), m)
+			}
+			msg := d.next(t)
+			conn.wait()
+
+			if got, want := groupMembers(t, msg), []string{`{"n":1}`, `"not json"`, `{"n":3}`}; !slices.Equal(got, want) {
+				t.Errorf("members = %v, want %v", got, want)
+			}
+			for i, m := range msgs {
+				if got := m.settles(); !slices.Equal(got, tt.settles) {
+					t.Errorf("member %d: settles = %v, want %v", i+1, got, tt.settles)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupsNakAll(t *testing.T) {
+	d := newDispatched(OutcomeAck)
+	conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+		GroupKey:     newTestKey(t, ".k"),
+		GroupWindow:  50 * time.Millisecond,
+		GroupMaxSize: 10,
+	}, d.handle)
+	gs := newGroups(conn)
+
+	msgs := []*fakeMsg{newFakeMsg(`{"k":1}`), newFakeMsg(`{"k":1}`), newFakeMsg(`{"k":2}`)}
+	for _, m := range msgs {
+		gs.add(context.Background(), m)
+	}
+
+	if n := gs.nakAll(); n != len(msgs) {
+		t.Errorf("nacked %d messages, want %d", n, len(msgs))
+	}
+	for i, m := range msgs {
+		if got := m.settles(); !slices.Equal(got, []string{"nak"}) {
+			t.Errorf("message %d: settles = %v, want nak", i+1, got)
+		}
+	}
+
+	// The windows of the nacked groups are over without dispatching them.
+	time.Sleep(100 * time.Millisecond)
+	conn.wait()
+	if n := d.count(); n != 0 {
+		t.Errorf("dispatched %d messages after drain, want 0", n)
+	}
+}
+
+func TestDebounceConflation(t *testing.T) {
+	d := newDispatched(OutcomeAck)
+	conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+		DebounceKey:    newTestKey(t, ".k"),
+		DebounceWindow: 20 * time.Millisecond,
+		GroupMaxSize:   1, // ignored by conflation
+	}, d.handle)
+	gs := newGroups(conn)
+
+	// The redelivered message of sequence 1 arrives after the newer ones.
+	msgs := map[uint64]*fakeMsg{}
+	for _, seq := range []uint64{2, 3, 1} {
+		m := newFakeMsg(fmt.Sprintf(`{"k":1,"seq":%d}`, seq))
+		m.meta.Sequence.Stream = seq
+		msgs[seq] = m
+		gs.add(context.Background(), m)
+	}
+	other := newFakeMsg(`{"k":2,"seq":4}`)
+	gs.add(context.Background(), other)
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		msg := d.next(t)
+		if msg.Headers().Get(HeaderGroupSize) != "" {
+			t.Errorf("conflated message %s is dispatched as a group", msg.Data())
+		}
+		got = append(got, string(msg.Data()))
+	}
+	conn.wait()
+
+	slices.Sort(got)
+	if want := []string{`{"k":1,"seq":3}`, `{"k":2,"seq":4}`}; !slices.Equal(got, want) {
+		t.Errorf("dispatched %v, want %v", got, want)
+	}
+	for seq, want := range map[uint64][]string{1: {"ack"}, 2: {"ack"}, 3: {"ack"}} {
+		if got := msgs[seq].settles(); !slices.Equal(got, want) {
+			t.Errorf("message of sequence %d: settles = %v, want %v", seq, got, want)
+		}
+	}
+	if n := d.count(); n != 2 {
+		t.Errorf("dispatched %d messages, want 2", n)
+	}
+}
@@ -0,0 +1,31 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrResponseLost is reported to the error topic with the response which failed to be published
+// after the message was acked with at_most_once guarantee.
+var ErrResponseLost = errors.New("response is not published after the message was acked")
+
+// Guarantee defines the order of the input message ack and the response publish.
+type Guarantee string
+
+const (
+	// GuaranteeAtLeastOnce acks the message only after the response publish is acked by the response stream.
+	GuaranteeAtLeastOnce Guarantee = "at_least_once"
+	// GuaranteeAtMostOnce publishes the response only after the message ack is confirmed by the server.
+	GuaranteeAtMostOnce Guarantee = "at_most_once"
+)
+
+func (g *Guarantee) SetString(s string) error {
+	switch guarantee := Guarantee(strings.ToLower(s)); guarantee {
+	case GuaranteeAtLeastOnce, GuaranteeAtMostOnce:
+		*g = guarantee
+	default:
+		return fmt.Errorf("wrong guarantee: only 'at_least_once|at_most_once' are accepted")
+	}
+	return nil
+}
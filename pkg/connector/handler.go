@@ -0,0 +1,361 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/codec"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/encryption"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/largemsg"
+	"github.com/nats-io/nats.go"
+)
+
+// handle invokes the HTTP endpoint with the message and publishes the response.
+// Errors are reported inside, the returned outcome tells how the message should be settled.
+func (conn *Connector) handle(ctx context.Context, msg Message) Outcome {
+	log := conn.log(ctx)
+
+	if conn.dueIn(msg) > 0 {
+		return OutcomeDeferred
+	}
+
+	if o := conn.expired(ctx, msg); o != OutcomeAck {
+		return o
+	}
+
+	if conn.replayed(ctx, msg) {
+		return OutcomeReplayed
+	}
+
+	data, err := conn.messageData(msg)
+	if errors.Is(err, errObjectStore) {
+		log.Error("failed to get message data - message is redelivered", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeRedeliver
+	}
+	if err != nil {
+		log.Error("failed to get message data - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeTerm
+	}
+
+	keyID := msg.Headers().Get(encryption.HeaderKeyID)
+	if keyID != "" {
+		data, err = conn.connectordata.EncryptionKeys.Decrypt(keyID, data)
+		if err != nil {
+			log.Error("failed to decrypt message data - message is terminated", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeTerm
+		}
+	}
+
+	var encoding string
+	if conn.connectordata.Decompress {
+		encoding = msg.Headers().Get(codec.HeaderContentEncoding)
+		data, err = conn.decoder.Decode(encoding, data)
+		if err != nil {
+			log.Error("failed to decompress message data - message is terminated", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeTerm
+		}
+	}
+
+	message := data
+
+	var envelope string
+	if conn.connectordata.PayloadPath != nil {
+		data, envelope, err = conn.extractPayload(data)
+		if err != nil {
+			log.Error("failed to extract payload - message is terminated", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeTerm
+		}
+	}
+
+	if len(conn.connectordata.RedisEnrich) > 0 {
+		data, err = conn.enrich(ctx, data)
+		if err != nil {
+			log.Error("failed to enrich payload", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			if errors.Is(err, errKV) {
+				return OutcomeRedeliver
+			}
+			return OutcomeTerm
+		}
+	}
+
+	if conn.hook != nil {
+		var o Outcome
+		var ok bool
+		data, o, ok = conn.runHook(ctx, data)
+		if !ok {
+			return o
+		}
+	}
+
+	headers := conn.metaHeaders()
+	maps.Copy(headers, conn.forwardedHeaders(msg)) // Add and overwrite headers from Jetstream
+	if encoding != "" {
+		delete(headers, codec.HeaderContentEncoding) // body is already decompressed
+	}
+	delete(headers, encryption.HeaderKeyID) // body is already decrypted
+	if envelope != "" {
+		headers[conn.connectordata.PayloadEnvelopeHeader] = []string{envelope}
+	}
+	if token := jobToken(ctx); token != "" {
+		headers[conn.connectordata.JobTokenHeader] = []string{token}
+	}
+
+	var scriptEndpoint string
+	if conn.script != nil {
+		var keep bool
+		data, scriptEndpoint, keep, err = conn.script.OnMessage(ctx, data, headers)
+		if err != nil {
+			log.Error("on_message hook failed - message is terminated", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeTerm
+		}
+		if !keep {
+			log.Debug("Message is skipped by on_message hook")
+			return OutcomeAck
+		}
+	}
+
+	data, contentType, err := conn.encodeRequest(data)
+	if errors.Is(err, errObjectStore) {
+		log.Error("failed to encode request - message is redelivered", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeRedeliver
+	}
+	if err != nil {
+		log.Error("failed to encode request - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeTerm
+	}
+	headers["Content-Type"] = []string{contentType}
+	if conn.connectordata.InvokeProtocol == ProtocolSOAP && conn.connectordata.SOAPVersion != SOAP12 && conn.connectordata.SOAPAction != "" {
+		headers["SOAPAction"] = []string{conn.connectordata.SOAPAction}
+	}
+
+	cfg := conn.connectordata
+	endpoint := scriptEndpoint
+	if endpoint == "" {
+		endpoint, cfg.RetryPolicy = conn.selectEndpoint(ctx, message, headers)
+	}
+	cfg.HTTPEndpoint, err = expandEndpoint(endpoint, message)
+	if err != nil {
+		log.Error("failed to expand http endpoint - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeTerm
+	}
+
+	var pending *callback
+	if conn.connectordata.AsyncCallback {
+		pending = conn.awaitCallback(msg, message, encoding, headers)
+	}
+
+	t0 := time.Now()
+	body, status, respHeader, err := conn.invokeCached(ctx, cfg, data, headers, conn.cacheKey(cfg.HTTPEndpoint, msg.Headers()))
+	conn.checkSlowRequest(msg.Subject(), time.Since(t0))
+	setEndpointStatus(ctx, status, err)
+	if err != nil {
+		log.Info(err.Error())
+		conn.reject(pending)
+		if errors.Is(context.Cause(ctx), context.Canceled) {
+			return OutcomeRedeliver
+		}
+		if fault := conn.soapFault(err); fault != nil {
+			conn.errorHandler(ctx, fmt.Errorf("%w. http_endpoint: %v, source: %v", fault, cfg.HTTPEndpoint, cfg.SourceName))
+			if fault.Client() {
+				return OutcomeTerm
+			}
+			return OutcomeRedeliver
+		}
+		conn.errorHandler(ctx, err)
+		if errors.Is(err, ErrNonRetryable) {
+			return OutcomeTerm
+		}
+		return OutcomeRedeliver
+	}
+
+	if pending != nil {
+		if status == http.StatusAccepted {
+			return conn.accept(pending)
+		}
+		conn.reject(pending)
+	}
+	if conn.connectordata.AsyncPoll && status == http.StatusAccepted {
+		if o, ok := conn.awaitPoll(msg, message, encoding, cfg.HTTPEndpoint, respHeader, headers); ok {
+			return o
+		}
+	}
+
+	body, err = conn.decodeResponse(body)
+	if err != nil {
+		log.Error("failed to decode response", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		if errors.Is(err, ErrGraphQL) && conn.connectordata.GraphQLRetryErrors {
+			return OutcomeRedeliver
+		}
+		var fault *SOAPFault
+		if errors.As(err, &fault) && !fault.Client() {
+			return OutcomeRedeliver
+		}
+		return OutcomeTerm
+	}
+
+	if len(conn.connectordata.StageEndpoints) > 0 {
+		body, status, respHeader, err = conn.invokeStages(ctx, body, headers)
+		conn.checkSlowRequest(msg.Subject(), time.Since(t0))
+		setEndpointStatus(ctx, status, err)
+		if err != nil {
+			log.Info(err.Error())
+			if errors.Is(context.Cause(ctx), context.Canceled) {
+				return OutcomeRedeliver
+			}
+			conn.errorHandler(ctx, err)
+			if errors.Is(err, ErrNonRetryable) {
+				return OutcomeTerm
+			}
+			return OutcomeRedeliver
+		}
+	}
+
+	return conn.respond(ctx, msg, message, encoding, body, status, respHeader)
+}
+
+// respond publishes the response of the endpoint to the message. It returns how the message should be settled.
+func (conn *Connector) respond(ctx context.Context, msg Message, message []byte, encoding string, body []byte, status int, respHeader http.Header) Outcome {
+	log := conn.log(ctx)
+
+	var err error
+	if conn.script != nil {
+		var keep bool
+		body, keep, err = conn.script.OnResponse(ctx, body, status)
+		if err != nil {
+			log.Error("on_response hook failed - message is terminated", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeTerm
+		}
+		if !keep {
+			log.Debug("Response is discarded by on_response hook")
+			return OutcomeAck
+		}
+	}
+
+	if conn.discard(msg, status, body, respHeader) {
+		return OutcomeAck
+	}
+
+	if err := conn.connectordata.ResponseSchema.Validate(body); err != nil {
+		log.Error("Response does not match the schema - message is terminated", slog.Any("error", err))
+		conn.metrics.invalidResponses(conn.metrics.subjects.Value(msg.Subject()))
+		conn.errorHandler(ctx, fmt.Errorf("validate response. http_endpoint: %v, source: %v: %w", conn.connectordata.HTTPEndpoint, conn.connectordata.SourceName, err))
+		return OutcomeTerm
+	}
+
+	if conn.connectordata.ResponseMerge != MergeNone {
+		body, err = conn.mergeResponse(message, body)
+		if err != nil {
+			log.Error("failed to merge response - message is terminated", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeTerm
+		}
+	}
+
+	if conn.connectordata.ProcessingGuarantee == GuaranteeAtMostOnce {
+		if conn.noResponseTopic() && conn.connectordata.NoResponseTopic == NoResponseTopicRedeliver {
+			// checked before the ack: an acked message can't be redelivered
+			log.Warn("Response topic not set - message is redelivered")
+			return OutcomeRedeliver
+		}
+		err = msg.DoubleAck(ctx)
+		if err != nil {
+			log.Error("failed to ack message before publishing the response", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeRedeliver
+		}
+
+		if conn.responseHandler(ctx, msg, body, encoding, conn.responseHeaders(respHeader)) != OutcomeAck {
+			// The message can't be redelivered, so the response is kept in the error topic to be recovered.
+			conn.metrics.discardedResponses("publish_failed")
+			conn.errorHandler(ctx, fmt.Errorf("%w. message_id: %v, source: %v, response: %s",
+				ErrResponseLost, responseMsgID(msg), conn.connectordata.SourceName, body))
+			return OutcomeAcked
+		}
+		log.Info("done processing message", slog.String("message", string(body)))
+		conn.archive(msg, message, body)
+		return OutcomeAcked
+	}
+
+	o := conn.responseHandler(ctx, msg, body, encoding, conn.responseHeaders(respHeader))
+	if o == OutcomeAck {
+		log.Info("done processing message", slog.String("message", string(body)))
+		conn.archive(msg, message, body)
+	}
+	return o
+}
+
+// invoke sends the request to the endpoint with INVOKE_PROTOCOL and returns the response body, status and headers.
+func (conn *Connector) invoke(ctx context.Context, cfg Config, data []byte, headers http.Header) ([]byte, int, http.Header, error) {
+	if cfg.InvokeProtocol == ProtocolWebSocket {
+		body, err := conn.invokeWebSocket(ctx, cfg.HTTPEndpoint, data)
+		return body, http.StatusOK, http.Header{}, err
+	}
+
+	t0 := time.Now()
+	resp, err := HandleHTTPRequest(ctx, conn.httpClient, string(data), headers, cfg, conn.log(ctx))
+	conn.coldStreak.observe(time.Since(t0))
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("read response. http_endpoint: %v, source: %v: %w", cfg.HTTPEndpoint, cfg.SourceName, err)
+	}
+	return body, resp.StatusCode, resp.Header, nil
+}
+
+func (conn *Connector) checkSlowRequest(subject string, latency time.Duration) {
+	threshold := conn.connectordata.SlowRequestThreshold
+	if threshold <= 0 || latency <= threshold {
+		return
+	}
+
+	conn.metrics.slowRequests(conn.metrics.subjects.Value(subject))
+	conn.logger.Warn("Slow request",
+		slog.String("subject", subject),
+		slog.Duration("latency", latency),
+		slog.Duration("threshold", threshold),
+		slog.String("http_endpoint", conn.connectordata.HTTPEndpoint))
+}
+
+// messageData returns the message body, or the referenced object content in claim check mode.
+// Failures of the object store other than a missing object wrap errObjectStore.
+func (conn *Connector) messageData(msg Message) ([]byte, error) {
+	if !conn.connectordata.ClaimCheck {
+		return msg.Data(), nil
+	}
+
+	ref := msg.Headers().Get(largemsg.HeaderObjectRef)
+	if ref == "" {
+		return msg.Data(), nil
+	}
+
+	data, err := conn.objStore.GetBytes(ref)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, fmt.Errorf("get object %q from object store %q: %w", ref, conn.connectordata.ObjectStoreBucket, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object %q from object store %q: %w: %w", ref, conn.connectordata.ObjectStoreBucket, errObjectStore, err)
+	}
+	return data, nil
+}
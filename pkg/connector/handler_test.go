@@ -0,0 +1,118 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/codec"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/encryption"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/largemsg"
+	"github.com/nats-io/nats.go"
+)
+
+// fakeObjectStore returns the objects by name, err for other names.
+type fakeObjectStore struct {
+	nats.ObjectStore
+	objects map[string][]byte
+	err     error
+}
+
+func (s fakeObjectStore) GetBytes(name string, _ ...nats.GetObjectOpt) ([]byte, error) {
+	if data, ok := s.objects[name]; ok {
+		return data, nil
+	}
+	return nil, s.err
+}
+
+func TestHandleMessageDataErrors(t *testing.T) {
+	gzipped, err := codec.Encode(codec.EncodingGzip, []byte(`{"payload":"too large"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := encryption.Keys{"k1": make([]byte, 32)}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		store   nats.ObjectStore
+		data    []byte
+		headers map[string]string
+		outcome Outcome
+	}{
+		{
+			name:    "corrupt compressed data is terminated",
+			cfg:     Config{Decompress: true, DecompressMaxSize: 1024}, //nolint:exhaustruct // test config
+			data:    []byte("not gzip"),
+			headers: map[string]string{codec.HeaderContentEncoding: codec.EncodingGzip},
+			outcome: OutcomeTerm,
+		},
+		{
+			name:    "unsupported encoding is terminated",
+			cfg:     Config{Decompress: true, DecompressMaxSize: 1024}, //nolint:exhaustruct // test config
+			data:    []byte("{}"),
+			headers: map[string]string{codec.HeaderContentEncoding: "br"},
+			outcome: OutcomeTerm,
+		},
+		{
+			name:    "oversize decompressed data is terminated",
+			cfg:     Config{Decompress: true, DecompressMaxSize: 8}, //nolint:exhaustruct // test config
+			data:    gzipped,
+			headers: map[string]string{codec.HeaderContentEncoding: codec.EncodingGzip},
+			outcome: OutcomeTerm,
+		},
+		{
+			name:    "missing claim check object is terminated",
+			cfg:     Config{ClaimCheck: true},                     //nolint:exhaustruct // test config
+			store:   fakeObjectStore{err: nats.ErrObjectNotFound}, //nolint:exhaustruct // no objects
+			headers: map[string]string{largemsg.HeaderObjectRef: "missing"},
+			outcome: OutcomeTerm,
+		},
+		{
+			name:    "object store failure is redelivered",
+			cfg:     Config{ClaimCheck: true},                                 //nolint:exhaustruct // test config
+			store:   fakeObjectStore{err: errors.New("object store is down")}, //nolint:exhaustruct // no objects
+			headers: map[string]string{largemsg.HeaderObjectRef: "object"},
+			outcome: OutcomeRedeliver,
+		},
+		{
+			name:    "unknown encryption key id is terminated",
+			cfg:     Config{EncryptionKeys: keys}, //nolint:exhaustruct // test config
+			data:    []byte("encrypted"),
+			headers: map[string]string{encryption.HeaderKeyID: "k2"},
+			outcome: OutcomeTerm,
+		},
+		{
+			name:    "failed decryption is terminated",
+			cfg:     Config{EncryptionKeys: keys}, //nolint:exhaustruct // test config
+			data:    make([]byte, 64),
+			headers: map[string]string{encryption.HeaderKeyID: "k1"},
+			outcome: OutcomeTerm,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.PublishMaxAttempts = 1
+			tt.cfg.AMQPErrorRoutingKey = "errors"
+			conn := newTestConnector(tt.cfg, nil)
+			conn.objStore = tt.store
+			if tt.cfg.Decompress {
+				conn.decoder = codec.NewDecoder(tt.cfg.DecompressMaxSize)
+			}
+			sink := &fakeSink{} //nolint:exhaustruct // no error
+			conn.connectordata.ErrorSink = SinkAMQP
+			conn.SetSink(SinkAMQP, sink)
+
+			msg := newFakeMsg(string(tt.data))
+			for k, v := range tt.headers {
+				msg.headers.Set(k, v)
+			}
+			if o := conn.handle(context.Background(), msg); o != tt.outcome {
+				t.Errorf("outcome = %v, want %v", o, tt.outcome)
+			}
+			if len(sink.errors) != 1 {
+				t.Errorf("published %d errors, want 1", len(sink.errors))
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package connector
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/headerfilter"
+)
+
+// headerDisabled is the header name value that disables forwarding of the meta header.
+const headerDisabled = "-"
+
+// metaHeaders returns the connector meta headers forwarded to the HTTP endpoint.
+// The names are used as is, without canonicalization, so the configured casing is kept.
+func (conn *Connector) metaHeaders() http.Header {
+	cfg := conn.connectordata
+
+	headers := http.Header{
+		"Content-Type": {cfg.ContentType},
+	}
+	for name, value := range map[string]string{
+		cfg.HeaderTopic:         cfg.Topic,
+		cfg.HeaderResponseTopic: cfg.ResponseTopic,
+		cfg.HeaderErrorTopic:    cfg.ErrorTopic,
+		cfg.HeaderSourceName:    cfg.SourceName,
+	} {
+		if name == "" || name == headerDisabled {
+			continue
+		}
+		headers[name] = []string{value}
+	}
+	return headers
+}
+
+// forwardedHeaders returns the message headers passed through FORWARD_HEADERS_ALLOW and FORWARD_HEADERS_DENY.
+func (conn *Connector) forwardedHeaders(msg Message) nats.Header {
+	hdr := maps.Clone(msg.Headers())
+	headerfilter.Apply(headerfilter.Filter{
+		Allow: conn.connectordata.ForwardHeadersAllow,
+		Deny:  conn.connectordata.ForwardHeadersDeny,
+	}, hdr)
+	return hdr
+}
+
+// responseHeaders returns the endpoint response headers to be published with the response.
+// No headers are published unless RESPONSE_HEADERS_ALLOW is set.
+func (conn *Connector) responseHeaders(h http.Header) nats.Header {
+	hdr := nats.Header{}
+	if len(conn.connectordata.ResponseHeadersAllow) == 0 {
+		return hdr
+	}
+
+	maps.Copy(hdr, h)
+	headerfilter.Apply(headerfilter.Filter{
+		Allow: conn.connectordata.ResponseHeadersAllow,
+		Deny:  conn.connectordata.ResponseHeadersDeny,
+	}, hdr)
+	return hdr
+}
@@ -0,0 +1,161 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/unixsock"
+)
+
+var (
+	ErrEndpointUnhealthy = errors.New("http endpoint is unhealthy")
+	ErrNATSDisconnected  = errors.New("nats connection is not connected")
+)
+
+// gate blocks waiters while it is closed.
+type gate struct {
+	mx   sync.Mutex
+	ch   chan struct{}
+	open bool
+}
+
+func newGate(open bool) *gate {
+	g := &gate{ch: make(chan struct{}), open: false} //nolint:exhaustruct // zero value initialization
+	g.Set(open)
+	return g
+}
+
+func (g *gate) Set(open bool) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	switch {
+	case open && !g.open:
+		close(g.ch)
+	case !open && g.open:
+		g.ch = make(chan struct{})
+	}
+	g.open = open
+}
+
+func (g *gate) IsOpen() bool {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	return g.open
+}
+
+func (g *gate) Wait(ctx context.Context) {
+	g.mx.Lock()
+	ch := g.ch
+	g.mx.Unlock()
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+}
+
+// RunHealthProbe probes the HTTP endpoint every HEALTH_PROBE_INTERVAL until the context is done.
+// The dispatch of messages is paused while the endpoint is unhealthy.
+func (conn *Connector) RunHealthProbe(ctx context.Context) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("probe", "endpoint"))
+
+	probeURL, err := endpointURL(cfg.HTTPEndpoint, cfg.HealthProbePath)
+	if err != nil {
+		log.Error("Cannot parse http endpoint - health probe is disabled", slog.Any("error", err))
+		conn.endpointHealth.Set(true)
+		return
+	}
+
+	ticker := time.NewTicker(cfg.HealthProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		err := conn.probe(ctx, probeURL)
+		healthy := err == nil
+		if healthy != conn.endpointHealth.IsOpen() {
+			if healthy {
+				log.Info("HTTP endpoint is healthy - consumption is resumed")
+				conn.event(EventNormal, "EndpointHealthy", "HTTP endpoint is healthy - consumption is resumed")
+				go conn.rampUp(ctx)
+			} else {
+				log.Warn("HTTP endpoint is unhealthy - consumption is paused", slog.Any("error", err))
+				conn.event(EventWarning, "EndpointUnhealthy", "HTTP endpoint is unhealthy - consumption is paused: "+err.Error())
+			}
+		}
+		conn.endpointHealth.Set(healthy)
+		if healthy {
+			conn.metrics.endpointHealthy.Set(1)
+		} else {
+			conn.metrics.endpointHealthy.Set(0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (conn *Connector) probe(ctx context.Context, probeURL string) error {
+	cfg := conn.connectordata
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.HealthProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return fmt.Errorf("create probe request: %w", err)
+	}
+
+	resp, err := conn.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("probe request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != cfg.HealthProbeStatus {
+		return fmt.Errorf("probe returned status %d, expected %d", resp.StatusCode, cfg.HealthProbeStatus)
+	}
+	return nil
+}
+
+// endpointURL returns the URL of the endpoint with the path replaced and without the query.
+func endpointURL(endpoint, path string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err //nolint:wrapcheck // caller logs the error
+	}
+	if u.Scheme == unixsock.Scheme {
+		unixsock.SetPath(u, path)
+	} else {
+		u.Path = path
+	}
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// HealthCheck returns an error while the HTTP endpoint is unhealthy.
+func (conn *Connector) HealthCheck() error {
+	if !conn.endpointHealth.IsOpen() {
+		return ErrEndpointUnhealthy
+	}
+	return nil
+}
+
+// NATSCheck returns an error while the NATS connection is not connected, e.g. reconnecting.
+func (conn *Connector) NATSCheck() error {
+	if !conn.nc.IsConnected() {
+		return ErrNATSDisconnected
+	}
+	return nil
+}
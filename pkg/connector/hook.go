@@ -0,0 +1,79 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/wasmhook"
+)
+
+// TransformHook runs custom per-message logic, e.g. a WebAssembly module.
+type TransformHook interface {
+	// Filter reports whether the message should be sent to the endpoint.
+	Filter(ctx context.Context, data []byte) (bool, error)
+	// Transform returns the message sent to the endpoint.
+	Transform(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// SetTransformHook sets the hook applied to the payload before the endpoint invocation.
+func (conn *Connector) SetTransformHook(h TransformHook) {
+	conn.hook = h
+}
+
+// runHook filters and transforms the payload with the hook. Filtered out messages are acked without the invocation,
+// messages failed in the hook are terminated. Messages not run because the hook is unavailable
+// (e.g. its runtime is closed on shutdown) are redelivered. ok is false if the returned outcome settles the message.
+func (conn *Connector) runHook(ctx context.Context, data []byte) ([]byte, Outcome, bool) {
+	keep, err := conn.hook.Filter(ctx, data)
+	if errors.Is(err, wasmhook.ErrUnavailable) {
+		return nil, conn.hookUnavailable(ctx, err), false
+	}
+	if err != nil {
+		conn.metrics.hookResults("error")
+		conn.log(ctx).Error("Filter hook failed - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, fmt.Errorf("filter hook. source: %v: %w", conn.connectordata.SourceName, err))
+		return nil, OutcomeTerm, false
+	}
+	if !keep {
+		conn.metrics.hookResults("filtered")
+		conn.log(ctx).Debug("Message is filtered out by the hook")
+		return nil, OutcomeAck, false
+	}
+
+	data, err = conn.hook.Transform(ctx, data)
+	if errors.Is(err, wasmhook.ErrUnavailable) {
+		return nil, conn.hookUnavailable(ctx, err), false
+	}
+	if err != nil {
+		conn.metrics.hookResults("error")
+		conn.log(ctx).Error("Transform hook failed - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, fmt.Errorf("transform hook. source: %v: %w", conn.connectordata.SourceName, err))
+		return nil, OutcomeTerm, false
+	}
+	conn.metrics.hookResults("passed")
+	return data, OutcomeAck, true
+}
+
+func (conn *Connector) hookUnavailable(ctx context.Context, err error) Outcome {
+	conn.metrics.hookResults("unavailable")
+	conn.log(ctx).Warn("Hook is unavailable - message is redelivered", slog.Any("error", err))
+	return OutcomeRedeliver
+}
+
+// ScriptHook runs lightweight mutation hooks of a script, e.g. Lua.
+type ScriptHook interface {
+	// OnMessage returns the data sent to the endpoint and the endpoint overriding the selected one if it is not empty.
+	OnMessage(ctx context.Context, data []byte, headers http.Header) (out []byte, endpoint string, keep bool, err error)
+	// OnResponse returns the response to be published.
+	OnResponse(ctx context.Context, body []byte, status int) (out []byte, keep bool, err error)
+	// OnError returns the error message to be published.
+	OnError(ctx context.Context, message string) (string, bool)
+}
+
+// SetScriptHook sets the hooks called on the message, the response and the error.
+func (conn *Connector) SetScriptHook(h ScriptHook) {
+	conn.script = h
+}
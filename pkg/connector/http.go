@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNonRetryable is wrapped by StatusError of a failure status not listed in retry_statuses of the retry policy:
+// the message is not redelivered.
+var ErrNonRetryable = errors.New("response status is not retryable")
+
+// HandleHTTPRequest sends message and headers data to HTTP endpoint using HTTP_METHOD (POST by default) and returns response on success or error in case of failure.
+// GET requests have no body. Failures are retried according to the retry policy (see RETRY_POLICY).
+func HandleHTTPRequest(ctx context.Context, client *http.Client, message string, headers http.Header, cfg Config, log *slog.Logger) (*http.Response, error) {
+	method := cfg.HTTPMethod
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	policy := cfg.retryPolicy()
+	t0 := time.Now()
+
+	var resp *http.Response
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := policy.delay(attempt - 1)
+			if policy.Budget > 0 && time.Since(t0)+delay > time.Duration(policy.Budget) {
+				log.Warn("Retry budget is exhausted", slog.String("http_endpoint", cfg.HTTPEndpoint), slog.Int("attempts", attempt))
+				break
+			}
+			if delay > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(delay):
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("function invocation is interrupted. http_endpoint: %v, source: %v: %w", cfg.HTTPEndpoint, cfg.SourceName, ctx.Err())
+		}
+
+		// Create request
+		var body io.Reader
+		if method != http.MethodGet {
+			body = strings.NewReader(message)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, cfg.HTTPEndpoint, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request to invoke function. http_endpoint: %v, source: %v: %w", cfg.HTTPEndpoint, cfg.SourceName, err)
+		}
+
+		// Add headers
+		for key, vals := range headers {
+			for _, val := range vals {
+				req.Header.Add(key, val)
+			}
+		}
+
+		// Make the request
+		if resp != nil {
+			resp.Body.Close() // previous failed attempt
+			resp = nil
+		}
+		resp, err = client.Do(req)
+		if err != nil {
+			log.Error("sending function invocation request failed",
+				slog.Any("error", err),
+				slog.String("http_endpoint", cfg.HTTPEndpoint),
+				slog.String("source", cfg.SourceName))
+			continue
+		}
+		if resp == nil {
+			continue
+		}
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			// Success, quit retrying
+			return resp, nil
+		}
+		if !policy.retryable(resp.StatusCode) {
+			break
+		}
+	}
+
+	if resp == nil {
+		return nil, fmt.Errorf("every function invocation retry failed; final retry gave empty response. http_endpoint: %v, source: %v", cfg.HTTPEndpoint, cfg.SourceName)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       body,
+			Endpoint:   cfg.HTTPEndpoint,
+			Source:     cfg.SourceName,
+			Retryable:  policy.retryable(resp.StatusCode),
+		}
+	}
+	return resp, nil
+}
+
+// maxErrorBody limits the body of a failure response kept in StatusError.
+const maxErrorBody = 64 << 10
+
+// StatusError is returned when the last invocation retry responded with a failure status.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+	Endpoint   string
+	Source     string
+	// Retryable is false if the status is not in retry_statuses of the retry policy, the error wraps ErrNonRetryable then.
+	Retryable bool
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request returned failure: %v. http_endpoint: %v, source: %v", e.StatusCode, e.Endpoint, e.Source)
+}
+
+func (e *StatusError) Unwrap() error {
+	if e.Retryable {
+		return nil
+	}
+	return ErrNonRetryable
+}
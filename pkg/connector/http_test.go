@@ -0,0 +1,210 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusServer responds with the statuses in order, the last one is repeated. It counts the requests.
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := int(requests.Add(1)) - 1
+		w.WriteHeader(statuses[min(n, len(statuses)-1)])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestHandleHTTPRequestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		statuses   []int
+		attempts   int64
+		status     int  // 0 if the request fails
+		retryable  bool // of the failure status
+		minElapsed time.Duration
+	}{
+		{
+			name:     "success",
+			policy:   RetryPolicy{MaxAttempts: 3},
+			statuses: []int{http.StatusOK},
+			attempts: 1,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "retried status then success",
+			policy:   RetryPolicy{MaxAttempts: 3, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			attempts: 3,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "status outside retry statuses",
+			policy:   RetryPolicy{MaxAttempts: 3, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			statuses: []int{http.StatusBadRequest},
+			attempts: 1,
+		},
+		{
+			name:      "retry statuses exhausted",
+			policy:    RetryPolicy{MaxAttempts: 3, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			statuses:  []int{http.StatusServiceUnavailable},
+			attempts:  3,
+			retryable: true,
+		},
+		{
+			name:      "all statuses retried without retry statuses",
+			policy:    RetryPolicy{MaxAttempts: 2},
+			statuses:  []int{http.StatusBadRequest},
+			attempts:  2,
+			retryable: true,
+		},
+		{
+			name:       "backoff delays",
+			policy:     RetryPolicy{MaxAttempts: 3, Backoff: Duration(20 * time.Millisecond)},
+			statuses:   []int{http.StatusInternalServerError},
+			attempts:   3,
+			retryable:  true,
+			minElapsed: 60 * time.Millisecond, // 20ms + 40ms
+		},
+		{
+			name:      "budget",
+			policy:    RetryPolicy{MaxAttempts: 5, Backoff: Duration(20 * time.Millisecond), Budget: Duration(30 * time.Millisecond)},
+			statuses:  []int{http.StatusInternalServerError},
+			attempts:  2, // the second delay of 40ms exceeds the budget
+			retryable: true,
+		},
+		{
+			name:     "3xx status is a failure",
+			policy:   RetryPolicy{MaxAttempts: 1, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			statuses: []int{http.StatusMultipleChoices},
+			attempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := statusServer(t, tt.statuses...)
+			cfg := Config{ //nolint:exhaustruct // test config
+				HTTPEndpoint:  srv.URL,
+				HTTPMethod:    http.MethodPost,
+				RetryPolicies: RetryPolicies{"test": tt.policy},
+				RetryPolicy:   "test",
+			}
+
+			t0 := time.Now()
+			resp, err := HandleHTTPRequest(context.Background(), http.DefaultClient, "{}", http.Header{}, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			elapsed := time.Since(t0)
+
+			if n := requests.Load(); n != tt.attempts {
+				t.Errorf("attempts = %d, want %d", n, tt.attempts)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("elapsed = %v, want at least %v", elapsed, tt.minElapsed)
+			}
+			if tt.status != 0 {
+				if err != nil {
+					t.Fatalf("error = %v, want status %d", err, tt.status)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.status {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+				}
+				return
+			}
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("error = %v, want StatusError", err)
+			}
+			if statusErr.Retryable != tt.retryable || errors.Is(err, ErrNonRetryable) == tt.retryable {
+				t.Errorf("retryable = %v (non-retryable error: %v), want %v", statusErr.Retryable, errors.Is(err, ErrNonRetryable), tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration // by attempt
+	}{
+		{
+			name:   "no backoff",
+			policy: RetryPolicy{MaxAttempts: 3},
+			want:   []time.Duration{0, 0, 0},
+		},
+		{
+			name:   "doubled",
+			policy: RetryPolicy{MaxAttempts: 4, Backoff: Duration(100 * time.Millisecond)},
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond},
+		},
+		{
+			name:   "limited by max backoff",
+			policy: RetryPolicy{MaxAttempts: 5, Backoff: Duration(100 * time.Millisecond), MaxBackoff: Duration(300 * time.Millisecond)},
+			want:   []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:   "backoff above max backoff",
+			policy: RetryPolicy{MaxAttempts: 2, Backoff: Duration(time.Second), MaxBackoff: Duration(500 * time.Millisecond)},
+			want:   []time.Duration{500 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for attempt, want := range tt.want {
+				if got := tt.policy.delay(attempt); got != want {
+					t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRetryPolicyOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		outcome  Outcome
+	}{
+		{name: "status outside retry statuses is terminated", statuses: []int{http.StatusBadRequest}, outcome: OutcomeTerm},
+		{name: "retry statuses exhausted are redelivered", statuses: []int{http.StatusServiceUnavailable}, outcome: OutcomeRedeliver},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := statusServer(t, tt.statuses...)
+			conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+				HTTPEndpoint:       srv.URL,
+				HTTPMethod:         http.MethodPost,
+				InvokeProtocol:     ProtocolHTTP,
+				BodyEncoding:       BodyRaw,
+				PublishMaxAttempts: 1,
+				RetryPolicies:      RetryPolicies{"test": {MaxAttempts: 2, RetryStatuses: []int{http.StatusServiceUnavailable}}}, //nolint:exhaustruct // no backoff
+				RetryPolicy:        "test",
+
+				AMQPResponseRoutingKey: "responses",
+				AMQPErrorRoutingKey:    "errors",
+			}, nil)
+			sink := &fakeSink{} //nolint:exhaustruct // no error
+			conn.connectordata.ErrorSink = SinkAMQP
+			conn.SetSink(SinkAMQP, sink)
+
+			if o := conn.handle(context.Background(), newFakeMsg("{}")); o != tt.outcome {
+				t.Errorf("outcome = %v, want %v", o, tt.outcome)
+			}
+			if len(sink.errors) != 1 {
+				t.Errorf("published %d errors, want 1", len(sink.errors))
+			}
+		})
+	}
+}
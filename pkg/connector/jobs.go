@@ -0,0 +1,100 @@
+package connector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobs are the leases of the messages being processed in job-queue mode (JOB_LEASE).
+// The endpoint extends the lease of a long job by POST /jobs/<token>/extend with the token of JOB_TOKEN_HEADER header:
+// the message is marked in progress and the processing deadline is moved by the processing timeout.
+type jobs struct {
+	mu     sync.Mutex
+	leases map[string]*lease
+}
+
+type lease struct {
+	msg     Message
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+type jobTokenKey struct{}
+
+// jobToken returns the job token of the processing context, empty if the message has no lease.
+func jobToken(ctx context.Context) string {
+	token, _ := ctx.Value(jobTokenKey{}).(string)
+	return token
+}
+
+// lease returns the processing context of the message with the deadline extendable by the job token
+// and the function releasing the lease.
+func (conn *Connector) lease(ctx context.Context, msg Message, timeout time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	token := newToken()
+	l := &lease{msg: msg, timeout: timeout, timer: time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) })}
+
+	conn.jobs.mu.Lock()
+	conn.jobs.leases[token] = l
+	conn.jobs.mu.Unlock()
+
+	return context.WithValue(ctx, jobTokenKey{}, token), func() {
+		conn.jobs.mu.Lock()
+		delete(conn.jobs.leases, token)
+		conn.jobs.mu.Unlock()
+
+		l.timer.Stop()
+		cancel(nil)
+	}
+}
+
+// extend extends the lease of the job. It returns false if the job is unknown or already finished.
+func (conn *Connector) extend(token string) (bool, error) {
+	conn.jobs.mu.Lock()
+	l, ok := conn.jobs.leases[token]
+	conn.jobs.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	if err := l.msg.InProgress(); err != nil {
+		return true, err //nolint:wrapcheck // caller logs the error
+	}
+	if !l.timer.Reset(l.timeout) {
+		return false, nil // deadline is already exceeded
+	}
+	conn.metrics.leaseExtensions.Inc()
+	return true, nil
+}
+
+// JobsHandler serves POST /jobs/<token>/extend lease extension requests of the endpoint:
+// 204 - the lease is extended, 404 - the job is unknown or finished.
+func (conn *Connector) JobsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/extend")
+		if !ok || token == "" || strings.Contains(token, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		found, err := conn.extend(token)
+		switch {
+		case err != nil:
+			conn.logger.Error("Failed to extend job lease", slog.Any("error", err))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		case !found:
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestJobsHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string // empty - the extend path of the job token
+		release bool   // the lease is released before the request
+		status  int
+		settles []string
+	}{
+		{name: "extend", status: http.StatusNoContent, settles: []string{"in_progress"}},
+		{name: "unknown token", path: "/jobs/unknown/extend", status: http.StatusNotFound},
+		{name: "finished job", release: true, status: http.StatusNotFound},
+		{name: "no extend suffix", path: "/jobs/token", status: http.StatusNotFound},
+		{name: "empty token", path: "/jobs//extend", status: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{}, nil) //nolint:exhaustruct // test config
+			msg := newFakeMsg("{}")
+
+			ctx, release := conn.lease(context.Background(), msg, time.Minute)
+			defer release()
+			if tt.release {
+				release()
+			}
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			path := tt.path
+			if path == "" {
+				path = "/jobs/" + jobToken(ctx) + "/extend"
+			}
+			rec := httptest.NewRecorder()
+			conn.JobsHandler().ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := msg.settles(); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+		})
+	}
+}
+
+func TestLeaseTimeout(t *testing.T) {
+	conn := newTestConnector(Config{}, nil) //nolint:exhaustruct // test config
+	const timeout = 300 * time.Millisecond
+
+	ctx, release := conn.lease(context.Background(), newFakeMsg("{}"), timeout)
+	defer release()
+	started := time.Now()
+
+	time.Sleep(timeout / 2)
+	if found, err := conn.extend(jobToken(ctx)); !found || err != nil {
+		t.Fatalf("extend = %v, %v, want the lease extended", found, err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease is not timed out")
+	}
+	if elapsed := time.Since(started); elapsed < timeout*3/2 {
+		t.Errorf("lease is timed out in %v, want it extended by %v", elapsed, timeout)
+	}
+	if err := context.Cause(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("cause = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if found, _ := conn.extend(jobToken(ctx)); found {
+		t.Error("timed out lease is extended")
+	}
+}
+
+func TestLeaseRelease(t *testing.T) {
+	conn := newTestConnector(Config{}, nil) //nolint:exhaustruct // test config
+
+	ctx, release := conn.lease(context.Background(), newFakeMsg("{}"), time.Minute)
+	if jobToken(ctx) == "" {
+		t.Fatal("lease context has no job token")
+	}
+	release()
+
+	if err := context.Cause(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cause = %v, want %v", err, context.Canceled)
+	}
+	if found, _ := conn.extend(jobToken(ctx)); found {
+		t.Error("released lease is extended")
+	}
+	if token := jobToken(context.Background()); token != "" {
+		t.Errorf("job token without a lease = %q, want empty", token)
+	}
+}
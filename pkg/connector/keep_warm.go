@@ -0,0 +1,102 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// coldStreak counts consecutive invocations slower than KEEP_WARM_COLD_THRESHOLD.
+type coldStreak struct {
+	threshold time.Duration
+	n         atomic.Int64
+}
+
+func (s *coldStreak) observe(latency time.Duration) {
+	if s.threshold <= 0 {
+		return
+	}
+	if latency > s.threshold {
+		s.n.Add(1)
+	} else {
+		s.n.Store(0)
+	}
+}
+
+// RunKeepWarm pings KEEP_WARM_PATH of the HTTP endpoint every KEEP_WARM_INTERVAL while the consumer has a backlog
+// and the last KEEP_WARM_COLD_COUNT invocations were slower than KEEP_WARM_COLD_THRESHOLD,
+// so a scale-to-zero endpoint (Knative, Cloud Run) keeps instances warm for the backlog.
+func (conn *Connector) RunKeepWarm(ctx context.Context) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("probe", "keep-warm"))
+
+	pingURL, err := endpointURL(cfg.HTTPEndpoint, cfg.KeepWarmPath)
+	if err != nil {
+		log.Error("Cannot parse http endpoint - keep-warm pings are disabled", slog.Any("error", err))
+		return
+	}
+
+	ticker := time.NewTicker(cfg.KeepWarmInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if conn.coldStreak.n.Load() < int64(cfg.KeepWarmColdCount) {
+			continue
+		}
+
+		pending, err := conn.backlog(ctx)
+		if err != nil {
+			log.Warn("Failed to get consumer info for keep-warm pings", slog.Any("error", err))
+			continue
+		}
+		if pending == 0 {
+			continue
+		}
+
+		t0 := time.Now()
+		if err := conn.ping(ctx, pingURL); err != nil {
+			conn.metrics.keepWarmPings("error")
+			log.Warn("Keep-warm ping failed", slog.Any("error", err))
+			continue
+		}
+		conn.metrics.keepWarmPings("ok")
+		log.Debug("Keep-warm ping", slog.Uint64("pending", pending), slog.Duration("latency", time.Since(t0)))
+	}
+}
+
+// backlog returns the number of messages pending and waiting for ack for the consumer.
+func (conn *Connector) backlog(ctx context.Context) (uint64, error) {
+	cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+	if err != nil {
+		return 0, fmt.Errorf("get consumer %s: %w", conn.consumer, err)
+	}
+	info := cs.CachedInfo()
+	return info.NumPending + uint64(info.NumAckPending), nil
+}
+
+// ping sends GET request to the URL within KEEP_WARM_INTERVAL, any response status keeps the endpoint warm.
+func (conn *Connector) ping(ctx context.Context, pingURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, conn.connectordata.KeepWarmInterval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL, nil)
+	if err != nil {
+		return fmt.Errorf("create keep-warm request: %w", err)
+	}
+
+	resp, err := conn.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("keep-warm request: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
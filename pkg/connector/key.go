@@ -0,0 +1,84 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Key selects the key of a message: the value of a JSON field, e.g. '.order.id', or of a header, e.g. 'header:Order-Id'.
+type Key struct {
+	header string
+	path   []string
+}
+
+// SetString parses the key in format '.field.subfield' or 'header:<name>'. The empty string disables the key.
+func (k *Key) SetString(s string) error {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		*k = Key{}
+	case strings.HasPrefix(s, "header:"):
+		name := strings.TrimPrefix(s, "header:")
+		if name == "" {
+			return fmt.Errorf("wrong key %q: header name is empty", s)
+		}
+		*k = Key{header: name, path: nil}
+	case strings.HasPrefix(s, ".") && len(s) > 1:
+		*k = Key{header: "", path: strings.Split(strings.TrimPrefix(s, "."), ".")}
+	default:
+		return fmt.Errorf("wrong key %q: only '.field.subfield' and 'header:<name>' are accepted", s)
+	}
+	return nil
+}
+
+func (k Key) String() string {
+	if k.header != "" {
+		return "header:" + k.header
+	}
+	if k.path == nil {
+		return ""
+	}
+	return "." + strings.Join(k.path, ".")
+}
+
+// Enabled reports whether the key is set.
+func (k Key) Enabled() bool {
+	return k.header != "" || k.path != nil
+}
+
+// Value returns the key of the message. ok is false if the message has no key.
+// String fields are returned as is, other JSON values are returned as JSON.
+func (k Key) Value(body []byte, hdr http.Header) (string, bool) {
+	if k.header != "" {
+		v := hdr.Get(k.header)
+		return v, v != ""
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	for _, name := range k.path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[name]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
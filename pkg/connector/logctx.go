@@ -0,0 +1,72 @@
+package connector
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service/logger"
+)
+
+type (
+	loggerKey    struct{}
+	logBufferKey struct{}
+)
+
+// maxDeferredRecords limits the records of a message kept until it is settled in LOG_SAMPLE_RATE mode.
+const maxDeferredRecords = 64
+
+// withMessageLogger returns the context carrying the logger of the message, so all log lines of one message
+// can be found by its correlation ID. The attribute set is bounded: subject, stream sequence, delivery count
+// and the correlation ID (the value of CORRELATION_ID_HEADER header or <stream>-<stream sequence>).
+// If LOG_SAMPLE_RATE is set, records below Warn of the messages not sampled are kept until the message is settled
+// and emitted only if it failed (see flushLog).
+func (conn *Connector) withMessageLogger(ctx context.Context, msg Message) context.Context {
+	attrs := []any{slog.String("subject", msg.Subject())}
+
+	id := msg.Headers().Get(conn.connectordata.CorrelationIDHeader)
+	if meta, err := msg.Metadata(); err == nil {
+		attrs = append(attrs, slog.Uint64("stream_seq", meta.Sequence.Stream), slog.Uint64("delivered", meta.NumDelivered))
+		if id == "" {
+			id = responseMsgID(msg)
+		}
+	}
+	if id != "" {
+		attrs = append(attrs, slog.String("correlation_id", id))
+	}
+	log := conn.logger.With(attrs...)
+
+	if n := conn.connectordata.LogSampleRate; n > 1 && rand.Int63n(int64(n)) != 0 { //nolint:gosec // sampling needs no crypto
+		buf := logger.NewDeferredBuffer(maxDeferredRecords)
+		log = slog.New(logger.Deferred(log.Handler(), buf))
+		ctx = context.WithValue(ctx, logBufferKey{}, buf)
+	}
+	holder := new(atomic.Pointer[slog.Logger])
+	holder.Store(log)
+	return context.WithValue(ctx, loggerKey{}, holder)
+}
+
+// addLogAttrs adds the attributes to the logger of the message for the rest of its processing,
+// e.g. the route known only after the message is decoded.
+func (conn *Connector) addLogAttrs(ctx context.Context, attrs ...any) {
+	if holder, ok := ctx.Value(loggerKey{}).(*atomic.Pointer[slog.Logger]); ok {
+		holder.Store(holder.Load().With(attrs...))
+	}
+}
+
+// flushLog emits the kept records of the message not sampled if the processing result is a failure.
+func (conn *Connector) flushLog(ctx context.Context, result string) {
+	if buf, ok := ctx.Value(logBufferKey{}).(*logger.DeferredBuffer); ok && slices.Contains(failedResults, result) {
+		buf.Flush(ctx)
+	}
+}
+
+// log returns the logger of the message processed with the context, or the connector logger.
+func (conn *Connector) log(ctx context.Context) *slog.Logger {
+	if holder, ok := ctx.Value(loggerKey{}).(*atomic.Pointer[slog.Logger]); ok {
+		return holder.Load()
+	}
+	return conn.logger
+}
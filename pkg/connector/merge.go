@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// MergeMode defines how the HTTP response is combined with the original message before publishing.
+type MergeMode string
+
+const (
+	// MergeNone publishes the response as is.
+	MergeNone MergeMode = "none"
+	// MergeShallow merges the fields of the response object into the message object, the response fields win.
+	MergeShallow MergeMode = "shallow"
+	// MergeField puts the response into the message field referred by RESPONSE_MERGE_PATH.
+	MergeField MergeMode = "field"
+)
+
+func (m *MergeMode) SetString(s string) error {
+	switch mode := MergeMode(strings.ToLower(s)); mode {
+	case MergeNone, MergeShallow, MergeField:
+		*m = mode
+	default:
+		return fmt.Errorf("wrong merge mode: only 'none|shallow|field' are accepted")
+	}
+	return nil
+}
+
+// mergeResponse combines the JSON response with the original JSON message according to RESPONSE_MERGE.
+func (conn *Connector) mergeResponse(message, response []byte) ([]byte, error) {
+	var doc, resp any
+	if err := decodeJSON(message, &doc); err != nil {
+		return nil, fmt.Errorf("parse message as JSON to merge response: %w", err)
+	}
+	if err := decodeJSON(response, &resp); err != nil {
+		return nil, fmt.Errorf("parse response as JSON to merge: %w", err)
+	}
+
+	switch conn.connectordata.ResponseMerge {
+	case MergeShallow:
+		docObj, ok := doc.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("merge response: message is not a JSON object")
+		}
+		respObj, ok := resp.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("merge response: response is not a JSON object")
+		}
+		maps.Copy(docObj, respObj)
+	case MergeField:
+		var err error
+		doc, err = conn.connectordata.ResponseMergePath.Set(doc, resp)
+		if err != nil {
+			return nil, fmt.Errorf("merge response: %w", err)
+		}
+	case MergeNone:
+		return response, nil
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal merged response: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,32 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Message is a message processed by the connector pipeline.
+// jetstream.Msg implements it, alternate sources (core NATS, KV, test fakes) plug into the pipeline by implementing it.
+type Message interface {
+	Data() []byte
+	Headers() nats.Header
+	Subject() string
+	// Metadata returns the JetStream metadata of the message, sources without it return an error.
+	Metadata() (*jetstream.MsgMetadata, error)
+
+	Ack() error
+	// DoubleAck acks the message and waits for the confirmation.
+	DoubleAck(ctx context.Context) error
+	Nak() error
+	NakWithDelay(delay time.Duration) error
+	InProgress() error
+	Term() error
+}
+
+var (
+	_ Message = jetstream.Msg(nil)
+	_ Message = legacyMsg{}
+)
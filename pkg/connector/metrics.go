@@ -0,0 +1,270 @@
+package connector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/metrics"
+)
+
+type connectorMetrics struct {
+	subjects       *metrics.LabelGuard
+	messages       func(subject, route, result string)
+	processingTime func(subject, route string, seconds float64)
+
+	semaphoreWait      prometheus.Histogram
+	semaphoreSaturated prometheus.Counter
+	inFlight           prometheus.Gauge
+	inFlightBytes      prometheus.Gauge
+	slowRequests       metrics.CounterV1Func
+	contextErrors      func(reason, route string)
+	panics             prometheus.Counter
+	invalidResponses   metrics.CounterV1Func
+	discardedResponses metrics.CounterV1Func
+	routedMessages     metrics.CounterV1Func
+	sseEvents          metrics.CounterV1Func
+	archivedObjects    metrics.CounterV1Func
+	responseCache      metrics.CounterV1Func
+	enrichments        metrics.CounterV1Func
+	keepWarmPings      metrics.CounterV1Func
+	hookResults        metrics.CounterV1Func
+	largeResponses     metrics.CounterV1Func
+	sseReconnects      prometheus.Counter
+	wsDialErrors       prometheus.Counter
+	wsUnexpectedFrames prometheus.Counter
+	endpointHealthy    prometheus.Gauge
+	responseStreamFull prometheus.Gauge
+	consumeErrors      metrics.CounterV1Func
+	consumeHealthy     prometheus.Gauge
+	consumePaused      prometheus.Gauge
+	pausedMessages     metrics.CounterV1Func
+
+	consumerRecreations prometheus.Counter
+	groupSize           prometheus.Histogram
+	debouncedMessages   prometheus.Counter
+	leaseExtensions     prometheus.Counter
+	callbacks           metrics.CounterV1Func
+	events              metrics.CounterV1Func
+	publishRetries      metrics.CounterV1Func
+	publishFailures     metrics.CounterV1Func
+	errorsSuppressed    prometheus.Counter
+	shutdownMessages    *prometheus.GaugeVec
+	sloSuccess          *prometheus.CounterVec
+	sloFailure          *prometheus.CounterVec
+
+	concurrencyEffective prometheus.Gauge
+
+	redeliveries   metrics.CounterV1Func
+	deliveryCount  prometheus.Histogram
+	messageAge     prometheus.Histogram
+	backlogPending prometheus.Gauge
+}
+
+func newConnectorMetrics(concurrent, maxSubjects int, unacked *unacked) connectorMetrics {
+	promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "concurrency_limit",
+		Help: "Maximum number of messages processed concurrently (CONCURRENT)",
+	}).Set(float64(concurrent))
+
+	concurrencyEffective := promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "concurrency_effective_limit",
+		Help: "Current concurrency limit, less than CONCURRENT during the ramp-up",
+	})
+	concurrencyEffective.Set(float64(concurrent))
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "backlog_age_seconds",
+		Help: "Age of the oldest received message which is not acked yet - how far behind the consumer is",
+	}, func() float64 { return unacked.age(time.Now()) })
+
+	return connectorMetrics{
+		subjects: metrics.NewLabelGuard(maxSubjects, "other"),
+		messages: metrics.CounterV3(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "messages_total",
+			Help: "Counts processed messages by subject, route of ROUTES ('default' if none matched) and result",
+		}, []string{"subject", "route", "result"})),
+		processingTime: metrics.HistogramV2(promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "message_processing_seconds",
+			Help:    "Message processing time by subject and route of ROUTES",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"subject", "route"})),
+
+		semaphoreWait: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "semaphore_wait_seconds",
+			Help:    "Time a message waits for a free concurrency slot",
+			Buckets: prometheus.DefBuckets,
+		}),
+		semaphoreSaturated: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "semaphore_saturated_total",
+			Help: "Counts messages that found all concurrency slots busy",
+		}),
+		inFlight: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "messages_in_flight",
+			Help: "Number of messages being processed at the moment",
+		}),
+		inFlightBytes: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "messages_in_flight_bytes",
+			Help: "Size of messages being processed at the moment",
+		}),
+		slowRequests: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "slow_requests_total",
+			Help: "Counts endpoint invocations exceeding SLOW_REQUEST_THRESHOLD by subject",
+		}, []string{"subject"})),
+		contextErrors: metrics.CounterV2(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "invocation_context_errors_total",
+			Help: "Counts endpoint invocations interrupted by timeout or by shutdown by reason and route of ROUTES",
+		}, []string{"reason", "route"})),
+		invalidResponses: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "invalid_responses_total",
+			Help: "Counts endpoint responses not matching RESPONSE_SCHEMA by subject",
+		}, []string{"subject"})),
+		discardedResponses: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "discarded_responses_total",
+			Help: "Counts endpoint responses not published because of DISCARD_* rules, unset RESPONSE_TOPIC or a failed publish after the at_most_once ack by reason (status|empty|rule|no_response_topic|publish_failed)",
+		}, []string{"reason"})),
+		routedMessages: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "routed_messages_total",
+			Help: "Counts messages by the matched route of ROUTES ('default' if none matched)",
+		}, []string{"route"})),
+		sseEvents: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "sse_source_events_total",
+			Help: "Counts events received from SSE_SOURCE_URL by result (published|error)",
+		}, []string{"result"})),
+		archivedObjects: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "archived_objects_total",
+			Help: "Counts objects put to ARCHIVE_BUCKET by result (stored|error|dropped)",
+		}, []string{"result"})),
+		responseCache: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "response_cache_total",
+			Help: "Counts lookups and failures of Redis and GET response caches by result (hit|miss|error)",
+		}, []string{"result"})),
+		enrichments: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "enrichments_total",
+			Help: "Counts REDIS_ENRICH lookups by result (found|missing)",
+		}, []string{"result"})),
+		keepWarmPings: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "keep_warm_pings_total",
+			Help: "Counts keep-warm pings of KEEP_WARM_PATH by result (ok|error)",
+		}, []string{"result"})),
+		hookResults: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "hook_messages_total",
+			Help: "Counts messages run through WASM_HOOK by result (passed|filtered|error|unavailable)",
+		}, []string{"result"})),
+		largeResponses: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "large_responses_total",
+			Help: "Counts responses exceeding the NATS max payload by LARGE_RESPONSE_MODE result (chunked|stored|truncated|dropped|failed)",
+		}, []string{"result"})),
+		sseReconnects: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "sse_source_reconnects_total",
+			Help: "Counts reconnects to SSE_SOURCE_URL",
+		}),
+		wsDialErrors: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "websocket_dial_errors_total",
+			Help: "Counts failed WebSocket connection attempts to the endpoint",
+		}),
+		wsUnexpectedFrames: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "websocket_unexpected_frames_total",
+			Help: "Counts WebSocket frames received while no message was waiting for a response, their connections are dropped",
+		}),
+		panics: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "handler_panics_total",
+			Help: "Counts panics recovered in the message handler",
+		}),
+		endpointHealthy: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "endpoint_healthy",
+			Help: "Result of the last HTTP endpoint health probe (1 - healthy, 0 - unhealthy)",
+		}),
+		responseStreamFull: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "response_stream_full",
+			Help: "Whether consumption is paused because the response stream is full (1 - full, 0 - has capacity)",
+		}),
+		consumeErrors: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "consume_errors_total",
+			Help: "Counts errors of the consume subscription reported by JetStream by reason (no_heartbeat|consumer_deleted|bad_request|other)",
+		}, []string{"reason"})),
+		consumePaused: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "consume_paused",
+			Help: "Whether the pull of messages is stopped by the dispatch gates (1 - paused, 0 - consuming)",
+		}),
+		pausedMessages: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "paused_messages_total",
+			Help: "Counts messages received while the consumption is paused and nacked with a delay by reason (manual|endpoint_unhealthy|response_stream_full)",
+		}, []string{"reason"})),
+		consumeHealthy: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "consume_healthy",
+			Help: "Whether messages are being consumed (1) or consuming is stopped by JetStream and being re-established (0)",
+		}),
+		consumerRecreations: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "consumer_recreations_total",
+			Help: "Counts consumers recreated after they were deleted externally",
+		}),
+		groupSize: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "message_group_size",
+			Help:    "Number of messages in the groups dispatched by GROUP_KEY",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		debouncedMessages: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "debounced_messages_total",
+			Help: "Counts messages acked without the invocation because a newer message of the same DEBOUNCE_KEY arrived",
+		}),
+		leaseExtensions: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "job_lease_extensions_total",
+			Help: "Counts job lease extensions requested by the endpoint",
+		}),
+		callbacks: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "async_jobs_total",
+			Help: "Counts jobs accepted by the endpoint in ASYNC_CALLBACK and ASYNC_POLL modes by result (accepted|success|retry|fail|timeout|shutdown)",
+		}, []string{"result"})),
+		events: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_total",
+			Help: "Counts recorded state change events (e.g. Kubernetes Events) by result (ok|error)",
+		}, []string{"result"})),
+		publishRetries: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "publish_retries_total",
+			Help: "Counts publish retries by topic (response|error)",
+		}, []string{"topic"})),
+		sloSuccess: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "connector_processing_success_total",
+			Help: "Counts messages processed successfully by stream and consumer, for SLO burn-rate alerting",
+		}, []string{"stream", "consumer"}),
+		sloFailure: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "connector_processing_failure_total",
+			Help: "Counts messages failed to be processed by stream, consumer and reason (term|timeout|redeliver|ack_error|panic), for SLO burn-rate alerting",
+		}, []string{"stream", "consumer", "reason"}),
+		shutdownMessages: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "shutdown_messages",
+			Help: "Shutdown report: messages by state (processed|in_flight|pending_jobs|nacked), set when the drain on shutdown is over",
+		}, []string{"state"}),
+		errorsSuppressed: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "errors_suppressed_total",
+			Help: "Counts errors not published to the error topic by ERROR_RATE_LIMIT, they are summarized instead",
+		}),
+		publishFailures: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "publish_failures_total",
+			Help: "Counts publishes failed after all PUBLISH_MAX_ATTEMPTS attempts by topic (response|error)",
+		}, []string{"topic"})),
+
+		concurrencyEffective: concurrencyEffective,
+
+		redeliveries: metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "connector_redelivered_total",
+			Help: "Counts redelivered messages by subject",
+		}, []string{"subject"})),
+		deliveryCount: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "message_delivery_count",
+			Help:    "Number of delivery attempts of received messages",
+			Buckets: []float64{1, 2, 3, 5, 10, 20, 50},
+		}),
+		messageAge: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "message_age_seconds",
+			Help:    "Age of received messages since they were stored in the stream",
+			Buckets: []float64{.01, .1, 1, 10, 60, 300, 900, 3600, 6 * 3600, 24 * 3600},
+		}),
+		backlogPending: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "backlog_pending_messages",
+			Help: "Number of messages pending for the consumer as of the last received message",
+		}),
+	}
+}
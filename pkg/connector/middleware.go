@@ -0,0 +1,22 @@
+package connector
+
+import "context"
+
+// Handler processes the message and returns how it should be settled.
+type Handler func(ctx context.Context, msg Message) Outcome
+
+// Middleware wraps the message handler, e.g. for tracing, validation, enrichment or rate limiting.
+// It may return an outcome without calling next to skip the processing of the message.
+type Middleware func(next Handler) Handler
+
+// Use adds middlewares to the message pipeline, the first added middleware is the outermost one.
+// It should be called before consuming starts.
+func (conn *Connector) Use(mw ...Middleware) {
+	conn.middlewares = append(conn.middlewares, mw...)
+
+	h := Handler(conn.handle)
+	for i := len(conn.middlewares) - 1; i >= 0; i-- {
+		h = conn.middlewares[i](h)
+	}
+	conn.handler = h
+}
@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// pauseCheckInterval is the interval the consumption is paused or resumed at by the dispatch gates.
+	pauseCheckInterval = time.Second
+	// pausedNakDelay delays the redelivery of the messages received while the consumption is paused.
+	pausedNakDelay = 5 * time.Second
+)
+
+// pauseReason returns why the consumption is paused, empty if it is not: the pull is stopped
+// while it is paused by the pause API, the HTTP endpoint is unhealthy or the response stream is full,
+// instead of blocking the consume callback.
+func (conn *Connector) pauseReason() string {
+	if conn.manualPause.Load() {
+		return "manual"
+	}
+	if !conn.endpointHealth.IsOpen() {
+		return "endpoint_unhealthy"
+	}
+	if !conn.responseCapacity.IsOpen() {
+		return "response_stream_full"
+	}
+	return ""
+}
+
+// deferPaused nacks the message received while the consumption is paused, e.g. pulled before the pause,
+// so the consume callback is not blocked. It returns false if the consumption is not paused.
+func (conn *Connector) deferPaused(ctx context.Context, msg Message) bool {
+	reason := conn.pauseReason()
+	if reason == "" {
+		return false
+	}
+	conn.metrics.pausedMessages(reason)
+	log := conn.log(conn.withMessageLogger(ctx, msg))
+	log.Debug("Consumption is paused - message is nacked", slog.String("reason", reason), slog.Duration("delay", pausedNakDelay))
+	if err := msg.NakWithDelay(pausedNakDelay); err != nil {
+		log.Error("failed to nak message received while the consumption is paused", slog.Any("error", err))
+	}
+	return true
+}
+
+// applyPause stops the pull while the consumption is paused and subscribes again when it is resumed.
+// It returns the consume context and its terminal error channel, both nil while the consumption is paused.
+func (conn *Connector) applyPause(ctx, procCtx context.Context, cc jetstream.ConsumeContext, terminal <-chan error) (jetstream.ConsumeContext, <-chan error) {
+	reason := conn.pauseReason()
+	switch {
+	case reason != "" && cc != nil:
+		cc.Stop()
+		conn.metrics.consumePaused.Set(1)
+		conn.logger.Warn("Consumption is paused", slog.String("reason", reason))
+		return nil, nil
+	case reason == "" && cc == nil:
+		cc, terminal, err := conn.resubscribe(ctx, procCtx)
+		if err != nil {
+			return nil, nil
+		}
+		conn.metrics.consumePaused.Set(0)
+		conn.logger.Info("Consumption is resumed")
+		return cc, terminal
+	}
+	return cc, terminal
+}
+
+// waitResumed blocks until the consumption is resumed or the context is done, for the consumers
+// which pull messages one by one, e.g. the backfill.
+func (conn *Connector) waitResumed(ctx context.Context) {
+	for conn.pauseReason() != "" {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pauseCheckInterval):
+		}
+	}
+}
+
+// PauseHandler pauses (pause is true) or resumes the consumption manually, e.g. from the dashboard,
+// and responds with the state of the dispatch gates. The pull is stopped within pauseCheckInterval.
+// Resuming doesn't lift the pause by the other gates.
+func (conn *Connector) PauseHandler(pause bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if conn.manualPause.Swap(pause) != pause {
+			if pause {
+				conn.logger.Warn("Consumption is paused by the pause API")
+			} else {
+				conn.logger.Info("Consumption is resumed by the pause API")
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conn.gates()) //nolint:errcheck,errchkjson // best effort response
+	})
+}
@@ -0,0 +1,42 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDeferPaused(t *testing.T) {
+	tests := []struct {
+		name             string
+		manual           bool
+		endpointHealthy  bool
+		responseCapacity bool
+		reason           string
+		settles          []string
+	}{
+		{name: "not paused", endpointHealthy: true, responseCapacity: true},
+		{name: "manual", manual: true, endpointHealthy: true, responseCapacity: true, reason: "manual", settles: []string{"nak_delay"}},
+		{name: "endpoint unhealthy", responseCapacity: true, reason: "endpoint_unhealthy", settles: []string{"nak_delay"}},
+		{name: "response stream full", endpointHealthy: true, reason: "response_stream_full", settles: []string{"nak_delay"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{}, nil) //nolint:exhaustruct // test config
+			conn.manualPause.Store(tt.manual)
+			conn.endpointHealth.Set(tt.endpointHealthy)
+			conn.responseCapacity.Set(tt.responseCapacity)
+			msg := newFakeMsg("{}")
+
+			if got := conn.pauseReason(); got != tt.reason {
+				t.Errorf("reason = %q, want %q", got, tt.reason)
+			}
+			if got := conn.deferPaused(context.Background(), msg); got != (tt.reason != "") {
+				t.Errorf("deferred = %v, want %v", got, tt.reason != "")
+			}
+			if got := msg.settles(); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var errTrailingData = errors.New("invalid character after top-level value")
+
+// decodeJSON unmarshals the JSON document keeping the numbers as json.Number, as pkg/jsonschema does:
+// large integers (e.g. IDs) pass the connector without the float64 precision loss.
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err //nolint:wrapcheck // callers wrap with the document
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errTrailingData
+	}
+	return nil
+}
+
+// extractPayload returns the part of the JSON message referred by PAYLOAD_PATH to be sent as the HTTP body,
+// and the rest of the message (without the extracted field) as compact JSON if PAYLOAD_ENVELOPE_HEADER is set.
+func (conn *Connector) extractPayload(data []byte) ([]byte, string, error) {
+	path := conn.connectordata.PayloadPath
+
+	var doc any
+	if err := decodeJSON(data, &doc); err != nil {
+		return nil, "", fmt.Errorf("parse message as JSON to extract payload: %w", err)
+	}
+
+	v, err := path.Get(doc)
+	if err != nil {
+		return nil, "", fmt.Errorf("extract payload: %w", err)
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal payload: %w", err)
+	}
+	if s, ok := v.(string); ok {
+		body = []byte(s) // string payload is sent as is, not as a JSON string
+	}
+
+	if conn.connectordata.PayloadEnvelopeHeader == "" {
+		return body, "", nil
+	}
+
+	path.Delete(doc)
+	envelope, err := json.Marshal(doc)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal envelope: %w", err)
+	}
+	return body, string(envelope), nil
+}
@@ -0,0 +1,135 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrStatusTooLarge is returned if the status response of the polled job is larger than the callback body limit.
+var ErrStatusTooLarge = errors.New("status response is too large")
+
+// awaitPoll keeps the message of the job accepted by the endpoint with 202 in progress and polls the status URL
+// of the Location header until the job is completed or POLL_TIMEOUT, for async endpoints which can't call back.
+// It returns false if the response has no valid Location header, then it is handled as a usual response.
+func (conn *Connector) awaitPoll(msg Message, message []byte, encoding, endpoint string, respHeader, headers http.Header) (Outcome, bool) {
+	loc := respHeader.Get("Location")
+	if loc == "" {
+		return OutcomeAck, false
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return OutcomeAck, false
+	}
+	ref, err := url.Parse(loc)
+	if err != nil {
+		conn.logger.Warn("Accepted job has invalid Location header", slog.String("location", loc), slog.Any("error", err))
+		return OutcomeAck, false
+	}
+	status := base.ResolveReference(ref)
+	statusURL := status.String()
+
+	// The request headers are forwarded to the status URL, e.g. for authorization, without the request body ones.
+	// They are forwarded only to the origin (scheme and host) of the endpoint, so a Location of another host
+	// can't get the credentials.
+	pollHeaders := headers.Clone()
+	pollHeaders.Del("Content-Type")
+	pollHeaders.Del("Content-Encoding")
+	pollHeaders.Del("SOAPAction")
+	if status.Scheme != base.Scheme || status.Host != base.Host {
+		conn.logger.Warn("Status URL of accepted job has another origin than the endpoint - request headers are not forwarded",
+			slog.String("status_url", statusURL))
+		pollHeaders = http.Header{}
+	}
+
+	cb := conn.newCallback(msg, message, encoding)
+	conn.markAccepted(cb)
+
+	go conn.keepInProgress(cb, conn.connectordata.PollTimeout)
+	go conn.poll(cb, statusURL, pollHeaders)
+	return OutcomePending, true
+}
+
+// poll requests the status URL with backoff from POLL_INTERVAL up to POLL_MAX_INTERVAL (or Retry-After)
+// until it responds with other than 202: 2xx completes the job with the body as the response, 4xx fails it.
+// Other failures are retried with the next poll.
+func (conn *Connector) poll(cb *callback, statusURL string, headers http.Header) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("callback_token", cb.token), slog.String("status_url", statusURL))
+
+	interval := cfg.PollInterval
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-cb.done: // timed out or shut down
+			return
+		case <-time.After(interval):
+		}
+
+		body, status, hdr, err := conn.pollStatus(statusURL, headers)
+		switch {
+		case errors.Is(err, ErrStatusTooLarge):
+			if conn.complete(cb) {
+				conn.metrics.callbacks("fail")
+				conn.completeJob(cb, "fail", []byte(err.Error()), hdr)
+			}
+			return
+		case err != nil:
+			log.Warn("Failed to poll status of accepted job", slog.Int("attempt", attempt), slog.Any("error", err))
+		case status == http.StatusAccepted:
+			log.Debug("Accepted job is in progress", slog.Int("attempt", attempt))
+		case status >= 200 && status < 300:
+			if conn.complete(cb) {
+				conn.metrics.callbacks("success")
+				conn.completeJob(cb, "success", body, hdr)
+			}
+			return
+		case status >= 400 && status < 500:
+			if conn.complete(cb) {
+				conn.metrics.callbacks("fail")
+				conn.completeJob(cb, "fail", []byte(fmt.Sprintf("status url responded %d: %s", status, body)), hdr)
+			}
+			return
+		default:
+			log.Warn("Status of accepted job is unavailable", slog.Int("attempt", attempt), slog.Int("status", status))
+		}
+
+		interval = min(2*interval, cfg.PollMaxInterval)
+		if after, err := strconv.Atoi(hdr.Get("Retry-After")); err == nil && after > 0 {
+			interval = min(time.Duration(after)*time.Second, cfg.PollMaxInterval)
+		}
+	}
+}
+
+// pollStatus requests the status URL once, redirects (e.g. 303 to the result) are followed.
+// The body is limited as the callback body, a larger response fails the job.
+func (conn *Connector) pollStatus(statusURL string, headers http.Header) ([]byte, int, http.Header, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), conn.connectordata.AckWait)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statusURL, nil)
+	if err != nil {
+		return nil, 0, http.Header{}, fmt.Errorf("create status request: %w", err)
+	}
+	req.Header = headers.Clone()
+
+	resp, err := conn.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, http.Header{}, fmt.Errorf("request status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCallbackBody+1))
+	if err != nil {
+		return nil, 0, resp.Header, fmt.Errorf("read status response: %w", err)
+	}
+	if len(body) > maxCallbackBody {
+		return nil, 0, resp.Header, fmt.Errorf("%w: more than %d bytes", ErrStatusTooLarge, maxCallbackBody)
+	}
+	return body, resp.StatusCode, resp.Header, nil
+}
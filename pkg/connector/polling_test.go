@@ -0,0 +1,146 @@
+package connector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAwaitPoll(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int // status responses in order, the last one is repeated
+		body      []byte
+		timeout   time.Duration
+		settles   []string
+		published int
+		errors    int
+	}{
+		{name: "completed", statuses: []int{http.StatusAccepted, http.StatusOK}, body: []byte(`{"ok":true}`), settles: []string{"ack"}, published: 1},
+		{name: "unavailable status is retried", statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, body: []byte(`{"ok":true}`), settles: []string{"ack"}, published: 1},
+		{name: "failed", statuses: []int{http.StatusAccepted, http.StatusUnprocessableEntity}, body: []byte("bad input"), settles: []string{"term"}, errors: 1},
+		{name: "too large status response", statuses: []int{http.StatusOK}, body: make([]byte, maxCallbackBody+1), settles: []string{"term"}, errors: 1},
+		{name: "timeout", statuses: []int{http.StatusAccepted}, timeout: 50 * time.Millisecond, settles: []string{"nak"}, errors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/status/1" {
+					http.NotFound(w, r)
+					return
+				}
+				status := tt.statuses[min(int(polls.Add(1)), len(tt.statuses))-1]
+				w.WriteHeader(status)
+				if status != http.StatusAccepted {
+					_, _ = w.Write(tt.body)
+				}
+			}))
+			defer srv.Close()
+
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			sink := &fakeSink{}             //nolint:exhaustruct // no error
+			conn := newJobConnector(Config{ //nolint:exhaustruct // test config
+				AsyncPoll:       true,
+				PollInterval:    time.Millisecond,
+				PollMaxInterval: 5 * time.Millisecond,
+				PollTimeout:     timeout,
+			}, sink)
+			msg := newFakeMsg(`{"id":1}`)
+
+			respHeader := http.Header{"Location": []string{"/status/1"}}
+			o, ok := conn.awaitPoll(msg, msg.Data(), "", srv.URL+"/jobs", respHeader, http.Header{})
+			if !ok || o != OutcomePending {
+				t.Fatalf("outcome = %v, %v, want %v", o, ok, OutcomePending)
+			}
+
+			if got := settledWith(waitSettled(t, msg)); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+			conn.callbacks.wg.Wait()
+			if len(sink.published) != tt.published {
+				t.Errorf("published %d responses, want %d", len(sink.published), tt.published)
+			}
+			if len(sink.errors) != tt.errors {
+				t.Errorf("published %d errors, want %d", len(sink.errors), tt.errors)
+			}
+		})
+	}
+}
+
+func TestAwaitPollLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		polled   bool
+	}{
+		{name: "no location", location: ""},
+		{name: "invalid location", location: "http://[::1"},
+		{name: "relative location", location: "/status/1", polled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newJobConnector(Config{PollInterval: time.Minute, PollMaxInterval: time.Minute, PollTimeout: time.Minute}, &fakeSink{}) //nolint:exhaustruct // test config
+			msg := newFakeMsg("{}")
+
+			respHeader := http.Header{}
+			if tt.location != "" {
+				respHeader.Set("Location", tt.location)
+			}
+			o, ok := conn.awaitPoll(msg, msg.Data(), "", "http://fn.svc/jobs", respHeader, http.Header{})
+			if ok != tt.polled {
+				t.Errorf("polled = %v, want %v", ok, tt.polled)
+			}
+			if ok {
+				if o != OutcomePending {
+					t.Errorf("outcome = %v, want %v", o, OutcomePending)
+				}
+				conn.nakCallbacks()
+			}
+		})
+	}
+}
+
+func TestAwaitPollHeaders(t *testing.T) {
+	auth := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Header.Get("Authorization")
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			t.Errorf("status request has Content-Type %q", ct)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		auth     string
+	}{
+		{name: "same origin", endpoint: srv.URL + "/jobs", auth: "Bearer secret"},
+		{name: "another origin", endpoint: "http://fn.svc/jobs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newJobConnector(Config{PollInterval: time.Millisecond, PollMaxInterval: time.Millisecond, PollTimeout: 5 * time.Second}, &fakeSink{}) //nolint:exhaustruct // test config
+			msg := newFakeMsg("{}")
+
+			headers := http.Header{"Authorization": []string{"Bearer secret"}, "Content-Type": []string{"application/json"}}
+			respHeader := http.Header{"Location": []string{srv.URL + "/status/1"}}
+			if _, ok := conn.awaitPoll(msg, msg.Data(), "", tt.endpoint, respHeader, headers); !ok {
+				t.Fatal("job is not polled")
+			}
+			waitSettled(t, msg)
+
+			if got := <-auth; got != tt.auth {
+				t.Errorf("Authorization = %q, want %q", got, tt.auth)
+			}
+		})
+	}
+}
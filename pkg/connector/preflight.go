@@ -0,0 +1,90 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Preflight validates the stream and the consumer before consuming starts
+// and returns actionable errors instead of JetStream API errors at consume time.
+func (conn *Connector) Preflight(ctx context.Context) error {
+	conn.warnChaos()
+
+	streamName := conn.connectordata.Topic
+	filter := conn.filterSubject()
+
+	stream, err := conn.jsContext.Stream(ctx, streamName)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return fmt.Errorf("stream %s is not found - check TOPIC", streamName)
+		}
+		return fmt.Errorf("get stream %s info: %w", streamName, err)
+	}
+
+	subjects := stream.CachedInfo().Config.Subjects
+	if !anySubjectOverlaps(subjects, filter) {
+		return fmt.Errorf("stream %s has no subject matching %s (stream subjects: %s)", streamName, filter, strings.Join(subjects, ", "))
+	}
+
+	if conn.connectordata.RecreateConsumer || conn.connectordata.Backfill {
+		return nil
+	}
+
+	cs, err := stream.Consumer(ctx, conn.consumer)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			conn.logger.Info("Preflight: consumer is not found - it will be created", slog.String("consumer", conn.consumer))
+			return nil
+		}
+		return fmt.Errorf("get consumer %s info: %w", conn.consumer, err)
+	}
+
+	cfg := cs.CachedInfo().Config
+	if cfg.AckPolicy != jetstream.AckExplicitPolicy {
+		return fmt.Errorf("consumer %s on stream %s has ack policy %s - %s is required", conn.consumer, streamName, cfg.AckPolicy, jetstream.AckExplicitPolicy)
+	}
+	if cfg.FilterSubject != "" && !anySubjectOverlaps(subjects, cfg.FilterSubject) {
+		return fmt.Errorf("consumer %s filter subject %s doesn't match any subject of stream %s", conn.consumer, cfg.FilterSubject, streamName)
+	}
+	if cfg.AckWait < conn.connectordata.AckWait {
+		conn.logger.Warn("Preflight: consumer ack wait is less than ACKWAIT - messages may be redelivered while being processed",
+			slog.String("consumer", conn.consumer),
+			slog.Duration("consumer_ack_wait", cfg.AckWait),
+			slog.Duration("ack_wait", conn.connectordata.AckWait))
+	}
+
+	return nil
+}
+
+func (conn *Connector) filterSubject() string {
+	return conn.connectordata.Topic + ".input"
+}
+
+func anySubjectOverlaps(subjects []string, subject string) bool {
+	for _, s := range subjects {
+		if subjectsOverlap(s, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectsOverlap reports whether there is a subject matched by both subject patterns
+// with '*' (single token) and '>' (one or more trailing tokens) wildcards.
+func subjectsOverlap(a, b string) bool {
+	at, bt := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		if at[i] == ">" || bt[i] == ">" {
+			return true
+		}
+		if at[i] != bt[i] && at[i] != "*" && bt[i] != "*" {
+			return false
+		}
+	}
+	return len(at) == len(bt)
+}
@@ -0,0 +1,95 @@
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrGraphQL = errors.New("graphql errors")
+
+// Protocol defines how the message is sent to the HTTP endpoint and how the response is read.
+type Protocol string
+
+const (
+	// ProtocolHTTP posts the message as is.
+	ProtocolHTTP Protocol = "http"
+	// ProtocolGraphQL posts GRAPHQL_QUERY with the JSON message as variables and publishes the 'data' of the response.
+	ProtocolGraphQL Protocol = "graphql"
+	// ProtocolSOAP wraps the XML message into SOAP_ENVELOPE and publishes the content of the response envelope body.
+	ProtocolSOAP Protocol = "soap"
+	// ProtocolWebSocket sends the message as a frame over a persistent WebSocket connection and publishes the received frame.
+	ProtocolWebSocket Protocol = "websocket"
+)
+
+func (p *Protocol) SetString(s string) error {
+	switch protocol := Protocol(strings.ToLower(s)); protocol {
+	case ProtocolHTTP, ProtocolGraphQL, ProtocolSOAP, ProtocolWebSocket:
+		*p = protocol
+	default:
+		return fmt.Errorf("wrong protocol: only 'http|graphql|soap|websocket' are accepted")
+	}
+	return nil
+}
+
+// encodeRequest returns the request body and its content type for INVOKE_PROTOCOL.
+func (conn *Connector) encodeRequest(data []byte) ([]byte, string, error) {
+	cfg := conn.connectordata
+
+	switch cfg.InvokeProtocol {
+	case ProtocolGraphQL:
+		body, err := json.Marshal(struct {
+			Query     string          `json:"query"`
+			Variables json.RawMessage `json:"variables,omitempty"`
+		}{cfg.GraphQLQuery, data})
+		if err != nil {
+			return nil, "", fmt.Errorf("message is not a JSON to be used as graphql variables: %w", err)
+		}
+		return body, "application/json", nil
+	case ProtocolSOAP:
+		body, err := conn.soapEnvelope(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, cfg.soapContentType(), nil
+	case ProtocolHTTP, ProtocolWebSocket:
+		switch cfg.BodyEncoding {
+		case BodyForm:
+			return encodeForm(data)
+		case BodyMultipart:
+			return conn.encodeMultipart(data)
+		case BodyRaw:
+		}
+	}
+	return data, cfg.ContentType, nil
+}
+
+// decodeResponse returns the response to be published for INVOKE_PROTOCOL.
+// GraphQL responses with errors are returned as an error wrapping ErrGraphQL, SOAP faults as *SOAPFault.
+func (conn *Connector) decodeResponse(body []byte) ([]byte, error) {
+	switch conn.connectordata.InvokeProtocol {
+	case ProtocolGraphQL:
+		var resp struct {
+			Data   json.RawMessage `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("parse graphql response: %w", err)
+		}
+		if len(resp.Errors) > 0 {
+			messages := make([]string, 0, len(resp.Errors))
+			for _, e := range resp.Errors {
+				messages = append(messages, e.Message)
+			}
+			return nil, fmt.Errorf("%w: %s. http_endpoint: %v, source: %v", ErrGraphQL, strings.Join(messages, "; "), conn.connectordata.HTTPEndpoint, conn.connectordata.SourceName)
+		}
+		return resp.Data, nil
+	case ProtocolSOAP:
+		return soapBody(body)
+	case ProtocolHTTP, ProtocolWebSocket:
+	}
+	return body, nil
+}
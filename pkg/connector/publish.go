@@ -0,0 +1,276 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nuid"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/codec"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/encryption"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/largemsg"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/pgsink"
+)
+
+// errorPublishTimeout bounds the publishing of an error message with retries.
+const errorPublishTimeout = 30 * time.Second
+
+// NoResponseTopicMode defines what to do with the messages if RESPONSE_TOPIC is not set with the NATS response sink.
+type NoResponseTopicMode string
+
+const (
+	NoResponseTopicAckAndDrop NoResponseTopicMode = "ack-and-drop" // the message is acked, the response is dropped
+	NoResponseTopicRedeliver  NoResponseTopicMode = "redeliver"    // the message is left unacked and redelivered
+)
+
+func (m *NoResponseTopicMode) SetString(s string) error {
+	switch mode := NoResponseTopicMode(strings.ToLower(s)); mode {
+	case NoResponseTopicAckAndDrop, NoResponseTopicRedeliver:
+		*m = mode
+	default:
+		return fmt.Errorf("wrong no response topic mode: only 'ack-and-drop|redeliver' are accepted")
+	}
+	return nil
+}
+
+// noResponseTopic reports whether the responses have no destination: RESPONSE_TOPIC is not set with the NATS sink.
+func (conn *Connector) noResponseTopic() bool {
+	return conn.connectordata.ResponseSink == SinkNATS && len(conn.connectordata.ResponseTopic) == 0
+}
+
+// responseHandler publishes the response with the given headers to the response topic.
+func (conn *Connector) responseHandler(ctx context.Context, msg Message, response []byte, encoding string, hdr nats.Header) Outcome {
+	log := conn.log(ctx)
+
+	if conn.noResponseTopic() {
+		if conn.connectordata.NoResponseTopic == NoResponseTopicRedeliver {
+			log.Warn("Response topic not set - message is redelivered")
+			return OutcomeRedeliver
+		}
+		conn.metrics.discardedResponses("no_response_topic")
+		log.Debug("Response topic not set - response is dropped")
+		return OutcomeAck
+	}
+
+	data := response
+	if conn.connectordata.DeduplicateResponses {
+		if id := responseMsgID(msg); id != "" {
+			hdr.Set(jetstream.MsgIDHeader, id)
+		}
+	}
+
+	if encoding != "" {
+		var err error
+		data = conn.truncatePlaintext(response, hdr)
+		data, err = codec.Encode(encoding, data)
+		if err != nil {
+			log.Error("failed to compress response", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeRedeliver
+		}
+		hdr.Set(codec.HeaderContentEncoding, encoding)
+	}
+
+	if keyID := conn.connectordata.EncryptionKeyID; keyID != "" {
+		var err error
+		data, err = conn.connectordata.EncryptionKeys.Encrypt(keyID, data)
+		if err != nil {
+			log.Error("failed to encrypt response", slog.Any("error", err))
+			conn.errorHandler(ctx, err)
+			return OutcomeRedeliver
+		}
+		hdr.Set(encryption.HeaderKeyID, keyID)
+	}
+
+	// The response is published even if the processing is timed out meanwhile.
+	pubCtx := context.WithoutCancel(ctx)
+	err := conn.retryPublish(pubCtx, "response", func() error { return conn.publishResponse(pubCtx, data, hdr) })
+	if errors.Is(err, largemsg.ErrTooLarge) {
+		log.Error("Response is too large to be published - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeTerm
+	}
+	if errors.Is(err, pgsink.ErrRowRejected) {
+		log.Error("Response is rejected by the postgres sink - message is terminated", slog.Any("error", err))
+		conn.errorHandler(ctx, err)
+		return OutcomeTerm
+	}
+	if err != nil {
+		log.Error("failed to publish response body from http request to topic",
+			slog.Any("error", err),
+			slog.String("topic", conn.connectordata.ResponseTopic),
+			slog.String("source", conn.connectordata.SourceName),
+			slog.String("http endpoint", conn.connectordata.HTTPEndpoint),
+		)
+		return OutcomeRedeliver
+	} else {
+		log.Info("Response is sent", slog.String("topic", conn.connectordata.ResponseTopic), slog.String("response", string(response)))
+	}
+	return OutcomeAck
+}
+
+// truncatePlaintext truncates the response to be compressed in 'truncate' large response mode: compressed data
+// can't be truncated, so the plaintext is truncated to the max payload and marked with Nats-Truncated header.
+// The compressed response fits unless the data is incompressible, then the response is too large.
+func (conn *Connector) truncatePlaintext(response []byte, hdr nats.Header) []byte {
+	limit := conn.maxPayload - largemsg.HeaderReserve
+	if conn.connectordata.LargeResponseMode != largemsg.ModeTruncate || conn.connectordata.ResponseSink != SinkNATS ||
+		conn.maxPayload <= 0 || len(response) <= limit {
+		return response
+	}
+	hdr.Set(largemsg.HeaderTruncated, strconv.Itoa(len(response)))
+	conn.metrics.largeResponses("truncated")
+	return response[:limit]
+}
+
+// publishResponse publishes the response to the response topic.
+// Responses larger than the server's max payload are handled according to the configured large response mode.
+func (conn *Connector) publishResponse(ctx context.Context, response []byte, hdr nats.Header) error {
+	if kind := conn.connectordata.ResponseSink; kind != SinkNATS {
+		return conn.publishToSink(ctx, kind, false, response, hdr)
+	}
+
+	subject := conn.connectordata.ResponseTopic
+	maxPayload := conn.maxPayload
+
+	if maxPayload <= 0 || len(response) <= maxPayload-largemsg.HeaderReserve {
+		m := nats.NewMsg(subject)
+		m.Data = response
+		maps.Copy(m.Header, hdr)
+		_, err := conn.jsContext.PublishMsg(ctx, m)
+		if !errors.Is(err, nats.ErrMaxPayload) {
+			return err //nolint:wrapcheck // caller logs the error with the context
+		}
+		// Reconnected to a server with a smaller max payload.
+		maxPayload = int(conn.nc.MaxPayload())
+	}
+
+	switch conn.connectordata.LargeResponseMode {
+	case largemsg.ModeChunk:
+		for _, m := range largemsg.ChunkMsgs(subject, response, maxPayload-largemsg.HeaderReserve, nuid.Next()) {
+			maps.Copy(m.Header, hdr)
+			if id := hdr.Get(jetstream.MsgIDHeader); id != "" {
+				m.Header.Set(jetstream.MsgIDHeader, id+"-"+m.Header.Get(largemsg.HeaderChunkSeq))
+			}
+			if _, err := conn.jsContext.PublishMsg(ctx, m); err != nil {
+				return fmt.Errorf("publish chunk %s/%s: %w", m.Header.Get(largemsg.HeaderChunkSeq), m.Header.Get(largemsg.HeaderChunkTotal), err)
+			}
+		}
+		conn.metrics.largeResponses("chunked")
+		conn.log(ctx).Info("Large response is published in chunks", slog.String("topic", subject), slog.Int("size", len(response)))
+		return nil
+	case largemsg.ModeObjectStore:
+		name := nuid.Next()
+		if _, err := conn.objStore.PutBytes(name, response); err != nil {
+			return fmt.Errorf("put response to object store %q: %w", conn.connectordata.ObjectStoreBucket, err)
+		}
+		m := largemsg.ObjectRefMsg(subject, conn.connectordata.ObjectStoreBucket, name)
+		maps.Copy(m.Header, hdr)
+		if _, err := conn.jsContext.PublishMsg(ctx, m); err != nil {
+			return fmt.Errorf("publish object reference: %w", err)
+		}
+		conn.metrics.largeResponses("stored")
+		conn.log(ctx).Info("Large response is stored in object store", slog.String("topic", subject), slog.String("object", name), slog.Int("size", len(response)))
+		return nil
+	case largemsg.ModeTruncate:
+		if hdr.Get(codec.HeaderContentEncoding) != "" || hdr.Get(encryption.HeaderKeyID) != "" {
+			// a truncated compressed or encrypted response can't be decoded
+			return fmt.Errorf("encoded response of %d bytes can't be truncated: %w", len(response), largemsg.ErrTooLarge)
+		}
+		m := largemsg.TruncatedMsg(subject, response, maxPayload-largemsg.HeaderReserve)
+		maps.Copy(m.Header, hdr)
+		if _, err := conn.jsContext.PublishMsg(ctx, m); err != nil {
+			return fmt.Errorf("publish truncated response: %w", err)
+		}
+		conn.metrics.largeResponses("truncated")
+		conn.log(ctx).Warn("Large response is truncated", slog.String("topic", subject), slog.Int("size", len(response)), slog.Int("truncated_size", len(m.Data)))
+		return nil
+	case largemsg.ModeDrop:
+		conn.metrics.largeResponses("dropped")
+		conn.log(ctx).Warn("Large response is dropped", slog.String("topic", subject), slog.Int("size", len(response)))
+		conn.errorHandler(ctx, fmt.Errorf("response of %d bytes to topic %q is dropped, http_endpoint: %v, source: %v: %w",
+			len(response), subject, conn.connectordata.HTTPEndpoint, conn.connectordata.SourceName, largemsg.ErrTooLarge))
+		return nil
+	case largemsg.ModeFail:
+	}
+
+	conn.metrics.largeResponses("failed")
+	return fmt.Errorf("response of %d bytes to topic %q, http_endpoint: %v, source: %v: %w",
+		len(response), subject, conn.connectordata.HTTPEndpoint, conn.connectordata.SourceName, largemsg.ErrTooLarge)
+}
+
+// responseMsgID is the deterministic id of the response to the message: redelivered messages get the same id,
+// so the response stream drops duplicated responses within its duplicate window.
+func responseMsgID(msg Message) string {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", meta.Stream, meta.Sequence.Stream)
+}
+
+// errorHandler reports the error of the message processed with the context to the error topic.
+func (conn *Connector) errorHandler(ctx context.Context, err error) {
+	conn.stats.error(err)
+	conn.countError()
+
+	message := err.Error()
+	if conn.script != nil {
+		var keep bool
+		message, keep = conn.script.OnError(context.Background(), message)
+		if !keep {
+			return
+		}
+	}
+	if conn.errorLimiter != nil && !conn.errorLimiter.allow(message) {
+		return
+	}
+	conn.publishError(ctx, message)
+}
+
+// publishError publishes the error message to the error topic, or the dead letter with the message to the error sink.
+// The error is published even if the processing is timed out meanwhile, but not longer than errorPublishTimeout,
+// so an unreachable sink doesn't hold the message.
+func (conn *Connector) publishError(ctx context.Context, message string) {
+	log := conn.log(ctx)
+
+	pubCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), errorPublishTimeout)
+	defer cancel()
+
+	if kind := conn.connectordata.ErrorSink; kind != SinkNATS {
+		publishErr := conn.retryPublish(pubCtx, "error", func() error {
+			return conn.publishToSink(pubCtx, kind, true, conn.deadLetter(ctx, message), nil)
+		})
+		if publishErr != nil {
+			log.Error("failed to publish message to error sink", slog.Any("error", publishErr), slog.String("sink", string(kind)))
+		}
+		return
+	}
+
+	if len(conn.connectordata.ErrorTopic) == 0 {
+		log.Warn("error topic not set")
+		return
+	}
+
+	publishErr := conn.retryPublish(pubCtx, "error", func() error {
+		_, err := conn.jsContext.Publish(pubCtx, conn.connectordata.ErrorTopic, []byte(message))
+		return err //nolint:wrapcheck // logged with the topic
+	})
+	if publishErr != nil {
+		log.Error("failed to publish message to error topic",
+			slog.Any("error", publishErr),
+			slog.String("source", conn.connectordata.SourceName),
+			slog.String("message", publishErr.Error()),
+			slog.String("topic", conn.connectordata.ErrorTopic))
+	} else {
+		log.Info("Error is sent to fallback topic", slog.String("topic", conn.connectordata.ErrorTopic), slog.String("error", message))
+	}
+}
@@ -0,0 +1,55 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/largemsg"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/pgsink"
+)
+
+// publishPolicy returns the retry policy of the response and error publishes.
+func (c Config) publishPolicy() RetryPolicy {
+	return RetryPolicy{ //nolint:exhaustruct // no statuses and budget
+		MaxAttempts: c.PublishMaxAttempts,
+		Backoff:     Duration(c.PublishBackoff),
+		MaxBackoff:  Duration(c.PublishMaxBackoff),
+	}
+}
+
+// retryPublish calls publish up to PUBLISH_MAX_ATTEMPTS times with backoff until it succeeds.
+// Errors which can't be fixed by a retry (e.g. too large messages) are returned at once.
+// The topic (response|error) labels the metrics.
+func (conn *Connector) retryPublish(ctx context.Context, topic string, publish func() error) error {
+	policy := conn.connectordata.publishPolicy()
+
+	var err error
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			conn.metrics.publishRetries(topic)
+			conn.logger.Warn("Retrying publish", slog.String("topic", topic), slog.Int("attempt", attempt+1), slog.Any("error", err))
+			select {
+			case <-ctx.Done():
+				conn.metrics.publishFailures(topic)
+				return errors.Join(err, ctx.Err())
+			case <-time.After(policy.delay(attempt - 1)):
+			}
+		}
+		if err = publish(); err == nil || !retryablePublish(err) {
+			break
+		}
+	}
+	if err != nil {
+		conn.metrics.publishFailures(topic)
+	}
+	return err
+}
+
+func retryablePublish(err error) bool {
+	return !errors.Is(err, largemsg.ErrTooLarge) && !errors.Is(err, nats.ErrMaxPayload) && !errors.Is(err, pgsink.ErrRowRejected) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
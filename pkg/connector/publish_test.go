@@ -0,0 +1,206 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var errFakePublish = errors.New("fake publish error")
+
+// fakeSink records the published responses and errors. Publishing responses fails with err.
+type fakeSink struct {
+	err       error
+	published [][]byte
+	errors    [][]byte
+}
+
+func (s *fakeSink) Publish(_ context.Context, key string, data []byte, _ map[string][]string) error {
+	if key == "errors" {
+		s.errors = append(s.errors, data)
+		return nil
+	}
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, data)
+	return nil
+}
+
+func TestRespondGuaranteeAndNoResponseTopic(t *testing.T) {
+	tests := []struct {
+		name      string
+		guarantee Guarantee
+		mode      NoResponseTopicMode
+		sink      *fakeSink // nil - RESPONSE_TOPIC is not set with the NATS sink
+		outcome   Outcome
+		settles   []string
+		published int
+		errors    int // the responses lost with at_most_once are reported as errors
+	}{
+		{
+			name:      "at least once, no response topic, ack and drop",
+			guarantee: GuaranteeAtLeastOnce,
+			mode:      NoResponseTopicAckAndDrop,
+			outcome:   OutcomeAck,
+		},
+		{
+			name:      "at least once, no response topic, redeliver",
+			guarantee: GuaranteeAtLeastOnce,
+			mode:      NoResponseTopicRedeliver,
+			outcome:   OutcomeRedeliver,
+		},
+		{
+			name:      "at least once, publish succeeded",
+			guarantee: GuaranteeAtLeastOnce,
+			mode:      NoResponseTopicRedeliver,
+			sink:      &fakeSink{}, //nolint:exhaustruct // no error
+			outcome:   OutcomeAck,
+			published: 1,
+		},
+		{
+			name:      "at least once, publish failed",
+			guarantee: GuaranteeAtLeastOnce,
+			mode:      NoResponseTopicAckAndDrop,
+			sink:      &fakeSink{err: errFakePublish}, //nolint:exhaustruct // no messages
+			outcome:   OutcomeRedeliver,
+			errors:    0,
+		},
+		{
+			name:      "at most once, no response topic, ack and drop",
+			guarantee: GuaranteeAtMostOnce,
+			mode:      NoResponseTopicAckAndDrop,
+			outcome:   OutcomeAcked,
+			settles:   []string{"double_ack"},
+		},
+		{
+			name:      "at most once, no response topic, redeliver",
+			guarantee: GuaranteeAtMostOnce,
+			mode:      NoResponseTopicRedeliver,
+			outcome:   OutcomeRedeliver,
+		},
+		{
+			name:      "at most once, publish succeeded",
+			guarantee: GuaranteeAtMostOnce,
+			mode:      NoResponseTopicRedeliver,
+			sink:      &fakeSink{}, //nolint:exhaustruct // no error
+			outcome:   OutcomeAcked,
+			settles:   []string{"double_ack"},
+			published: 1,
+		},
+		{
+			name:      "at most once, publish failed",
+			guarantee: GuaranteeAtMostOnce,
+			mode:      NoResponseTopicAckAndDrop,
+			sink:      &fakeSink{err: errFakePublish}, //nolint:exhaustruct // no messages
+			outcome:   OutcomeAcked,
+			settles:   []string{"double_ack"},
+			errors:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+				ProcessingGuarantee: tt.guarantee,
+				NoResponseTopic:     tt.mode,
+				ResponseMerge:       MergeNone,
+				PublishMaxAttempts:  1,
+
+				AMQPResponseRoutingKey: "responses",
+				AMQPErrorRoutingKey:    "errors",
+			}, nil)
+			if tt.sink != nil {
+				conn.connectordata.ResponseSink = SinkAMQP
+				conn.connectordata.ErrorSink = SinkAMQP
+				conn.SetSink(SinkAMQP, tt.sink)
+			}
+			msg := newFakeMsg("{}")
+
+			o := conn.respond(context.Background(), msg, msg.Data(), "", []byte(`{"ok":true}`), http.StatusOK, http.Header{})
+			if o != tt.outcome {
+				t.Errorf("outcome = %v, want %v", o, tt.outcome)
+			}
+			if got := msg.settles(); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+			if tt.sink == nil {
+				return
+			}
+			if len(tt.sink.published) != tt.published {
+				t.Errorf("published %d responses, want %d", len(tt.sink.published), tt.published)
+			}
+			if len(tt.sink.errors) != tt.errors {
+				t.Errorf("published %d errors, want %d", len(tt.sink.errors), tt.errors)
+			}
+			if tt.errors > 0 {
+				var dl deadLetter
+				if err := json.Unmarshal(tt.sink.errors[0], &dl); err != nil || !strings.Contains(dl.Error, `{"ok":true}`) {
+					t.Errorf("error %q doesn't carry the response", tt.sink.errors[0])
+				}
+			}
+		})
+	}
+}
+
+func TestPublishErrorDeadLetter(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *fakeMsg // nil - the error is not bound to a message
+		want deadLetter
+	}{
+		{
+			name: "message",
+			msg:  newFakeMsg(`{"id":1}`).withHeader("X-Trace", "abc"),
+			want: deadLetter{
+				Error:     "endpoint failed",
+				Subject:   "test.subject",
+				StreamSeq: 1,
+				Delivered: 1,
+				Headers:   map[string][]string{"X-Trace": {"abc"}},
+				Data:      []byte(`{"id":1}`),
+			},
+		},
+		{
+			name: "no message",
+			want: deadLetter{Error: "endpoint failed"}, //nolint:exhaustruct // no message
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+				PublishMaxAttempts:  1,
+				AMQPErrorRoutingKey: "errors",
+			}, nil)
+			sink := &fakeSink{} //nolint:exhaustruct // no error
+			conn.connectordata.ErrorSink = SinkAMQP
+			conn.SetSink(SinkAMQP, sink)
+
+			ctx := context.Background()
+			if tt.msg != nil {
+				ctx = withMessage(ctx, tt.msg)
+			}
+			conn.errorHandler(ctx, errors.New("endpoint failed")) //nolint:goerr113 // test error
+
+			if len(sink.errors) != 1 {
+				t.Fatalf("published %d errors, want 1", len(sink.errors))
+			}
+			var got deadLetter
+			if err := json.Unmarshal(sink.errors[0], &got); err != nil {
+				t.Fatalf("dead letter %q: %v", sink.errors[0], err)
+			}
+			if got.Error != tt.want.Error || got.Subject != tt.want.Subject || got.StreamSeq != tt.want.StreamSeq ||
+				got.Delivered != tt.want.Delivered || string(got.Data) != string(tt.want.Data) || len(got.Headers) != len(tt.want.Headers) {
+				t.Errorf("dead letter = %+v, want %+v", got, tt.want)
+			}
+			for k, v := range tt.want.Headers {
+				if !slices.Equal(got.Headers[k], v) {
+					t.Errorf("header %s = %v, want %v", k, got.Headers[k], v)
+				}
+			}
+		})
+	}
+}
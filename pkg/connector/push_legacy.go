@@ -0,0 +1,264 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ConsumerMode defines how messages are delivered to the connector.
+type ConsumerMode string
+
+const (
+	// ConsumerModePull consumes messages of a pull consumer.
+	ConsumerModePull ConsumerMode = "pull"
+	// ConsumerModePushLegacy subscribes to the deliver subject of a push consumer with a queue group,
+	// as the old nats.go JetStream API does.
+	ConsumerModePushLegacy ConsumerMode = "push-legacy"
+)
+
+func (m *ConsumerMode) SetString(s string) error {
+	switch mode := ConsumerMode(strings.ToLower(s)); mode {
+	case ConsumerModePull, ConsumerModePushLegacy:
+		*m = mode
+	default:
+		return fmt.Errorf("wrong consumer mode: only 'pull|push-legacy' are accepted")
+	}
+	return nil
+}
+
+// ConsumePushLegacy consumes messages of the durable push consumer with DELIVER_SUBJECT and QUEUE_GROUP
+// until the context is done. The consumer is created if it doesn't exist and is not deleted on shutdown.
+func (conn *Connector) ConsumePushLegacy(ctx context.Context) error {
+	defer conn.markDrained()
+	log := conn.logger
+
+	js, err := conn.nc.JetStream()
+	if err != nil {
+		return fmt.Errorf("get legacy jetstream context: %w", err)
+	}
+
+	queue := conn.queueGroup()
+	_, err = conn.ensurePushConsumer(js, queue)
+	if err != nil {
+		return err
+	}
+
+	if !conn.endpointHealth.IsOpen() {
+		log.Info("Waiting for the HTTP endpoint to be healthy")
+		conn.endpointHealth.Wait(ctx)
+	}
+
+	go conn.rampUp(ctx)
+
+	log.Info("Start receiving messages", slog.String("mode", string(ConsumerModePushLegacy)), slog.String("queue_group", queue))
+
+	// Processing outlives the shutdown signal up to DRAIN_TIMEOUT.
+	procCtx, cancelProc := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelProc()
+
+	sub, err := conn.subscribePushLegacy(procCtx, js, queue)
+	if err != nil {
+		return err
+	}
+	conn.consuming.Store(true)
+
+	// The consumer of a queue group has no idle heartbeats, so it is checked by its info every CONSUME_HEARTBEAT instead.
+	ticker := time.NewTicker(conn.connectordata.ConsumeHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("closing connection...")
+			if sub != nil {
+				if err := sub.Unsubscribe(); err != nil { // bound subscription - the consumer is kept
+					log.Warn("Failed to unsubscribe from push consumer", slog.Any("error", err))
+				}
+			}
+			conn.drain(cancelProc)
+			return nil
+		case <-ticker.C:
+			sub = conn.checkPushConsumer(procCtx, js, queue, sub)
+		}
+	}
+}
+
+func (conn *Connector) subscribePushLegacy(procCtx context.Context, js nats.JetStreamContext, queue string) (*nats.Subscription, error) {
+	sub, err := js.QueueSubscribe(conn.filterSubject(), queue, func(m *nats.Msg) {
+		if conn.deferPaused(procCtx, legacyMsg{m}) {
+			return
+		}
+		conn.log(conn.withMessageLogger(procCtx, legacyMsg{m})).Info("Got a message", slog.String("message", string(m.Data)))
+		conn.dispatch(procCtx, legacyMsg{m})
+	}, nats.Bind(conn.connectordata.Topic, conn.consumer), nats.ManualAck())
+	if err != nil {
+		return nil, fmt.Errorf("subscribe to push consumer: %w", err)
+	}
+
+	conn.consumeHealth.stopped.Store(false)
+	conn.metrics.consumeHealthy.Set(1)
+	return sub, nil
+}
+
+// checkPushConsumer returns the subscription if its consumer exists. A failed check makes ConsumeCheck fail
+// as missed heartbeats do. The consumer deleted externally is recreated and subscribed again,
+// nil is returned if it fails and it is retried on the next check.
+func (conn *Connector) checkPushConsumer(procCtx context.Context, js nats.JetStreamContext, queue string, sub *nats.Subscription) *nats.Subscription {
+	log := conn.logger.With(slog.String("consumer", conn.consumer))
+
+	if sub != nil {
+		_, err := sub.ConsumerInfo()
+		if err == nil {
+			return sub
+		}
+		if !errors.Is(err, nats.ErrConsumerNotFound) {
+			conn.metrics.consumeErrors("other")
+			conn.consumeHealth.errAt.Store(time.Now().UnixNano())
+			log.Warn("Failed to check push consumer", slog.Any("error", err))
+			return sub
+		}
+
+		conn.metrics.consumeErrors("consumer_deleted")
+		conn.consumeHealth.stopped.Store(true)
+		conn.metrics.consumeHealthy.Set(0)
+		log.Error("Consuming is stopped - push consumer is deleted")
+		if err := sub.Unsubscribe(); err != nil {
+			log.Warn("Failed to unsubscribe from deleted push consumer", slog.Any("error", err))
+		}
+	}
+
+	created, err := conn.ensurePushConsumer(js, queue)
+	if err == nil {
+		if created {
+			conn.consumerRecreated(conn.filterSubject())
+		}
+		sub, err = conn.subscribePushLegacy(procCtx, js, queue)
+		if err == nil {
+			log.Info("Consuming is re-established")
+			return sub
+		}
+	}
+	log.Warn("Failed to re-establish consuming", slog.Any("error", err), slog.Duration("retry_in", conn.connectordata.ConsumeHeartbeat))
+	return nil
+}
+
+// ensurePushConsumer creates the durable push consumer unless it exists and reports whether it is created.
+// The created consumer has MAX_ACK_PENDING (CONCURRENT by default) limit, so a slow connector isn't flooded.
+// It has no flow control and idle heartbeats: they reach one random member of the queue group,
+// so the delivery could stall on an idle or draining member.
+func (conn *Connector) ensurePushConsumer(js nats.JetStreamContext, queue string) (bool, error) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("topic", cfg.Topic), slog.String("consumer", conn.consumer))
+
+	maxAckPending := cfg.MaxAckPending
+	if maxAckPending == 0 {
+		maxAckPending = int(cfg.Concurrent)
+	}
+	jconf := &nats.ConsumerConfig{ //nolint:exhaustruct // optional parameters
+		Durable:        conn.consumer,
+		DeliverSubject: conn.deliverSubject(),
+		DeliverGroup:   queue,
+		AckPolicy:      nats.AckExplicitPolicy,
+		FilterSubject:  conn.filterSubject(),
+		AckWait:        cfg.AckWait + time.Second,
+		MaxAckPending:  maxAckPending,
+	}
+	switch {
+	case cfg.StartSequence > 0:
+		jconf.DeliverPolicy = nats.DeliverByStartSequencePolicy
+		jconf.OptStartSeq = cfg.StartSequence
+	case !cfg.StartTime.IsZero():
+		jconf.DeliverPolicy = nats.DeliverByStartTimePolicy
+		jconf.OptStartTime = &cfg.StartTime
+	}
+
+	info, err := js.ConsumerInfo(cfg.Topic, conn.consumer)
+	// The consumer is recreated only if it doesn't start from the configured position, see deleteStaleConsumer.
+	if err == nil && cfg.RecreateConsumer && startOf(jconf.FilterSubject, int(jconf.DeliverPolicy), jconf.OptStartSeq, jconf.OptStartTime) !=
+		startOf(info.Config.FilterSubject, int(info.Config.DeliverPolicy), info.Config.OptStartSeq, info.Config.OptStartTime) {
+		err = js.DeleteConsumer(cfg.Topic, conn.consumer)
+		if err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+			return false, fmt.Errorf("delete consumer to recreate: %w", err)
+		}
+		log.Info("Consumer is deleted to be recreated")
+		err = nats.ErrConsumerNotFound
+	}
+	if err == nil {
+		if info.Config.DeliverSubject == "" {
+			return false, fmt.Errorf("consumer %s on stream %s is a pull consumer - a push consumer is required by %q consumer mode", conn.consumer, cfg.Topic, ConsumerModePushLegacy)
+		}
+		log.Info("Use consumer", slog.String("deliver_subject", info.Config.DeliverSubject))
+		return false, nil
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return false, fmt.Errorf("get consumer %s info: %w", conn.consumer, err)
+	}
+
+	_, err = js.AddConsumer(cfg.Topic, jconf)
+	if err != nil {
+		return false, fmt.Errorf("create push consumer: %w", err)
+	}
+	log.Info("New push consumer is created", slog.String("deliver_subject", jconf.DeliverSubject), slog.String("queue_group", queue))
+	return true, nil
+}
+
+func (conn *Connector) deliverSubject() string {
+	if conn.connectordata.DeliverSubject != "" {
+		return conn.connectordata.DeliverSubject
+	}
+	return "_DELIVER." + conn.consumer
+}
+
+func (conn *Connector) queueGroup() string {
+	if conn.connectordata.QueueGroup != "" {
+		return conn.connectordata.QueueGroup
+	}
+	return conn.consumer
+}
+
+// legacyMsg adapts the message of the old JetStream API to jetstream.Msg.
+type legacyMsg struct {
+	msg *nats.Msg
+}
+
+func (m legacyMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	meta, err := m.msg.Metadata()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // same errors as jetstream.Msg
+	}
+	return &jetstream.MsgMetadata{
+		Sequence:     jetstream.SequencePair{Consumer: meta.Sequence.Consumer, Stream: meta.Sequence.Stream},
+		NumDelivered: meta.NumDelivered,
+		NumPending:   meta.NumPending,
+		Timestamp:    meta.Timestamp,
+		Stream:       meta.Stream,
+		Consumer:     meta.Consumer,
+		Domain:       meta.Domain,
+	}, nil
+}
+
+func (m legacyMsg) Data() []byte         { return m.msg.Data }
+func (m legacyMsg) Headers() nats.Header { return m.msg.Header }
+func (m legacyMsg) Subject() string      { return m.msg.Subject }
+func (m legacyMsg) Reply() string        { return m.msg.Reply }
+
+func (m legacyMsg) Ack() error { return m.msg.Ack() } //nolint:wrapcheck // same errors as jetstream.Msg
+
+func (m legacyMsg) DoubleAck(ctx context.Context) error {
+	return m.msg.AckSync(nats.Context(ctx)) //nolint:wrapcheck // same errors as jetstream.Msg
+}
+
+func (m legacyMsg) Nak() error { return m.msg.Nak() } //nolint:wrapcheck // same errors as jetstream.Msg
+
+func (m legacyMsg) NakWithDelay(delay time.Duration) error {
+	return m.msg.NakWithDelay(delay) //nolint:wrapcheck // same errors as jetstream.Msg
+}
+
+func (m legacyMsg) InProgress() error { return m.msg.InProgress() } //nolint:wrapcheck // same errors as jetstream.Msg
+func (m legacyMsg) Term() error       { return m.msg.Term() }       //nolint:wrapcheck // same errors as jetstream.Msg
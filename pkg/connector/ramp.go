@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// rampUp limits the concurrency to RampUpStartPercent of CONCURRENT and raises it to 100% over RampUpDuration.
+// The limit is applied by holding concurrency slots and releasing them one by one.
+// Only one ramp-up runs at a time.
+func (conn *Connector) rampUp(ctx context.Context) {
+	cfg := conn.connectordata
+	if cfg.RampUpDuration <= 0 || !conn.ramping.CompareAndSwap(false, true) {
+		return
+	}
+	defer conn.ramping.Store(false)
+
+	limit := cap(conn.concurrentSem)
+	start := max(1, limit*cfg.RampUpStartPercent/100)
+
+	var reserved int
+	defer func() {
+		for ; reserved > 0; reserved-- {
+			<-conn.concurrentSem
+		}
+		conn.setEffectiveConcurrency(limit)
+	}()
+
+	for reserved < limit-start {
+		select {
+		case conn.concurrentSem <- 1:
+			reserved++
+		case <-ctx.Done():
+			return
+		}
+	}
+	if reserved == 0 {
+		return
+	}
+	conn.setEffectiveConcurrency(limit - reserved)
+
+	conn.logger.Info("Concurrency ramp-up is started",
+		slog.Int("from", limit-reserved),
+		slog.Int("to", limit),
+		slog.Duration("duration", cfg.RampUpDuration))
+
+	ticker := time.NewTicker(cfg.RampUpDuration / time.Duration(reserved))
+	defer ticker.Stop()
+
+	for reserved > 0 {
+		select {
+		case <-ticker.C:
+			<-conn.concurrentSem
+			reserved--
+			conn.setEffectiveConcurrency(limit - reserved)
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	conn.logger.Info("Concurrency ramp-up is finished", slog.Int("concurrent", limit))
+}
+
+func (conn *Connector) setEffectiveConcurrency(n int) {
+	conn.metrics.concurrencyEffective.Set(float64(n))
+	conn.stats.effectiveConcurrency.Store(int64(n))
+}
@@ -0,0 +1,83 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Receipt is published to RECEIPTS_SUBJECT for every processed message, so the producers can track
+// the processing completion without subscribing to the full responses.
+type Receipt struct {
+	Subject       string `json:"subject"`
+	StreamSeq     uint64 `json:"stream_seq,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	Result        string `json:"result"`
+	LatencyMs     int64  `json:"latency_ms"`
+	Status        int    `json:"status,omitempty"` // HTTP status of the endpoint, omitted if it didn't respond
+}
+
+type endpointStatusKey struct{}
+
+// receiptsSubject returns RECEIPTS_SUBJECT, "<TOPIC>.receipts" by default.
+func (c Config) receiptsSubject() string {
+	if c.ReceiptsSubject != "" {
+		return c.ReceiptsSubject
+	}
+	return c.Topic + ".receipts"
+}
+
+// withEndpointStatus returns the context recording the endpoint status of the message for its receipt.
+func (conn *Connector) withEndpointStatus(ctx context.Context) context.Context {
+	if !conn.connectordata.Receipts {
+		return ctx
+	}
+	return context.WithValue(ctx, endpointStatusKey{}, new(atomic.Int64))
+}
+
+// setEndpointStatus records the status the endpoint responded with, including the failure status of the error.
+func setEndpointStatus(ctx context.Context, status int, err error) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.StatusCode
+	}
+	if s, ok := ctx.Value(endpointStatusKey{}).(*atomic.Int64); ok && status > 0 {
+		s.Store(int64(status))
+	}
+}
+
+// publishReceipt publishes the receipt of the processed message with the result.
+// Receipts are not published for the messages canceled on shutdown (they are redelivered) and the accepted async jobs
+// (the receipt is published when the job is completed).
+func (conn *Connector) publishReceipt(ctx context.Context, msg Message, result string, latency time.Duration) {
+	if !conn.connectordata.Receipts || result == "canceled" || result == "pending" {
+		return
+	}
+
+	receipt := Receipt{
+		Subject:       msg.Subject(),
+		StreamSeq:     0,
+		CorrelationID: msg.Headers().Get(conn.connectordata.CorrelationIDHeader),
+		Result:        result,
+		LatencyMs:     latency.Milliseconds(),
+		Status:        0,
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		receipt.StreamSeq = meta.Sequence.Stream
+	}
+	if s, ok := ctx.Value(endpointStatusKey{}).(*atomic.Int64); ok {
+		receipt.Status = int(s.Load())
+	}
+
+	subject := conn.connectordata.receiptsSubject()
+	data, err := json.Marshal(receipt)
+	if err == nil {
+		err = conn.nc.Publish(subject, data)
+	}
+	if err != nil {
+		conn.log(ctx).Error("Failed to publish receipt", slog.String("subject", subject), slog.Any("error", err))
+	}
+}
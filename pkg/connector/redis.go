@@ -0,0 +1,191 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/jsonpointer"
+)
+
+// errKV is wrapped by enrichment failures of the key-value store, which are retried unlike failures of the payload.
+var errKV = errors.New("key-value store failure")
+
+// KV is the key-value store of response caching and enrichment, e.g. Redis.
+type KV interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// SetKV sets the key-value store used by REDIS_CACHE_TTL and REDIS_ENRICH.
+func (conn *Connector) SetKV(kv KV) {
+	conn.kv = kv
+}
+
+// Enrichment sets the value of the key to the target field of the payload.
+type Enrichment struct {
+	Target jsonpointer.Pointer
+	// Key is the key template, '{/json/pointer}' placeholders are replaced with the payload fields.
+	Key string
+}
+
+// Enrichments are set as '/target=key:{/json/pointer},...'.
+type Enrichments []Enrichment
+
+func (e *Enrichments) SetString(s string) error {
+	*e = nil
+	if s == "" {
+		return nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		target, key, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || key == "" {
+			return fmt.Errorf("wrong enrichment %q: '/target=key' is expected", item)
+		}
+		p, err := jsonpointer.Parse(target)
+		if err != nil {
+			return fmt.Errorf("enrichment target %q: %w", target, err)
+		}
+		if len(p) == 0 {
+			return fmt.Errorf("enrichment target %q: the whole payload can't be replaced", target)
+		}
+		for _, m := range keyPlaceholder.FindAllStringSubmatch(key, -1) {
+			if _, err := jsonpointer.Parse(m[1]); err != nil {
+				return fmt.Errorf("enrichment key %q: %w", key, err)
+			}
+		}
+		*e = append(*e, Enrichment{Target: p, Key: key})
+	}
+	return nil
+}
+
+var keyPlaceholder = regexp.MustCompile(`\{(/[^}]*)\}`)
+
+// key returns the key of the payload. It fails if a placeholder field is missing.
+func (e Enrichment) key(doc any) (string, error) {
+	var err error
+	key := keyPlaceholder.ReplaceAllStringFunc(e.Key, func(m string) string {
+		p, _ := jsonpointer.Parse(m[1 : len(m)-1])
+		v, getErr := p.Get(doc)
+		if getErr != nil {
+			err = getErr
+			return ""
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	})
+	return key, err //nolint:wrapcheck // caller wraps with the key template
+}
+
+// enrich sets the values of REDIS_ENRICH keys to the JSON payload. Missing keys leave the target fields unchanged.
+func (conn *Connector) enrich(ctx context.Context, data []byte) ([]byte, error) {
+	var doc any
+	if err := decodeJSON(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse payload as JSON to enrich: %w", err)
+	}
+
+	for _, e := range conn.connectordata.RedisEnrich {
+		key, err := e.key(doc)
+		if err != nil {
+			return nil, fmt.Errorf("enrichment key %q: %w", e.Key, err)
+		}
+		value, ok, err := conn.kv.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("enrich %s: %w: %w", e.Target, errKV, err)
+		}
+		if !ok {
+			conn.metrics.enrichments("missing")
+			continue
+		}
+		conn.metrics.enrichments("found")
+
+		var v any
+		if decodeJSON(value, &v) != nil {
+			v = string(value) // not JSON values are set as strings
+		}
+		doc, err = e.Target.Set(doc, v)
+		if err != nil {
+			return nil, fmt.Errorf("enrich %s: %w", e.Target, err)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal enriched payload: %w", err)
+	}
+	return data, nil
+}
+
+// cacheKey returns the response cache key of the message to the resolved endpoint, or empty string if the response is not cached.
+// The endpoint is a part of the key: messages routed to different endpoints don't share the responses.
+func (conn *Connector) cacheKey(endpoint string, hdr map[string][]string) string {
+	if conn.kv == nil || conn.connectordata.RedisCacheTTL <= 0 {
+		return ""
+	}
+	if v := hdr[conn.connectordata.RedisCacheKeyHeader]; len(v) > 0 && v[0] != "" {
+		return "response:" + endpoint + ":" + v[0]
+	}
+	return ""
+}
+
+// cachedResponse is the cached response with its status and headers, so a hit is handled as the original response.
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// invokeCached returns the cached response of the key, or invokes the endpoint and caches the response for REDIS_CACHE_TTL.
+// Cache failures are logged only, the endpoint is invoked as if the cache is disabled.
+// GET invocations are cached by URL in memory if HTTP_CACHE_TTL is set.
+func (conn *Connector) invokeCached(ctx context.Context, cfg Config, data []byte, headers http.Header, key string) ([]byte, int, http.Header, error) {
+	if conn.getCache != nil && cfg.HTTPMethod == http.MethodGet && cfg.InvokeProtocol == ProtocolHTTP {
+		return conn.invokeGetCached(ctx, cfg, data, headers)
+	}
+	if key == "" {
+		return conn.invoke(ctx, cfg, data, headers)
+	}
+
+	value, ok, err := conn.kv.Get(ctx, key)
+	var cached cachedResponse
+	switch {
+	case err != nil:
+		conn.metrics.responseCache("error")
+		conn.log(ctx).Warn("failed to get cached response", slog.Any("error", err))
+	case ok && json.Unmarshal(value, &cached) != nil:
+		conn.metrics.responseCache("error")
+		conn.log(ctx).Warn("failed to parse cached response", slog.String("key", key))
+	case ok:
+		conn.metrics.responseCache("hit")
+		if cached.Header == nil {
+			cached.Header = http.Header{}
+		}
+		return cached.Body, cached.Status, cached.Header, nil
+	default:
+		conn.metrics.responseCache("miss")
+	}
+
+	body, status, respHeader, err := conn.invoke(ctx, cfg, data, headers)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	value, err = json.Marshal(cachedResponse{Status: status, Header: respHeader, Body: body})
+	if err == nil {
+		err = conn.kv.Set(ctx, key, value, cfg.RedisCacheTTL)
+	}
+	if err != nil {
+		conn.metrics.responseCache("error")
+		conn.log(ctx).Warn("failed to cache response", slog.Any("error", err))
+	}
+	return body, status, respHeader, nil
+}
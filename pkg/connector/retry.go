@@ -0,0 +1,89 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// RetryPolicy defines how the endpoint invocation is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of invocation attempts, the first one included.
+	MaxAttempts int `json:"max_attempts"`
+	// Backoff is the delay before the first retry, it is doubled for every next retry up to MaxBackoff.
+	Backoff Duration `json:"backoff"`
+	// MaxBackoff limits the retry delay, no limit if it is zero.
+	MaxBackoff Duration `json:"max_backoff"`
+	// RetryStatuses are response statuses which are retried. All failure statuses are retried if it is empty.
+	// Transport errors are always retried.
+	RetryStatuses []int `json:"retry_statuses"`
+	// Budget limits the total time of the attempts and delays, no limit if it is zero.
+	Budget Duration `json:"budget"`
+}
+
+// RetryPolicies are named retry policies referenced by RETRY_POLICY and routes.
+type RetryPolicies map[string]RetryPolicy
+
+// SetString parses policies from JSON: '{"fast":{"max_attempts":3,"backoff":"100ms","retry_statuses":[429,503]}}'.
+func (p *RetryPolicies) SetString(s string) error {
+	var policies RetryPolicies
+	if err := json.Unmarshal([]byte(s), &policies); err != nil {
+		return fmt.Errorf("parse retry policies: %w", err)
+	}
+	for name, policy := range policies {
+		if policy.MaxAttempts <= 0 {
+			return fmt.Errorf("retry policy %q: max_attempts must be positive", name)
+		}
+		if policy.Backoff < 0 || policy.MaxBackoff < 0 || policy.Budget < 0 {
+			return fmt.Errorf("retry policy %q: backoff, max_backoff and budget can't be negative", name)
+		}
+	}
+	*p = policies
+	return nil
+}
+
+// retryPolicy returns the policy of the invocation: the policy named by RETRY_POLICY (or by the matched route),
+// or MAX_RETRIES immediate retries of any failure if it is not set.
+func (c Config) retryPolicy() RetryPolicy {
+	if policy, ok := c.RetryPolicies[c.RetryPolicy]; ok {
+		return policy
+	}
+	return RetryPolicy{MaxAttempts: c.MaxRetries + 1} //nolint:exhaustruct // no backoff and budget
+}
+
+// retryable reports whether the response status is retried.
+func (p RetryPolicy) retryable(status int) bool {
+	return len(p.RetryStatuses) == 0 || slices.Contains(p.RetryStatuses, status)
+}
+
+// delay returns the delay before the retry after the attempt (0-based).
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := time.Duration(p.Backoff)
+	for i := 0; i < attempt && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= time.Duration(p.MaxBackoff) {
+			return time.Duration(p.MaxBackoff)
+		}
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, time.Duration(p.MaxBackoff))
+	}
+	return d
+}
+
+// Duration is a time.Duration set in JSON as a string, e.g. "100ms".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration should be a string like \"100ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err //nolint:wrapcheck // error has the value
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,86 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+)
+
+// defaultRoute labels the messages not matched by ROUTES.
+const defaultRoute = "default"
+
+type routeKey struct{}
+
+// Route sends messages matching the rule to the endpoint.
+type Route struct {
+	Name     string `json:"name"`
+	When     Rule   `json:"when"`
+	Endpoint string `json:"endpoint"`
+	// RetryPolicy names the retry policy of the route's invocations, RETRY_POLICY is used if it is empty.
+	RetryPolicy string `json:"retry_policy"`
+}
+
+// Routes are evaluated in order, the first matching route selects the endpoint.
+type Routes []Route
+
+// SetString parses routes from JSON: '[{"name":"refunds","when":".type == \"refund\"","endpoint":"http://refunds-svc"}]'.
+func (r *Routes) SetString(s string) error {
+	var routes Routes
+	if err := json.Unmarshal([]byte(s), &routes); err != nil {
+		return fmt.Errorf("parse routes: %w", err)
+	}
+	for i, route := range routes {
+		if route.Name == "" || route.Endpoint == "" || !route.When.Enabled() {
+			return fmt.Errorf("route %d: name, when and endpoint are required", i)
+		}
+	}
+	*r = routes
+	return nil
+}
+
+// selectEndpoint returns the endpoint and the retry policy of the first route matching the message,
+// or HTTP_ENDPOINT and RETRY_POLICY if none matches. The route is recorded for the logger and the metrics of the message.
+func (conn *Connector) selectEndpoint(ctx context.Context, data []byte, hdr http.Header) (endpoint, retryPolicy string) {
+	for _, route := range conn.connectordata.Routes {
+		if route.When.Match(data, hdr) {
+			conn.metrics.routedMessages(route.Name)
+			conn.setRoute(ctx, route.Name)
+			conn.log(ctx).Debug("Message is routed", slog.String("http_endpoint", route.Endpoint))
+			if route.RetryPolicy != "" {
+				return route.Endpoint, route.RetryPolicy
+			}
+			return route.Endpoint, conn.connectordata.RetryPolicy
+		}
+	}
+
+	if len(conn.connectordata.Routes) > 0 {
+		conn.metrics.routedMessages(defaultRoute)
+		conn.setRoute(ctx, defaultRoute)
+	}
+	return conn.connectordata.HTTPEndpoint, conn.connectordata.RetryPolicy
+}
+
+// withRoute returns the context recording the route of the message for its metrics.
+func withRoute(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, new(atomic.Pointer[string]))
+}
+
+func (conn *Connector) setRoute(ctx context.Context, name string) {
+	if r, ok := ctx.Value(routeKey{}).(*atomic.Pointer[string]); ok {
+		r.Store(&name)
+	}
+	conn.addLogAttrs(ctx, slog.String("route", name))
+}
+
+// routeOf returns the route of the message processed with the context, 'default' if it is not routed.
+func routeOf(ctx context.Context) string {
+	if r, ok := ctx.Value(routeKey{}).(*atomic.Pointer[string]); ok {
+		if name := r.Load(); name != nil {
+			return *name
+		}
+	}
+	return defaultRoute
+}
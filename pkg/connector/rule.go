@@ -0,0 +1,80 @@
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/jq"
+)
+
+// Rule is a jq filter on the JSON body, e.g. '.result.skip == true' or '.items | length > 0',
+// or a condition on the value of a header, e.g. 'header:Event-Type == "refund"'.
+type Rule struct {
+	filter *jq.Filter
+	header string
+	value  string
+}
+
+// ParseRule parses the rule: a jq filter (see pkg/jq for the supported subset) matching the body if its result is
+// neither false nor null, or 'header:<name> == <JSON string>'.
+func ParseRule(s string) (Rule, error) {
+	if field, value, ok := strings.Cut(s, "=="); ok {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(field), "header:"); ok {
+			var v string
+			if err := json.Unmarshal([]byte(strings.TrimSpace(value)), &v); err != nil || name == "" {
+				return Rule{}, fmt.Errorf("wrong rule %q: header value should be a JSON string", s)
+			}
+			return Rule{filter: nil, header: name, value: v}, nil
+		}
+	}
+
+	filter, err := jq.Parse(s)
+	if err != nil {
+		return Rule{}, fmt.Errorf("wrong rule: %w", err)
+	}
+	return Rule{filter: filter, header: "", value: ""}, nil
+}
+
+// SetString parses the rule, see ParseRule. The empty string disables the rule.
+func (r *Rule) SetString(s string) error {
+	if strings.TrimSpace(s) == "" {
+		*r = Rule{}
+		return nil
+	}
+
+	rule, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+// UnmarshalText parses the rule, so rules can be configured inside of JSON.
+func (r *Rule) UnmarshalText(text []byte) error {
+	return r.SetString(string(text))
+}
+
+// Enabled reports whether the rule is set.
+func (r Rule) Enabled() bool {
+	return r.filter != nil || r.header != ""
+}
+
+// Match reports whether the jq filter of the rule matches the JSON body, or whether a value of the header is equal
+// to the rule value. The header name is matched exactly as it is set: message headers keep the case they are published with,
+// HTTP response headers are canonical (e.g. 'Content-Type').
+func (r Rule) Match(body []byte, hdr http.Header) bool {
+	if r.header != "" {
+		return slices.Contains(hdr[r.header], r.value)
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return false
+	}
+	result, err := r.filter.Eval(v)
+	return err == nil && jq.Truthy(result)
+}
@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Sink publishes responses or errors to a broker other than NATS.
+type Sink interface {
+	Publish(ctx context.Context, key string, data []byte, headers map[string][]string) error
+}
+
+// SinkKind is the destination of responses or errors.
+type SinkKind string
+
+const (
+	SinkNATS SinkKind = "nats"
+	SinkAMQP SinkKind = "amqp"
+	SinkSQS  SinkKind = "sqs"
+	SinkSNS  SinkKind = "sns"
+	// SinkWebhook posts to all WEBHOOK_URLS, errors are posted to WEBHOOK_ERROR_URLS.
+	SinkWebhook SinkKind = "webhook"
+	// SinkPostgres inserts responses into POSTGRES_TABLE, it can't be an error sink.
+	SinkPostgres SinkKind = "postgres"
+)
+
+func (k *SinkKind) SetString(s string) error {
+	switch kind := SinkKind(strings.ToLower(s)); kind {
+	case SinkNATS, SinkAMQP, SinkSQS, SinkSNS, SinkWebhook, SinkPostgres:
+		*k = kind
+	default:
+		return fmt.Errorf("wrong sink: only 'nats|amqp|sqs|sns|webhook|postgres' are accepted")
+	}
+	return nil
+}
+
+// sinkWebhookError is the webhook sink of errors: webhooks have no routing key, so errors have their own URLs.
+const sinkWebhookError SinkKind = "webhook_error"
+
+// SetSink sets the sink used by RESPONSE_SINK and ERROR_SINK of the kind.
+func (conn *Connector) SetSink(kind SinkKind, s Sink) {
+	conn.sinks[kind] = s
+}
+
+// SetWebhookSinks sets the webhook sinks of responses and errors.
+func (conn *Connector) SetWebhookSinks(responses, errors Sink) {
+	conn.sinks[SinkWebhook] = responses
+	conn.sinks[sinkWebhookError] = errors
+}
+
+// sinkKey returns the routing key of the sink for the response or the error.
+func (conn *Connector) sinkKey(kind SinkKind, isError bool) string {
+	cfg := conn.connectordata
+
+	switch kind {
+	case SinkAMQP:
+		if isError {
+			return cfg.AMQPErrorRoutingKey
+		}
+		return cfg.AMQPResponseRoutingKey
+	case SinkSQS:
+		if isError {
+			return cfg.SQSErrorQueueURL
+		}
+		return cfg.SQSResponseQueueURL
+	case SinkSNS:
+		if isError {
+			return cfg.SNSErrorTopicARN
+		}
+		return cfg.SNSResponseTopicARN
+	case SinkNATS:
+	}
+	if isError {
+		return cfg.ErrorTopic
+	}
+	return cfg.ResponseTopic
+}
+
+func (conn *Connector) publishToSink(ctx context.Context, kind SinkKind, isError bool, data []byte, headers map[string][]string) error {
+	if kind == SinkWebhook && isError {
+		kind = sinkWebhookError
+	}
+
+	s, ok := conn.sinks[kind]
+	if !ok {
+		return fmt.Errorf("sink %q is not configured", kind)
+	}
+	return s.Publish(ctx, conn.sinkKey(kind, isError), data, headers) //nolint:wrapcheck // sinks wrap errors with the destination
+}
+
+type messageKey struct{}
+
+// withMessage returns the context carrying the processed message, so its errors are published to ERROR_SINK with it.
+func withMessage(ctx context.Context, msg Message) context.Context {
+	return context.WithValue(ctx, messageKey{}, msg)
+}
+
+// deadLetter is the error published to ERROR_SINK with the message it failed, so the message can be replayed
+// from the sink. The message fields are empty for the errors not bound to a message.
+type deadLetter struct {
+	Error     string              `json:"error"`
+	Subject   string              `json:"subject,omitempty"`
+	StreamSeq uint64              `json:"stream_seq,omitempty"`
+	Delivered uint64              `json:"delivered,omitempty"`
+	Headers   map[string][]string `json:"headers,omitempty"`
+	Data      []byte              `json:"data,omitempty"` // base64 encoded
+}
+
+// deadLetter returns the JSON dead letter of the error of the message processed with the context.
+func (conn *Connector) deadLetter(ctx context.Context, message string) []byte {
+	dl := deadLetter{Error: message} //nolint:exhaustruct // message fields are set below
+	if msg, ok := ctx.Value(messageKey{}).(Message); ok {
+		dl.Subject, dl.Headers, dl.Data = msg.Subject(), msg.Headers(), msg.Data()
+		if meta, err := msg.Metadata(); err == nil {
+			dl.StreamSeq, dl.Delivered = meta.Sequence.Stream, meta.NumDelivered
+		}
+	}
+	data, _ := json.Marshal(dl) //nolint:errchkjson // marshaling of the strings and bytes doesn't fail
+	return data
+}
@@ -0,0 +1,45 @@
+package connector
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HeaderTraceparent is the W3C trace context header the trace ID of the exemplars is taken from.
+const HeaderTraceparent = "Traceparent"
+
+// observeSLO counts the final result of the message by the SLO counters: failedResults are failures,
+// other results are successes except the canceled (shutdown), pending (async job) and replayed (skipped) ones.
+// With SLO_EXEMPLARS the failures have the trace ID of the message as the exemplar.
+func (conn *Connector) observeSLO(msg Message, result string) {
+	stream, consumer := conn.connectordata.Topic, conn.consumer
+
+	switch {
+	case result == "canceled" || result == "pending" || result == "replayed":
+		return
+	case !slices.Contains(failedResults, result):
+		conn.metrics.sloSuccess.WithLabelValues(stream, consumer).Inc()
+		return
+	}
+
+	failure := conn.metrics.sloFailure.WithLabelValues(stream, consumer, result)
+	if conn.connectordata.SLOExemplars {
+		if traceID, ok := traceID(http.Header(msg.Headers()).Get(HeaderTraceparent)); ok {
+			failure.(prometheus.ExemplarAdder).AddWithExemplar(1, prometheus.Labels{"trace_id": traceID}) //nolint:forcetypeassert // counter
+			return
+		}
+	}
+	failure.Inc()
+}
+
+// traceID returns the trace ID of the traceparent header: version-traceid-parentid-flags.
+func traceID(traceparent string) (string, bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" {
+		return "", false
+	}
+	return parts[1], true
+}
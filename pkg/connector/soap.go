@@ -0,0 +1,148 @@
+package connector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"text/template"
+)
+
+// SOAPVersion is the version of the SOAP protocol: it defines the default envelope and how the action is sent.
+type SOAPVersion string
+
+const (
+	// SOAP11 sends the action in SOAPAction header with 'text/xml' content type.
+	SOAP11 SOAPVersion = "1.1"
+	// SOAP12 sends the action as the parameter of 'application/soap+xml' content type.
+	SOAP12 SOAPVersion = "1.2"
+)
+
+func (v *SOAPVersion) SetString(s string) error {
+	switch version := SOAPVersion(s); version {
+	case SOAP11, SOAP12:
+		*v = version
+	default:
+		return fmt.Errorf("wrong soap version: only '1.1|1.2' are accepted")
+	}
+	return nil
+}
+
+// Default envelopes are used if SOAP_ENVELOPE is not set.
+var (
+	soap11Envelope = template.Must(template.New("envelope").Parse(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>{{.Body}}</soap:Body></soap:Envelope>`))
+	soap12Envelope = template.Must(template.New("envelope").Parse(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body>{{.Body}}</soap:Body></soap:Envelope>`))
+)
+
+var ErrSOAPFault = errors.New("soap fault")
+
+// SOAPFault is a fault response of a SOAP service. Client faults are permanent, others are retried.
+type SOAPFault struct {
+	Code   string
+	Reason string
+}
+
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrSOAPFault, f.Code, f.Reason)
+}
+
+func (f *SOAPFault) Unwrap() error { return ErrSOAPFault }
+
+// Client reports whether the fault is caused by the request (SOAP 1.1 'Client' or SOAP 1.2 'Sender' code).
+func (f *SOAPFault) Client() bool {
+	return strings.HasSuffix(f.Code, "Client") || strings.HasSuffix(f.Code, "Sender")
+}
+
+// EnvelopeTemplate is the text/template of the SOAP envelope, the message is available as {{.Body}}.
+type EnvelopeTemplate struct {
+	tmpl *template.Template
+}
+
+func (t *EnvelopeTemplate) SetString(s string) error {
+	if s == "" {
+		*t = EnvelopeTemplate{}
+		return nil
+	}
+
+	tmpl, err := template.New("envelope").Parse(s)
+	if err != nil {
+		return fmt.Errorf("parse soap envelope template: %w", err)
+	}
+	*t = EnvelopeTemplate{tmpl: tmpl}
+	return nil
+}
+
+// soapEnvelope wraps the XML message into the envelope.
+func (conn *Connector) soapEnvelope(data []byte) ([]byte, error) {
+	tmpl := conn.connectordata.SOAPEnvelope.tmpl
+	switch {
+	case tmpl != nil:
+	case conn.connectordata.SOAPVersion == SOAP12:
+		tmpl = soap12Envelope
+	default:
+		tmpl = soap11Envelope
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ Body string }{string(data)}); err != nil {
+		return nil, fmt.Errorf("execute soap envelope template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// soapContentType returns the content type of SOAP requests: SOAP 1.2 has the action as its parameter.
+func (c Config) soapContentType() string {
+	if c.SOAPVersion != SOAP12 {
+		return "text/xml; charset=utf-8"
+	}
+	params := map[string]string{"charset": "utf-8"}
+	if c.SOAPAction != "" {
+		params["action"] = c.SOAPAction
+	}
+	return mime.FormatMediaType("application/soap+xml", params)
+}
+
+// soapBody returns the content of the envelope body, or *SOAPFault if the body is a fault.
+func soapBody(data []byte) ([]byte, error) {
+	var env struct {
+		Body struct {
+			Inner []byte `xml:",innerxml"`
+			Fault *struct {
+				Code11   string `xml:"faultcode"`
+				Reason11 string `xml:"faultstring"`
+				Code12   string `xml:"Code>Value"`
+				Reason12 string `xml:"Reason>Text"`
+			} `xml:"Fault"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("parse soap envelope: %w", err)
+	}
+
+	if f := env.Body.Fault; f != nil {
+		fault := &SOAPFault{Code: f.Code11, Reason: f.Reason11}
+		if fault.Code == "" {
+			fault.Code, fault.Reason = f.Code12, f.Reason12
+		}
+		return nil, fault
+	}
+	return bytes.TrimSpace(env.Body.Inner), nil
+}
+
+// soapFault returns the fault of the failure response in 'soap' invoke protocol, or nil.
+func (conn *Connector) soapFault(err error) *SOAPFault {
+	var statusErr *StatusError
+	if conn.connectordata.InvokeProtocol != ProtocolSOAP || !errors.As(err, &statusErr) {
+		return nil
+	}
+
+	var fault *SOAPFault
+	if _, err := soapBody(statusErr.Body); errors.As(err, &fault) {
+		return fault
+	}
+	return nil
+}
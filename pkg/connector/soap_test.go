@@ -0,0 +1,88 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleSOAPVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     SOAPVersion
+		action      string
+		contentType string
+		soapAction  string
+		namespace   string
+	}{
+		{
+			name:        "soap 1.1",
+			version:     SOAP11,
+			action:      "urn:CreateOrder",
+			contentType: "text/xml; charset=utf-8",
+			soapAction:  "urn:CreateOrder",
+			namespace:   "http://schemas.xmlsoap.org/soap/envelope/",
+		},
+		{
+			name:        "soap 1.2",
+			version:     SOAP12,
+			action:      "urn:CreateOrder",
+			contentType: `application/soap+xml; action="urn:CreateOrder"; charset=utf-8`,
+			namespace:   "http://www.w3.org/2003/05/soap-envelope",
+		},
+		{
+			name:        "soap 1.2 without action",
+			version:     SOAP12,
+			contentType: "application/soap+xml; charset=utf-8",
+			namespace:   "http://www.w3.org/2003/05/soap-envelope",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				body, _ = io.ReadAll(r.Body)
+				io.WriteString(w, `<Envelope><Body><Order>1</Order></Body></Envelope>`) //nolint:errcheck // test response
+			}))
+			defer srv.Close()
+
+			conn := newTestConnector(Config{ //nolint:exhaustruct // test config
+				HTTPEndpoint:       srv.URL,
+				HTTPMethod:         http.MethodPost,
+				InvokeProtocol:     ProtocolSOAP,
+				SOAPVersion:        tt.version,
+				SOAPAction:         tt.action,
+				ResponseMerge:      MergeNone,
+				PublishMaxAttempts: 1,
+
+				AMQPResponseRoutingKey: "responses",
+				AMQPErrorRoutingKey:    "errors",
+			}, nil)
+			sink := &fakeSink{} //nolint:exhaustruct // no error
+			conn.connectordata.ResponseSink = SinkAMQP
+			conn.connectordata.ErrorSink = SinkAMQP
+			conn.SetSink(SinkAMQP, sink)
+
+			if o := conn.handle(context.Background(), newFakeMsg("<Order/>")); o != OutcomeAck {
+				t.Fatalf("outcome = %v, want %v (errors: %q)", o, OutcomeAck, sink.errors)
+			}
+			if ct := req.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("content type = %q, want %q", ct, tt.contentType)
+			}
+			if a := req.Header.Get("SOAPAction"); a != tt.soapAction {
+				t.Errorf("SOAPAction = %q, want %q", a, tt.soapAction)
+			}
+			if !strings.Contains(string(body), `xmlns:soap="`+tt.namespace+`"`) || !strings.Contains(string(body), "<soap:Body><Order/></soap:Body>") {
+				t.Errorf("envelope = %s, want %s namespace", body, tt.namespace)
+			}
+			if len(sink.published) != 1 || string(sink.published[0]) != "<Order>1</Order>" {
+				t.Errorf("published = %q, want the body content", sink.published)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package connector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	sseMinBackoff = time.Second
+	sseMaxBackoff = time.Minute
+)
+
+// HeaderSSEEvent is the header with the SSE event type of the published event.
+const HeaderSSEEvent = "Sse-Event"
+
+// sseEvent is a dispatched Server-Sent Event.
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// RunSSESource subscribes to SSE_SOURCE_URL and publishes received events to SSE_SOURCE_SUBJECT until the context is done.
+// The connection is reestablished with exponential backoff, the last event id is sent in 'Last-Event-ID' header on reconnect.
+func (conn *Connector) RunSSESource(ctx context.Context) {
+	cfg := conn.connectordata
+	log := conn.logger.With(slog.String("source", "sse"), slog.String("url", cfg.SSESourceURL))
+
+	var lastID string
+	backoff := sseMinBackoff
+	for ctx.Err() == nil {
+		n, err := conn.readSSE(ctx, &lastID)
+		if ctx.Err() != nil {
+			return
+		}
+		if n > 0 {
+			backoff = sseMinBackoff
+		}
+		log.Warn("SSE stream is closed - reconnecting", slog.Any("error", err), slog.Duration("backoff", backoff), slog.String("last_event_id", lastID))
+		conn.metrics.sseReconnects.Inc()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, sseMaxBackoff)
+	}
+}
+
+// readSSE reads events until the stream is closed and returns the number of published events.
+func (conn *Connector) readSSE(ctx context.Context, lastID *string) (int, error) {
+	cfg := conn.connectordata
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.SSESourceURL, http.NoBody)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("connect: unexpected status %d", resp.StatusCode)
+	}
+	conn.logger.Info("SSE stream is connected", slog.String("url", cfg.SSESourceURL))
+
+	var n int
+	var ev sseEvent
+	var data []string
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, conn.maxPayload+largeLineReserve)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if len(data) > 0 {
+				ev.data = strings.Join(data, "\n")
+				if err := conn.publishSSE(ctx, ev); err != nil {
+					return n, err
+				}
+				n++
+				if ev.id != "" {
+					*lastID = ev.id
+				}
+			}
+			ev, data = sseEvent{id: ev.id}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			ev.event = value
+		case "id":
+			ev.id = value
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return n, fmt.Errorf("read: %w", err)
+	}
+	return n, nil
+}
+
+// largeLineReserve is the room for the 'data: ' prefix of the longest accepted line.
+const largeLineReserve = 1024
+
+func (conn *Connector) publishSSE(ctx context.Context, ev sseEvent) error {
+	m := nats.NewMsg(conn.connectordata.SSESourceSubject)
+	m.Data = []byte(ev.data)
+	if ev.id != "" {
+		m.Header.Set(jetstream.MsgIDHeader, ev.id) // replayed events are dropped within the duplicate window
+	}
+	if ev.event != "" {
+		m.Header.Set(HeaderSSEEvent, ev.event)
+	}
+
+	if _, err := conn.jsContext.PublishMsg(ctx, m); err != nil {
+		conn.metrics.sseEvents("error")
+		return fmt.Errorf("publish event to %q: %w", conn.connectordata.SSESourceSubject, err)
+	}
+	conn.metrics.sseEvents("published")
+	return nil
+}
@@ -0,0 +1,81 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Endpoints is a list of HTTP endpoints.
+type Endpoints []string
+
+// SetString parses endpoints separated by comma.
+func (e *Endpoints) SetString(s string) error {
+	var endpoints Endpoints
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			endpoints = append(endpoints, v)
+		}
+	}
+	*e = endpoints
+	return nil
+}
+
+// invokeStages invokes STAGE_ENDPOINTS one by one with the response of the previous stage as the body,
+// transformed by STAGE_TRANSFORM, and returns the response of the last stage.
+func (conn *Connector) invokeStages(ctx context.Context, body []byte, headers http.Header) ([]byte, int, http.Header, error) {
+	cfg := conn.connectordata
+
+	var status int
+	var respHeader http.Header
+	for i, endpoint := range cfg.StageEndpoints {
+		if cfg.StageTransform != nil {
+			var err error
+			body, err = conn.transformStage(body)
+			if err != nil {
+				return nil, 0, nil, fmt.Errorf("stage %d: %w", i+1, err)
+			}
+		}
+
+		stageCfg := cfg
+		stageCfg.HTTPEndpoint = endpoint
+		stageCfg.HTTPMethod = http.MethodPost // stages always get the previous response as the body
+		resp, err := HandleHTTPRequest(ctx, conn.httpClient, string(body), headers, stageCfg, conn.log(ctx))
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("stage %d: %w", i+1, err)
+		}
+
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("stage %d: read response: %w", i+1, err)
+		}
+		status, respHeader = resp.StatusCode, resp.Header
+	}
+	return body, status, respHeader, nil
+}
+
+// transformStage extracts the STAGE_TRANSFORM field of the intermediate JSON response.
+func (conn *Connector) transformStage(body []byte) ([]byte, error) {
+	var doc any
+	if err := decodeJSON(body, &doc); err != nil {
+		return nil, fmt.Errorf("parse intermediate response as JSON: %w", err)
+	}
+
+	v, err := conn.connectordata.StageTransform.Get(doc)
+	if err != nil {
+		return nil, fmt.Errorf("transform intermediate response: %w", err)
+	}
+	if s, ok := v.(string); ok {
+		return []byte(s), nil
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal intermediate response: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,313 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service"
+)
+
+// statusConsumerTimeout bounds the consumer info request of the status.
+const statusConsumerTimeout = 2 * time.Second
+
+// rateWindows are the windows of the success and failure rates of the status, the longest one is kept in memory.
+//
+//nolint:gochecknoglobals // constant list
+var rateWindows = []time.Duration{time.Minute, 5 * time.Minute}
+
+type Status struct {
+	Stream         string    `json:"stream"`
+	Consumer       string    `json:"consumer"`
+	FilterSubject  string    `json:"filter_subject"`
+	HTTPEndpoint   string    `json:"http_endpoint"`
+	ResponseTopic  string    `json:"response_topic,omitempty"`
+	ErrorTopic     string    `json:"error_topic,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	Uptime         string    `json:"uptime"`
+	EndpointHealth bool      `json:"endpoint_healthy"`
+
+	Concurrent           int   `json:"concurrent"`
+	EffectiveConcurrency int64 `json:"effective_concurrency"`
+	InFlight             int64 `json:"in_flight"`
+	Redeliveries         int64 `json:"redeliveries"`
+
+	Results     map[string]uint64 `json:"results"`
+	Rates       []Rate            `json:"rates"`
+	LastError   string            `json:"last_error,omitempty"`
+	LastErrorAt *time.Time        `json:"last_error_at,omitempty"`
+
+	RecentErrors []RecentError `json:"recent_errors"` // the newest first
+
+	Gates         Gates          `json:"gates"`
+	ConsumerState *ConsumerState `json:"consumer_state,omitempty"`
+	ConsumerError string         `json:"consumer_error,omitempty"` // set if the consumer info can't be got
+
+	Backfill *BackfillProgress `json:"backfill,omitempty"`
+	Shutdown *ShutdownReport   `json:"shutdown,omitempty"` // set when the drain on shutdown is over
+}
+
+// Rate is the rate of the processed messages within the window: failed are the results counted by the error rate alert,
+// canceled messages are not counted.
+type Rate struct {
+	Window       string  `json:"window"`
+	Succeeded    uint64  `json:"succeeded"`
+	Failed       uint64  `json:"failed"`
+	SuccessRate  float64 `json:"success_per_second"`
+	FailureRate  float64 `json:"failure_per_second"`
+	FailureRatio float64 `json:"failure_ratio"`
+}
+
+// RecentError is one of the last recentErrorsSize errors sent to the error topic.
+type RecentError struct {
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+// recentErrorsSize is the number of the last errors kept for the status.
+const recentErrorsSize = 20
+
+// Gates is the state of the dispatch gates, which pause or hold the consumption.
+type Gates struct {
+	EndpointHealthy  bool   `json:"endpoint_healthy"`
+	ResponseCapacity bool   `json:"response_capacity"` // false while the response stream is full
+	ConsumeHealthy   bool   `json:"consume_healthy"`
+	Ramping          bool   `json:"ramping"`
+	Paused           bool   `json:"paused"`
+	PauseReason      string `json:"pause_reason,omitempty"`
+}
+
+// ConsumerState is the state of the durable consumer reported by JetStream.
+type ConsumerState struct {
+	NumPending     uint64 `json:"num_pending"`     // messages not delivered yet
+	NumAckPending  int    `json:"num_ack_pending"` // messages delivered and not acked yet
+	NumRedelivered int    `json:"num_redelivered"`
+	NumWaiting     int    `json:"num_waiting"`
+	Lag            uint64 `json:"lag"` // pending and unacked messages, as the lag alert
+}
+
+type connectorStats struct {
+	startedAt            time.Time
+	inFlight             atomic.Int64
+	effectiveConcurrency atomic.Int64
+	redeliveries         atomic.Int64
+
+	results     map[string]uint64
+	window      resultWindow
+	errors      uint64 // errors sent to the error topic
+	lastError   string
+	lastErrorAt time.Time
+	recent      []RecentError // ring of the last errors
+	mx          sync.Mutex
+}
+
+func newConnectorStats(concurrent int) *connectorStats {
+	s := &connectorStats{startedAt: time.Now(), results: make(map[string]uint64)} //nolint:exhaustruct // zero value initialization
+	s.effectiveConcurrency.Store(int64(concurrent))
+	return s
+}
+
+func (s *connectorStats) result(result string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.results[result]++
+	s.window.add(time.Now(), result)
+}
+
+// resultWindow counts succeeded and failed messages per second within the longest rate window.
+type resultWindow struct {
+	buckets [300]resultBucket
+}
+
+type resultBucket struct {
+	sec       int64
+	succeeded uint64
+	failed    uint64
+}
+
+func (w *resultWindow) add(now time.Time, result string) {
+	if result == "canceled" {
+		return
+	}
+	sec := now.Unix()
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	switch {
+	case b.sec > sec:
+		return // out of the window
+	case b.sec < sec:
+		*b = resultBucket{sec: sec} //nolint:exhaustruct // zero counters
+	}
+	if slices.Contains(failedResults, result) {
+		b.failed++
+	} else {
+		b.succeeded++
+	}
+}
+
+// rate returns the rate within the window, which is shortened to the uptime.
+func (w *resultWindow) rate(now, startedAt time.Time, window time.Duration) Rate {
+	r := Rate{Window: window.String()} //nolint:exhaustruct // counted below
+	from := now.Add(-window).Unix()
+	for _, b := range w.buckets {
+		if b.sec > from && b.sec <= now.Unix() {
+			r.Succeeded += b.succeeded
+			r.Failed += b.failed
+		}
+	}
+
+	seconds := min(window, now.Sub(startedAt)).Seconds()
+	if seconds > 0 {
+		r.SuccessRate = float64(r.Succeeded) / seconds
+		r.FailureRate = float64(r.Failed) / seconds
+	}
+	if total := r.Succeeded + r.Failed; total > 0 {
+		r.FailureRatio = float64(r.Failed) / float64(total)
+	}
+	return r
+}
+
+func (s *connectorStats) error(err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.errors++
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+
+	e := RecentError{Error: s.lastError, At: s.lastErrorAt}
+	if len(s.recent) < recentErrorsSize {
+		s.recent = append(s.recent, e)
+	} else {
+		s.recent[(s.errors-1)%recentErrorsSize] = e
+	}
+}
+
+// recentErrors returns the last errors, the newest first. It is called with the lock held.
+func (s *connectorStats) recentErrors() []RecentError {
+	errs := make([]RecentError, 0, len(s.recent))
+	for i := range s.recent {
+		errs = append(errs, s.recent[(int(s.errors)-1-i+len(s.recent))%len(s.recent)])
+	}
+	return errs
+}
+
+// counts returns a copy of the result counters and the number of errors.
+func (s *connectorStats) counts() (map[string]uint64, uint64) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	results := make(map[string]uint64, len(s.results))
+	for k, v := range s.results {
+		results[k] = v
+	}
+	return results, s.errors
+}
+
+// Status returns the connector status. The consumer state is got from JetStream within the context.
+func (conn *Connector) Status(ctx context.Context) Status {
+	cfg := conn.connectordata
+	stats := conn.stats
+
+	st := Status{
+		Stream:         cfg.Topic,
+		Consumer:       conn.consumer,
+		FilterSubject:  conn.filterSubject(),
+		HTTPEndpoint:   service.RedactString(cfg.HTTPEndpoint), // as in /info
+		ResponseTopic:  cfg.ResponseTopic,
+		ErrorTopic:     cfg.ErrorTopic,
+		StartedAt:      stats.startedAt,
+		Uptime:         time.Since(stats.startedAt).Round(time.Second).String(),
+		EndpointHealth: conn.endpointHealth.IsOpen(),
+
+		Concurrent:           int(cfg.Concurrent),
+		EffectiveConcurrency: stats.effectiveConcurrency.Load(),
+		InFlight:             stats.inFlight.Load(),
+		Redeliveries:         stats.redeliveries.Load(),
+
+		Results:     nil,
+		Rates:       nil,
+		LastError:   "",
+		LastErrorAt: nil,
+
+		RecentErrors: nil,
+
+		Gates:         conn.gates(),
+		ConsumerState: nil,
+		ConsumerError: "",
+
+		Backfill: nil,
+		Shutdown: conn.shutdownReport.Load(),
+	}
+
+	now := time.Now()
+	stats.mx.Lock()
+	st.Results = make(map[string]uint64, len(stats.results))
+	for k, v := range stats.results {
+		st.Results[k] = v
+	}
+	for _, window := range rateWindows {
+		st.Rates = append(st.Rates, stats.window.rate(now, stats.startedAt, window))
+	}
+	if stats.lastError != "" {
+		at := stats.lastErrorAt
+		st.LastError = stats.lastError
+		st.LastErrorAt = &at
+	}
+	st.RecentErrors = stats.recentErrors()
+	stats.mx.Unlock()
+
+	if cfg.Backfill {
+		progress := conn.BackfillProgress()
+		st.Backfill = &progress
+	}
+
+	if state, err := conn.consumerState(ctx); err != nil {
+		st.ConsumerError = err.Error()
+	} else {
+		st.ConsumerState = &state
+	}
+	return st
+}
+
+func (conn *Connector) gates() Gates {
+	reason := conn.pauseReason()
+	return Gates{
+		EndpointHealthy:  conn.endpointHealth.IsOpen(),
+		ResponseCapacity: conn.responseCapacity.IsOpen(),
+		ConsumeHealthy:   conn.ConsumeCheck() == nil,
+		Ramping:          conn.ramping != nil && conn.ramping.Load(),
+		Paused:           reason != "",
+		PauseReason:      reason,
+	}
+}
+
+func (conn *Connector) consumerState(ctx context.Context) (ConsumerState, error) {
+	ctx, cancel := context.WithTimeout(ctx, statusConsumerTimeout)
+	defer cancel()
+
+	cs, err := conn.jsContext.Consumer(ctx, conn.connectordata.Topic, conn.consumer)
+	if err != nil {
+		return ConsumerState{}, err //nolint:exhaustruct,wrapcheck // reported as is
+	}
+	info := cs.CachedInfo()
+	return ConsumerState{
+		NumPending:     info.NumPending,
+		NumAckPending:  info.NumAckPending,
+		NumRedelivered: info.NumRedelivered,
+		NumWaiting:     info.NumWaiting,
+		Lag:            info.NumPending + uint64(info.NumAckPending),
+	}, nil
+}
+
+// StatusHandler serves the connector status as JSON for dashboards.
+func (conn *Connector) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conn.Status(r.Context())) //nolint:errcheck,errchkjson // best effort response
+	})
+}
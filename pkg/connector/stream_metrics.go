@@ -0,0 +1,50 @@
+package connector
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RunStreamInfoMetrics exports the stream state every STREAM_INFO_INTERVAL until the context is done.
+func (conn *Connector) RunStreamInfoMetrics(ctx context.Context) {
+	streamName := conn.connectordata.Topic
+
+	gauge := func(name, help string) prometheus.Gauge {
+		return promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name,
+			Help: help,
+		}, []string{"stream"}).WithLabelValues(streamName)
+	}
+	messages := gauge("jetstream_stream_messages", "Number of messages in the stream")
+	bytes := gauge("jetstream_stream_bytes", "Size of messages in the stream")
+	firstSeq := gauge("jetstream_stream_first_seq", "First sequence of the stream")
+	lastSeq := gauge("jetstream_stream_last_seq", "Last sequence of the stream")
+	consumers := gauge("jetstream_stream_consumers", "Number of consumers of the stream")
+
+	ticker := time.NewTicker(conn.connectordata.StreamInfoInterval)
+	defer ticker.Stop()
+
+	for {
+		stream, err := conn.jsContext.Stream(ctx, streamName)
+		if err != nil {
+			conn.logger.Warn("Failed to get stream info for metrics", slog.String("stream", streamName), slog.Any("error", err))
+		} else {
+			state := stream.CachedInfo().State
+			messages.Set(float64(state.Msgs))
+			bytes.Set(float64(state.Bytes))
+			firstSeq.Set(float64(state.FirstSeq))
+			lastSeq.Set(float64(state.LastSeq))
+			consumers.Set(float64(state.Consumers))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
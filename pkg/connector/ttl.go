@@ -0,0 +1,69 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Headers of the messages routed to EXPIRED_SUBJECT: the age (a Go duration), the subject and the stream sequence
+// of the expired message.
+const (
+	HeaderExpiredAge      = "Nats-Expired-Age"
+	HeaderExpiredSubject  = "Nats-Expired-Subject"
+	HeaderExpiredSequence = "Nats-Expired-Sequence"
+)
+
+var ErrMessageExpired = errors.New("message is expired")
+
+// expired returns OutcomeExpired if the message is older than MAX_MESSAGE_AGE by the stream timestamp:
+// the endpoint is not invoked, the message is routed to EXPIRED_SUBJECT (if set) and acked.
+// If the routing fails, the message is redelivered. It returns OutcomeAck if the message is not expired.
+func (conn *Connector) expired(ctx context.Context, msg Message) Outcome {
+	maxAge := conn.connectordata.MaxMessageAge
+	if maxAge <= 0 {
+		return OutcomeAck
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		return OutcomeAck
+	}
+
+	age := time.Since(meta.Timestamp)
+	if age <= maxAge {
+		return OutcomeAck
+	}
+
+	log := conn.log(ctx)
+	subject := conn.connectordata.ExpiredSubject
+	if subject == "" {
+		log.Warn("Message is expired - it is acked without processing", slog.Duration("age", age))
+		return OutcomeExpired
+	}
+
+	hdr := nats.Header{}
+	for k, v := range msg.Headers() {
+		hdr[k] = v
+	}
+	hdr.Set(HeaderExpiredAge, age.String())
+	hdr.Set(HeaderExpiredSubject, msg.Subject())
+	hdr.Set(HeaderExpiredSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+	// The redelivered message gets the same id, so the stream of the subject drops the duplicate.
+	hdr.Set(nats.MsgIdHdr, "expired-"+responseMsgID(msg))
+
+	_, err = conn.jsContext.PublishMsg(ctx, &nats.Msg{Subject: subject, Data: msg.Data(), Header: hdr}) //nolint:exhaustruct // optional fields
+	if err != nil {
+		log.Error("failed to route expired message - message is redelivered", slog.Any("error", err), slog.String("subject", subject))
+		conn.errorHandler(ctx, fmt.Errorf("route to %q, subject: %v, stream sequence: %v, age: %v, max age: %v, source: %v: %w: %w",
+			subject, msg.Subject(), meta.Sequence.Stream, age, maxAge, conn.connectordata.SourceName, ErrMessageExpired, err))
+		return OutcomeRedeliver
+	}
+	log.Warn("Message is expired - it is routed to the expired subject and acked", slog.Duration("age", age), slog.String("subject", subject))
+	return OutcomeExpired
+}
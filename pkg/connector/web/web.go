@@ -0,0 +1,17 @@
+package web
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed dashboard.html
+var dashboard []byte
+
+// Dashboard serves the web UI which renders the connector status from the sibling 'status' endpoint.
+func Dashboard() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboard) //nolint:errcheck // best effort response
+	})
+}
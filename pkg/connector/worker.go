@@ -0,0 +1,227 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Outcome tells how the processed message should be settled.
+type Outcome int
+
+const (
+	OutcomeAck       Outcome = iota // processed successfully
+	OutcomeRedeliver                // left unacked - redelivered after AckWait
+	OutcomeTerm                     // never redelivered
+	OutcomeAcked                    // already acked before the response is published
+	OutcomeExpired                  // older than MAX_MESSAGE_AGE - acked without processing
+	OutcomeDeferred                 // not due yet - redelivered when due
+	OutcomePending                  // accepted by the endpoint - settled on the callback
+	OutcomeReplayed                 // at or below the checkpoint with EXACTLY_ONCE_HINT - acked without processing
+)
+
+// dispatch starts the processing of the message, or adds it to its group if GROUP_KEY or DEBOUNCE_KEY is set.
+func (conn *Connector) dispatch(ctx context.Context, msg Message) {
+	msg = conn.observeDelivery(msg)
+	if conn.groups != nil {
+		conn.groups.add(ctx, msg)
+		return
+	}
+	conn.start(ctx, msg)
+}
+
+// start blocks until a concurrency slot and the in-flight bytes budget are free and processes the message in a new goroutine.
+// The slot and the bytes are released on every exit path of the goroutine, panics included.
+// The pull is stopped while the HTTP endpoint is unhealthy (see pauseReason), so it doesn't wait for the endpoint.
+func (conn *Connector) start(ctx context.Context, msg Message) {
+	size := conn.acquire(int64(len(msg.Data())))
+
+	ctx = conn.withMessageLogger(ctx, msg)
+	conn.log(ctx).Info("Start processing", slog.String("message", string(msg.Data())))
+	go func() {
+		defer conn.release(size)
+
+		conn.process(ctx, msg)
+	}()
+}
+
+// process handles the message within AckWait (extendable by the endpoint in JOB_LEASE mode)
+// and settles it according to the outcome.
+func (conn *Connector) process(ctx context.Context, msg Message) {
+	ctx = withMessage(withRoute(ctx), msg)
+	defer conn.recoverPanic(ctx, msg)
+
+	var cancel func()
+	if conn.connectordata.JobLease {
+		ctx, cancel = conn.lease(ctx, msg, conn.timeout(msg))
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, conn.timeout(msg))
+	}
+	defer cancel()
+	ctx = conn.withEndpointStatus(ctx)
+
+	t0 := time.Now()
+	result := conn.settle(ctx, msg, conn.handler(ctx, msg))
+	conn.flushLog(ctx, result)
+
+	subject, route := conn.metrics.subjects.Value(msg.Subject()), routeOf(ctx)
+	conn.metrics.messages(subject, route, result)
+	conn.observeSLO(msg, result)
+	conn.stats.result(result)
+	conn.observeAggregate(msg, result)
+	conn.metrics.processingTime(subject, route, time.Since(t0).Seconds())
+	conn.audit(msg, result, time.Since(t0))
+	conn.publishReceipt(ctx, msg, result, time.Since(t0))
+}
+
+// timeout returns the processing timeout of the message: AckWait, or the value of TIMEOUT_HEADER header
+// if it is shorter than AckWait.
+func (conn *Connector) timeout(msg Message) time.Duration {
+	timeout := conn.connectordata.AckWait
+
+	header := conn.connectordata.TimeoutHeader
+	if header == "" {
+		return timeout
+	}
+
+	v := msg.Headers().Get(header)
+	if v == "" {
+		return timeout
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		conn.logger.Warn("Wrong timeout header value - ACKWAIT is used", slog.String("header", header), slog.String("value", v))
+		return timeout
+	}
+	return min(d, timeout)
+}
+
+// settle makes the ack decision. The endpoint timeout is distinguished from the shutdown cancellation:
+// timed out messages are nacked with a delay, canceled ones are nacked to be redelivered to another replica immediately.
+// It returns the result used as a metrics label.
+func (conn *Connector) settle(ctx context.Context, msg Message, o Outcome) string {
+	log := conn.log(ctx)
+
+	switch {
+	case o == OutcomeAcked:
+		return "ack"
+	case o == OutcomePending:
+		return "pending"
+	case o == OutcomeDeferred:
+		delay := conn.dueIn(msg)
+		log.Debug("Message is not due yet - it is deferred", slog.Duration("delay", delay))
+		if err := msg.NakWithDelay(delay); err != nil {
+			log.Error("failed to nak deferred message", slog.Any("error", err))
+		}
+		return "deferred"
+	case o == OutcomeTerm:
+		if err := msg.Term(); err != nil {
+			log.Error("failed to terminate message", slog.Any("error", err))
+		}
+		return "term"
+	case o == OutcomeExpired:
+		if err := conn.ack(ctx, msg); err != nil {
+			log.Error("failed to ack expired message", slog.Any("error", err))
+		}
+		return "expired"
+	case errors.Is(context.Cause(ctx), context.DeadlineExceeded):
+		conn.metrics.contextErrors("timeout", routeOf(ctx))
+		if err := msg.NakWithDelay(conn.connectordata.TimeoutNakDelay); err != nil {
+			log.Error("failed to nak timed out message", slog.Any("error", err))
+		}
+		return "timeout"
+	case errors.Is(context.Cause(ctx), context.Canceled):
+		conn.metrics.contextErrors("canceled", routeOf(ctx))
+		log.Info("Processing is canceled by shutdown - message is nacked", slog.String("subject", msg.Subject()))
+		if err := msg.Nak(); err != nil {
+			log.Error("failed to nak canceled message", slog.Any("error", err))
+		}
+		return "canceled"
+	case o == OutcomeReplayed:
+		if err := msg.Ack(); err != nil {
+			log.Error("failed to ack replayed message", slog.Any("error", err))
+		}
+		return "replayed"
+	case o == OutcomeAck:
+		if conn.chaosDropAck() {
+			return "ack_dropped"
+		}
+		if err := conn.ack(ctx, msg); err != nil {
+			log.Info(err.Error())
+			conn.errorHandler(ctx, err)
+			return "ack_error"
+		}
+		return "ack"
+	case o == OutcomeRedeliver:
+	}
+	return "redeliver"
+}
+
+// ack acks the message, with the confirmation from the server if ACK_SYNC is enabled.
+func (conn *Connector) ack(ctx context.Context, msg Message) error {
+	if conn.connectordata.AckSync {
+		return msg.DoubleAck(ctx) //nolint:wrapcheck // caller logs the error
+	}
+	return msg.Ack() //nolint:wrapcheck // caller logs the error
+}
+
+// acquire takes a concurrency slot and size bytes of the in-flight budget and records how long the message waited for them.
+// It returns the amount of bytes to release.
+func (conn *Connector) acquire(size int64) int64 {
+	t0 := time.Now()
+	select {
+	case conn.concurrentSem <- 1:
+	default:
+		conn.metrics.semaphoreSaturated.Inc()
+		conn.concurrentSem <- 1
+	}
+	size = conn.inflightBytes.acquire(size)
+	conn.metrics.semaphoreWait.Observe(time.Since(t0).Seconds())
+	conn.metrics.inFlight.Inc()
+	conn.stats.inFlight.Add(1)
+	conn.metrics.inFlightBytes.Add(float64(size))
+	return size
+}
+
+func (conn *Connector) release(size int64) {
+	conn.metrics.inFlightBytes.Sub(float64(size))
+	conn.metrics.inFlight.Dec()
+	conn.stats.inFlight.Add(-1)
+	conn.inflightBytes.release(size)
+	<-conn.concurrentSem
+}
+
+// recoverPanic should be deferred by the message handler: it keeps the worker alive and nacks the message on panic.
+func (conn *Connector) recoverPanic(ctx context.Context, msg Message) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	conn.metrics.panics.Inc()
+	conn.metrics.messages(conn.metrics.subjects.Value(msg.Subject()), routeOf(ctx), "panic")
+	conn.observeSLO(msg, "panic")
+	conn.stats.result("panic")
+	conn.audit(msg, "panic", 0)
+	conn.logger.Error("Message handler panicked - message is nacked",
+		slog.Any("panic", r),
+		slog.String("subject", msg.Subject()),
+		slog.String("stack", string(debug.Stack())))
+
+	if err := msg.Nak(); err != nil {
+		conn.logger.Error("failed to nak message after panic", slog.Any("error", err))
+	}
+}
+
+// wait blocks until all dispatched messages are processed.
+func (conn *Connector) wait() {
+	for i := 0; i < cap(conn.concurrentSem); i++ {
+		conn.concurrentSem <- 1
+	}
+	for i := 0; i < cap(conn.concurrentSem); i++ {
+		<-conn.concurrentSem
+	}
+}
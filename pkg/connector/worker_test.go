@@ -0,0 +1,279 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var errFakeAck = errors.New("fake ack error")
+
+// fakeMsg records how the message is settled.
+type fakeMsg struct {
+	data    []byte
+	headers nats.Header
+	meta    *jetstream.MsgMetadata
+	ackErr  error
+
+	mx      sync.Mutex
+	settled []string
+}
+
+func newFakeMsg(data string) *fakeMsg {
+	return &fakeMsg{ //nolint:exhaustruct // zero mutex and settles
+		data:    []byte(data),
+		headers: nats.Header{},
+		meta: &jetstream.MsgMetadata{ //nolint:exhaustruct // test metadata
+			Sequence:     jetstream.SequencePair{Consumer: 1, Stream: 1},
+			NumDelivered: 1,
+			Stream:       "test",
+			Consumer:     "test",
+			Timestamp:    time.Now(),
+		},
+	}
+}
+
+func (m *fakeMsg) Data() []byte         { return m.data }
+func (m *fakeMsg) Headers() nats.Header { return m.headers }
+func (m *fakeMsg) Subject() string      { return "test.subject" }
+
+func (m *fakeMsg) Metadata() (*jetstream.MsgMetadata, error) { return m.meta, nil }
+
+func (m *fakeMsg) record(s string, err error) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.settled = append(m.settled, s)
+	return err
+}
+
+func (m *fakeMsg) Ack() error                       { return m.record("ack", m.ackErr) }
+func (m *fakeMsg) DoubleAck(context.Context) error  { return m.record("double_ack", m.ackErr) }
+func (m *fakeMsg) Nak() error                       { return m.record("nak", nil) }
+func (m *fakeMsg) NakWithDelay(time.Duration) error { return m.record("nak_delay", nil) }
+func (m *fakeMsg) InProgress() error                { return m.record("in_progress", nil) }
+func (m *fakeMsg) Term() error                      { return m.record("term", nil) }
+
+func (m *fakeMsg) settles() []string {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return slices.Clone(m.settled)
+}
+
+func (m *fakeMsg) withHeader(k, v string) *fakeMsg { m.headers.Set(k, v); return m }
+func (m *fakeMsg) withAckError(err error) *fakeMsg { m.ackErr = err; return m }
+
+// testMetrics are registered once: the metrics are registered in the default registry.
+var testMetrics = sync.OnceValue(func() connectorMetrics { return newConnectorMetrics(1, 100, newUnacked()) })
+
+// newTestConnector returns the connector processing messages with the handler without NATS.
+func newTestConnector(cfg Config, handler Handler) *Connector {
+	if cfg.Concurrent == 0 {
+		cfg.Concurrent = 1
+	}
+	if cfg.AckWait == 0 {
+		cfg.AckWait = time.Second
+	}
+	cfg.ErrorSink = SinkNATS
+	cfg.ResponseSink = SinkNATS
+
+	conn := &Connector{ //nolint:exhaustruct // no NATS connection and optional features
+		connectordata: cfg,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		consumer:      "test",
+		concurrentSem: make(chan int, cfg.Concurrent),
+		sinks:         map[SinkKind]Sink{},
+		metrics:       testMetrics(),
+		backfill:      &backfillState{},
+		stats:         newConnectorStats(int(cfg.Concurrent)),
+		coldStreak:    &coldStreak{threshold: cfg.KeepWarmColdThreshold}, //nolint:exhaustruct // zero counter
+		drained:       make(chan struct{}),
+		jobs:          &jobs{leases: map[string]*lease{}},                 //nolint:exhaustruct // zero mutex
+		callbacks:     &callbacks{pending: map[string]*callback{}},        //nolint:exhaustruct // zero mutex
+		errorRate:     &errorRate{threshold: cfg.K8SEventsErrorThreshold}, //nolint:exhaustruct // zero window
+		httpClient:    http.DefaultClient,
+
+		endpointHealth:   newGate(true),
+		responseCapacity: newGate(true),
+		consuming:        &atomic.Bool{},
+		consumeHealth:    &consumeHealth{heartbeat: cfg.ConsumeHeartbeat}, //nolint:exhaustruct // zero state
+	}
+	conn.handler = handler
+	return conn
+}
+
+func TestSettle(t *testing.T) {
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		ctx     context.Context //nolint:containedctx // test case context
+		msg     *fakeMsg
+		outcome Outcome
+		result  string
+		settles []string
+	}{
+		{name: "ack", outcome: OutcomeAck, result: "ack", settles: []string{"ack"}},
+		{name: "ack sync", cfg: Config{AckSync: true}, outcome: OutcomeAck, result: "ack", settles: []string{"double_ack"}},
+		{name: "ack error", msg: newFakeMsg("{}").withAckError(errFakeAck), outcome: OutcomeAck, result: "ack_error", settles: []string{"ack"}},
+		{name: "already acked", outcome: OutcomeAcked, result: "ack"},
+		{name: "pending", outcome: OutcomePending, result: "pending"},
+		{name: "redeliver", outcome: OutcomeRedeliver, result: "redeliver"},
+		{name: "term", outcome: OutcomeTerm, result: "term", settles: []string{"term"}},
+		{name: "expired", outcome: OutcomeExpired, result: "expired", settles: []string{"ack"}},
+		{name: "deferred", outcome: OutcomeDeferred, result: "deferred", settles: []string{"nak_delay"}},
+		{name: "replayed", outcome: OutcomeReplayed, result: "replayed", settles: []string{"ack"}},
+		{name: "timeout", ctx: expired, outcome: OutcomeRedeliver, result: "timeout", settles: []string{"nak_delay"}},
+		{name: "canceled", ctx: canceled, outcome: OutcomeRedeliver, result: "canceled", settles: []string{"nak"}},
+		{name: "term wins over timeout", ctx: expired, outcome: OutcomeTerm, result: "term", settles: []string{"term"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(tt.cfg, nil)
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			msg := tt.msg
+			if msg == nil {
+				msg = newFakeMsg("{}")
+			}
+
+			result := conn.settle(ctx, msg, tt.outcome)
+			if result != tt.result {
+				t.Errorf("result = %q, want %q", result, tt.result)
+			}
+			if got := msg.settles(); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+		result  string
+		settles []string
+	}{
+		{
+			name:    "success",
+			handler: func(context.Context, Message) Outcome { return OutcomeAck },
+			result:  "ack",
+			settles: []string{"ack"},
+		},
+		{
+			name:    "error",
+			handler: func(context.Context, Message) Outcome { return OutcomeRedeliver },
+			result:  "redeliver",
+		},
+		{
+			name:    "terminal error",
+			handler: func(context.Context, Message) Outcome { return OutcomeTerm },
+			result:  "term",
+			settles: []string{"term"},
+		},
+		{
+			name: "timeout",
+			handler: func(ctx context.Context, _ Message) Outcome {
+				<-ctx.Done()
+				return OutcomeRedeliver
+			},
+			result:  "timeout",
+			settles: []string{"nak_delay"},
+		},
+		{
+			name:    "panic",
+			handler: func(context.Context, Message) Outcome { panic("handler failed") },
+			result:  "panic",
+			settles: []string{"nak"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{AckWait: 50 * time.Millisecond, Concurrent: 2}, tt.handler) //nolint:exhaustruct // test config
+			msg := newFakeMsg("{}")
+
+			conn.start(context.Background(), msg)
+			conn.wait()
+
+			if got := msg.settles(); !slices.Equal(got, tt.settles) {
+				t.Errorf("settles = %v, want %v", got, tt.settles)
+			}
+			if n := len(conn.concurrentSem); n != 0 {
+				t.Errorf("%d concurrency slots are not released", n)
+			}
+			if n := conn.stats.inFlight.Load(); n != 0 {
+				t.Errorf("in flight = %d, want 0", n)
+			}
+			results, _ := conn.stats.counts()
+			if results[tt.result] != 1 {
+				t.Errorf("results = %v, want one %q", results, tt.result)
+			}
+		})
+	}
+}
+
+func TestStartReleasesSlotsOnPanic(t *testing.T) {
+	conn := newTestConnector(Config{Concurrent: 1}, func(context.Context, Message) Outcome { panic("handler failed") }) //nolint:exhaustruct // test config
+
+	// With one slot the next message starts only after the slot of the panicked one is released.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			conn.start(context.Background(), newFakeMsg("{}"))
+		}
+		conn.wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("concurrency slot of the panicked message is not released")
+	}
+	results, _ := conn.stats.counts()
+	if results["panic"] != 3 {
+		t.Errorf("results = %v, want 3 panics", results)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "no header", want: time.Second},
+		{name: "shorter", header: "100ms", want: 100 * time.Millisecond},
+		{name: "longer than ack wait", header: "1m", want: time.Second},
+		{name: "wrong", header: "soon", want: time.Second},
+		{name: "negative", header: "-1s", want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConnector(Config{AckWait: time.Second, TimeoutHeader: "X-Timeout"}, nil) //nolint:exhaustruct // test config
+			msg := newFakeMsg("{}")
+			if tt.header != "" {
+				msg.withHeader("X-Timeout", tt.header)
+			}
+			if got := conn.timeout(msg); got != tt.want {
+				t.Errorf("timeout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
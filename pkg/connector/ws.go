@@ -0,0 +1,153 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/websocket"
+)
+
+const (
+	wsMinBackoff = 100 * time.Millisecond
+	wsMaxBackoff = 5 * time.Second
+)
+
+// invokeWebSocket sends the message as a frame over a persistent WebSocket connection to the endpoint
+// and returns the next received frame as the response. Connections are reused by the following messages,
+// a broken connection is dropped and a new one is dialed with backoff up to MAX_RETRIES times.
+// Message headers are not sent, the handshake request has the connector meta headers only.
+//
+// Responses are matched to messages by order, one message is in flight per connection. A connection is dropped
+// if no frame is received until the message times out, so a late frame is not taken as the response
+// to the next message, and if a frame is received while no message is waiting for it, e.g. the second frame of a response.
+func (conn *Connector) invokeWebSocket(ctx context.Context, endpoint string, data []byte) ([]byte, error) {
+	s, err := conn.wsConn(ctx, endpoint, conn.metaHeaders())
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.ws.SetWriteDeadline(deadline)
+	}
+	if err := s.ws.WriteMessage(conn.wsOpcode(), data); err != nil {
+		s.close()
+		return nil, fmt.Errorf("send websocket message. http_endpoint: %v, source: %v: %w", endpoint, conn.connectordata.SourceName, err)
+	}
+	_ = s.ws.SetWriteDeadline(time.Time{})
+
+	select {
+	case f := <-s.frames:
+		if f.err != nil {
+			s.close()
+			return nil, fmt.Errorf("receive websocket message. http_endpoint: %v, source: %v: %w", endpoint, conn.connectordata.SourceName, f.err)
+		}
+		select {
+		case conn.wsPool <- s:
+		default:
+			s.close()
+		}
+		return f.data, nil
+	case <-ctx.Done():
+		s.close()
+		return nil, fmt.Errorf("receive websocket message. http_endpoint: %v, source: %v: %w", endpoint, conn.connectordata.SourceName, ctx.Err())
+	}
+}
+
+// wsFrame is a message received from the endpoint, or the error which broke the connection.
+type wsFrame struct {
+	data []byte
+	err  error
+}
+
+// wsSession is a WebSocket connection with the goroutine reading its frames.
+type wsSession struct {
+	ws     *websocket.Conn
+	frames chan wsFrame // one frame is buffered, it is unexpected if nobody is waiting for it
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newWSSession(ws *websocket.Conn) *wsSession {
+	s := &wsSession{ws: ws, frames: make(chan wsFrame, 1), done: make(chan struct{})} //nolint:exhaustruct // zero once
+	go s.read()
+	return s
+}
+
+func (s *wsSession) read() {
+	for {
+		_, data, err := s.ws.ReadMessage()
+		select {
+		case s.frames <- wsFrame{data: data, err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *wsSession) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.ws.Close()
+	})
+}
+
+// wsConn takes an idle connection from the pool or dials a new one. Idle connections which received
+// a frame or broke meanwhile are dropped.
+func (conn *Connector) wsConn(ctx context.Context, endpoint string, headers http.Header) (*wsSession, error) {
+	for {
+		var s *wsSession
+		select {
+		case s = <-conn.wsPool:
+		default:
+		}
+		if s == nil {
+			break
+		}
+
+		select {
+		case f := <-s.frames:
+			if f.err == nil {
+				conn.metrics.wsUnexpectedFrames.Inc()
+				conn.logger.Warn("Unexpected websocket frame is received, the connection is dropped", slog.String("http_endpoint", endpoint))
+			}
+			s.close()
+		default:
+			return s, nil
+		}
+	}
+
+	dialer := &websocket.Dialer{NetDial: conn.dial, MaxMessageSize: conn.connectordata.WebSocketMaxMessageSize}
+	backoff := wsMinBackoff
+	for attempt := 0; ; attempt++ {
+		ws, err := dialer.Dial(ctx, endpoint, headers)
+		if err == nil {
+			return newWSSession(ws), nil
+		}
+		conn.metrics.wsDialErrors.Inc()
+		if attempt >= conn.connectordata.MaxRetries {
+			return nil, fmt.Errorf("dial websocket. http_endpoint: %v, source: %v: %w", endpoint, conn.connectordata.SourceName, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("dial websocket is interrupted. http_endpoint: %v, source: %v: %w", endpoint, conn.connectordata.SourceName, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, wsMaxBackoff)
+	}
+}
+
+// wsOpcode returns the frame type: text for textual content types, binary otherwise.
+func (conn *Connector) wsOpcode() byte {
+	if conn.connectordata.WebSocketBinary {
+		return websocket.OpBinary
+	}
+	return websocket.OpText
+}
@@ -0,0 +1,89 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/websocket"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/websocket/websockettest"
+)
+
+func TestInvokeWebSocketCorrelation(t *testing.T) {
+	tests := []struct {
+		name  string
+		first string // the server responds with no frame to "none" and with two frames to "double"
+		err   bool
+		dials int64 // of both messages
+	}{
+		{name: "one frame, the connection is reused", first: "a", dials: 1},
+		{name: "two frames, the connection is dropped", first: "double", dials: 2},
+		{name: "no frame, the connection is dropped", first: "none", err: true, dials: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dials atomic.Int64
+			srv := websockettest.NewServer(func(c *websockettest.Conn) {
+				dials.Add(1)
+				for {
+					_, op, payload, err := c.ReadFrame()
+					if err != nil || op == websocket.OpClose {
+						return
+					}
+					switch string(payload) {
+					case "none":
+						continue
+					case "double":
+						_ = c.WriteFrame(true, websocket.OpText, []byte("resp:double"))
+						_ = c.WriteFrame(true, websocket.OpText, []byte("extra"))
+					default:
+						_ = c.WriteFrame(true, websocket.OpText, append([]byte("resp:"), payload...))
+					}
+				}
+			})
+			defer srv.Close()
+
+			conn := newTestConnector(Config{InvokeProtocol: ProtocolWebSocket}, nil) //nolint:exhaustruct // test config
+			conn.wsPool = make(chan *wsSession, 1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			resp, err := conn.invokeWebSocket(ctx, srv.URL, []byte(tt.first))
+			cancel()
+			if tt.err {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("first error = %v, want deadline exceeded", err)
+				}
+			} else if err != nil || string(resp) != "resp:"+tt.first {
+				t.Fatalf("first response = %q, %v, want %q", resp, err, "resp:"+tt.first)
+			}
+			if tt.first == "double" {
+				waitBufferedFrame(t, conn)
+			}
+
+			ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			resp, err = conn.invokeWebSocket(ctx, srv.URL, []byte("b"))
+			if err != nil || string(resp) != "resp:b" {
+				t.Errorf("second response = %q, %v, want %q", resp, err, "resp:b")
+			}
+			if n := dials.Load(); n != tt.dials {
+				t.Errorf("dials = %d, want %d", n, tt.dials)
+			}
+		})
+	}
+}
+
+// waitBufferedFrame waits until the pooled connection receives the unexpected frame.
+func waitBufferedFrame(t *testing.T, conn *Connector) {
+	t.Helper()
+
+	s := <-conn.wsPool
+	defer func() { conn.wsPool <- s }()
+	for deadline := time.Now().Add(5 * time.Second); len(s.frames) == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("unexpected frame is not received")
+		}
+	}
+}
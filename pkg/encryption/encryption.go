@@ -0,0 +1,98 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const HeaderKeyID = "Nats-Encryption-Key-Id"
+
+var ErrUnknownKey = errors.New("unknown encryption key id")
+
+// Keys are AES keys (16, 24 or 32 bytes long) by key id.
+// Several keys allow to rotate the encryption key: messages encrypted with an old key are still decrypted.
+type Keys map[string][]byte
+
+// SetString parses keys in format 'id1:base64key1,id2:base64key2'.
+func (k *Keys) SetString(s string) error {
+	keys := Keys{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			return fmt.Errorf("wrong format: only 'id:base64key' pairs separated by comma are accepted")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("decode key %q: %w", id, err)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	*k = keys
+	return nil
+}
+
+// Encrypt encrypts data with AES-GCM using the key with the given id.
+// The random nonce is prepended to the ciphertext.
+func (k Keys) Encrypt(id string, data []byte) ([]byte, error) {
+	aead, err := k.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// Decrypt decrypts data produced by Encrypt with the key with the given id.
+func (k Keys) Decrypt(id string, data []byte) ([]byte, error) {
+	aead, err := k.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	out, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with key %q: %w", id, err)
+	}
+	return out, nil
+}
+
+func (k Keys) aead(id string) (cipher.AEAD, error) {
+	key, ok := k[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new gcm: %w", err)
+	}
+	return aead, nil
+}
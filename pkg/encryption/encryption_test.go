@@ -0,0 +1,125 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestKeysSetString(t *testing.T) {
+	key16 := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	key32 := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name string
+		s    string
+		ids  []string
+		err  bool
+	}{
+		{name: "empty", s: ""},
+		{name: "one key", s: "k1:" + key16, ids: []string{"k1"}},
+		{name: "several keys", s: "k1:" + key16 + ", k2:" + key32, ids: []string{"k1", "k2"}},
+		{name: "no id", s: ":" + key16, err: true},
+		{name: "no key", s: "k1", err: true},
+		{name: "not base64", s: "k1:???", err: true},
+		{name: "wrong key size", s: "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 10)), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var k Keys
+			err := k.SetString(tt.s)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("keys = %v, want error", k)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("set string: %v", err)
+			}
+			if len(k) != len(tt.ids) {
+				t.Errorf("keys = %v, want ids %v", k, tt.ids)
+			}
+			for _, id := range tt.ids {
+				if _, ok := k[id]; !ok {
+					t.Errorf("key %q is missing", id)
+				}
+			}
+		})
+	}
+}
+
+func TestKeysDecrypt(t *testing.T) {
+	old := Keys{"old": bytes.Repeat([]byte{1}, 32)}
+	rotated := Keys{"old": old["old"], "new": bytes.Repeat([]byte{2}, 16)}
+	other := Keys{"old": bytes.Repeat([]byte{3}, 32)}
+
+	data := []byte(`{"payload":"secret"}`)
+	encrypted := func(k Keys, id string) []byte {
+		t.Helper()
+		b, err := k.Encrypt(id, data)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name      string
+		keys      Keys
+		id        string
+		encrypted []byte
+		err       error // nil if any error is expected with wantErr
+		wantErr   bool
+	}{
+		{name: "round trip", keys: old, id: "old", encrypted: encrypted(old, "old")},
+		{name: "old key after rotation", keys: rotated, id: "old", encrypted: encrypted(old, "old")},
+		{name: "new key after rotation", keys: rotated, id: "new", encrypted: encrypted(rotated, "new")},
+		{name: "unknown key id", keys: old, id: "new", encrypted: encrypted(rotated, "new"), err: ErrUnknownKey, wantErr: true},
+		{name: "wrong key", keys: other, id: "old", encrypted: encrypted(old, "old"), wantErr: true},
+		{name: "tampered ciphertext", keys: old, id: "old", encrypted: tamper(encrypted(old, "old")), wantErr: true},
+		{name: "too short", keys: old, id: "old", encrypted: []byte{1, 2, 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keys.Decrypt(tt.id, tt.encrypted)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decrypted %q, want error", got)
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("decrypted %q, want %q", got, data)
+			}
+		})
+	}
+}
+
+func TestKeysEncryptNonce(t *testing.T) {
+	k := Keys{"k": make([]byte, 16)}
+	a, err := k.Encrypt("k", []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := k.Encrypt("k", []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("same data is encrypted to the same ciphertext, want a random nonce")
+	}
+}
+
+func tamper(b []byte) []byte {
+	b = bytes.Clone(b)
+	b[len(b)-1] ^= 0xFF
+	return b
+}
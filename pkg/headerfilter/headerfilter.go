@@ -0,0 +1,61 @@
+package headerfilter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Patterns are case-insensitive header name patterns with '*' and '?' wildcards, e.g. 'Nats-Expected-*'.
+type Patterns []string
+
+// SetString parses patterns separated by comma.
+func (p *Patterns) SetString(s string) error {
+	var patterns Patterns
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("wrong pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	*p = patterns
+	return nil
+}
+
+// Match reports whether the header name matches any of the patterns.
+func (p Patterns) Match(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range p {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter keeps the headers matched by Allow (all headers if it is empty) and not matched by Deny.
+type Filter struct {
+	Allow Patterns
+	Deny  Patterns
+}
+
+// Keep reports whether the header with the name passes the filter.
+func (f Filter) Keep(name string) bool {
+	if len(f.Allow) > 0 && !f.Allow.Match(name) {
+		return false
+	}
+	return !f.Deny.Match(name)
+}
+
+// Apply removes the headers which don't pass the filter. Both http.Header and nats.Header are accepted.
+func Apply[H ~map[string][]string](f Filter, h H) {
+	for name := range h {
+		if !f.Keep(name) {
+			delete(h, name)
+		}
+	}
+}
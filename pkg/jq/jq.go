@@ -0,0 +1,631 @@
+// Package jq evaluates a subset of jq filters over documents decoded by encoding/json.
+//
+// Supported are the identity '.', paths ('.a.b', '."a-b"', '.["a"]', '.[0]', '.[-1]', '.a[.i]'), literals
+// (numbers, strings, 'true', 'false', 'null', JSON arrays and objects), comparisons ('==', '!=', '<', '<=', '>', '>=')
+// with jq ordering of values, 'and', 'or', pipes ('|'), parentheses and the builtins 'not', 'length', 'ascii_downcase',
+// 'has(f)', 'startswith(f)' and 'endswith(f)'. Every filter produces exactly one value: generators (e.g. '.[]'),
+// variables, arithmetic and function definitions are not supported.
+package jq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrEval = errors.New("jq filter can't be evaluated")
+
+// Filter is a parsed jq filter.
+type Filter struct {
+	src  string
+	root node
+}
+
+// Parse parses the jq filter.
+func Parse(s string) (*Filter, error) {
+	p := &parser{s: s, pos: 0}
+	root, err := p.parsePipe()
+	if err == nil {
+		p.skipSpace()
+		if p.pos < len(p.s) {
+			err = p.errorf("unexpected %q", p.s[p.pos:])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse jq filter %q: %w", s, err)
+	}
+	return &Filter{src: s, root: root}, nil
+}
+
+// Eval applies the filter to the decoded JSON value. Numbers may be float64 or json.Number.
+func (f *Filter) Eval(v any) (any, error) {
+	return f.root.eval(v)
+}
+
+func (f *Filter) String() string {
+	return f.src
+}
+
+// Truthy reports whether the value is true by jq rules: everything but null and false.
+func Truthy(v any) bool {
+	return v != nil && v != false
+}
+
+type node interface {
+	eval(input any) (any, error)
+}
+
+type (
+	identity struct{}
+	literal  struct{ value any }
+	pipe     struct{ left, right node }
+	or       struct{ left, right node }
+	and      struct{ left, right node }
+	compare  struct {
+		op          string
+		left, right node
+	}
+	// index indexes the value of the target by the key, which is evaluated with the same input as the target.
+	index struct {
+		target, key node
+	}
+	builtin struct {
+		name string
+		arg  node // nil for the builtins without arguments
+	}
+)
+
+func (identity) eval(input any) (any, error) { return input, nil }
+
+func (n literal) eval(any) (any, error) { return n.value, nil }
+
+func (n pipe) eval(input any) (any, error) {
+	v, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return n.right.eval(v)
+}
+
+func (n or) eval(input any) (any, error) {
+	l, err := n.left.eval(input)
+	if err != nil || Truthy(l) {
+		return Truthy(l), err
+	}
+	r, err := n.right.eval(input)
+	return Truthy(r), err
+}
+
+func (n and) eval(input any) (any, error) {
+	l, err := n.left.eval(input)
+	if err != nil || !Truthy(l) {
+		return false, err
+	}
+	r, err := n.right.eval(input)
+	return Truthy(r), err
+}
+
+func (n compare) eval(input any) (any, error) {
+	l, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	c := compareValues(l, r)
+	switch n.op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+func (n index) eval(input any) (any, error) {
+	v, err := n.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		if k, ok := key.(string); ok {
+			return v[k], nil
+		}
+	case []any:
+		if k, ok := number(key); ok {
+			i := int(math.Floor(k))
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			return v[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot index %s with %s", ErrEval, typeName(v), typeName(key))
+}
+
+func (n builtin) eval(input any) (any, error) {
+	var arg any
+	if n.arg != nil {
+		var err error
+		if arg, err = n.arg.eval(input); err != nil {
+			return nil, err
+		}
+	}
+
+	switch n.name {
+	case "not":
+		return !Truthy(input), nil
+	case "length":
+		switch v := input.(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		}
+		if f, ok := number(input); ok {
+			return math.Abs(f), nil
+		}
+	case "ascii_downcase":
+		if s, ok := input.(string); ok {
+			return strings.Map(func(r rune) rune {
+				if r <= unicode.MaxASCII {
+					return unicode.ToLower(r)
+				}
+				return r
+			}, s), nil
+		}
+	case "has":
+		switch v := input.(type) {
+		case map[string]any:
+			if k, ok := arg.(string); ok {
+				_, has := v[k]
+				return has, nil
+			}
+		case []any:
+			if k, ok := number(arg); ok {
+				return k >= 0 && k < float64(len(v)), nil
+			}
+		}
+	case "startswith", "endswith":
+		s, ok1 := input.(string)
+		affix, ok2 := arg.(string)
+		if ok1 && ok2 {
+			if n.name == "startswith" {
+				return strings.HasPrefix(s, affix), nil
+			}
+			return strings.HasSuffix(s, affix), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is not defined for %s", ErrEval, n.name, typeName(input))
+}
+
+// builtins are the supported builtins by the number of arguments.
+//
+//nolint:gochecknoglobals // constant map
+var builtins = map[string]int{"not": 0, "length": 0, "ascii_downcase": 0, "has": 1, "startswith": 1, "endswith": 1}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// The jq order of the types: null < false < true < numbers < strings < arrays < objects.
+const (
+	orderNull = iota
+	orderFalse
+	orderTrue
+	orderNumber
+	orderString
+	orderArray
+	orderObject
+)
+
+func typeOrder(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return orderNull
+	case bool:
+		if v {
+			return orderTrue
+		}
+		return orderFalse
+	case string:
+		return orderString
+	case []any:
+		return orderArray
+	case map[string]any:
+		return orderObject
+	}
+	return orderNumber
+}
+
+// compareValues compares the values by jq rules: objects are compared by their sorted keys first, then by the values.
+func compareValues(a, b any) int {
+	if ta, tb := typeOrder(a), typeOrder(b); ta != tb {
+		return ta - tb
+	}
+
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string)) //nolint:forcetypeassert // same type order
+	case []any:
+		b := b.([]any) //nolint:forcetypeassert // same type order
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compareValues(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return len(a) - len(b)
+	case map[string]any:
+		b := b.(map[string]any) //nolint:forcetypeassert // same type order
+		ka, kb := sortedKeys(a), sortedKeys(b)
+		if c := slices.Compare(ka, kb); c != 0 {
+			return c
+		}
+		for _, k := range ka {
+			if c := compareValues(a[k], b[k]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case nil, bool:
+		return 0
+	}
+
+	fa, _ := number(a)
+	fb, _ := number(b)
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parser is a recursive descent parser of the filter. From the lowest precedence: '|', 'or', 'and', comparisons,
+// paths and primary filters.
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume skips the spaces and the token if it is next.
+func (p *parser) consume(token string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.s[p.pos:], token) {
+		return false
+	}
+	// Keywords are not prefixes of identifiers, e.g. 'or' of 'order'.
+	if isIdentByte(token[0]) && p.pos+len(token) < len(p.s) && isIdentByte(p.s[p.pos+len(token)]) {
+		return false
+	}
+	p.pos += len(token)
+	return true
+}
+
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *parser) parsePipe() (node, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("|") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = pipe{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("and") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return compare{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parsePostfix parses the primary filter followed by the path: '.name', '."name"', '[f]' and '.[f]'.
+func (p *parser) parsePostfix() (node, error) {
+	target, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], ".["), strings.HasPrefix(p.s[p.pos:], "["):
+			p.consume(".")
+			if target, err = p.parseBracket(target); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(p.s[p.pos:], "."):
+			p.pos++
+			key, err := p.parseFieldName()
+			if err != nil {
+				return nil, err
+			}
+			target = index{target: target, key: literal{value: key}}
+		default:
+			return target, nil
+		}
+	}
+}
+
+func (p *parser) parseBracket(target node) (node, error) {
+	p.consume("[")
+	key, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume("]") {
+		return nil, p.errorf("']' is expected")
+	}
+	return index{target: target, key: key}, nil
+}
+
+func (p *parser) parseFieldName() (string, error) {
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		return p.parseString()
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos || unicode.IsDigit(rune(p.s[start])) {
+		return "", p.errorf("field name is expected")
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, p.errorf("filter is expected")
+	case c == '.':
+		p.pos++
+		switch {
+		case p.pos < len(p.s) && p.s[p.pos] == '[':
+			return p.parseBracket(identity{})
+		case p.pos < len(p.s) && (p.s[p.pos] == '"' || isIdentByte(p.s[p.pos]) && !unicode.IsDigit(rune(p.s[p.pos]))):
+			key, err := p.parseFieldName()
+			if err != nil {
+				return nil, err
+			}
+			return index{target: identity{}, key: literal{value: key}}, nil
+		}
+		return identity{}, nil
+	case c == '(':
+		p.pos++
+		n, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("')' is expected")
+		}
+		return n, nil
+	case c == '"':
+		s, err := p.parseString()
+		return literal{value: s}, err
+	case c == '[', c == '{':
+		return p.parseJSON()
+	case c == '-', c >= '0' && c <= '9':
+		return p.parseNumber()
+	case isIdentByte(c):
+		return p.parseIdent()
+	}
+	return nil, p.errorf("unexpected %q", p.s[p.pos:])
+}
+
+// parseJSON parses the JSON array or object literal.
+func (p *parser) parseJSON() (node, error) {
+	dec := json.NewDecoder(strings.NewReader(p.s[p.pos:]))
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, p.errorf("only JSON array and object literals are supported: %v", err)
+	}
+	p.pos += int(dec.InputOffset())
+	return literal{value: v}, nil
+}
+
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.s); p.pos++ {
+		switch p.s[p.pos] {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal([]byte(p.s[start:p.pos]), &s); err != nil {
+				return "", p.errorf("wrong string %s: %v", p.s[start:p.pos], err)
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorf("string is not terminated")
+}
+
+func (p *parser) parseNumber() (node, error) {
+	start := p.pos
+	if p.s[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.s) && strings.IndexByte("0123456789.eE+-", p.s[p.pos]) >= 0 {
+		if (p.s[p.pos] == '+' || p.s[p.pos] == '-') && p.s[p.pos-1] != 'e' && p.s[p.pos-1] != 'E' {
+			break
+		}
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, p.errorf("wrong number %q", p.s[start:p.pos])
+	}
+	return literal{value: f}, nil
+}
+
+func (p *parser) parseIdent() (node, error) {
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos]) {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+
+	switch name {
+	case "true":
+		return literal{value: true}, nil
+	case "false":
+		return literal{value: false}, nil
+	case "null":
+		return literal{value: nil}, nil
+	}
+
+	args, ok := builtins[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("%s is not supported", name)
+	}
+	if args == 0 {
+		return builtin{name: name, arg: nil}, nil
+	}
+	if !p.consume("(") {
+		return nil, p.errorf("%s needs an argument", name)
+	}
+	arg, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(")") {
+		return nil, p.errorf("')' is expected")
+	}
+	return builtin{name: name, arg: arg}, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
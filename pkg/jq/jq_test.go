@@ -0,0 +1,166 @@
+package jq
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return v
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    bool
+	}{
+		{filter: "."},
+		{filter: ".a.b"},
+		{filter: `."a-b"`},
+		{filter: `.["a"]`},
+		{filter: ".[0]"},
+		{filter: ".[-1]"},
+		{filter: ".a[.i]"},
+		{filter: ".a | length"},
+		{filter: `.type == "refund" and (.amount > 100 or .vip)`},
+		{filter: `[1, 2] == .items`},
+		{filter: `{"a": 1} != .`},
+		{filter: `has("a") | not`},
+		{filter: `.name | startswith("a") or endswith("z")`},
+		{filter: ".order"}, // 'or' keyword prefix
+		{filter: "", err: true},
+		{filter: ".a ==", err: true},
+		{filter: ".[0", err: true},
+		{filter: "(.a", err: true},
+		{filter: `"unterminated`, err: true},
+		{filter: ".[]", err: true},
+		{filter: ".a + 1", err: true},
+		{filter: "$var", err: true},
+		{filter: "map(.a)", err: true},
+		{filter: "has", err: true},
+		{filter: `has("a"`, err: true},
+		{filter: ".1", err: true},
+		{filter: "1e", err: true},
+		{filter: ". .", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := Parse(tt.filter)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if err == nil && f.String() != tt.filter {
+				t.Errorf("String() = %q, want %q", f.String(), tt.filter)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	const doc = `{"type":"refund","amount":150,"big":12345678901234567890,"vip":false,"name":"Alice",
+		"items":[1,2,3],"nested":{"a-b":{"c":"d"}},"i":1,"empty":[],"nil":null,"obj":{"b":1,"a":2}}`
+
+	tests := []struct {
+		filter string
+		want   any
+		err    error
+	}{
+		{filter: ".type", want: "refund"},
+		{filter: ".missing", want: nil},
+		{filter: ".missing.deeper", want: nil},
+		{filter: `."nested"."a-b".c`, want: "d"},
+		{filter: `.nested["a-b"]["c"]`, want: "d"},
+		{filter: ".items[0]", want: json.Number("1")},
+		{filter: ".items[-1]", want: json.Number("3")},
+		{filter: ".items[5]", want: nil},
+		{filter: ".items[.i]", want: json.Number("2")},
+		{filter: ".items | .[1]", want: json.Number("2")},
+		{filter: `.type == "refund"`, want: true},
+		{filter: `.type != "refund"`, want: false},
+		{filter: ".amount > 100", want: true},
+		{filter: ".amount >= 150", want: true},
+		{filter: ".amount < 150", want: false},
+		{filter: ".amount <= 149.5", want: false},
+		{filter: ".big == 12345678901234567890", want: true},
+		{filter: `.type == "refund" and .amount > 100`, want: true},
+		{filter: `.vip or .amount > 1000`, want: false},
+		{filter: `.vip or .name`, want: true},
+		{filter: ".vip | not", want: true},
+		{filter: ".nil | not", want: true},
+		{filter: "(.amount > 100) and (.items | length == 3)", want: true},
+		{filter: ".items | length", want: float64(3)},
+		{filter: ".name | length", want: float64(5)},
+		{filter: ".obj | length", want: float64(2)},
+		{filter: ".nil | length", want: float64(0)},
+		{filter: "-.5 | length", want: 0.5},
+		{filter: ".empty | length == 0", want: true},
+		{filter: ".name | ascii_downcase", want: "alice"},
+		{filter: `has("type")`, want: true},
+		{filter: `has("missing")`, want: false},
+		{filter: ".items | has(2)", want: true},
+		{filter: ".items | has(3)", want: false},
+		{filter: `.name | startswith("Al")`, want: true},
+		{filter: `.name | endswith("ce")`, want: true},
+		{filter: `.items == [1, 2, 3]`, want: true},
+		{filter: `.obj == {"a": 2, "b": 1}`, want: true},
+		{filter: `null < false and false < true and true < 0 and 0 < "" and "" < [] and [] < {}`, want: true},
+		{filter: `[1, 2] < [1, 3]`, want: true},
+		{filter: `{"a": 1} < {"b": 0}`, want: true},
+		{filter: ".type[0]", err: ErrEval},
+		{filter: `.items["a"]`, err: ErrEval},
+		{filter: ".amount | ascii_downcase", err: ErrEval},
+		{filter: `.amount | startswith("1")`, err: ErrEval},
+		{filter: ".vip | length", err: ErrEval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := f.Eval(decode(t, doc))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("result = %v, error = %v, want %v", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("eval: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	tests := []struct {
+		v    any
+		want bool
+	}{
+		{v: nil, want: false},
+		{v: false, want: false},
+		{v: true, want: true},
+		{v: float64(0), want: true},
+		{v: "", want: true},
+		{v: []any{}, want: true},
+	}
+	for _, tt := range tests {
+		if got := Truthy(tt.v); got != tt.want {
+			t.Errorf("Truthy(%#v) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package jsonpointer implements JSON Pointer (RFC 6901) over documents decoded by encoding/json.
+package jsonpointer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrNotFound = errors.New("value is not found")
+
+// Pointer is a parsed JSON pointer, e.g. '/data/items/0'. The empty pointer refers to the whole document.
+type Pointer []string
+
+// Parse parses the JSON pointer.
+func Parse(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("json pointer %q should start with '/'", s)
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+				return nil, fmt.Errorf("json pointer %q: '~' should be escaped as '~0'", s)
+			}
+		}
+		// '~1' is replaced first, so '~01' is '~1' rather than '/'.
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// SetString parses the JSON pointer. The empty string leaves the pointer unset (nil).
+func (p *Pointer) SetString(s string) error {
+	if s == "" {
+		*p = nil
+		return nil
+	}
+
+	pointer, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*p = pointer
+	return nil
+}
+
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, t := range p {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+// Get returns the value the pointer refers to.
+func (p Pointer) Get(doc any) (any, error) {
+	v := doc
+	for _, t := range p {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[t]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+			}
+		case []any:
+			i, ok := arrayIndex(t)
+			if !ok || i >= len(node) {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+		}
+	}
+	return v, nil
+}
+
+// Set sets the value the pointer refers to and returns the updated document.
+// Missing objects on the path are created, '-' index appends the value to the array.
+func (p Pointer) Set(doc, value any) (any, error) {
+	if len(p) == 0 {
+		return value, nil
+	}
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, err := p[1:].Set(node[p[0]], value)
+		if err != nil {
+			return nil, err
+		}
+		node[p[0]] = child
+		return node, nil
+	case []any:
+		if p[0] == "-" {
+			child, err := p[1:].Set(nil, value)
+			if err != nil {
+				return nil, err
+			}
+			return append(node, child), nil
+		}
+		i, ok := arrayIndex(p[0])
+		if !ok || i >= len(node) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, p)
+		}
+		child, err := p[1:].Set(node[i], value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	case nil:
+		return p.Set(map[string]any{}, value)
+	}
+	return nil, fmt.Errorf("%w: %s: not a container", ErrNotFound, p)
+}
+
+// Delete removes the object member the pointer refers to. Missing members are ignored.
+func (p Pointer) Delete(doc any) {
+	if len(p) == 0 {
+		return
+	}
+	parent, err := p[:len(p)-1].Get(doc)
+	if err != nil {
+		return
+	}
+	if obj, ok := parent.(map[string]any); ok {
+		delete(obj, p[len(p)-1])
+	}
+}
+
+// arrayIndex parses the array index token: '0' or a decimal number without leading zeros.
+func arrayIndex(t string) (int, bool) {
+	if t == "" || (len(t) > 1 && t[0] == '0') || strings.TrimLeft(t, "0123456789") != "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(t)
+	return i, err == nil
+}
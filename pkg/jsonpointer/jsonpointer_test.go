@@ -0,0 +1,190 @@
+package jsonpointer
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return v
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Pointer
+		err  bool
+	}{
+		{s: "", want: Pointer{}},
+		{s: "/", want: Pointer{""}},
+		{s: "/a/b", want: Pointer{"a", "b"}},
+		{s: "/a~1b", want: Pointer{"a/b"}},
+		{s: "/m~0n", want: Pointer{"m~n"}},
+		{s: "/~01", want: Pointer{"~1"}}, // not '/'
+		{s: "/~10", want: Pointer{"/0"}},
+		{s: "/items/0", want: Pointer{"items", "0"}},
+		{s: "a/b", err: true},
+		{s: "/a~", err: true},
+		{s: "/a~2", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			p, err := Parse(tt.s)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("pointer = %q, want %q", p, tt.want)
+			}
+			if p.String() != tt.s {
+				t.Errorf("String() = %q, want %q", p.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	// The document of RFC 6901 section 5.
+	const doc = `{"foo":["bar","baz"],"":0,"a/b":1,"c%d":2,"e^f":3,"g|h":4,"i\\j":5,"k\"l":6," ":7,"m~n":8}`
+
+	tests := []struct {
+		pointer string
+		want    string // JSON, empty if not found
+	}{
+		{pointer: "", want: doc},
+		{pointer: "/foo", want: `["bar","baz"]`},
+		{pointer: "/foo/0", want: `"bar"`},
+		{pointer: "/foo/1", want: `"baz"`},
+		{pointer: "/", want: `0`},
+		{pointer: "/a~1b", want: `1`},
+		{pointer: "/c%d", want: `2`},
+		{pointer: "/e^f", want: `3`},
+		{pointer: "/g|h", want: `4`},
+		{pointer: "/i\\j", want: `5`},
+		{pointer: "/k\"l", want: `6`},
+		{pointer: "/ ", want: `7`},
+		{pointer: "/m~0n", want: `8`},
+		{pointer: "/missing"},
+		{pointer: "/foo/2"},
+		{pointer: "/foo/-"},
+		{pointer: "/foo/-1"},
+		{pointer: "/foo/01"},
+		{pointer: "/foo/+1"},
+		{pointer: "/foo/bar"},
+		{pointer: "/foo/0/deeper"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			p, err := Parse(tt.pointer)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := p.Get(decode(t, doc))
+			if tt.want == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("value = %v, error = %v, want %v", got, err, ErrNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("value = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		pointer string
+		value   any
+		want    string // JSON, empty if an error is expected
+	}{
+		{name: "root", doc: `{"a":1}`, pointer: "", value: "x", want: `"x"`},
+		{name: "member", doc: `{"a":1}`, pointer: "/a", value: "x", want: `{"a":"x"}`},
+		{name: "new member", doc: `{"a":1}`, pointer: "/b", value: "x", want: `{"a":1,"b":"x"}`},
+		{name: "missing objects are created", doc: `{}`, pointer: "/a/b", value: "x", want: `{"a":{"b":"x"}}`},
+		{name: "escaped member", doc: `{}`, pointer: "/a~1b/m~0n", value: "x", want: `{"a/b":{"m~n":"x"}}`},
+		{name: "array item", doc: `{"a":[1,2]}`, pointer: "/a/1", value: "x", want: `{"a":[1,"x"]}`},
+		{name: "append", doc: `{"a":[1,2]}`, pointer: "/a/-", value: "x", want: `{"a":[1,2,"x"]}`},
+		{name: "append object", doc: `{"a":[]}`, pointer: "/a/-/b", value: "x", want: `{"a":[{"b":"x"}]}`},
+		{name: "array index out of range", doc: `{"a":[1]}`, pointer: "/a/1", value: "x"},
+		{name: "array index with leading zero", doc: `{"a":[1,2]}`, pointer: "/a/01", value: "x"},
+		{name: "not a container", doc: `{"a":1}`, pointer: "/a/b", value: "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.pointer)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got, err := p.Set(decode(t, tt.doc), tt.value)
+			if tt.want == "" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("document = %v, error = %v, want %v", got, err, ErrNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("document = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{pointer: "/a/b", want: `{"a":{"c":2},"a/b":0,"l":[1]}`},
+		{pointer: "/a~1b", want: `{"a":{"b":1,"c":2},"l":[1]}`},
+		{pointer: "/missing/b", want: `{"a":{"b":1,"c":2},"a/b":0,"l":[1]}`},
+		{pointer: "/l/0", want: `{"a":{"b":1,"c":2},"a/b":0,"l":[1]}`}, // array items are not deleted
+		{pointer: "", want: `{"a":{"b":1,"c":2},"a/b":0,"l":[1]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			p, err := Parse(tt.pointer)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			doc := decode(t, `{"a":{"b":1,"c":2},"a/b":0,"l":[1]}`)
+			p.Delete(doc)
+			if want := decode(t, tt.want); !reflect.DeepEqual(doc, want) {
+				t.Errorf("document = %v, want %v", doc, want)
+			}
+		})
+	}
+}
+
+func TestPointerSetString(t *testing.T) {
+	var p Pointer
+	if err := p.SetString("/a"); err != nil || !reflect.DeepEqual(p, Pointer{"a"}) {
+		t.Fatalf("pointer = %q, %v", p, err)
+	}
+	if err := p.SetString(""); err != nil || p != nil {
+		t.Errorf("empty string: pointer = %q, %v, want nil", p, err)
+	}
+	if err := p.SetString("a"); err == nil {
+		t.Error("pointer without '/' is accepted")
+	}
+}
@@ -0,0 +1,281 @@
+// Package jsonschema validates JSON documents against a subset of JSON Schema:
+// type, enum, const, required, properties, additionalProperties (boolean), items,
+// minimum, maximum, minLength, maxLength, pattern, minItems and maxItems.
+// Annotations (e.g. title and description) are ignored, other keywords fail the compilation,
+// so a schema isn't silently weaker than it is written.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+var ErrInvalid = errors.New("document does not match schema")
+
+type node struct {
+	Type                 any              `json:"type"`
+	Enum                 []any            `json:"enum"`
+	Const                any              `json:"const"`
+	Required             []string         `json:"required"`
+	Properties           map[string]*node `json:"properties"`
+	AdditionalProperties *bool            `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+	MinLength            *int             `json:"minLength"`
+	MaxLength            *int             `json:"maxLength"`
+	Pattern              string           `json:"pattern"`
+	MinItems             *int             `json:"minItems"`
+	MaxItems             *int             `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// keywords are the supported keywords and the annotations ignored by the validation.
+//
+//nolint:gochecknoglobals // constant set
+var keywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "required": true, "properties": true, "additionalProperties": true,
+	"items": true, "minimum": true, "maximum": true, "minLength": true, "maxLength": true, "pattern": true,
+	"minItems": true, "maxItems": true,
+
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true, "default": true,
+	"examples": true, "deprecated": true, "readOnly": true, "writeOnly": true, "format": true,
+}
+
+func (n *node) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err //nolint:wrapcheck // wrapped by Compile
+	}
+	for name := range fields {
+		if !keywords[name] {
+			return fmt.Errorf("keyword %q is not supported", name)
+		}
+	}
+
+	// plain has no UnmarshalJSON method, so the fields are decoded by default.
+	type plain node
+	return json.Unmarshal(data, (*plain)(n)) //nolint:wrapcheck // wrapped by Compile
+}
+
+// Schema is a compiled schema. The zero value accepts any document.
+type Schema struct {
+	root *node
+}
+
+// SetString loads the schema from the file.
+func (s *Schema) SetString(path string) error {
+	if path == "" {
+		*s = Schema{}
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read schema: %w", err)
+	}
+
+	schema, err := Compile(data)
+	if err != nil {
+		return fmt.Errorf("schema %q: %w", path, err)
+	}
+	*s = schema
+	return nil
+}
+
+// Compile parses the schema.
+func Compile(data []byte) (Schema, error) {
+	var root node
+	if err := json.Unmarshal(data, &root); err != nil {
+		return Schema{}, fmt.Errorf("parse schema: %w", err)
+	}
+	if err := root.compile(); err != nil {
+		return Schema{}, err
+	}
+	return Schema{root: &root}, nil
+}
+
+func (n *node) compile() error {
+	if n.Pattern != "" {
+		re, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("compile pattern %q: %w", n.Pattern, err)
+		}
+		n.pattern = re
+	}
+	for _, p := range n.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if n.Items != nil {
+		return n.Items.compile()
+	}
+	return nil
+}
+
+// Enabled reports whether the schema is loaded.
+func (s Schema) Enabled() bool {
+	return s.root != nil
+}
+
+// Validate checks the JSON document against the schema. The returned error wraps ErrInvalid
+// and tells the path of the first mismatch.
+func (s Schema) Validate(data []byte) error {
+	if s.root == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: not a JSON: %w", ErrInvalid, err)
+	}
+	return s.root.validate("$", doc)
+}
+
+func (n *node) validate(path string, v any) error {
+	if n.Type != nil && !n.matchType(v) {
+		return invalid(path, "type %s is expected", n.Type)
+	}
+	if n.Enum != nil && !slices.ContainsFunc(n.Enum, func(e any) bool { return equal(e, v) }) {
+		return invalid(path, "value is not one of enum")
+	}
+	if n.Const != nil && !equal(n.Const, v) {
+		return invalid(path, "value is not equal to const")
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range n.Required {
+			if _, ok := v[name]; !ok {
+				return invalid(path, "required property %q is missing", name)
+			}
+		}
+		for name, val := range v {
+			p, ok := n.Properties[name]
+			if !ok {
+				if n.AdditionalProperties != nil && !*n.AdditionalProperties {
+					return invalid(path, "additional property %q is not allowed", name)
+				}
+				continue
+			}
+			if err := p.validate(path+"."+name, val); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if n.MinItems != nil && len(v) < *n.MinItems {
+			return invalid(path, "at least %d items are expected", *n.MinItems)
+		}
+		if n.MaxItems != nil && len(v) > *n.MaxItems {
+			return invalid(path, "at most %d items are expected", *n.MaxItems)
+		}
+		if n.Items != nil {
+			for i, item := range v {
+				if err := n.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		l := utf8.RuneCountInString(v)
+		if n.MinLength != nil && l < *n.MinLength {
+			return invalid(path, "at least %d characters are expected", *n.MinLength)
+		}
+		if n.MaxLength != nil && l > *n.MaxLength {
+			return invalid(path, "at most %d characters are expected", *n.MaxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			return invalid(path, "value does not match pattern %q", n.Pattern)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if n.Minimum != nil && f < *n.Minimum {
+			return invalid(path, "value is less than minimum %v", *n.Minimum)
+		}
+		if n.Maximum != nil && f > *n.Maximum {
+			return invalid(path, "value is greater than maximum %v", *n.Maximum)
+		}
+	}
+	return nil
+}
+
+func (n *node) matchType(v any) bool {
+	switch t := n.Type.(type) {
+	case string:
+		return isType(t, v)
+	case []any:
+		return slices.ContainsFunc(t, func(t any) bool {
+			s, ok := t.(string)
+			return ok && isType(s, v)
+		})
+	}
+	return true
+}
+
+func isType(t string, v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case map[string]any:
+		return t == "object"
+	case []any:
+		return t == "array"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		_, err := v.Int64()
+		return t == "integer" && err == nil
+	}
+	return false
+}
+
+// equal compares a schema value (numbers are float64) with a document value (numbers are json.Number).
+func equal(schema, doc any) bool {
+	return reflect.DeepEqual(schema, floats(doc))
+}
+
+// floats returns the document value with its numbers, nested ones included, converted to float64.
+func floats(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		return f
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = floats(item)
+		}
+		return items
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[k] = floats(item)
+		}
+		return m
+	}
+	return v
+}
+
+func invalid(path, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalid, path, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,120 @@
+package jsonschema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		valid  bool
+	}{
+		{name: "type", schema: `{"type":"object"}`, doc: `{}`, valid: true},
+		{name: "type mismatch", schema: `{"type":"object"}`, doc: `[]`},
+		{name: "type list", schema: `{"type":["string","null"]}`, doc: `null`, valid: true},
+		{name: "type list mismatch", schema: `{"type":["string","null"]}`, doc: `1`},
+		{name: "integer", schema: `{"type":"integer"}`, doc: `12345678901234567`, valid: true},
+		{name: "integer mismatch", schema: `{"type":"integer"}`, doc: `1.5`},
+		{name: "number", schema: `{"type":"number"}`, doc: `1.5`, valid: true},
+		{name: "boolean", schema: `{"type":"boolean"}`, doc: `false`, valid: true},
+		{name: "enum", schema: `{"enum":["ok",1,{"a":[2]}]}`, doc: `1`, valid: true},
+		{name: "enum nested numbers", schema: `{"enum":["ok",1,{"a":[2]}]}`, doc: `{"a":[2]}`, valid: true},
+		{name: "enum mismatch", schema: `{"enum":["ok",1]}`, doc: `"failed"`},
+		{name: "const", schema: `{"const":"ok"}`, doc: `"ok"`, valid: true},
+		{name: "const mismatch", schema: `{"const":"ok"}`, doc: `"ko"`},
+		{name: "required", schema: `{"required":["id"]}`, doc: `{"id":1}`, valid: true},
+		{name: "required missing", schema: `{"required":["id"]}`, doc: `{"name":"x"}`},
+		{name: "required of non-object", schema: `{"required":["id"]}`, doc: `"id"`, valid: true},
+		{name: "properties", schema: `{"properties":{"id":{"type":"string"}}}`, doc: `{"id":"a","other":1}`, valid: true},
+		{name: "properties mismatch", schema: `{"properties":{"id":{"type":"string"}}}`, doc: `{"id":1}`},
+		{name: "additional properties allowed", schema: `{"properties":{"id":{}},"additionalProperties":true}`, doc: `{"x":1}`, valid: true},
+		{name: "additional properties denied", schema: `{"properties":{"id":{}},"additionalProperties":false}`, doc: `{"id":1,"x":1}`},
+		{name: "items", schema: `{"items":{"type":"integer"}}`, doc: `[1,2]`, valid: true},
+		{name: "items mismatch", schema: `{"items":{"type":"integer"}}`, doc: `[1,"2"]`},
+		{name: "minimum", schema: `{"minimum":1}`, doc: `1`, valid: true},
+		{name: "minimum mismatch", schema: `{"minimum":1}`, doc: `0.5`},
+		{name: "maximum", schema: `{"maximum":10}`, doc: `10`, valid: true},
+		{name: "maximum mismatch", schema: `{"maximum":10}`, doc: `11`},
+		{name: "minLength", schema: `{"minLength":2}`, doc: `"ÿÿ"`, valid: true},
+		{name: "minLength mismatch", schema: `{"minLength":2}`, doc: `"ÿ"`},
+		{name: "maxLength", schema: `{"maxLength":2}`, doc: `"ab"`, valid: true},
+		{name: "maxLength mismatch", schema: `{"maxLength":2}`, doc: `"abc"`},
+		{name: "pattern", schema: `{"pattern":"^[a-z]+$"}`, doc: `"abc"`, valid: true},
+		{name: "pattern mismatch", schema: `{"pattern":"^[a-z]+$"}`, doc: `"ABC"`},
+		{name: "minItems", schema: `{"minItems":1}`, doc: `[1]`, valid: true},
+		{name: "minItems mismatch", schema: `{"minItems":1}`, doc: `[]`},
+		{name: "maxItems", schema: `{"maxItems":1}`, doc: `[1]`, valid: true},
+		{name: "maxItems mismatch", schema: `{"maxItems":1}`, doc: `[1,2]`},
+		{name: "annotations are ignored", schema: `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"t","format":"email"}`, doc: `"x"`, valid: true},
+		{name: "nested", schema: `{"properties":{"items":{"items":{"required":["id"]}}}}`, doc: `{"items":[{"id":1},{}]}`},
+		{name: "not a json", schema: `{}`, doc: `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			err = s.Validate([]byte(tt.doc))
+			if tt.valid && err != nil {
+				t.Errorf("validate: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalid) {
+				t.Errorf("error = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    bool
+	}{
+		{name: "empty", schema: `{}`},
+		{name: "all supported keywords", schema: `{"type":"object","required":["a"],"additionalProperties":false,
+			"properties":{"a":{"type":"array","minItems":1,"maxItems":2,"items":{"type":"string","minLength":1,"maxLength":2,"pattern":"x"}},
+			"b":{"minimum":1,"maximum":2},"c":{"enum":[1]},"d":{"const":1}}}`},
+		{name: "unsupported keyword", schema: `{"oneOf":[{"type":"string"}]}`, err: true},
+		{name: "unsupported nested keyword", schema: `{"properties":{"a":{"$ref":"#/$defs/a"}}}`, err: true},
+		{name: "unsupported items keyword", schema: `{"items":{"uniqueItems":true}}`, err: true},
+		{name: "wrong pattern", schema: `{"pattern":"("}`, err: true},
+		{name: "wrong nested pattern", schema: `{"properties":{"a":{"pattern":"("}}}`, err: true},
+		{name: "not an object", schema: `[]`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if (err != nil) != tt.err {
+				t.Errorf("error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSchemaSetString(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type":"object"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var s Schema
+	if err := s.SetString(path); err != nil || !s.Enabled() {
+		t.Fatalf("set string: %v, enabled %v", err, s.Enabled())
+	}
+	if err := s.SetString(""); err != nil || s.Enabled() {
+		t.Errorf("empty path: %v, enabled %v", err, s.Enabled())
+	}
+	if err := s.Validate([]byte(`[]`)); err != nil {
+		t.Errorf("zero schema rejected the document: %v", err)
+	}
+	if err := s.SetString(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file is loaded")
+	}
+}
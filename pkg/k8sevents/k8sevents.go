@@ -0,0 +1,175 @@
+// Package k8sevents creates Kubernetes Events on the pod object with the in-cluster service account,
+// so they are shown by 'kubectl describe pod'. The service account needs 'create' permission on 'events'.
+package k8sevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Event types.
+const (
+	Normal  = "Normal"
+	Warning = "Warning"
+)
+
+const (
+	component      = "nats-jetstream-http-connector"
+	serviceAccount = "/var/run/secrets/kubernetes.io/serviceaccount/"
+)
+
+var ErrNotInCluster = errors.New("not running in a kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+
+// Pod is the object the events are reported on.
+type Pod struct {
+	Name      string
+	Namespace string // the namespace of the service account if it is empty
+	UID       string // optional, 'kubectl describe' matches the events by UID if it is set
+	NodeName  string
+}
+
+// Recorder creates the events of the pod.
+type Recorder struct {
+	pod     Pod
+	apiURL  string
+	http    *http.Client
+	tokenFn func() ([]byte, error)
+}
+
+// NewInCluster creates the recorder with the API server address from the environment and the service account credentials.
+func NewInCluster(pod Pod) (*Recorder, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	if pod.Name == "" {
+		return nil, errors.New("pod name is required")
+	}
+
+	if pod.Namespace == "" {
+		ns, err := os.ReadFile(serviceAccount + "namespace")
+		if err != nil {
+			return nil, fmt.Errorf("read service account namespace: %w", err)
+		}
+		pod.Namespace = string(bytes.TrimSpace(ns))
+	}
+
+	ca, err := os.ReadFile(serviceAccount + "ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read service account ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("service account ca has no certificates")
+	}
+
+	return &Recorder{
+		pod:    pod,
+		apiURL: "https://" + net.JoinHostPort(host, port),
+		http: &http.Client{ //nolint:exhaustruct // optional parameters
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{ //nolint:exhaustruct // optional parameters
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, //nolint:exhaustruct // optional parameters
+			},
+		},
+		// The token is read for every request: projected service account tokens are rotated.
+		tokenFn: func() ([]byte, error) { return os.ReadFile(serviceAccount + "token") },
+	}, nil
+}
+
+type objectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	UID        string `json:"uid,omitempty"`
+}
+
+type event struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		GenerateName string `json:"generateName"`
+		Namespace    string `json:"namespace"`
+	} `json:"metadata"`
+	InvolvedObject objectReference `json:"involvedObject"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Type           string          `json:"type"`
+	Source         struct {
+		Component string `json:"component"`
+		Host      string `json:"host,omitempty"`
+	} `json:"source"`
+	FirstTimestamp     time.Time `json:"firstTimestamp"`
+	LastTimestamp      time.Time `json:"lastTimestamp"`
+	Count              int       `json:"count"`
+	ReportingComponent string    `json:"reportingComponent"`
+	ReportingInstance  string    `json:"reportingInstance"`
+}
+
+// Event creates the event of the type (Normal or Warning) with the reason (UpperCamelCase) and the message on the pod.
+func (r *Recorder) Event(ctx context.Context, eventType, reason, message string) error {
+	now := time.Now().UTC()
+	ev := event{ //nolint:exhaustruct // nested structs are set below
+		APIVersion: "v1",
+		Kind:       "Event",
+		InvolvedObject: objectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       r.pod.Name,
+			Namespace:  r.pod.Namespace,
+			UID:        r.pod.UID,
+		},
+		Reason:             reason,
+		Message:            message,
+		Type:               eventType,
+		FirstTimestamp:     now,
+		LastTimestamp:      now,
+		Count:              1,
+		ReportingComponent: component,
+		ReportingInstance:  r.pod.Name,
+	}
+	ev.Metadata.GenerateName = r.pod.Name + "."
+	ev.Metadata.Namespace = r.pod.Namespace
+	ev.Source.Component = component
+	ev.Source.Host = r.pod.NodeName
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	token, err := r.tokenFn()
+	if err != nil {
+		return fmt.Errorf("read service account token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.apiURL+"/api/v1/namespaces/"+r.pod.Namespace+"/events", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+string(bytes.TrimSpace(token)))
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("create event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("create event: status %d: %s", resp.StatusCode, b)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package largemsg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	HeaderChunkID    = "Nats-Chunk-Id"
+	HeaderChunkSeq   = "Nats-Chunk-Seq"
+	HeaderChunkTotal = "Nats-Chunk-Total"
+
+	HeaderObjectRef    = "Nats-Object-Ref"
+	HeaderObjectBucket = "Nats-Object-Bucket"
+
+	// HeaderTruncated marks a truncated message, the value is the original size in bytes.
+	HeaderTruncated = "Nats-Truncated"
+)
+
+// HeaderReserve is the room left in every chunk for the message headers.
+const HeaderReserve = 1024
+
+var ErrTooLarge = errors.New("message exceeds max payload")
+
+type Mode string
+
+const (
+	ModeFail        Mode = "fail"
+	ModeChunk       Mode = "chunk"
+	ModeObjectStore Mode = "objectstore"
+	ModeTruncate    Mode = "truncate"
+	ModeDrop        Mode = "drop"
+)
+
+func (m *Mode) SetString(s string) error {
+	switch mode := Mode(strings.ToLower(s)); mode {
+	case ModeFail, ModeChunk, ModeObjectStore, ModeTruncate, ModeDrop:
+		*m = mode
+	default:
+		return fmt.Errorf("wrong mode: only 'fail|chunk|objectstore|truncate|drop' are accepted")
+	}
+	return nil
+}
+
+// ChunkMsgs splits data into messages of at most size bytes of payload.
+// Every message is marked with the same chunk id, its 1-based sequence number and the total amount of chunks.
+func ChunkMsgs(subject string, data []byte, size int, id string) []*nats.Msg {
+	if size <= 0 {
+		size = len(data)
+	}
+	total := (len(data) + size - 1) / size
+
+	msgs := make([]*nats.Msg, 0, total)
+	for i := 0; i < total; i++ {
+		end := min((i+1)*size, len(data))
+
+		msg := nats.NewMsg(subject)
+		msg.Data = data[i*size : end]
+		msg.Header.Set(HeaderChunkID, id)
+		msg.Header.Set(HeaderChunkSeq, strconv.Itoa(i+1))
+		msg.Header.Set(HeaderChunkTotal, strconv.Itoa(total))
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// TruncatedMsg returns the message with the first size bytes of data marked with the original size.
+func TruncatedMsg(subject string, data []byte, size int) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Data = data[:min(size, len(data))]
+	msg.Header.Set(HeaderTruncated, strconv.Itoa(len(data)))
+	return msg
+}
+
+// ObjectRefMsg returns an empty message that points to the object stored in the bucket.
+func ObjectRefMsg(subject, bucket, name string) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	msg.Header.Set(HeaderObjectRef, name)
+	msg.Header.Set(HeaderObjectBucket, bucket)
+	return msg
+}
@@ -0,0 +1,376 @@
+// Package loadgen publishes synthetic messages at a target rate and reports the throughput and the latency
+// of the connector from its metrics and receipts.
+package loadgen
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nuid"
+)
+
+// Config of the load generator.
+type Config struct {
+	NatsServer string
+	Subject    string
+	Rate       float64
+	Duration   time.Duration
+	Size       int
+	MetricsURL string
+	Drain      time.Duration
+	// ReceiptsSubject is RECEIPTS_SUBJECT of the connector: the end-to-end latency is measured by the receipts.
+	ReceiptsSubject string
+}
+
+// ParseFlags parses the loadgen subcommand arguments.
+func ParseFlags(args []string) (Config, error) {
+	var cfg Config
+
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	fs.StringVar(&cfg.NatsServer, "nats-server", nats.DefaultURL, "NATS server address")
+	fs.StringVar(&cfg.Subject, "subject", "", "Subject to publish to - input subject of the connector (TOPIC + '.input')")
+	fs.Float64Var(&cfg.Rate, "rate", 100, "Target messages per second")
+	fs.DurationVar(&cfg.Duration, "duration", time.Minute, "How long to publish")
+	fs.IntVar(&cfg.Size, "size", 256, "Message size in bytes")
+	fs.StringVar(&cfg.MetricsURL, "metrics-url", "http://localhost:2112/metrics", "Metrics endpoint of the connector, empty disables the report")
+	fs.DurationVar(&cfg.Drain, "drain", 30*time.Second, "How long to wait for the connector to process published messages")
+	fs.StringVar(&cfg.ReceiptsSubject, "receipts-subject", "",
+		"Receipts subject of the connector with RECEIPTS enabled ('<TOPIC>.receipts' by default), empty disables the end-to-end latency")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, fmt.Errorf("parse flags: %w", err)
+	}
+	if cfg.Subject == "" {
+		return cfg, errors.New("-subject is required")
+	}
+	if cfg.Rate <= 0 {
+		return cfg, errors.New("-rate should be positive")
+	}
+	if cfg.Duration <= 0 {
+		return cfg, errors.New("-duration should be positive")
+	}
+	if cfg.Size <= 0 {
+		return cfg, errors.New("-size should be positive")
+	}
+	if cfg.Drain < 0 {
+		return cfg, errors.New("-drain should not be negative")
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return cfg, nil
+}
+
+// Report is the result of the load test.
+type Report struct {
+	Published     int // acked by the stream
+	PublishErrors int // failed or not acked before the drain timeout
+	Elapsed       time.Duration
+
+	Processed   float64
+	Results     map[string]float64
+	Throughput  float64
+	AvgLatency  time.Duration
+	MetricsRead bool
+
+	// Latency is the end-to-end latency from the publishing to the 'ack' receipt, set if ReceiptsSubject is set.
+	Latency *Latency
+}
+
+// Latency is the distribution of the end-to-end latencies of the acked messages.
+type Latency struct {
+	Count              int
+	P50, P90, P99, Max time.Duration
+}
+
+func newLatency(samples []time.Duration) *Latency {
+	l := &Latency{Count: len(samples)} //nolint:exhaustruct // no samples
+	if len(samples) == 0 {
+		return l
+	}
+	slices.Sort(samples)
+	at := func(q float64) time.Duration { return samples[int(q*float64(len(samples)-1))] }
+	l.P50, l.P90, l.P99, l.Max = at(0.5), at(0.9), at(0.99), samples[len(samples)-1]
+	return l
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "published: %d (errors: %d) in %v, %.1f msg/s\n",
+		r.Published, r.PublishErrors, r.Elapsed.Round(time.Millisecond), float64(r.Published)/r.Elapsed.Seconds())
+	if r.Latency != nil {
+		fmt.Fprintf(&b, "end-to-end latency of %d acked messages: p50 %v, p90 %v, p99 %v, max %v\n", r.Latency.Count,
+			r.Latency.P50.Round(time.Microsecond), r.Latency.P90.Round(time.Microsecond), r.Latency.P99.Round(time.Microsecond), r.Latency.Max.Round(time.Microsecond))
+	}
+	if !r.MetricsRead {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "processed: %.0f, %.1f msg/s, avg processing time: %v\n", r.Processed, r.Throughput, r.AvgLatency.Round(time.Microsecond))
+	for result, n := range r.Results {
+		fmt.Fprintf(&b, "  %s: %.0f\n", result, n)
+	}
+	return b.String()
+}
+
+// tracker measures the end-to-end latencies: every message has a unique Nats-Msg-Id, the connector reports it
+// as the correlation ID of the receipt.
+type tracker struct {
+	mx        sync.Mutex
+	published map[string]time.Time
+	latencies []time.Duration
+}
+
+func (t *tracker) publish(id string, at time.Time) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	t.published[id] = at
+}
+
+func (t *tracker) receipt(data []byte, at time.Time) {
+	var receipt struct {
+		CorrelationID string `json:"correlation_id"`
+		Result        string `json:"result"`
+	}
+	if err := json.Unmarshal(data, &receipt); err != nil || receipt.Result != "ack" {
+		return
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if published, ok := t.published[receipt.CorrelationID]; ok {
+		delete(t.published, receipt.CorrelationID)
+		t.latencies = append(t.latencies, at.Sub(published))
+	}
+}
+
+func (t *tracker) received() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	return len(t.latencies)
+}
+
+func (t *tracker) latency() *Latency {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	return newLatency(slices.Clone(t.latencies))
+}
+
+// Run publishes messages at the target rate and waits until the connector processes them or the drain timeout.
+func Run(ctx context.Context, cfg Config, out io.Writer) (Report, error) {
+	nc, err := nats.Connect(cfg.NatsServer)
+	if err != nil {
+		return Report{}, fmt.Errorf("connect to nats: %w", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return Report{}, fmt.Errorf("jetstream context: %w", err)
+	}
+
+	var before snapshot
+	if cfg.MetricsURL != "" {
+		before, err = scrape(ctx, cfg.MetricsURL)
+		if err != nil {
+			return Report{}, fmt.Errorf("scrape metrics before the test: %w", err)
+		}
+	}
+
+	var track *tracker
+	if cfg.ReceiptsSubject != "" {
+		track = &tracker{published: map[string]time.Time{}} //nolint:exhaustruct // no latencies yet
+		sub, err := nc.Subscribe(cfg.ReceiptsSubject, func(m *nats.Msg) { track.receipt(m.Data, time.Now()) })
+		if err != nil {
+			return Report{}, fmt.Errorf("subscribe to receipts: %w", err)
+		}
+		defer sub.Unsubscribe() //nolint:errcheck // the connection is closed anyway
+	}
+
+	payload := make([]byte, cfg.Size)
+	_, _ = rand.Read(payload)
+	data := []byte(base64.StdEncoding.EncodeToString(payload))[:cfg.Size]
+
+	var report Report
+	var acks []jetstream.PubAckFuture
+	runID := nuid.Next()
+	t0 := time.Now()
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+	defer ticker.Stop()
+
+	deadline := time.After(cfg.Duration)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			m := nats.NewMsg(cfg.Subject)
+			m.Data = data
+			id := fmt.Sprintf("%s-%d", runID, len(acks)+report.PublishErrors)
+			m.Header.Set(jetstream.MsgIDHeader, id)
+			if track != nil {
+				track.publish(id, time.Now())
+			}
+			ack, err := js.PublishMsgAsync(m)
+			if err != nil {
+				report.PublishErrors++
+				continue
+			}
+			acks = append(acks, ack)
+		}
+	}
+
+	select {
+	case <-js.PublishAsyncComplete():
+	case <-time.After(cfg.Drain):
+	}
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+			report.Published++
+		default: // failed or not acked in time
+			report.PublishErrors++
+		}
+	}
+	report.Elapsed = time.Since(t0)
+	fmt.Fprintf(out, "publishing is done: %d messages acked, %d failed\n", report.Published, report.PublishErrors)
+
+	var after snapshot
+	drainUntil := time.Now().Add(cfg.Drain)
+	for {
+		done := track == nil || track.received() >= report.Published
+		if cfg.MetricsURL != "" {
+			after, err = scrape(ctx, cfg.MetricsURL)
+			if err != nil {
+				return report, fmt.Errorf("scrape metrics after the test: %w", err)
+			}
+			done = done && after.processed()-before.processed() >= float64(report.Published)
+		}
+		if done || time.Now().After(drainUntil) || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	elapsed := time.Since(t0)
+
+	if track != nil {
+		report.Latency = track.latency()
+	}
+	if cfg.MetricsURL == "" {
+		return report, nil
+	}
+
+	report.MetricsRead = true
+	report.Processed = after.processed() - before.processed()
+	report.Throughput = report.Processed / elapsed.Seconds()
+	report.Results = map[string]float64{}
+	for result, n := range after.results {
+		if d := n - before.results[result]; d > 0 {
+			report.Results[result] = d
+		}
+	}
+	if count := after.latencyCount - before.latencyCount; count > 0 {
+		report.AvgLatency = time.Duration((after.latencySum - before.latencySum) / count * float64(time.Second))
+	}
+	return report, nil
+}
+
+// snapshot is the part of the connector metrics used by the report.
+type snapshot struct {
+	results      map[string]float64
+	latencySum   float64
+	latencyCount float64
+}
+
+func (s snapshot) processed() float64 {
+	var n float64
+	for _, v := range s.results {
+		n += v
+	}
+	return n
+}
+
+func scrape(ctx context.Context, url string) (snapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return snapshot{}, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return snapshot{}, fmt.Errorf("get metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	s := snapshot{results: map[string]float64{}}
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		name, labels, value, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "messages_total":
+			s.results[label(labels, "result")] += value
+		case "message_processing_seconds_sum":
+			s.latencySum += value
+		case "message_processing_seconds_count":
+			s.latencyCount += value
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return snapshot{}, fmt.Errorf("read metrics: %w", err)
+	}
+	return s, nil
+}
+
+// parseLine parses a sample of the Prometheus text format: 'name{labels} value'.
+func parseLine(line string) (name, labels string, value float64, ok bool) {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", 0, false
+	}
+	i := strings.LastIndexByte(line, ' ')
+	if i < 0 {
+		return "", "", 0, false
+	}
+	value, err := strconv.ParseFloat(line[i+1:], 64)
+	if err != nil {
+		return "", "", 0, false
+	}
+	name = line[:i]
+	if j := strings.IndexByte(name, '{'); j >= 0 {
+		name, labels = name[:j], name[j:]
+	}
+	return name, labels, value, true
+}
+
+func label(labels, name string) string {
+	_, v, ok := strings.Cut(labels, name+`="`)
+	if !ok {
+		return ""
+	}
+	v, _, _ = strings.Cut(v, `"`)
+	return v
+}
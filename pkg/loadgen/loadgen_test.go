@@ -0,0 +1,86 @@
+package loadgen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  bool
+	}{
+		{name: "defaults", args: []string{"-subject", "orders.input"}},
+		{name: "receipts", args: []string{"-subject", "orders.input", "-receipts-subject", "orders.receipts", "-drain", "0s"}},
+		{name: "no subject", args: nil, err: true},
+		{name: "zero rate", args: []string{"-subject", "orders.input", "-rate", "0"}, err: true},
+		{name: "negative size", args: []string{"-subject", "orders.input", "-size", "-1"}, err: true},
+		{name: "zero size", args: []string{"-subject", "orders.input", "-size", "0"}, err: true},
+		{name: "zero duration", args: []string{"-subject", "orders.input", "-duration", "0s"}, err: true},
+		{name: "negative drain", args: []string{"-subject", "orders.input", "-drain", "-1s"}, err: true},
+		{name: "unexpected argument", args: []string{"-subject", "orders.input", "extra"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFlags(tt.args)
+			if (err != nil) != tt.err {
+				t.Errorf("error = %v, want error: %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTrackerLatency(t *testing.T) {
+	t0 := time.Now()
+	track := &tracker{published: map[string]time.Time{}} //nolint:exhaustruct // no latencies yet
+	for i, id := range []string{"run-0", "run-1", "run-2", "run-3"} {
+		track.publish(id, t0.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	receipts := []string{
+		`{"correlation_id":"run-0","result":"ack"}`,
+		`{"correlation_id":"run-1","result":"redeliver"}`, // not completed yet
+		`{"correlation_id":"run-1","result":"ack"}`,
+		`{"correlation_id":"run-1","result":"ack"}`, // duplicate receipt
+		`{"correlation_id":"other-0","result":"ack"}`,
+		`{"correlation_id":"run-3","result":"ack"}`,
+		`not json`,
+	}
+	for _, r := range receipts {
+		track.receipt([]byte(r), t0.Add(10*time.Millisecond))
+	}
+
+	l := track.latency()
+	want := Latency{Count: 3, P50: 9 * time.Millisecond, P90: 9 * time.Millisecond, P99: 9 * time.Millisecond, Max: 10 * time.Millisecond}
+	if *l != want {
+		t.Errorf("latency = %+v, want %+v", *l, want)
+	}
+}
+
+func TestNewLatency(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    Latency
+	}{
+		{name: "no samples", want: Latency{}}, //nolint:exhaustruct // zero latency
+		{
+			name:    "one sample",
+			samples: []time.Duration{time.Second},
+			want:    Latency{Count: 1, P50: time.Second, P90: time.Second, P99: time.Second, Max: time.Second},
+		},
+		{
+			name:    "unsorted samples",
+			samples: []time.Duration{10, 1, 9, 2, 8, 3, 7, 4, 6, 5, 100},
+			want:    Latency{Count: 11, P50: 6, P90: 10, P99: 10, Max: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newLatency(tt.samples); *got != tt.want {
+				t.Errorf("latency = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package luahook runs lightweight message mutation hooks of a Lua script in a sandbox:
+// only base (without file and module loading), string, table and math libraries are available
+// and a call is interrupted when its context is done.
+//
+// The script defines the hooks (all are optional):
+//   - on_message(data, headers) returns the data sent to the endpoint and optionally the endpoint URL
+//     overriding the selected one, nil skips the message;
+//   - on_response(body, status) returns the response to be published, nil discards it;
+//   - on_error(message) returns the error message to be published, nil suppresses it.
+package luahook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+var ErrHookResult = errors.New("wrong hook result")
+
+// unsafeFuncs are removed from the base library: they access the filesystem or load code from outside the script.
+//
+//nolint:gochecknoglobals // constant list
+var unsafeFuncs = []string{"dofile", "loadfile", "load", "loadstring", "require", "module"}
+
+// Script calls the hooks of the script. Lua states are not safe for concurrent use,
+// so the script keeps a pool of up to size states.
+type Script struct {
+	code  *lua.FunctionProto
+	path  string
+	pool  chan *lua.LState
+	slots chan struct{}
+
+	hasMessage  bool
+	hasResponse bool
+	hasError    bool
+}
+
+// Load compiles the script from the file and checks it by running in a new state. Size is the maximum number of concurrent calls.
+func Load(path string, size int) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read lua script: %w", err)
+	}
+
+	chunk, err := parse.Parse(bytes.NewReader(src), path)
+	if err != nil {
+		return nil, fmt.Errorf("parse lua script %q: %w", path, err)
+	}
+	proto, err := lua.Compile(chunk, path)
+	if err != nil {
+		return nil, fmt.Errorf("compile lua script %q: %w", path, err)
+	}
+
+	s := &Script{ //nolint:exhaustruct // hooks are set below
+		code:  proto,
+		path:  path,
+		pool:  make(chan *lua.LState, size),
+		slots: make(chan struct{}, size),
+	}
+
+	L, err := s.newState()
+	if err != nil {
+		return nil, err
+	}
+	s.hasMessage = L.GetGlobal("on_message").Type() == lua.LTFunction
+	s.hasResponse = L.GetGlobal("on_response").Type() == lua.LTFunction
+	s.hasError = L.GetGlobal("on_error").Type() == lua.LTFunction
+	if !s.hasMessage && !s.hasResponse && !s.hasError {
+		L.Close()
+		return nil, fmt.Errorf("lua script %q defines none of on_message, on_response and on_error hooks", path)
+	}
+	s.pool <- L
+	return s, nil
+}
+
+func (s *Script) newState() (*lua.LState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true, CallStackSize: 256}) //nolint:exhaustruct // optional parameters
+	for name, open := range map[string]lua.LGFunction{
+		lua.BaseLibName:   lua.OpenBase,
+		lua.StringLibName: lua.OpenString,
+		lua.TabLibName:    lua.OpenTable,
+		lua.MathLibName:   lua.OpenMath,
+	} {
+		L.Push(L.NewFunction(open))
+		L.Push(lua.LString(name))
+		L.Call(1, 0)
+	}
+	for _, name := range unsafeFuncs {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	L.Push(L.NewFunctionFromProto(s.code))
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("run lua script %q: %w", s.path, err)
+	}
+	return L, nil
+}
+
+// OnMessage calls on_message hook. It returns keep false if the message should be skipped.
+// The data is returned as is without the hook.
+func (s *Script) OnMessage(ctx context.Context, data []byte, headers http.Header) (out []byte, endpoint string, keep bool, err error) {
+	if !s.hasMessage {
+		return data, "", true, nil
+	}
+
+	hdr := map[string]string{}
+	for k := range headers {
+		hdr[k] = headers.Get(k)
+	}
+
+	err = s.call(ctx, "on_message", func(L *lua.LState) []lua.LValue {
+		t := L.NewTable()
+		for k, v := range hdr {
+			t.RawSetString(k, lua.LString(v))
+		}
+		return []lua.LValue{lua.LString(data), t}
+	}, 2, func(ret []lua.LValue) error {
+		if ret[0] == lua.LNil {
+			return nil
+		}
+		v, ok := ret[0].(lua.LString)
+		if !ok {
+			return fmt.Errorf("%w: on_message returned %s instead of string or nil", ErrHookResult, ret[0].Type())
+		}
+		out, keep = []byte(v), true
+		if e, ok := ret[1].(lua.LString); ok {
+			endpoint = string(e)
+		}
+		return nil
+	})
+	return out, endpoint, keep, err
+}
+
+// OnResponse calls on_response hook. It returns keep false if the response should be discarded.
+// The body is returned as is without the hook.
+func (s *Script) OnResponse(ctx context.Context, body []byte, status int) (out []byte, keep bool, err error) {
+	if !s.hasResponse {
+		return body, true, nil
+	}
+
+	err = s.call(ctx, "on_response", func(*lua.LState) []lua.LValue {
+		return []lua.LValue{lua.LString(body), lua.LNumber(status)}
+	}, 1, func(ret []lua.LValue) error {
+		if ret[0] == lua.LNil {
+			return nil
+		}
+		v, ok := ret[0].(lua.LString)
+		if !ok {
+			return fmt.Errorf("%w: on_response returned %s instead of string or nil", ErrHookResult, ret[0].Type())
+		}
+		out, keep = []byte(v), true
+		return nil
+	})
+	return out, keep, err
+}
+
+// OnError calls on_error hook. It returns keep false if the error should not be published.
+// The message is returned as is without the hook or if the hook failed.
+func (s *Script) OnError(ctx context.Context, message string) (string, bool) {
+	if !s.hasError {
+		return message, true
+	}
+
+	out, keep := message, true
+	err := s.call(ctx, "on_error", func(*lua.LState) []lua.LValue {
+		return []lua.LValue{lua.LString(message)}
+	}, 1, func(ret []lua.LValue) error {
+		if v, ok := ret[0].(lua.LString); ok {
+			out = string(v)
+		} else if ret[0] == lua.LNil {
+			keep = false
+		}
+		return nil
+	})
+	if err != nil {
+		return message, true
+	}
+	return out, keep
+}
+
+// Close closes the pooled states.
+func (s *Script) Close() {
+	for {
+		select {
+		case L := <-s.pool:
+			L.Close()
+		default:
+			return
+		}
+	}
+}
+
+// call calls the global function with a pooled state. A state which failed is closed and a new one is created next time.
+func (s *Script) call(ctx context.Context, fn string, args func(*lua.LState) []lua.LValue, nret int, result func([]lua.LValue) error) error {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // context error
+	}
+	defer func() { <-s.slots }()
+
+	var L *lua.LState
+	select {
+	case L = <-s.pool:
+	default:
+		var err error
+		L, err = s.newState()
+		if err != nil {
+			return err
+		}
+	}
+
+	L.SetContext(ctx)
+	err := L.CallByParam(lua.P{Fn: L.GetGlobal(fn), NRet: nret, Protect: true, Handler: nil}, args(L)...)
+	if err == nil {
+		ret := make([]lua.LValue, nret)
+		for i := range ret {
+			ret[i] = L.Get(-nret + i)
+		}
+		L.Pop(nret)
+		err = result(ret)
+	}
+	L.RemoveContext()
+	if err != nil {
+		L.Close()
+		return fmt.Errorf("lua %s: %w", fn, err)
+	}
+	s.pool <- L
+	return nil
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import "sync"
+
+// LabelGuard limits the cardinality of a label: after limit distinct values are seen,
+// new values are replaced with the overflow value.
+type LabelGuard struct {
+	limit    int
+	overflow string
+
+	seen map[string]struct{}
+	mx   sync.RWMutex
+}
+
+func NewLabelGuard(limit int, overflow string) *LabelGuard {
+	return &LabelGuard{limit: limit, overflow: overflow, seen: make(map[string]struct{})} //nolint:exhaustruct // mutex is initialized by zero value
+}
+
+func (g *LabelGuard) Value(v string) string {
+	g.mx.RLock()
+	_, ok := g.seen[v]
+	g.mx.RUnlock()
+	if ok {
+		return v
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if _, ok := g.seen[v]; ok {
+		return v
+	}
+	if len(g.seen) >= g.limit {
+		return g.overflow
+	}
+	g.seen[v] = struct{}{}
+	return v
+}
@@ -0,0 +1,227 @@
+// Package pgsink inserts JSON messages as rows into a Postgres table.
+package pgsink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/jsonpointer"
+)
+
+var (
+	ErrClosed = errors.New("postgres sink is closed")
+	// ErrRowRejected is returned if the row is rejected by the database (e.g. a constraint violation
+	// or a wrong value), so it can't be inserted by a retry.
+	ErrRowRejected = errors.New("row is rejected")
+)
+
+// Column maps the value at the JSON pointer of the message to the table column.
+type Column struct {
+	Name    string
+	Pointer jsonpointer.Pointer
+}
+
+// Columns are set as 'column=/json/pointer,...'.
+type Columns []Column
+
+func (c *Columns) SetString(s string) error {
+	*c = nil
+	if s == "" {
+		return nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		name, ptr, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || name == "" {
+			return fmt.Errorf("wrong column %q: 'column=/json/pointer' is expected", item)
+		}
+		p, err := jsonpointer.Parse(ptr)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", name, err)
+		}
+		*c = append(*c, Column{Name: name, Pointer: p})
+	}
+	return nil
+}
+
+type row struct {
+	values []any
+	done   chan error
+}
+
+// Sink inserts rows in batches: a batch is flushed in one transaction when it has batchSize rows or after the interval.
+// Publish returns when the batch of the row is committed.
+type Sink struct {
+	pool     *pgxpool.Pool
+	insert   string
+	columns  Columns
+	size     int
+	interval time.Duration
+
+	rows    chan row
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+// New connects to the database at the URL and starts flushing batches.
+func New(ctx context.Context, url, table string, columns Columns, batchSize int, interval time.Duration) (*Sink, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+
+	names := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = pgx.Identifier{c.Name}.Sanitize()
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	s := &Sink{
+		pool:     pool,
+		insert:   fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", pgx.Identifier(strings.Split(table, ".")).Sanitize(), strings.Join(names, ", "), strings.Join(params, ", ")),
+		columns:  columns,
+		size:     max(batchSize, 1),
+		interval: interval,
+		rows:     make(chan row),
+		closing:  make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Publish inserts the columns of the JSON message. The key and the headers are not used.
+func (s *Sink) Publish(ctx context.Context, _ string, data []byte, _ map[string][]string) error {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("postgres sink: message is not JSON: %w", err)
+	}
+
+	values := make([]any, len(s.columns))
+	for i, c := range s.columns {
+		v, err := c.Pointer.Get(doc)
+		if err != nil {
+			continue // missing fields are NULL
+		}
+		switch v.(type) {
+		case map[string]any, []any:
+			b, _ := json.Marshal(v)
+			v = string(b) // json or jsonb columns
+		}
+		values[i] = v
+	}
+
+	r := row{values: values, done: make(chan error, 1)}
+	select {
+	case s.rows <- r:
+	case <-s.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // context error
+	}
+
+	select {
+	case err := <-r.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // context error
+	}
+}
+
+func (s *Sink) run() {
+	defer close(s.closed)
+
+	var batch []row
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case r := <-s.rows:
+			batch = append(batch, r)
+			if len(batch) < s.size {
+				continue
+			}
+		case <-ticker.C:
+		case <-s.closing:
+			s.flush(batch)
+			return
+		}
+
+		s.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush inserts the batch in one transaction. If a row is rejected, the whole batch fails,
+// so the rows are inserted one by one then and only the rejected ones fail with ErrRowRejected.
+func (s *Sink) flush(batch []row) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		b := &pgx.Batch{}
+		for _, r := range batch {
+			b.Queue(s.insert, r.values...)
+		}
+		return tx.SendBatch(ctx, b).Close() //nolint:wrapcheck // wrapped below
+	})
+	if err != nil && len(batch) > 1 && rejected(err) {
+		for _, r := range batch {
+			r.done <- s.insertRow(ctx, r)
+		}
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("insert %d rows: %w", len(batch), rowError(err))
+	}
+
+	for _, r := range batch {
+		r.done <- err
+	}
+}
+
+func (s *Sink) insertRow(ctx context.Context, r row) error {
+	_, err := s.pool.Exec(ctx, s.insert, r.values...)
+	if err != nil {
+		return fmt.Errorf("insert row: %w", rowError(err))
+	}
+	return nil
+}
+
+// rejected reports whether the database rejected the data: data exceptions (class 22)
+// and integrity constraint violations (class 23).
+func rejected(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+func rowError(err error) error {
+	if rejected(err) {
+		return fmt.Errorf("%w: %w", ErrRowRejected, err)
+	}
+	return err
+}
+
+// Close flushes the pending batch and closes the connections.
+func (s *Sink) Close() {
+	close(s.closing)
+	<-s.closed
+	s.pool.Close()
+}
@@ -0,0 +1,107 @@
+// Package profile captures CPU and runtime profiles on demand and uploads them to an object store.
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultDuration = 30 * time.Second
+	maxDuration     = 5 * time.Minute
+)
+
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Uploader stores the captured profile. nats.ObjectStore implements it.
+type Uploader interface {
+	PutBytes(name string, data []byte, opts ...nats.ObjectOpt) (*nats.ObjectInfo, error)
+}
+
+// Result is the response of the capture handler.
+type Result struct {
+	Object string `json:"object"`
+	Size   int    `json:"size"`
+}
+
+// Capture returns the profile of the type: 'cpu' is sampled for the duration, others are runtime/pprof profiles (heap, allocs, goroutine, ...).
+func Capture(ctx context.Context, typ string, d time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if typ == "cpu" {
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return nil, fmt.Errorf("start cpu profile: %w", err)
+		}
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+		pprof.StopCPUProfile()
+		return buf.Bytes(), nil
+	}
+
+	p := pprof.Lookup(typ)
+	if p == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, typ)
+	}
+	if err := p.WriteTo(&buf, 0); err != nil {
+		return nil, fmt.Errorf("write %s profile: %w", typ, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Handler captures the profile by 'POST ?type=cpu&seconds=30' request and uploads it as '<prefix>-<type>-<unix time>.pprof' object.
+func Handler(store Uploader, prefix string, log *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		typ := r.URL.Query().Get("type")
+		if typ == "" {
+			typ = "cpu"
+		}
+
+		d := defaultDuration
+		if s := r.URL.Query().Get("seconds"); s != "" {
+			sec, err := strconv.Atoi(s)
+			if err != nil || sec <= 0 {
+				http.Error(w, "wrong seconds", http.StatusBadRequest)
+				return
+			}
+			d = min(time.Duration(sec)*time.Second, maxDuration)
+		}
+
+		data, err := Capture(r.Context(), typ, d)
+		if errors.Is(err, ErrUnknownProfile) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		name := fmt.Sprintf("%s-%s-%d.pprof", prefix, typ, time.Now().Unix())
+		if _, err := store.PutBytes(name, data); err != nil {
+			log.Error("Failed to upload profile", slog.String("object", name), slog.Any("error", err))
+			http.Error(w, "upload profile", http.StatusBadGateway)
+			return
+		}
+		log.Info("Profile is uploaded", slog.String("object", name), slog.Int("size", len(data)))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Result{Object: name, Size: len(data)}) //nolint:errcheck,errchkjson // best effort response
+	})
+}
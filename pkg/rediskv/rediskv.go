@@ -0,0 +1,59 @@
+// Package rediskv is a small key-value client of Redis with prefixed keys.
+package rediskv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Client gets and sets values of keys with the prefix.
+type Client struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+// New creates the client of 'redis://[user:password@]host:port/db' URL, 'rediss://' enables TLS.
+// Connections are dialed on demand.
+func New(url, prefix string) (*Client, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	return &Client{rdb: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+// Get returns the value of the key. The bool is false if the key does not exist.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := c.rdb.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis get %q: %w", c.prefix+key, err)
+	}
+	return v, true, nil
+}
+
+// Set sets the value of the key with the TTL, zero TTL keeps the key forever.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.rdb.Set(ctx, c.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set %q: %w", c.prefix+key, err)
+	}
+	return nil
+}
+
+// Ping checks the connection.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.rdb.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) Close() {
+	_ = c.rdb.Close()
+}
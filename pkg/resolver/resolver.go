@@ -0,0 +1,247 @@
+// Package resolver resolves hosts of outgoing HTTP connections with a custom DNS server, caching and pinned addresses.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MinTTL is the shortest time the addresses are cached for, e.g. of the records with zero TTL.
+const MinTTL = time.Second
+
+// Pins are pinned addresses of hosts, set as 'host=ip,host=ip,...'. A host may be pinned to several addresses.
+type Pins map[string][]string
+
+func (p *Pins) SetString(s string) error {
+	*p = nil
+	if s == "" {
+		return nil
+	}
+
+	pins := Pins{}
+	for _, item := range strings.Split(s, ",") {
+		host, ip, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || host == "" || net.ParseIP(ip) == nil {
+			return fmt.Errorf("wrong pin %q: 'host=ip' is expected", item)
+		}
+		pins[host] = append(pins[host], ip)
+	}
+	*p = pins
+	return nil
+}
+
+// Resolver caches addresses of hosts. Cached hosts are re-resolved by Run when their DNS records expire
+// (at least MinTTL), or every refresh interval if the TTL is not known, e.g. the records are resolved
+// by the system resolver without the DNS protocol. Failed re-resolutions keep the previous addresses
+// and are retried after the refresh interval.
+type Resolver struct {
+	resolver *net.Resolver
+	pins     Pins
+	refresh  time.Duration
+	log      *slog.Logger
+	dialer   *net.Dialer
+	// OnChange is called when addresses of a host change, e.g. to close idle connections to the old addresses.
+	OnChange func(host string)
+
+	mx      sync.RWMutex
+	entries map[string]entry
+	added   chan struct{} // a new host is cached, Run recalculates the next refresh
+}
+
+type entry struct {
+	addrs   []string
+	expires time.Time
+}
+
+type ttlKey struct{}
+
+// New creates the resolver. If server ('host:port') is set, hosts are resolved with this DNS server instead of the system one.
+func New(server string, pins Pins, refresh time.Duration, log *slog.Logger) *Resolver {
+	r := &Resolver{ //nolint:exhaustruct // OnChange is optional
+		pins:    pins,
+		refresh: refresh,
+		log:     log,
+		dialer:  &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}, //nolint:exhaustruct // defaults of http.DefaultTransport
+		entries: map[string]entry{},
+		added:   make(chan struct{}, 1),
+	}
+	// The Go resolver is used for the system DNS servers too, so the TTLs can be read from its DNS responses.
+	r.resolver = &net.Resolver{ //nolint:exhaustruct // optional parameters
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if server != "" {
+				address = server
+			}
+			conn, err := r.dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err //nolint:wrapcheck // dial errors have the address
+			}
+			ttl, ok := ctx.Value(ttlKey{}).(*minTTL)
+			if !ok {
+				return conn, nil
+			}
+			c := &ttlConn{Conn: conn, ttl: ttl, stream: true} //nolint:exhaustruct // empty buffer
+			// The Go resolver frames the messages by the type of the connection.
+			if pc, ok := conn.(net.PacketConn); ok {
+				c.stream = false
+				return ttlPacketConn{ttlConn: c, pc: pc}, nil
+			}
+			return c, nil
+		},
+	}
+	return r
+}
+
+// DialContext dials the address trying the resolved addresses of the host in order. It is used as http.Transport.DialContext.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("split host and port of %q: %w", address, err)
+	}
+	if net.ParseIP(host) != nil {
+		return r.dialer.DialContext(ctx, network, address) //nolint:wrapcheck // dial errors have the address
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r *Resolver) lookup(ctx context.Context, host string) ([]string, error) {
+	if pinned, ok := r.pins[host]; ok {
+		return pinned, nil
+	}
+
+	r.mx.RLock()
+	e, ok := r.entries[host]
+	r.mx.RUnlock()
+	if ok {
+		return e.addrs, nil
+	}
+
+	addrs, ttl, err := r.resolve(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %w", host, err)
+	}
+	r.mx.Lock()
+	r.entries[host] = entry{addrs: addrs, expires: time.Now().Add(ttl)}
+	r.mx.Unlock()
+
+	select {
+	case r.added <- struct{}{}:
+	default:
+	}
+	return addrs, nil
+}
+
+// resolve returns the sorted addresses of the host and the time to cache them for: the lowest TTL of the records
+// (at least MinTTL), or the refresh interval if the TTL is not known.
+func (r *Resolver) resolve(ctx context.Context, host string) ([]string, time.Duration, error) {
+	ttl := &minTTL{} //nolint:exhaustruct // no TTL yet
+	addrs, err := r.resolver.LookupHost(context.WithValue(ctx, ttlKey{}, ttl), host)
+	if err != nil {
+		return nil, 0, err //nolint:wrapcheck // wrapped by the callers
+	}
+	slices.Sort(addrs)
+
+	d, ok := ttl.get()
+	if !ok {
+		return addrs, r.refresh, nil
+	}
+	return addrs, max(d, MinTTL), nil
+}
+
+// Run re-resolves cached hosts when they expire until the context is canceled.
+func (r *Resolver) Run(ctx context.Context) {
+	timer := time.NewTimer(r.next())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.added:
+		case <-timer.C:
+			for _, host := range r.expired(time.Now()) {
+				r.reresolve(ctx, host)
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(r.next())
+	}
+}
+
+// next returns the time until the first cached host expires, not longer than the refresh interval.
+func (r *Resolver) next() time.Duration {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	d := r.refresh
+	for _, e := range r.entries {
+		d = min(d, time.Until(e.expires))
+	}
+	return max(d, 0)
+}
+
+func (r *Resolver) expired(now time.Time) []string {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	var hosts []string
+	for host, e := range r.entries {
+		if !e.expires.After(now) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func (r *Resolver) reresolve(ctx context.Context, host string) {
+	addrs, ttl, err := r.resolve(ctx, host)
+	if err != nil {
+		r.log.Warn("failed to re-resolve host, previous addresses are used", slog.String("host", host), slog.Any("error", err))
+		r.mx.Lock()
+		e := r.entries[host]
+		e.expires = time.Now().Add(r.refresh)
+		r.entries[host] = e
+		r.mx.Unlock()
+		return
+	}
+
+	r.mx.Lock()
+	prev := r.entries[host].addrs
+	r.entries[host] = entry{addrs: addrs, expires: time.Now().Add(ttl)}
+	r.mx.Unlock()
+
+	if slices.Equal(prev, addrs) {
+		return
+	}
+	r.log.Info("Host addresses are changed", slog.String("host", host), slog.Any("addresses", addrs), slog.Any("previous", prev))
+	if r.OnChange != nil {
+		r.OnChange(host)
+	}
+}
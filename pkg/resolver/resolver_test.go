@@ -0,0 +1,182 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dnsServer answers A queries with the address and the TTL, AAAA queries with no answers.
+type dnsServer struct {
+	addr atomic.Value // string
+	ttl  atomic.Uint32
+	conn net.PacketConn
+}
+
+func newDNSServer(t *testing.T, addr string, ttl uint32) *dnsServer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &dnsServer{conn: conn} //nolint:exhaustruct // set below
+	s.addr.Store(addr)
+	s.ttl.Store(ttl)
+	go s.serve()
+	return s
+}
+
+func (s *dnsServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.answer(buf[:n]); resp != nil {
+			_, _ = s.conn.WriteTo(resp, from)
+		}
+	}
+}
+
+func (s *dnsServer) answer(query []byte) []byte {
+	end := skipName(query, dnsHeaderSize)
+	if end < 0 || end+4 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end:])
+
+	resp := append([]byte{}, query[:2]...) // id
+	resp = append(resp, 0x81, 0x80, 0, 1)  // response, recursion desired and available, 1 question
+	if qtype == typeA {
+		resp = append(resp, 0, 1)
+	} else {
+		resp = append(resp, 0, 0)
+	}
+	resp = append(resp, 0, 0, 0, 0)                    // no authority and additional records
+	resp = append(resp, query[dnsHeaderSize:end+4]...) // question
+	if qtype == typeA {
+		ip := net.ParseIP(s.addr.Load().(string)).To4() //nolint:forcetypeassert // set by the test
+		resp = append(resp, 0xC0, dnsHeaderSize, 0, typeA, 0, 1)
+		resp = binary.BigEndian.AppendUint32(resp, s.ttl.Load())
+		resp = append(resp, 0, 4)
+		resp = append(resp, ip...)
+	}
+	return resp
+}
+
+func TestResolveTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  uint32
+		want time.Duration
+	}{
+		{name: "record ttl", ttl: 60, want: time.Minute},
+		{name: "zero ttl", ttl: 0, want: MinTTL},
+		{name: "ttl above refresh interval", ttl: 3600, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newDNSServer(t, "10.0.0.1", tt.ttl)
+			r := New(srv.conn.LocalAddr().String(), nil, 30*time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			addrs, ttl, err := r.resolve(context.Background(), "endpoint.test.")
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+				t.Errorf("addresses = %v, want [10.0.0.1]", addrs)
+			}
+			if ttl != tt.want {
+				t.Errorf("ttl = %v, want %v", ttl, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunReresolvesExpired(t *testing.T) {
+	srv := newDNSServer(t, "10.0.0.1", 1)
+	r := New(srv.conn.LocalAddr().String(), nil, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	changed := make(chan string, 1)
+	r.OnChange = func(host string) { changed <- host }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	if _, err := r.lookup(ctx, "endpoint.test."); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	srv.addr.Store("10.0.0.2")
+
+	// The record expires after 1s, much earlier than the refresh interval.
+	select {
+	case host := <-changed:
+		if host != "endpoint.test." {
+			t.Errorf("changed host = %q", host)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expired host is not re-resolved")
+	}
+	if addrs, _ := r.lookup(ctx, "endpoint.test."); len(addrs) != 1 || addrs[0] != "10.0.0.2" {
+		t.Errorf("addresses = %v, want [10.0.0.2]", addrs)
+	}
+}
+
+func TestResponseTTL(t *testing.T) {
+	question := []byte{3, 'a', 'p', 'i', 0, 0, typeA, 0, 1}
+	record := func(name []byte, typ uint16, ttl uint32, rdata []byte) []byte {
+		b := append([]byte{}, name...)
+		b = binary.BigEndian.AppendUint16(b, typ)
+		b = append(b, 0, 1)
+		b = binary.BigEndian.AppendUint32(b, ttl)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(rdata)))
+		return append(b, rdata...)
+	}
+	msg := func(flags byte, answers ...[]byte) []byte {
+		b := []byte{0, 1, flags, 0x80, 0, 1, 0, byte(len(answers)), 0, 0, 0, 0}
+		b = append(b, question...)
+		for _, a := range answers {
+			b = append(b, a...)
+		}
+		return b
+	}
+	pointer := []byte{0xC0, dnsHeaderSize}
+
+	tests := []struct {
+		name string
+		msg  []byte
+		ttl  time.Duration
+		ok   bool
+	}{
+		{name: "a record", msg: msg(0x81, record(pointer, typeA, 30, []byte{10, 0, 0, 1})), ttl: 30 * time.Second, ok: true},
+		{
+			name: "lowest of cname chain",
+			msg:  msg(0x81, record(pointer, typeCNAME, 10, []byte{3, 'w', 'e', 'b', 0}), record([]byte{3, 'w', 'e', 'b', 0}, typeA, 300, []byte{10, 0, 0, 1})),
+			ttl:  10 * time.Second,
+			ok:   true,
+		},
+		{name: "aaaa record", msg: msg(0x81, record(pointer, typeAAAA, 5, make([]byte, 16))), ttl: 5 * time.Second, ok: true},
+		{name: "other records are ignored", msg: msg(0x81, record(pointer, 16, 1, []byte{0})), ok: false},
+		{name: "no answers", msg: msg(0x81), ok: false},
+		{name: "query", msg: msg(0x01, record(pointer, typeA, 30, []byte{10, 0, 0, 1})), ok: false},
+		{name: "truncated", msg: msg(0x81, record(pointer, typeA, 30, []byte{10, 0, 0, 1}))[:30], ok: false},
+		{name: "too short", msg: []byte{0, 1}, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, ok := responseTTL(tt.msg)
+			if ok != tt.ok || ttl != tt.ttl {
+				t.Errorf("ttl = %v, %v, want %v, %v", ttl, ok, tt.ttl, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package resolver
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	dnsHeaderSize = 12
+	typeA         = 1
+	typeCNAME     = 5
+	typeAAAA      = 28
+)
+
+// minTTL collects the lowest TTL of the address records of the DNS responses of a lookup.
+type minTTL struct {
+	mx  sync.Mutex
+	ttl time.Duration
+	ok  bool
+}
+
+func (m *minTTL) observe(ttl time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if !m.ok || ttl < m.ttl {
+		m.ttl, m.ok = ttl, true
+	}
+}
+
+func (m *minTTL) get() (time.Duration, bool) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return m.ttl, m.ok
+}
+
+// ttlConn reads the TTLs of the DNS responses read by the Go resolver from the connection.
+// UDP reads are whole messages, TCP messages are prefixed with their length (RFC 1035 4.2.2).
+type ttlConn struct {
+	net.Conn
+	ttl    *minTTL
+	stream bool
+	buf    []byte
+}
+
+func (c *ttlConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		if !c.stream {
+			c.parse(p[:n])
+		} else {
+			c.buf = append(c.buf, p[:n]...)
+			for len(c.buf) >= 2 {
+				l := int(binary.BigEndian.Uint16(c.buf))
+				if len(c.buf) < 2+l {
+					break
+				}
+				c.parse(c.buf[2 : 2+l])
+				c.buf = c.buf[2+l:]
+			}
+		}
+	}
+	return n, err //nolint:wrapcheck // the connection of the Go resolver
+}
+
+// ttlPacketConn is the ttlConn of UDP: the Go resolver sends whole messages over net.PacketConn connections.
+type ttlPacketConn struct {
+	*ttlConn
+	pc net.PacketConn
+}
+
+func (c ttlPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.pc.ReadFrom(p)
+	if n > 0 {
+		c.parse(p[:n])
+	}
+	return n, addr, err //nolint:wrapcheck // the connection of the Go resolver
+}
+
+func (c ttlPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	return c.pc.WriteTo(p, addr) //nolint:wrapcheck // the connection of the Go resolver
+}
+
+func (c *ttlConn) parse(msg []byte) {
+	if ttl, ok := responseTTL(msg); ok {
+		c.ttl.observe(ttl)
+	}
+}
+
+// responseTTL returns the lowest TTL of the A, AAAA and CNAME answers of the DNS response.
+// It returns false if the response has no such answers or is malformed.
+func responseTTL(msg []byte) (time.Duration, bool) {
+	if len(msg) < dnsHeaderSize || msg[2]&0x80 == 0 { // not a response
+		return 0, false
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := dnsHeaderSize
+	for i := 0; i < qdcount; i++ {
+		if off = skipName(msg, off); off < 0 || off+4 > len(msg) {
+			return 0, false
+		}
+		off += 4 // type and class
+	}
+
+	var ttl uint32
+	found := false
+	for i := 0; i < ancount; i++ {
+		if off = skipName(msg, off); off < 0 || off+10 > len(msg) {
+			return 0, false
+		}
+		typ := binary.BigEndian.Uint16(msg[off:])
+		t := binary.BigEndian.Uint32(msg[off+4:])
+		off += 10 + int(binary.BigEndian.Uint16(msg[off+8:]))
+		if off > len(msg) {
+			return 0, false
+		}
+		if typ == typeA || typ == typeAAAA || typ == typeCNAME {
+			if !found || t < ttl {
+				ttl, found = t, true
+			}
+		}
+	}
+	return time.Duration(ttl) * time.Second, found
+}
+
+// skipName returns the offset after the domain name at the offset, -1 if the name is malformed.
+func skipName(msg []byte, off int) int {
+	for off < len(msg) {
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1
+		case l&0xC0 == 0xC0: // compression pointer
+			if off+2 > len(msg) {
+				return -1
+			}
+			return off + 2
+		default:
+			off += 1 + l
+		}
+	}
+	return -1
+}
@@ -3,12 +3,16 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	stdlog "log"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
-	"os/signal"
 	"runtime"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,8 +29,9 @@ import (
 
 //nolint:gochecknoglobals // build variables
 var (
-	version string
-	commit  string
+	version   string
+	commit    string
+	buildDate string
 )
 
 type baseConfig[C any] struct {
@@ -35,6 +40,11 @@ type baseConfig[C any] struct {
 	Addr string `default:":8080"`
 
 	ShutdownTimeout time.Duration `default:"30s"`
+	// ShutdownDelay keeps the servers running while /ready reports 503 before the shutdown,
+	// so the load balancers stop sending traffic before the listeners are closed.
+	ShutdownDelay time.Duration
+
+	Signals signalsConfig
 
 	Server struct {
 		ReadTimeout       time.Duration
@@ -43,6 +53,8 @@ type baseConfig[C any] struct {
 		IdleTimeout       time.Duration `default:"5m"`
 	}
 
+	Runtime runtimeConfig
+
 	Log struct {
 		Level     configtypes.LogLevel   `default:"info"`
 		Handler   configtypes.LogHandler `default:"json"`
@@ -50,70 +62,197 @@ type baseConfig[C any] struct {
 	}
 
 	Metrics struct {
-		Enable bool   `default:"true"`
-		Addr   string `default:":2112"`
+		Enable     bool   `default:"true"`
+		Addr       string `default:":2112"`
+		User       string
+		Password   string
+		AllowCIDRs configtypes.CIDRs
 	}
 
 	Pprof struct {
-		Enable bool   `default:"true"`
-		Addr   string `default:":6060"`
+		Enable     bool   `default:"true"`
+		Addr       string `default:":6060"`
+		Token      string
+		User       string
+		Password   string
+		AllowCIDRs configtypes.CIDRs
 	}
 }
 
 type Base interface {
-	AddGracefulService(name string, run func(), shutdown func(context.Context) error)
+	AddGracefulService(name string, run func() error, shutdown func(context.Context) error, opts ...server.ServiceOption)
 	AddHTTPServer(name string, _ *http.Server)
+	// AddHTTPListener serves the server on the existing listener, e.g. bound by tests.
+	AddHTTPListener(name string, _ *http.Server, _ net.Listener)
+	AddReadinessCheck(name string, check func() error)
+	// AddDependencyCheck exports the state of the downstream dependency by dependency_up metric with name label:
+	// 1 while the check returns nil, 0 otherwise. The check is called on every scrape and doesn't affect the readiness.
+	AddDependencyCheck(name string, check func() error)
+	// Handle registers the handler of the method and the path pattern (e.g. "/callbacks/{token}") on the API server,
+	// see server.Router. The requests are labeled by the pattern in response_time metric.
+	// The requests not matching any route are served by the handler passed to ListenAndServe.
+	Handle(method, pattern string, h http.Handler)
 	ListenAndServe(_ http.Handler, _ server.RouteInfoFunc)
+	// SetCrashReporter sets the reporter of the panics recovered in the main function and the services.
+	SetCrashReporter(CrashReporter)
+	// Addr returns the bound address of the HTTP server (api, metrics, pprof or added ones),
+	// or nil if the server is not listening yet. The api, metrics and pprof addresses are bound before the main function,
+	// so the actual ports of "0" (ephemeral port) addresses are known to it, e.g. in parallel integration tests.
+	Addr(name string) net.Addr
 }
 
-func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+// ErrPrintHelp is returned by Load when the help is requested and printed.
+var ErrPrintHelp = gowalker.ErrPrintHelp
 
+// Config is the loaded config of the service: the config of the main function with the base config.
+type Config[C any] struct {
+	base baseConfig[C]
+}
+
+// Load loads the config from the flags of the args (args[0] is the program name) and the environment variables,
+// so one-shot modes (e.g. a config check) can run with the config before Run starts the servers.
+func Load[C any](args []string) (*Config[C], error) {
 	var cfg baseConfig[C]
-	err := config.Default(&cfg)
+	err := config.Walk(&cfg, stdlog.New(os.Stdout, "", 0),
+		gowalker.Flags(gowalker.FieldKey("flag", gowalker.FlagNamer), args),
+		gowalker.Envs(gowalker.FieldKey("env", gowalker.EnvNamer), os.LookupEnv),
+		gowalker.Tag("default"),
+		gowalker.Required("required"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return &Config[C]{base: cfg}, nil
+}
+
+// Service returns the config of the main function.
+func (c *Config[C]) Service() C {
+	return c.base.C
+}
+
+// Logger returns the logger configured by the config. Unlike the logger of Run, it doesn't count the logs by metrics.
+func (c *Config[C]) Logger() *slog.Logger {
+	return c.logger(c.handler())
+}
+
+func (c *Config[C]) handler() slog.Handler {
+	return c.base.Log.Handler(os.Stdout, &slog.HandlerOptions{
+		Level:       c.base.Log.Level,
+		AddSource:   c.base.Log.AddSource,
+		ReplaceAttr: nil,
+	})
+}
+
+func (c *Config[C]) logger(h slog.Handler) *slog.Logger {
+	return slog.New(h).With(
+		slog.String("version", version),
+		slog.String("commit_hash", commit),
+		slog.String("goversion", runtime.Version()),
+	)
+}
+
+// Main loads the config from the command line flags and the environment variables and runs the main function, see Run.
+func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
+	cfg, err := Load[C](os.Args)
 	if err != nil {
-		if errors.Is(err, gowalker.ErrPrintHelp) {
+		if errors.Is(err, ErrPrintHelp) {
 			return
 		}
 		slog.Error("Service finished with an error - load config", slog.Any("error", err))
 		os.Exit(1)
 	}
+	Run(cfg, fn)
+}
 
-	log := slog.New(logger.SlogMetrics(
-		cfg.Log.Handler(os.Stdout, &slog.HandlerOptions{
-			Level:       cfg.Log.Level,
-			AddSource:   cfg.Log.AddSource,
-			ReplaceAttr: nil,
-		}),
+// Run runs the main function with the loaded config, serves the API, metrics and pprof servers
+// and shuts them down gracefully.
+func Run[C any](c *Config[C], fn func(context.Context, C, *slog.Logger, Base) error) {
+	startedAt := time.Now().UTC()
+	cfg := c.base
+
+	log := c.logger(logger.SlogMetrics(
+		c.handler(),
 		metrics.CounterV1(promauto.NewCounterVec(prometheus.CounterOpts{
 			Name: "slog_total",
 			Help: "Counts amount of logs by level",
 		}, []string{"level"})),
-	)).With(
-		slog.String("version", version),
-		slog.String("commit_hash", commit),
-		slog.String("goversion", runtime.Version()),
-	)
+	))
+
+	setRuntimeLimits(cfg.Runtime, log)
+
+	ctx, cancel := notifyContext(cfg.Signals, log)
+
+	activated, err := server.SystemdListeners()
+	if err != nil {
+		log.Error("Service finished with an error - socket activation", slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	graceful := server.NewGracefulStopper(log.WithGroup("graceful"))
 
+	readiness := server.NewReadiness(nil, http.StatusServiceUnavailable, nil)
+
+	router := server.NewRouter()
+
 	var mainHandler http.Handler
 	var mainRouteInfoFn server.RouteInfoFunc
 	mainInit := make(chan struct{})
 	mainErr := make(chan error, 1)
 
-	go func() {
-		err := fn(ctx, cfg.C, log, &base{graceful, func(h http.Handler, routeInfoFn server.RouteInfoFunc) {
+	b := &base{
+		log:       log,
+		graceful:  graceful,
+		readiness: readiness,
+		router:    router,
+		activated: activated,
+		bound:     map[string]net.Listener{},
+		serverPort: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_server_port",
+			Help: "Bound port of the HTTP server by server name",
+		}, []string{"server"}),
+		listenAndServe: func(h http.Handler, routeInfoFn server.RouteInfoFunc) {
 			mainHandler = h
 			mainRouteInfoFn = routeInfoFn
 			close(mainInit)
 
 			<-ctx.Done()
-		}})
+		},
+		mx:            sync.Mutex{},
+		crashReporter: nil,
+	}
+	graceful.SetPanicHandler(b.crash)
+	defer func() {
+		if r := recover(); r != nil {
+			b.crash("main", r, debug.Stack())
+			os.Exit(2)
+		}
+	}()
+
+	// The servers' addresses are bound before the main function, so it gets the bound addresses by Base.Addr,
+	// e.g. the ephemeral ports of "0" addresses.
+	for name, addr := range map[string]string{"api": cfg.Addr, "metrics": cfg.Metrics.Addr, "pprof": cfg.Pprof.Addr} {
+		if name == "pprof" && !cfg.Pprof.Enable {
+			continue
+		}
+		if err := b.bind(name, addr); err != nil {
+			log.Error("Service finished with an error - listen", slog.String("name", name), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	go func() {
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				b.crash("main", r, debug.Stack())
+				mainErr <- fmt.Errorf("main function panicked: %v", r)
+			}
+		}()
+
+		err := fn(ctx, cfg.C, log, b)
 		if err != nil {
 			mainErr <- err
 		}
-		cancel()
 	}()
 
 	select {
@@ -126,7 +265,19 @@ func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
 		os.Exit(1)
 	}
 
-	readiness := server.NewReadiness(nil, http.StatusServiceUnavailable, nil)
+	router.HandleFunc(http.MethodGet, "/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Handle(http.MethodGet, "/ready", readiness)
+	router.Handle(http.MethodGet, "/info", infoHandler(cfg, startedAt))
+	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mainHandler != nil {
+			mainHandler.ServeHTTP(w, r)
+		} else {
+			log.Debug("Not found", slog.String("path", r.URL.Path))
+			http.NotFound(w, r)
+		}
+	})
 
 	apiServerHandler := server.ResponseTimeMiddleware(
 		metrics.HistogramV3(promauto.NewHistogramVec(prometheus.HistogramOpts{
@@ -134,28 +285,22 @@ func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
 			Help:    "Response time",
 			Buckets: prometheus.DefBuckets,
 		}, []string{"path", "method", "status"})),
-		mainRouteInfoFn,
+		func(r *http.Request) (string, bool) {
+			if pattern, ok := router.RouteInfo(r); ok {
+				return pattern, true
+			}
+			if mainRouteInfoFn != nil {
+				return mainRouteInfoFn(r)
+			}
+			return "", false
+		},
 	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Debug("Request", slog.String("url", r.URL.String()))
 
-		switch r.URL.Path {
-		case "/health":
-			w.WriteHeader(http.StatusOK)
-			return
-		case "/ready":
-			readiness.ServeHTTP(w, r)
-			return
-		}
-
-		if mainHandler != nil {
-			mainHandler.ServeHTTP(w, r)
-		} else {
-			log.Debug("Not found", slog.String("path", r.URL.Path))
-			http.NotFound(w, r)
-		}
+		router.ServeHTTP(w, r)
 	}))
 
-	graceful.StartHTTP("api", &http.Server{ //nolint:exhaustruct // ignore optional parameters
+	b.AddHTTPServer("api", &http.Server{ //nolint:exhaustruct // ignore optional parameters
 		Addr:              cfg.Addr,
 		Handler:           apiServerHandler,
 		ReadTimeout:       cfg.Server.ReadTimeout,
@@ -165,10 +310,14 @@ func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
 	})
 
 	metricsServerMux := http.NewServeMux()
-	metricsServerMux.Handle("/metrics", promhttp.Handler())
-	graceful.StartHTTP("metrics", &http.Server{ //nolint:gosec,govet,exhaustruct // internal usage only
-		Addr:    cfg.Metrics.Addr,
-		Handler: metricsServerMux,
+	// OpenMetrics is negotiated by the Accept header, it exposes the exemplars.
+	metricsServerMux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}), //nolint:exhaustruct // defaults
+	))
+	b.AddHTTPServer("metrics", &http.Server{ //nolint:gosec,govet,exhaustruct // internal usage only
+		Addr: cfg.Metrics.Addr,
+		Handler: server.AllowCIDRs(cfg.Metrics.AllowCIDRs,
+			server.BasicAuth("metrics", cfg.Metrics.User, cfg.Metrics.Password, metricsServerMux)),
 	})
 
 	if cfg.Pprof.Enable {
@@ -178,9 +327,14 @@ func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
 		pprofMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 		pprofMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 		pprofMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
-		graceful.StartHTTP("pprof", &http.Server{ //nolint:gosec,govet,exhaustruct // internal usage only
+		// Both auths use Authorization header, so the basic auth is used only if the token is not set.
+		pprofHandler := server.BearerAuth(cfg.Pprof.Token, pprofMux)
+		if cfg.Pprof.Token == "" {
+			pprofHandler = server.BasicAuth("pprof", cfg.Pprof.User, cfg.Pprof.Password, pprofMux)
+		}
+		b.AddHTTPServer("pprof", &http.Server{ //nolint:gosec,govet,exhaustruct // internal usage only
 			Addr:    cfg.Pprof.Addr,
-			Handler: pprofMux,
+			Handler: server.AllowCIDRs(cfg.Pprof.AllowCIDRs, pprofHandler),
 		})
 	}
 
@@ -191,23 +345,44 @@ func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
 		Help: "Provide additional build information",
 	}, []string{"version", "commit", "goversion"}).WithLabelValues(version, commit, runtime.Version()).Set(1)
 
-	log.Info("The server is ready to handle requests", slog.String("addr", cfg.Addr))
+	log.Info("The server is ready to handle requests", slog.Any("addr", graceful.Addr("api")))
 
 	select {
 	case <-ctx.Done():
 		log.Info("Termination signal is received - the service will shut down")
 	case <-graceful.DoneAny():
-		log.Error("One of the servers stopped unexpectedly")
+		var failed []any
+		for name, err := range graceful.FailedServices() {
+			failed = append(failed, slog.String(name, err.Error()))
+		}
+		log.Error("One of the servers stopped unexpectedly", slog.Group("failed", failed...))
 		cancel()
 	}
 
 	readiness.Set(nil, http.StatusServiceUnavailable, nil)
 
+	if cfg.ShutdownDelay > 0 {
+		log.Info("The service is not ready - the shutdown is delayed", slog.Duration("delay", cfg.ShutdownDelay))
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
 	graceful.ShutdownAll(shutdownCtx)
 
+	// The run functions are waited within the shutdown timeout, so nothing is running after the base returns.
+	stopped := make(chan struct{})
+	go func() {
+		graceful.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		log.Error("Services are still running after the shutdown timeout")
+	}
+
 	log.Info("The server gracefully shut down")
 
 	select {
@@ -220,16 +395,116 @@ func Main[C any](fn func(context.Context, C, *slog.Logger, Base) error) {
 }
 
 type base struct {
+	log            *slog.Logger
 	graceful       *server.GracefulStopper
+	readiness      *server.Readiness
+	router         *server.Router
+	serverPort     *prometheus.GaugeVec
 	listenAndServe func(h http.Handler, routeInfoFn server.RouteInfoFunc)
+
+	mx        sync.Mutex
+	activated map[string]net.Listener // passed by systemd socket activation
+	bound     map[string]net.Listener // bound before the servers are started
+
+	crashReporter CrashReporter
 }
 
-func (b *base) AddGracefulService(name string, run func(), shutdown func(context.Context) error) {
-	b.graceful.StartCustom(name, run, shutdown)
+func (b *base) AddGracefulService(name string, run func() error, shutdown func(context.Context) error, opts ...server.ServiceOption) {
+	b.graceful.StartCustom(name, run, shutdown, opts...)
+}
+
+// bind binds the address of the server before it is started: it takes the socket activated listener of the same name
+// (or the only socket activated listener not named after another server if it is the API server),
+// otherwise it listens on the address.
+func (b *base) bind(name, addr string) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if _, ok := b.bound[name]; ok {
+		return nil
+	}
+
+	key := name
+	if _, ok := b.activated[key]; !ok && name == "api" && len(b.activated) == 1 {
+		for k := range b.activated {
+			if k != "metrics" && k != "pprof" {
+				key = k
+			}
+		}
+	}
+	if ln, ok := b.activated[key]; ok {
+		delete(b.activated, key)
+		b.bound[name] = ln
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", server.ListenAddr(addr))
+	if err != nil {
+		return fmt.Errorf("listen %q: %w", addr, err)
+	}
+	b.bound[name] = ln
+	return nil
 }
 
+// AddHTTPServer serves the server on the listener bound for it (see bind).
 func (b *base) AddHTTPServer(name string, s *http.Server) {
-	b.graceful.StartHTTP(name, s)
+	if err := b.bind(name, s.Addr); err != nil {
+		b.graceful.StartHTTP(name, s) // reports the listen error
+		return
+	}
+
+	b.mx.Lock()
+	ln := b.bound[name]
+	b.mx.Unlock()
+
+	b.graceful.StartHTTPListener(name, s, ln)
+	b.exportPort(name)
+}
+
+func (b *base) AddHTTPListener(name string, s *http.Server, ln net.Listener) {
+	b.graceful.StartHTTPListener(name, s, ln)
+	b.exportPort(name)
+}
+
+// exportPort exports the bound port of the server by http_server_port metric.
+func (b *base) exportPort(name string) {
+	if addr, ok := b.Addr(name).(*net.TCPAddr); ok {
+		b.serverPort.WithLabelValues(name).Set(float64(addr.Port))
+	}
+}
+
+func (b *base) Addr(name string) net.Addr {
+	if addr := b.graceful.Addr(name); addr != nil {
+		return addr
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if ln, ok := b.bound[name]; ok {
+		return ln.Addr()
+	}
+	return nil
+}
+
+func (b *base) AddReadinessCheck(name string, check func() error) {
+	b.readiness.AddCheck(name, check)
+}
+
+func (b *base) AddDependencyCheck(name string, check func() error) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "dependency_up",
+		Help:        "Whether the downstream dependency is healthy (1) or not (0) by dependency name",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		if check() != nil {
+			return 0
+		}
+		return 1
+	})
+}
+
+func (b *base) Handle(method, pattern string, h http.Handler) {
+	b.router.Handle(method, pattern, h)
 }
 
 func (b *base) ListenAndServe(h http.Handler, routeInfoFn server.RouteInfoFunc) {
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service/server"
+)
+
+func newTestBase() *base {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &base{
+		log:       log,
+		graceful:  server.NewGracefulStopper(log),
+		readiness: server.NewReadiness(nil, http.StatusOK, nil),
+		router:    server.NewRouter(),
+		bound:     map[string]net.Listener{},
+		serverPort: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_server_port",
+			Help: "Bound port of the HTTP server by server name",
+		}, []string{"server"}),
+		listenAndServe: nil,
+		mx:             sync.Mutex{},
+		activated:      nil,
+		crashReporter:  nil,
+	}
+}
+
+func TestBaseAddr(t *testing.T) {
+	b := newTestBase()
+
+	if err := b.bind("api", "127.0.0.1:0"); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	bound, ok := b.Addr("api").(*net.TCPAddr)
+	if !ok || bound.Port == 0 {
+		t.Fatalf("addr before the start = %v, want a bound ephemeral port", b.Addr("api"))
+	}
+
+	b.AddHTTPServer("api", &http.Server{Addr: "127.0.0.1:0", ReadHeaderTimeout: time.Second}) //nolint:exhaustruct // test server
+	if got := b.Addr("api"); got.String() != bound.String() {
+		t.Errorf("addr of the started server = %v, want the bound %v", got, bound)
+	}
+	if b.Addr("metrics") != nil {
+		t.Errorf("addr of not bound server = %v, want nil", b.Addr("metrics"))
+	}
+
+	b.graceful.ShutdownAll(context.Background())
+	b.graceful.Wait()
+
+	if got := b.Addr("api"); got == nil || got.String() != bound.String() {
+		t.Errorf("addr after shutdown = %v, want %v", got, bound)
+	}
+}
+
+type crashRecorder struct {
+	crashes chan Crash
+}
+
+func (r crashRecorder) ReportCrash(_ context.Context, c Crash) error {
+	r.crashes <- c
+	return nil
+}
+
+func TestBaseCrash(t *testing.T) {
+	b := newTestBase()
+	b.graceful.SetPanicHandler(b.crash)
+	reporter := crashRecorder{crashes: make(chan Crash, 1)}
+	b.SetCrashReporter(reporter)
+
+	b.AddGracefulService("worker", func() error { panic("boom") }, nil)
+	b.graceful.Wait()
+
+	select {
+	case c := <-reporter.crashes:
+		if c.Service != "worker" || c.Panic != "boom" || c.Stack == "" {
+			t.Errorf("crash = %+v, want the panic of worker with the stack", c)
+		}
+	default:
+		t.Fatal("crash is not reported")
+	}
+	if err := b.graceful.FailedServices()["worker"]; err == nil {
+		t.Error("panicked service is not failed")
+	}
+
+	rec := httptest.NewRecorder()
+	b.readiness.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readiness status after the crash = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	type config struct {
+		Name  string `env:"TEST_LOAD_NAME" default:"default"`
+		Ready bool   `env:"TEST_LOAD_READY"`
+	}
+	t.Setenv("TEST_LOAD_READY", "true")
+
+	cfg, err := Load[config]([]string{"service", "--name=flag", "--addr=127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := cfg.Service(); got.Name != "flag" || !got.Ready {
+		t.Errorf("config = %+v, want the name of the flag and ready of the env", got)
+	}
+	if cfg.base.Addr != "127.0.0.1:0" {
+		t.Errorf("addr = %q, want the address of the flag", cfg.base.Addr)
+	}
+	if cfg.Logger() == nil {
+		t.Error("logger is nil")
+	}
+
+	if _, err := Load[config]([]string{"service", "--unknown=1"}); err == nil {
+		t.Error("unknown flag is accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package configtypes
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// CIDRs is a comma separated list of CIDR prefixes, e.g. "10.0.0.0/8,192.168.1.10" (an address is a single host prefix).
+type CIDRs []netip.Prefix
+
+func (c CIDRs) String() string {
+	prefixes := make([]string, 0, len(c))
+	for _, p := range c {
+		prefixes = append(prefixes, p.String())
+	}
+	return strings.Join(prefixes, ",")
+}
+
+func (c *CIDRs) SetString(str string) error {
+	var cidrs CIDRs
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return fmt.Errorf("parse CIDR %q: %w", s, err)
+			}
+			cidrs = append(cidrs, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return fmt.Errorf("parse CIDR %q: %w", s, err)
+		}
+		cidrs = append(cidrs, p.Masked())
+	}
+	*c = cidrs
+	return nil
+}
@@ -21,6 +21,8 @@ func (l *LogLevel) SetString(s string) error {
 
 func (l LogLevel) Level() slog.Level { return slog.Level(l) }
 
+func (l LogLevel) String() string { return slog.Level(l).String() }
+
 type LogHandler func(io.Writer, *slog.HandlerOptions) slog.Handler
 
 func (l *LogHandler) SetString(s string) error {
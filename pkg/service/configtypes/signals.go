@@ -0,0 +1,61 @@
+package configtypes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Signals is a comma separated list of signal names, e.g. "SIGINT,SIGTERM" (the "SIG" prefix is optional).
+type Signals []os.Signal
+
+//nolint:gochecknoglobals // constant map
+var signalNames = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+func (s Signals) String() string {
+	names := make([]string, 0, len(s))
+	for _, sig := range s {
+		names = append(names, signalName(sig))
+	}
+	return strings.Join(names, ",")
+}
+
+func signalName(sig os.Signal) string {
+	for _, m := range []map[string]os.Signal{signalNames, platformSignalNames} {
+		for name, s := range m {
+			if s == sig {
+				return name
+			}
+		}
+	}
+	return sig.String()
+}
+
+func (s *Signals) SetString(str string) error {
+	var signals Signals
+	for _, name := range strings.Split(str, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+		sig, ok := signalNames[name]
+		if !ok {
+			sig, ok = platformSignalNames[name]
+		}
+		if !ok {
+			return fmt.Errorf("unknown signal %q", name)
+		}
+		signals = append(signals, sig)
+	}
+	*s = signals
+	return nil
+}
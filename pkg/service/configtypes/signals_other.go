@@ -0,0 +1,8 @@
+//go:build !unix
+
+package configtypes
+
+import "os"
+
+//nolint:gochecknoglobals // constant map
+var platformSignalNames = map[string]os.Signal{}
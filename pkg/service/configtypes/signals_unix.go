@@ -0,0 +1,14 @@
+//go:build unix
+
+package configtypes
+
+import (
+	"os"
+	"syscall"
+)
+
+//nolint:gochecknoglobals // constant map
+var platformSignalNames = map[string]os.Signal{
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// crashReportTimeout limits the crash report, the service exits or shuts down after it.
+const crashReportTimeout = 5 * time.Second
+
+// Crash is a recovered panic of the main function or a service.
+type Crash struct {
+	Service   string    `json:"service"` // "main" or the name of the graceful service
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CrashReporter reports the crashes to an external system, e.g. an adapter to Sentry.
+type CrashReporter interface {
+	ReportCrash(ctx context.Context, crash Crash) error
+}
+
+func (b *base) SetCrashReporter(r CrashReporter) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.crashReporter = r
+}
+
+// crash handles the recovered panic: it logs the stack trace, makes the service not ready
+// and reports the crash to the crash reporter, if it is set.
+func (b *base) crash(name string, recovered any, stack []byte) {
+	b.readiness.Set(nil, http.StatusServiceUnavailable, nil)
+
+	c := Crash{
+		Service:   name,
+		Panic:     fmt.Sprint(recovered),
+		Stack:     string(stack),
+		Version:   version,
+		Commit:    commit,
+		Timestamp: time.Now().UTC(),
+	}
+	b.log.Error("Service panicked", slog.String("name", name), slog.String("panic", c.Panic), slog.String("stack", c.Stack))
+
+	b.mx.Lock()
+	reporter := b.crashReporter
+	b.mx.Unlock()
+	if reporter == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), crashReportTimeout)
+	defer cancel()
+	if err := reporter.ReportCrash(ctx, c); err != nil {
+		b.log.Error("Failed to report crash", slog.String("name", name), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of the secret config fields in /info.
+const redacted = "REDACTED"
+
+// secretNames are the parts of the field names of secret config values.
+//
+//nolint:gochecknoglobals // constant list
+var secretNames = []string{"TOKEN", "SECRET", "PASSWORD", "KEYS"}
+
+// Info is served by /info endpoint of the API server.
+type Info struct {
+	Version   string         `json:"version"`
+	Commit    string         `json:"commit"`
+	BuildDate string         `json:"build_date"`
+	GoVersion string         `json:"go_version"`
+	StartedAt time.Time      `json:"started_at"`
+	Config    map[string]any `json:"config"` // by the environment variable names, secrets are redacted
+}
+
+func infoHandler(cfg any, startedAt time.Time) http.Handler {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		StartedAt: startedAt,
+		Config:    map[string]any{},
+	}
+	effectiveConfig(info.Config, "", reflect.ValueOf(cfg))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info) //nolint:errcheck,errchkjson // best effort response
+	})
+}
+
+// effectiveConfig adds the config fields to the map by their environment variable names:
+// the env tag, or the upper case field name prefixed by the names of the parent structs.
+func effectiveConfig(m map[string]any, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+
+		name := prefix + strings.ToUpper(f.Name)
+		if env, ok := f.Tag.Lookup("env"); ok {
+			name = env
+		}
+
+		switch {
+		case f.Tag.Get("walker") == "embed":
+			effectiveConfig(m, prefix, fv)
+		case f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) && !implementsStringer(fv):
+			effectiveConfig(m, name+"_", fv)
+		case f.Type.Kind() == reflect.Func:
+			// e.g. the log handler constructor
+		default:
+			m[name] = configValue(name, fv)
+		}
+	}
+}
+
+func implementsStringer(v reflect.Value) bool {
+	_, ok := v.Interface().(fmt.Stringer)
+	return ok
+}
+
+func configValue(name string, v reflect.Value) any {
+	if isSecret(name) && !v.IsZero() {
+		return redacted
+	}
+	return redactValue(v)
+}
+
+func isSecret(name string) bool {
+	name = strings.ToUpper(name)
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue returns the value with the secrets redacted recursively: the strings of slices, maps and structs
+// (e.g. the endpoints of a list or a JSON config) are redacted as well as the secret fields of the structs.
+func redactValue(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	value := v.Interface()
+	switch x := value.(type) {
+	case string:
+		return RedactString(x)
+	case time.Duration:
+		return x.String()
+	case time.Time:
+		return x
+	case fmt.Stringer:
+		return RedactString(x.String())
+	}
+
+	switch v.Kind() { //nolint:exhaustive // other kinds are returned as is
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = redactValue(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key().Interface())
+			if isSecret(k) && !iter.Value().IsZero() {
+				m[k] = redacted
+				continue
+			}
+			m[k] = redactValue(iter.Value())
+		}
+		return m
+	case reflect.Struct:
+		m := map[string]any{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			k, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if k == "-" {
+				continue
+			}
+			if k == "" {
+				k = f.Name
+			}
+			if isSecret(k) && !v.Field(i).IsZero() {
+				m[k] = redacted
+				continue
+			}
+			m[k] = redactValue(v.Field(i))
+		}
+		return m
+	}
+
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
+
+// secretParams are the parts of the names of secret URL query parameters, in lower case.
+//
+//nolint:gochecknoglobals // constant list
+var secretParams = []string{"password", "passwd", "pwd", "secret", "token", "key", "signature", "sig", "auth", "credential"}
+
+// dsnPassword matches the password of a keyword/value connection string, e.g. "host=db password='s3cr3t' dbname=x".
+var dsnPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// RedactString redacts the password and the secret query parameters of a URL, e.g. of a database connection string
+// or an endpoint with an API key, and the password of a keyword/value connection string.
+func RedactString(s string) string {
+	s = dsnPassword.ReplaceAllString(s, "${1}"+redacted)
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" {
+		return s
+	}
+	changed := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+		changed = true
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name, values := range q {
+			if !isSecretParam(name) {
+				continue
+			}
+			for i := range values {
+				values[i] = redacted
+			}
+			changed = true
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	if !changed {
+		return s
+	}
+	return u.String()
+}
+
+func isSecretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretParams {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
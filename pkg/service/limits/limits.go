@@ -0,0 +1,84 @@
+// Package limits detects the CPU quota and the memory limit of the container from cgroups (v2 and v1).
+package limits
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//nolint:gochecknoglobals // paths are variables to be overridden on non-standard mounts
+var (
+	CgroupV2Root = "/sys/fs/cgroup"
+	CgroupV1Root = "/sys/fs/cgroup"
+)
+
+var ErrNoLimit = errors.New("limit is not set")
+
+// CPUQuota returns the CPU quota in cores, e.g. 1.5 for 'cpu: 1500m' limit.
+func CPUQuota() (float64, error) {
+	if data, err := os.ReadFile(CgroupV2Root + "/cpu.max"); err == nil {
+		quota, period, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+		return ratio(quota, period)
+	}
+
+	quota, err := os.ReadFile(CgroupV1Root + "/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, err //nolint:wrapcheck // os errors are descriptive
+	}
+	period, err := os.ReadFile(CgroupV1Root + "/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, err //nolint:wrapcheck // os errors are descriptive
+	}
+	return ratio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// MemoryLimit returns the memory limit in bytes.
+func MemoryLimit() (int64, error) {
+	data, err := os.ReadFile(CgroupV2Root + "/memory.max")
+	if err != nil {
+		data, err = os.ReadFile(CgroupV1Root + "/memory/memory.limit_in_bytes")
+		if err != nil {
+			return 0, err //nolint:wrapcheck // os errors are descriptive
+		}
+	}
+
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, ErrNoLimit
+	}
+	limit, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // strconv errors are descriptive
+	}
+	// cgroup v1 reports the max page aligned int64 if there is no limit
+	if limit <= 0 || limit >= math.MaxInt64/2 {
+		return 0, ErrNoLimit
+	}
+	return limit, nil
+}
+
+// MaxProcs returns GOMAXPROCS for the CPU quota: the quota rounded down, at least 1.
+func MaxProcs(quota float64) int {
+	return max(1, int(math.Floor(quota)))
+}
+
+func ratio(quota, period string) (float64, error) {
+	if quota == "max" || quota == "-1" {
+		return 0, ErrNoLimit
+	}
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // strconv errors are descriptive
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // strconv errors are descriptive
+	}
+	if q <= 0 || p <= 0 {
+		return 0, ErrNoLimit
+	}
+	return q / p, nil
+}
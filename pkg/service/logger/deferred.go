@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// DeferredBuffer keeps the records of the deferred handlers until they are flushed or discarded.
+// Records over the limit are dropped.
+type DeferredBuffer struct {
+	mu      sync.Mutex
+	max     int
+	records []deferredRecord
+}
+
+type deferredRecord struct {
+	handler slog.Handler
+	record  slog.Record
+}
+
+func NewDeferredBuffer(maxRecords int) *DeferredBuffer {
+	return &DeferredBuffer{max: maxRecords} //nolint:exhaustruct // empty buffer
+}
+
+// Flush emits the kept records and empties the buffer.
+func (b *DeferredBuffer) Flush(ctx context.Context) {
+	b.mu.Lock()
+	records := b.records
+	b.records = nil
+	b.mu.Unlock()
+
+	for _, r := range records {
+		_ = r.handler.Handle(ctx, r.record) // the same as slog.Logger does with handler errors
+	}
+}
+
+// Deferred returns the handler which emits the records at Warn and above at once
+// and keeps the others in the buffer, e.g. to emit them only if the operation ultimately fails.
+func Deferred(h slog.Handler, buf *DeferredBuffer) slog.Handler {
+	return deferred{Handler: h, Buffer: buf}
+}
+
+type deferred struct {
+	Handler slog.Handler
+	Buffer  *DeferredBuffer
+}
+
+func (d deferred) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		return d.Handler.Handle(ctx, r) //nolint:wrapcheck // don't wrap on simple wrapper type
+	}
+
+	d.Buffer.mu.Lock()
+	defer d.Buffer.mu.Unlock()
+	if len(d.Buffer.records) < d.Buffer.max {
+		d.Buffer.records = append(d.Buffer.records, deferredRecord{handler: d.Handler, record: r.Clone()})
+	}
+	return nil
+}
+
+func (d deferred) Enabled(ctx context.Context, l slog.Level) bool {
+	return d.Handler.Enabled(ctx, l)
+}
+
+func (d deferred) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return deferred{d.Handler.WithAttrs(attrs), d.Buffer}
+}
+
+func (d deferred) WithGroup(name string) slog.Handler {
+	return deferred{d.Handler.WithGroup(name), d.Buffer}
+}
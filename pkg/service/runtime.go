@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service/limits"
+)
+
+type runtimeConfig struct {
+	AutoMaxProcs  bool    `default:"true"`
+	MemLimitRatio float64 `default:"0.9"`
+}
+
+// setRuntimeLimits sets GOMAXPROCS from the container CPU quota and GOMEMLIMIT from the container memory limit
+// unless they are set by the environment variables, and exports the effective values as metrics.
+func setRuntimeLimits(cfg runtimeConfig, log *slog.Logger) {
+	if _, ok := os.LookupEnv("GOMAXPROCS"); !ok && cfg.AutoMaxProcs {
+		quota, err := limits.CPUQuota()
+		switch {
+		case err == nil:
+			runtime.GOMAXPROCS(limits.MaxProcs(quota))
+		case !errors.Is(err, limits.ErrNoLimit) && !errors.Is(err, os.ErrNotExist):
+			log.Warn("Failed to detect CPU quota - GOMAXPROCS is not changed", slog.Any("error", err))
+		}
+	}
+
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); !ok && cfg.MemLimitRatio > 0 {
+		limit, err := limits.MemoryLimit()
+		switch {
+		case err == nil:
+			debug.SetMemoryLimit(int64(float64(limit) * cfg.MemLimitRatio))
+		case !errors.Is(err, limits.ErrNoLimit) && !errors.Is(err, os.ErrNotExist):
+			log.Warn("Failed to detect memory limit - GOMEMLIMIT is not changed", slog.Any("error", err))
+		}
+	}
+
+	maxProcs := runtime.GOMAXPROCS(0)
+	memLimit := debug.SetMemoryLimit(-1)
+
+	promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "runtime_gomaxprocs",
+		Help: "Effective GOMAXPROCS",
+	}).Set(float64(maxProcs))
+	promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "runtime_gomemlimit_bytes",
+		Help: "Effective GOMEMLIMIT (math.MaxInt64 if there is no limit)",
+	}).Set(float64(memLimit))
+
+	log.Info("Runtime limits", slog.Int("gomaxprocs", maxProcs), slog.Int64("gomemlimit", memLimit))
+}
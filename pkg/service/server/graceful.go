@@ -3,108 +3,259 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 type GracefulStopper struct {
-	log *slog.Logger
+	log     *slog.Logger
+	onPanic func(name string, recovered any, stack []byte)
 
 	servers []server
 	doneAny chan struct{}
+	failed  map[string]error // errors of the services stopped with an error
+
+	running      sync.WaitGroup // run goroutines
+	shutdownOnce sync.Once
 
 	mx sync.Mutex
 }
 
 type server struct {
-	name       string
-	shutdownFn shutdownFunc
-	ch         chan struct{}
+	name            string
+	shutdownFn      shutdownFunc
+	ch              chan struct{}
+	addr            net.Addr      // bound address of HTTP servers
+	shutdownTimeout time.Duration // limits the shutdown of the service within the global deadline
 }
 
 type shutdownFunc func(ctx context.Context) error
 
+// ServiceOption configures the service started by the GracefulStopper.
+type ServiceOption func(*server)
+
+// ShutdownTimeout limits the shutdown of the service, so a slow service doesn't take the whole global deadline.
+func ShutdownTimeout(d time.Duration) ServiceOption {
+	return func(s *server) { s.shutdownTimeout = d }
+}
+
+// shutdownProgressInterval is the interval of the logs of the services still shutting down if there is no global deadline.
+const shutdownProgressInterval = 5 * time.Second
+
 func NewGracefulStopper(log *slog.Logger) *GracefulStopper {
 	return &GracefulStopper{ //nolint:exhaustruct // zero value initialization
 		log:     log,
 		doneAny: make(chan struct{}, 1),
+		failed:  map[string]error{},
 	}
 }
 
+// Service is run until it is shut down. An error returned by Run is recorded, see FailedServices.
 type Service interface {
-	Run()
+	Run() error
 	Shutdown(ctx context.Context) error
 }
 
-func (g *GracefulStopper) start(name string, run func(), shutdown func(context.Context) error) {
+func (g *GracefulStopper) start(name string, run func() error, shutdown func(context.Context) error, addr net.Addr, opts []ServiceOption) {
 	g.mx.Lock()
 	defer g.mx.Unlock()
 
 	if run == nil {
-		run = func() {}
+		run = func() error { return nil }
 	}
 	if shutdown == nil {
 		shutdown = func(_ context.Context) error { return nil }
 	}
 
-	srv := server{name, shutdown, make(chan struct{})}
+	srv := server{name, shutdown, make(chan struct{}), addr, 0}
+	for _, opt := range opts {
+		opt(&srv)
+	}
 	g.servers = append(g.servers, srv)
 
+	g.running.Add(1)
 	go func() {
+		defer g.running.Done()
 		defer close(srv.ch)
+		defer func() {
+			select {
+			case g.doneAny <- struct{}{}:
+			default:
+			}
+		}()
+		defer g.recoverPanic(name)
 
-		run()
-
-		select {
-		case g.doneAny <- struct{}{}:
-		default:
+		if err := run(); err != nil {
+			g.fail(name, err)
 		}
 	}()
 }
 
-func (g *GracefulStopper) StartHTTP(name string, httpSrv *http.Server) {
-	g.start(name, func() {
-		err := httpSrv.ListenAndServe()
-		if err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				g.log.Error("HTTP server stopped with an error", slog.String("name", name), slog.Any("error", err))
-			}
+// fail records the error of the stopped service.
+func (g *GracefulStopper) fail(name string, err error) {
+	g.log.Error("Service stopped with an error", slog.String("name", name), slog.Any("error", err))
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	g.failed[name] = err
+}
+
+// FailedServices returns the errors of the services stopped with an error (or a panic) by the service names.
+func (g *GracefulStopper) FailedServices() map[string]error {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	failed := make(map[string]error, len(g.failed))
+	for name, err := range g.failed {
+		failed[name] = err
+	}
+	return failed
+}
+
+// SetPanicHandler sets the handler of the panics recovered in the run functions of the services.
+// A panicked service is stopped as if its run function returned.
+func (g *GracefulStopper) SetPanicHandler(fn func(name string, recovered any, stack []byte)) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	g.onPanic = fn
+}
+
+func (g *GracefulStopper) recoverPanic(name string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+
+	g.mx.Lock()
+	onPanic := g.onPanic
+	g.failed[name] = fmt.Errorf("panic: %v", r)
+	g.mx.Unlock()
+
+	if onPanic == nil {
+		g.log.Error("Service panicked", slog.String("name", name), slog.Any("panic", r), slog.String("stack", string(stack)))
+		return
+	}
+	onPanic(name, r, stack)
+}
+
+// StartHTTP listens on the address of the server and serves it.
+// The address is bound before it returns, so the bound address is available by Addr.
+func (g *GracefulStopper) StartHTTP(name string, httpSrv *http.Server, opts ...ServiceOption) {
+	ln, err := net.Listen("tcp", ListenAddr(httpSrv.Addr))
+	if err != nil {
+		g.start(name, func() error {
+			return fmt.Errorf("listen: %w", err)
+		}, nil, nil, opts)
+		return
+	}
+	g.StartHTTPListener(name, httpSrv, ln, opts...)
+}
+
+// ListenAddr returns the TCP address to listen on: ":http" if the address is empty and ":<port>" if it is a port only,
+// e.g. "0" binds an ephemeral port.
+func ListenAddr(addr string) string {
+	switch {
+	case addr == "":
+		return ":http"
+	case !strings.Contains(addr, ":"):
+		return ":" + addr
+	}
+	return addr
+}
+
+// StartHTTPListener serves the server on the existing listener, e.g. passed by systemd socket activation
+// or bound by tests. The listener is closed on shutdown.
+func (g *GracefulStopper) StartHTTPListener(name string, httpSrv *http.Server, ln net.Listener, opts ...ServiceOption) {
+	g.start(name, func() error {
+		err := httpSrv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("serve: %w", err)
 		}
-	}, httpSrv.Shutdown)
+		return nil
+	}, httpSrv.Shutdown, ln.Addr(), opts)
+
+	g.log.Info("HTTP server is listening", slog.String("name", name), slog.String("addr", ln.Addr().String()))
+}
 
-	g.log.Info("HTTP server is listening", slog.String("name", name), slog.String("addr", httpSrv.Addr))
+// Addr returns the bound address of the HTTP server, or nil if the server is not listening.
+func (g *GracefulStopper) Addr(name string) net.Addr {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	for _, srv := range g.servers {
+		if srv.name == name {
+			return srv.addr
+		}
+	}
+	return nil
 }
 
-func (g *GracefulStopper) Start(name string, s Service) {
-	g.start(name, s.Run, s.Shutdown)
+func (g *GracefulStopper) Start(name string, s Service, opts ...ServiceOption) {
+	g.start(name, s.Run, s.Shutdown, nil, opts)
 
 	g.log.Info("Worker is running", slog.String("name", name))
 }
 
-func (g *GracefulStopper) StartCustom(name string, run func(), shutdown func(context.Context) error) {
-	g.start(name, run, shutdown)
+func (g *GracefulStopper) StartCustom(name string, run func() error, shutdown func(context.Context) error, opts ...ServiceOption) {
+	g.start(name, run, shutdown, nil, opts)
 
 	g.log.Info("Worker is running", slog.String("name", name))
 }
 
+// DoneAny is signaled when any service stops, FailedServices tells the cause if it stopped with an error.
 func (g *GracefulStopper) DoneAny() <-chan struct{} {
 	return g.doneAny
 }
 
+// ShutdownAll shuts down the started services concurrently within the context.
+// It is safe to call it multiple times: the services are shut down once, the following calls wait for the first one.
 func (g *GracefulStopper) ShutdownAll(ctx context.Context) {
-	g.mx.Lock()
-	defer g.mx.Unlock()
+	g.shutdownOnce.Do(func() {
+		// The servers are kept, so Addr still reports the addresses after the shutdown.
+		g.mx.Lock()
+		servers := slices.Clone(g.servers)
+		g.mx.Unlock()
+
+		g.shutdown(ctx, servers)
+	})
+}
+
+// Wait blocks until the run functions of all started services return, e.g. after ShutdownAll.
+func (g *GracefulStopper) Wait() {
+	g.running.Wait()
+}
+
+func (g *GracefulStopper) shutdown(ctx context.Context, servers []server) {
+	pending := newPendingServices(servers)
+	stopProgress := g.logShutdownProgress(ctx, pending)
+	defer stopProgress()
 
 	var wg sync.WaitGroup
-	for _, srv := range g.servers {
+	for _, srv := range servers {
 		wg.Add(1)
 		go func(s server) {
 			defer wg.Done()
+			defer pending.done(s.name)
 
 			log := g.log.With(slog.String("name", s.name))
 
-			err := s.shutdownFn(ctx)
+			shutdownCtx := ctx
+			if s.shutdownTimeout > 0 {
+				var cancel context.CancelFunc
+				shutdownCtx, cancel = context.WithTimeout(ctx, s.shutdownTimeout)
+				defer cancel()
+			}
+
+			err := s.shutdownFn(shutdownCtx)
 			switch {
 			case err == nil:
 				log.Info("Server is shut down")
@@ -121,6 +272,76 @@ func (g *GracefulStopper) ShutdownAll(ctx context.Context) {
 	}
 
 	wg.Wait()
+}
+
+// pendingServices are the services still shutting down.
+type pendingServices struct {
+	mx    sync.Mutex
+	names map[string]struct{}
+}
 
-	g.servers = nil
+func newPendingServices(servers []server) *pendingServices {
+	p := &pendingServices{names: make(map[string]struct{}, len(servers))} //nolint:exhaustruct // zero mutex
+	for _, s := range servers {
+		p.names[s.name] = struct{}{}
+	}
+	return p
+}
+
+func (p *pendingServices) done(name string) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	delete(p.names, name)
+}
+
+func (p *pendingServices) list() []string {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	names := make([]string, 0, len(p.names))
+	for name := range p.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// logShutdownProgress logs the services still shutting down at a quarter, a half and three quarters
+// of the global deadline (every shutdownProgressInterval if there is no deadline) until it is stopped.
+func (g *GracefulStopper) logShutdownProgress(ctx context.Context, pending *pendingServices) func() {
+	stop := make(chan struct{})
+	go func() {
+		deadline, hasDeadline := ctx.Deadline()
+		interval := shutdownProgressInterval
+		if hasDeadline {
+			interval = time.Until(deadline) / 4
+		}
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			names := pending.list()
+			if len(names) == 0 {
+				return
+			}
+			log := g.log.With(slog.Any("pending", names))
+			if hasDeadline {
+				log = log.With(slog.Duration("deadline_in", time.Until(deadline).Round(time.Millisecond)))
+			}
+			log.Warn("Services are still shutting down")
+		}
+	}()
+	return func() { close(stop) }
 }
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestStopper() *GracefulStopper {
+	return NewGracefulStopper(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func waitDone(t *testing.T, g *GracefulStopper) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		g.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("services are still running")
+	}
+}
+
+func TestGracefulStopperAddr(t *testing.T) {
+	g := newTestStopper()
+	g.StartHTTP("api", &http.Server{Addr: "127.0.0.1:0", ReadHeaderTimeout: time.Second}) //nolint:exhaustruct // test server
+
+	addr, ok := g.Addr("api").(*net.TCPAddr)
+	if !ok || addr.Port == 0 {
+		t.Fatalf("addr = %v, want a bound ephemeral port", g.Addr("api"))
+	}
+	if g.Addr("unknown") != nil {
+		t.Errorf("addr of unknown server = %v, want nil", g.Addr("unknown"))
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/") //nolint:noctx // test request
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+
+	g.ShutdownAll(context.Background())
+	waitDone(t, g)
+
+	if got := g.Addr("api"); got == nil || got.String() != addr.String() {
+		t.Errorf("addr after shutdown = %v, want %v", got, addr)
+	}
+	if _, err := net.Dial("tcp", addr.String()); err == nil {
+		t.Error("listener is not closed by the shutdown")
+	}
+}
+
+func TestGracefulStopperListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	g := newTestStopper()
+	g.StartHTTP("api", &http.Server{Addr: ln.Addr().String(), ReadHeaderTimeout: time.Second}) //nolint:exhaustruct // test server
+	waitDone(t, g)
+
+	if g.Addr("api") != nil {
+		t.Errorf("addr = %v, want nil if the server is not listening", g.Addr("api"))
+	}
+	if g.FailedServices()["api"] == nil {
+		t.Error("listen error is not recorded")
+	}
+}
+
+func TestGracefulStopperShutdownAllTwice(t *testing.T) {
+	g := newTestStopper()
+
+	var shutdowns atomic.Int32
+	stop := make(chan struct{})
+	g.StartCustom("worker", func() error {
+		<-stop
+		return nil
+	}, func(context.Context) error {
+		shutdowns.Add(1)
+		close(stop)
+		return nil
+	})
+
+	g.ShutdownAll(context.Background())
+	g.ShutdownAll(context.Background())
+	waitDone(t, g)
+
+	if n := shutdowns.Load(); n != 1 {
+		t.Errorf("shutdowns = %d, want 1", n)
+	}
+}
+
+func TestGracefulStopperWaitAfterFailure(t *testing.T) {
+	g := newTestStopper()
+
+	errRun := errors.New("run failed")
+	g.StartCustom("failing", func() error { return errRun }, nil)
+
+	stop := make(chan struct{})
+	var stopped atomic.Bool
+	g.StartCustom("worker", func() error {
+		<-stop
+		stopped.Store(true)
+		return nil
+	}, func(context.Context) error {
+		close(stop)
+		return nil
+	})
+
+	select {
+	case <-g.DoneAny():
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed service is not signaled")
+	}
+	if err := g.FailedServices()["failing"]; !errors.Is(err, errRun) {
+		t.Errorf("failed service error = %v, want %v", err, errRun)
+	}
+
+	g.ShutdownAll(context.Background())
+	waitDone(t, g)
+
+	if !stopped.Load() {
+		t.Error("Wait returned before the run function of the worker")
+	}
+	if len(g.FailedServices()) != 1 {
+		t.Errorf("failed services = %v, want only the failing one", g.FailedServices())
+	}
+}
+
+func TestGracefulStopperPanic(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler bool
+	}{
+		{name: "logged"},
+		{name: "handler", handler: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestStopper()
+
+			var recovered atomic.Value
+			if tt.handler {
+				g.SetPanicHandler(func(name string, r any, stack []byte) {
+					if name != "worker" || len(stack) == 0 {
+						t.Errorf("panic handler called with name %q and stack of %d bytes", name, len(stack))
+					}
+					recovered.Store(r)
+				})
+			}
+
+			g.StartCustom("worker", func() error { panic("boom") }, nil)
+
+			select {
+			case <-g.DoneAny():
+			case <-time.After(5 * time.Second):
+				t.Fatal("panicked service is not signaled")
+			}
+			waitDone(t, g)
+
+			if err := g.FailedServices()["worker"]; err == nil || err.Error() != "panic: boom" {
+				t.Errorf("failed service error = %v, want panic: boom", err)
+			}
+			if tt.handler && recovered.Load() != "boom" {
+				t.Errorf("recovered = %v, want boom", recovered.Load())
+			}
+
+			g.ShutdownAll(context.Background())
+		})
+	}
+}
+
+func TestGracefulStopperShutdownTimeout(t *testing.T) {
+	g := newTestStopper()
+
+	shutdownErr := make(chan error, 1)
+	g.StartCustom("slow", nil, func(ctx context.Context) error {
+		<-ctx.Done()
+		shutdownErr <- ctx.Err()
+		return ctx.Err()
+	}, ShutdownTimeout(10*time.Millisecond))
+
+	g.ShutdownAll(context.Background())
+	waitDone(t, g)
+
+	if err := <-shutdownErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := map[string]string{
+		"":               ":http",
+		"0":              ":0",
+		"8080":           ":8080",
+		":8080":          ":8080",
+		"127.0.0.1:8080": "127.0.0.1:8080",
+	}
+	for addr, want := range tests {
+		if got := ListenAddr(addr); got != want {
+			t.Errorf("ListenAddr(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
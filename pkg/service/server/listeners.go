@@ -0,0 +1,51 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+// SystemdListeners returns the listeners passed by systemd socket activation by their names
+// (FileDescriptorName= of the socket unit, LISTEN_FDNAMES), or nil if the process is not socket activated.
+// The LISTEN_* variables are unset, so child processes don't inherit them.
+func SystemdListeners() (map[string]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil //nolint:nilnil // not socket activated
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("wrong LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for _, env := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		os.Unsetenv(env)
+	}
+
+	listeners := make(map[string]net.Listener, n)
+	var errs []error
+	for i := 0; i < n; i++ {
+		name := "fd" + strconv.Itoa(listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		ln, err := net.FileListener(f)
+		f.Close() // the listener has a dup of the descriptor
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listener %q: %w", name, err))
+			continue
+		}
+		listeners[name] = ln
+	}
+	return listeners, errors.Join(errs...)
+}
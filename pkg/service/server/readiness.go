@@ -10,9 +10,16 @@ type Readiness struct {
 	StatusCode int
 	Body       []byte
 
+	checks []readinessCheck
+
 	mx sync.Mutex
 }
 
+type readinessCheck struct {
+	name  string
+	check func() error
+}
+
 func NewReadiness(hs http.Header, status int, body []byte) *Readiness {
 	return &Readiness{Headers: hs, StatusCode: status, Body: body} //nolint:exhaustruct // mutex is initialized by zero value
 }
@@ -26,10 +33,29 @@ func (r *Readiness) Set(hs http.Header, status int, body []byte) {
 	r.Body = body
 }
 
+// AddCheck adds a check which makes the service not ready (503) while it returns an error.
+// Checks are called only if the service is ready otherwise.
+func (r *Readiness) AddCheck(name string, check func() error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.checks = append(r.checks, readinessCheck{name, check})
+}
+
 func (r *Readiness) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 
+	if r.StatusCode >= 200 && r.StatusCode < 300 {
+		for _, c := range r.checks {
+			if err := c.check(); err != nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(c.name + ": " + err.Error())) //nolint:errcheck // body is optional
+				return
+			}
+		}
+	}
+
 	for k, v := range r.Headers {
 		w.Header()[k] = v
 	}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Router routes the requests by method and path pattern (see Routes for the pattern syntax).
+// The values of the "{name}" segments are returned by PathValue. Requests of a known pattern with another method
+// get 405, requests not matching any pattern are served by the NotFound handler.
+// RouteInfo labels the requests by the matched pattern for ResponseTimeMiddleware.
+type Router struct {
+	// NotFound serves the requests not matching any pattern, http.NotFound if it is nil.
+	NotFound http.Handler
+
+	mx     sync.RWMutex
+	routes []*methodRoute
+}
+
+type methodRoute struct {
+	route
+	handlers map[string]http.Handler // by method, "" matches any method
+}
+
+type pathValuesKey struct{}
+
+func NewRouter() *Router {
+	return &Router{} //nolint:exhaustruct // zero value initialization
+}
+
+// Handle registers the handler of the method and the pattern. An empty method matches any method, GET matches HEAD too.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	rt.mx.Lock()
+	defer rt.mx.Unlock()
+
+	for _, mr := range rt.routes {
+		if mr.pattern == pattern {
+			mr.handlers[method] = h
+			return
+		}
+	}
+	rt.routes = append(rt.routes, &methodRoute{
+		route:    route{pattern: pattern, segments: splitPath(pattern)},
+		handlers: map[string]http.Handler{method: h},
+	})
+}
+
+func (rt *Router) HandleFunc(method, pattern string, h func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(method, pattern, http.HandlerFunc(h))
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mr, params := rt.lookup(r.URL.Path)
+	if mr == nil {
+		notFound := rt.NotFound
+		if notFound == nil {
+			notFound = http.NotFoundHandler()
+		}
+		notFound.ServeHTTP(w, r)
+		return
+	}
+
+	h, ok := mr.handler(r.Method)
+	if !ok {
+		w.Header().Set("Allow", mr.allow())
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if params != nil {
+		r = r.WithContext(context.WithValue(r.Context(), pathValuesKey{}, params))
+	}
+	h.ServeHTTP(w, r)
+}
+
+// RouteInfo returns the pattern matching the request path. It is a RouteInfoFunc.
+func (rt *Router) RouteInfo(r *http.Request) (string, bool) {
+	if mr, _ := rt.lookup(r.URL.Path); mr != nil {
+		return mr.pattern, true
+	}
+	return "", false
+}
+
+func (rt *Router) lookup(path string) (*methodRoute, map[string]string) {
+	segments := splitPath(path)
+
+	rt.mx.RLock()
+	defer rt.mx.RUnlock()
+
+	for _, mr := range rt.routes {
+		if params, ok := mr.match(segments); ok {
+			return mr, params
+		}
+	}
+	return nil, nil
+}
+
+func (mr *methodRoute) handler(method string) (http.Handler, bool) {
+	if h, ok := mr.handlers[method]; ok {
+		return h, true
+	}
+	if method == http.MethodHead {
+		if h, ok := mr.handlers[http.MethodGet]; ok {
+			return h, true
+		}
+	}
+	h, ok := mr.handlers[""]
+	return h, ok
+}
+
+func (mr *methodRoute) allow() string {
+	methods := make([]string, 0, len(mr.handlers)+1)
+	for m := range mr.handlers {
+		methods = append(methods, m)
+		if m == http.MethodGet {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	slices.Sort(methods)
+	return strings.Join(slices.Compact(methods), ", ")
+}
+
+// PathValue returns the value of the "{name}" segment of the pattern matched by the Router.
+func PathValue(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathValuesKey{}).(map[string]string)
+	return params[name]
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Routes is a registry of route patterns for ResponseTimeMiddleware, so the requests of dynamic paths
+// are labeled by their pattern and the path label cardinality stays bounded, e.g. "/callbacks/{token}".
+// A "{name}" segment of the pattern matches any non-empty path segment, other segments match as is.
+type Routes struct {
+	mx       sync.RWMutex
+	patterns []route
+}
+
+type route struct {
+	pattern  string
+	segments []string
+}
+
+func NewRoutes(patterns ...string) *Routes {
+	rs := &Routes{} //nolint:exhaustruct // zero value initialization
+	for _, p := range patterns {
+		rs.Add(p)
+	}
+	return rs
+}
+
+// Add registers the pattern. Patterns are matched in the order they are added.
+func (rs *Routes) Add(pattern string) {
+	rs.mx.Lock()
+	defer rs.mx.Unlock()
+
+	rs.patterns = append(rs.patterns, route{pattern: pattern, segments: splitPath(pattern)})
+}
+
+// RouteInfo returns the first pattern matching the request path. It is a RouteInfoFunc,
+// requests not matching any pattern are labeled by their path.
+func (rs *Routes) RouteInfo(r *http.Request) (string, bool) {
+	segments := splitPath(r.URL.Path)
+
+	rs.mx.RLock()
+	defer rs.mx.RUnlock()
+
+	for _, rt := range rs.patterns {
+		if _, ok := rt.match(segments); ok {
+			return rt.pattern, true
+		}
+	}
+	return "", false
+}
+
+// match returns the values of the "{name}" segments if the path segments match the route.
+func (rt route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, s := range rt.segments {
+		if name, ok := strings.CutPrefix(s, "{"); ok && strings.HasSuffix(name, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[strings.TrimSuffix(name, "}")] = segments[i]
+			continue
+		}
+		if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
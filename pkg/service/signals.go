@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"slices"
+	"syscall"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/service/configtypes"
+)
+
+type signalsConfig struct {
+	Shutdown   configtypes.Signals `default:"SIGINT,SIGTERM"`
+	ForceExit  bool                `default:"true"`
+	DumpOnQuit bool                `default:"true"`
+}
+
+// notifyContext returns the context canceled by the first shutdown signal. If ForceExit is set, a shutdown signal
+// received after the shutdown is started exits the process immediately. If DumpOnQuit is set and SIGQUIT is not
+// a shutdown signal, SIGQUIT dumps the goroutines to stderr without exiting.
+func notifyContext(cfg signalsConfig, log *slog.Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, cfg.Shutdown...)
+	go func() {
+		for sig := range ch {
+			if ctx.Err() == nil {
+				log.Info("Shutdown signal is received", slog.String("signal", sig.String()))
+				cancel()
+				continue
+			}
+			if cfg.ForceExit {
+				log.Error("Shutdown signal is received again - the service exits immediately", slog.String("signal", sig.String()))
+				os.Exit(1)
+			}
+		}
+	}()
+
+	if cfg.DumpOnQuit && !slices.Contains(cfg.Shutdown, os.Signal(syscall.SIGQUIT)) {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGQUIT)
+		go func() {
+			for range quit {
+				log.Warn("SIGQUIT is received - goroutines are dumped to stderr")
+				if err := pprof.Lookup("goroutine").WriteTo(os.Stderr, 2); err != nil {
+					log.Error("Failed to dump goroutines", slog.Any("error", err))
+				}
+			}
+		}()
+	}
+	return ctx, cancel
+}
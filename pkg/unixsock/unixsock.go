@@ -0,0 +1,96 @@
+// Package unixsock sends HTTP requests over unix domain sockets, e.g. to a function sidecar in the same pod.
+//
+// The socket and the request path are set in the URL as 'unix:///path/to/socket.sock:/request/path?query'.
+// The request path defaults to '/'.
+package unixsock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheme is the URL scheme of unix domain socket endpoints.
+const Scheme = "unix"
+
+var ErrNoSocket = errors.New("socket path is empty")
+
+// Split returns the socket path and the request path of the URL path of a unix endpoint.
+func Split(path string) (socket, reqPath string) {
+	socket, reqPath, _ = strings.Cut(path, ":")
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	return socket, reqPath
+}
+
+// SetPath replaces the request path of the unix endpoint URL keeping the socket path.
+func SetPath(u *url.URL, reqPath string) {
+	socket, _ := Split(u.Path)
+	u.Path = socket + ":" + reqPath
+	u.RawPath = ""
+}
+
+// Validate returns an error if the endpoint is a unix endpoint without the socket path.
+func Validate(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != Scheme {
+		return nil //nolint:nilerr // not a unix endpoint
+	}
+	if socket, _ := Split(u.Path); socket == "" {
+		return fmt.Errorf("unix endpoint %q: %w", endpoint, ErrNoSocket)
+	}
+	return nil
+}
+
+// Transport is a http.RoundTripper for 'unix' URLs. It keeps a keep-alive transport per socket.
+type Transport struct {
+	mx         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// Register makes the transport handle 'unix' URLs of the requests sent with t.
+func Register(t *http.Transport) {
+	t.RegisterProtocol(Scheme, &Transport{transports: map[string]*http.Transport{}}) //nolint:exhaustruct // zero mutex
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	socket, reqPath := Split(req.URL.Path)
+	if socket == "" {
+		return nil, fmt.Errorf("unix endpoint %q: %w", req.URL, ErrNoSocket)
+	}
+
+	r := req.Clone(req.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = "localhost"
+	r.URL.Path = reqPath
+	r.URL.RawPath = ""
+	r.Host = "localhost"
+
+	return t.transport(socket).RoundTrip(r) //nolint:wrapcheck // transport errors have the socket address
+}
+
+func (t *Transport) transport(socket string) *http.Transport {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	tr, ok := t.transports[socket]
+	if !ok {
+		dialer := &net.Dialer{Timeout: 30 * time.Second} //nolint:exhaustruct // defaults of http.DefaultTransport
+		tr = &http.Transport{                            //nolint:exhaustruct // optional parameters
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+			MaxIdleConnsPerHost: 100, // all connections go to the same sidecar
+			IdleConnTimeout:     90 * time.Second,
+		}
+		t.transports[socket] = tr
+	}
+	return tr
+}
@@ -0,0 +1,204 @@
+// Package wasmhook runs custom per-message transform and filter hooks of a WebAssembly module in a sandbox:
+// the module has no access to the filesystem, the network or the environment, its memory is limited
+// and a call is interrupted when its context is done.
+//
+// The module exports 'memory' and 'alloc(size i32) i32' which returns a buffer for the input, and the hooks
+// (both are optional):
+//   - 'filter(ptr i32, len i32) i32' returns 0 to skip the message and 1 to process it;
+//   - 'transform(ptr i32, len i32) i64' returns the output packed as 'ptr << 32 | len', 0 on failure.
+//
+// Instances are reused for the next messages, so the module should release or reuse its buffers
+// (e.g. a bump allocator reset by alloc). WASI modules (e.g. built by TinyGo or Rust wasm32-wasi target as reactors)
+// are supported.
+package wasmhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// memoryLimitPages limits the memory of a module instance to 64 MiB.
+const memoryLimitPages = 1024
+
+var (
+	ErrNoExport  = errors.New("module export is not found")
+	ErrTransform = errors.New("transform hook failed")
+	// ErrUnavailable is returned if the message is not run by the module, e.g. the runtime is closed
+	// or the call is interrupted by its context. The message may be retried.
+	ErrUnavailable = errors.New("wasm hook is unavailable")
+)
+
+// Hook calls the hooks of the module. Module instances are not safe for concurrent use,
+// so the hook keeps a pool of up to size instances.
+type Hook struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	pool     chan api.Module
+	slots    chan struct{}
+	closed   atomic.Bool
+
+	hasFilter    bool
+	hasTransform bool
+}
+
+// Load compiles the WebAssembly module from the file. Size is the maximum number of concurrent calls.
+func Load(ctx context.Context, path string, size int) (*Hook, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read wasm module: %w", err)
+	}
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(memoryLimitPages))
+
+	_, err = wasi_snapshot_preview1.Instantiate(ctx, r)
+	if err != nil {
+		r.Close(ctx)
+		return nil, fmt.Errorf("instantiate wasi: %w", err)
+	}
+
+	compiled, err := r.CompileModule(ctx, code)
+	if err != nil {
+		r.Close(ctx)
+		return nil, fmt.Errorf("compile wasm module %q: %w", path, err)
+	}
+
+	if len(compiled.ExportedMemories()) == 0 {
+		r.Close(ctx)
+		return nil, fmt.Errorf("wasm module %q: memory: %w", path, ErrNoExport)
+	}
+	exports := compiled.ExportedFunctions()
+	if _, ok := exports["alloc"]; !ok {
+		r.Close(ctx)
+		return nil, fmt.Errorf("wasm module %q: alloc: %w", path, ErrNoExport)
+	}
+	_, hasFilter := exports["filter"]
+	_, hasTransform := exports["transform"]
+	if !hasFilter && !hasTransform {
+		r.Close(ctx)
+		return nil, fmt.Errorf("wasm module %q: filter or transform: %w", path, ErrNoExport)
+	}
+
+	return &Hook{
+		runtime:      r,
+		compiled:     compiled,
+		pool:         make(chan api.Module, size),
+		slots:        make(chan struct{}, size),
+		hasFilter:    hasFilter,
+		hasTransform: hasTransform,
+	}, nil
+}
+
+// Filter reports whether the message should be processed. Messages pass if the module has no filter hook.
+func (h *Hook) Filter(ctx context.Context, data []byte) (bool, error) {
+	if !h.hasFilter {
+		return true, nil
+	}
+
+	var keep bool
+	err := h.call(ctx, func(m api.Module) error {
+		res, err := h.invoke(ctx, m, "filter", data)
+		if err != nil {
+			return err
+		}
+		keep = uint32(res) != 0
+		return nil
+	})
+	return keep, err
+}
+
+// Transform returns the transformed message. The message is returned as is if the module has no transform hook.
+func (h *Hook) Transform(ctx context.Context, data []byte) ([]byte, error) {
+	if !h.hasTransform {
+		return data, nil
+	}
+
+	var out []byte
+	err := h.call(ctx, func(m api.Module) error {
+		res, err := h.invoke(ctx, m, "transform", data)
+		if err != nil {
+			return err
+		}
+		if res == 0 {
+			return ErrTransform
+		}
+		ptr, size := uint32(res>>32), uint32(res)
+		b, ok := m.Memory().Read(ptr, size)
+		if !ok {
+			return fmt.Errorf("%w: output is out of memory range", ErrTransform)
+		}
+		out = append([]byte(nil), b...)
+		return nil
+	})
+	return out, err
+}
+
+// Close closes the module instances and the runtime. Calls after Close fail with ErrUnavailable.
+func (h *Hook) Close(ctx context.Context) error {
+	h.closed.Store(true)
+	return h.runtime.Close(ctx) //nolint:wrapcheck // close error
+}
+
+// call runs fn with a pooled instance. An instance which failed is closed, a new one is instantiated next time,
+// so the state of a trapped module is never reused.
+func (h *Hook) call(ctx context.Context, fn func(api.Module) error) error {
+	if h.closed.Load() {
+		return ErrUnavailable
+	}
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrUnavailable, ctx.Err())
+	}
+	defer func() { <-h.slots }()
+
+	var m api.Module
+	select {
+	case m = <-h.pool:
+	default:
+		var err error
+		m, err = h.runtime.InstantiateModule(ctx, h.compiled, wazero.NewModuleConfig().
+			WithName("").
+			WithStartFunctions("_initialize"))
+		if err != nil {
+			return fmt.Errorf("%w: instantiate wasm module: %w", ErrUnavailable, err)
+		}
+	}
+
+	err := fn(m)
+	if err != nil {
+		m.Close(ctx)
+		if h.closed.Load() || ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return err
+	}
+	h.pool <- m
+	return nil
+}
+
+// invoke copies the data into the memory of the instance and calls the hook with it.
+func (h *Hook) invoke(ctx context.Context, m api.Module, name string, data []byte) (uint64, error) {
+	res, err := m.ExportedFunction("alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("alloc %d bytes: %w", len(data), err)
+	}
+	ptr := uint32(res[0])
+	if !m.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("alloc returned out of memory range buffer %d", ptr)
+	}
+
+	res, err = m.ExportedFunction(name).Call(ctx, uint64(ptr), uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("call %s: %w", name, err)
+	}
+	return res[0], nil
+}
@@ -0,0 +1,130 @@
+// Package webhook posts messages to outbound webhook URLs with HMAC signatures and retries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature-256"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+)
+
+const (
+	minBackoff = 200 * time.Millisecond
+	maxBackoff = 10 * time.Second
+
+	// DefaultTimeout bounds every post if the timeout isn't set.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Sink posts every message to all the URLs.
+type Sink struct {
+	URLs        []string
+	ContentType string
+	// Secret signs the body: 'X-Webhook-Signature-256: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">'. Empty disables signing.
+	Secret     string
+	MaxRetries int
+	// Timeout bounds every post (the retries are not included). Zero means DefaultTimeout.
+	Timeout time.Duration
+	HTTP    *http.Client
+}
+
+// Publish posts the message to all the URLs concurrently. The key is not used.
+// It fails if any of the URLs fails after the retries, so redelivered messages may be posted to some URLs again.
+func (s *Sink) Publish(ctx context.Context, _ string, data []byte, headers map[string][]string) error {
+	errs := make([]error, len(s.URLs))
+
+	var wg sync.WaitGroup
+	for i, url := range s.URLs {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			errs[i] = s.post(ctx, url, data, headers)
+		}(i, url)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (s *Sink) post(ctx context.Context, url string, data []byte, headers map[string][]string) error {
+	client := s.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	backoff := minBackoff
+	var err error
+	for attempt := 0; attempt <= s.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("webhook %s: %w", url, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+
+		err = s.postOnce(ctx, client, timeout, url, data, headers)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *Sink) postOnce(ctx context.Context, client *http.Client, timeout time.Duration, url string, data []byte, headers map[string][]string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("webhook %s: create request: %w", url, err)
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	if s.ContentType != "" {
+		req.Header.Set("Content-Type", s.ContentType)
+	}
+	if s.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, ts)
+		req.Header.Set(HeaderSignature, "sha256="+Sign(s.Secret, ts, data))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns hex HMAC-SHA256 of '<timestamp>.<body>' with the secret. Receivers verify it the same way.
+func Sign(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,321 @@
+// Package websocket is a minimal WebSocket (RFC 6455) client: text and binary messages, fragmented messages,
+// ping/pong and close frames. Extensions are not supported.
+//
+// Besides 'ws' and 'wss' URLs it connects to unix domain sockets with 'unix:///path/to/socket.sock:/request/path' URLs,
+// see the unixsock package.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // required by RFC 6455
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/unixsock"
+)
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageSize is the default limit of the size of a received message, all its fragments included.
+const MaxMessageSize = 64 << 20
+
+// maxControlPayload is the limit of the payload of control frames (RFC 6455 5.5).
+const maxControlPayload = 125
+
+var (
+	ErrClosed   = errors.New("websocket is closed")
+	ErrTooLarge = errors.New("websocket message is too large")
+	ErrProtocol = errors.New("websocket protocol error")
+)
+
+// Conn is a client WebSocket connection. Writes are safe for concurrent use, reads are not.
+type Conn struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	maxSize int64
+
+	wmx sync.Mutex
+}
+
+// Dialer opens WebSocket connections.
+type Dialer struct {
+	// NetDial dials TCP connections of 'ws' and 'wss' URLs, e.g. with a custom resolver. net.Dialer is used if it is nil.
+	NetDial func(ctx context.Context, network, address string) (net.Conn, error)
+	// MaxMessageSize limits the size of a received message, all its fragments included. MaxMessageSize is used if it is 0.
+	MaxMessageSize int64
+}
+
+// Dial opens the connection to ws:// or wss:// URL with the default dialer.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	return (&Dialer{}).Dial(ctx, rawURL, header) //nolint:exhaustruct // default dialer
+}
+
+// Dial opens the connection to ws://, wss:// or unix:// URL.
+func (d *Dialer) Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+
+	network, address := "tcp", u.Host
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), "443")
+		}
+	case unixsock.Scheme:
+		socket, reqPath := unixsock.Split(u.Path)
+		if socket == "" {
+			return nil, fmt.Errorf("unix endpoint %q: %w", rawURL, unixsock.ErrNoSocket)
+		}
+		network, address = "unix", socket
+		u = &url.URL{Path: reqPath, RawQuery: u.RawQuery, Host: "localhost"} //nolint:exhaustruct // request uri and host only
+	default:
+		return nil, fmt.Errorf("wrong scheme %q: only 'ws|wss|unix' are accepted", u.Scheme)
+	}
+
+	netDial := d.NetDial
+	if netDial == nil || network == "unix" {
+		netDial = (&net.Dialer{}).DialContext //nolint:exhaustruct // default dialer
+	}
+	nc, err := netDial(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	if u.Scheme == "wss" {
+		tc := tls.Client(nc, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		nc = tc
+	}
+
+	c, err := handshake(ctx, nc, u, header)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	c.maxSize = d.MaxMessageSize
+	if c.maxSize <= 0 {
+		c.maxSize = MaxMessageSize
+	}
+	return c, nil
+}
+
+func handshake(ctx context.Context, nc net.Conn, u *url.URL, header http.Header) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(deadline)
+		defer nc.SetDeadline(time.Time{}) //nolint:errcheck // best effort reset
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{ //nolint:exhaustruct // client request
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery}, //nolint:exhaustruct // request uri only
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Host:       u.Host,
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(nc); err != nil {
+		return nil, fmt.Errorf("write handshake: %w", err)
+	}
+
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("read handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("handshake: unexpected status %d", resp.StatusCode)
+	}
+
+	h := sha1.Sum([]byte(key + acceptGUID)) //nolint:gosec // required by RFC 6455
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(h[:]) {
+		return nil, errors.New("handshake: wrong Sec-WebSocket-Accept")
+	}
+	return &Conn{conn: nc, br: br, maxSize: MaxMessageSize}, nil //nolint:exhaustruct // zero mutex
+}
+
+// WriteMessage sends the message in a single masked frame.
+func (c *Conn) WriteMessage(op byte, data []byte) error {
+	c.wmx.Lock()
+	defer c.wmx.Unlock()
+
+	return c.writeFrame(op, data)
+}
+
+func (c *Conn) writeFrame(op byte, data []byte) error {
+	hdr := make([]byte, 0, 14)
+	hdr = append(hdr, 0x80|op)
+	switch l := len(data); {
+	case l < 126:
+		hdr = append(hdr, 0x80|byte(l))
+	case l <= 0xFFFF:
+		hdr = append(hdr, 0x80|126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(l))
+	default:
+		hdr = append(hdr, 0x80|127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(l))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return fmt.Errorf("generate mask: %w", err)
+	}
+	hdr = append(hdr, mask[:]...)
+
+	payload := make([]byte, len(data))
+	for i := range data {
+		payload[i] = data[i] ^ mask[i%4]
+	}
+
+	if _, err := c.conn.Write(append(hdr, payload...)); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	return nil
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered, fragmented messages are assembled
+// up to the max message size.
+func (c *Conn) ReadMessage() (byte, []byte, error) {
+	var op byte
+	var msg []byte
+	for {
+		fin, frameOp, data, err := c.readFrame(c.maxSize - int64(len(msg)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOp {
+		case OpPing:
+			if err := c.WriteMessage(OpPong, data); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			_ = c.WriteMessage(OpClose, nil)
+			return 0, nil, ErrClosed
+		case OpText, OpBinary:
+			if op != 0 {
+				return 0, nil, fmt.Errorf("%w: new message before the fragmented message is finished", ErrProtocol)
+			}
+			op, msg = frameOp, data
+		case OpContinuation:
+			if op == 0 {
+				return 0, nil, fmt.Errorf("%w: continuation frame without a message", ErrProtocol)
+			}
+			msg = append(msg, data...)
+		default:
+			return 0, nil, fmt.Errorf("%w: unknown opcode %d", ErrProtocol, frameOp)
+		}
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame reads the next frame. Its payload is limited to maxSize bytes, the rest of the max message size.
+func (c *Conn) readFrame(maxSize int64) (bool, byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return false, 0, nil, fmt.Errorf("read frame: %w", err)
+	}
+	fin, op := hdr[0]&0x80 != 0, hdr[0]&0x0F
+	masked := hdr[1]&0x80 != 0
+	if hdr[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits are set without extensions", ErrProtocol)
+	}
+	if masked {
+		return false, 0, nil, fmt.Errorf("%w: server frame is masked", ErrProtocol)
+	}
+
+	l := uint64(hdr[1] & 0x7F)
+	switch l {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("read frame length: %w", err)
+		}
+		l = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("read frame length: %w", err)
+		}
+		l = binary.BigEndian.Uint64(b[:])
+	}
+
+	if op >= OpClose && (!fin || l > maxControlPayload) {
+		return false, 0, nil, fmt.Errorf("%w: control frame is fragmented or longer than %d bytes", ErrProtocol, maxControlPayload)
+	}
+	if op < OpClose && l > uint64(max(maxSize, 0)) {
+		return false, 0, nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, c.maxSize)
+	}
+
+	data := make([]byte, l)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		return false, 0, nil, fmt.Errorf("read frame payload: %w", err)
+	}
+	return fin, op, data, nil
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t) //nolint:wrapcheck // net errors are descriptive
+}
+
+// SetDeadline sets the read and write deadline of the underlying connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t) //nolint:wrapcheck // net errors are descriptive
+}
+
+// Close sends the close frame and closes the connection.
+func (c *Conn) Close() error {
+	_ = c.WriteMessage(OpClose, nil)
+	return c.conn.Close() //nolint:wrapcheck // net errors are descriptive
+}
@@ -0,0 +1,280 @@
+package websocket_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/websocket"
+	"github.com/glassflow/nats-jetstream-http-connector/pkg/websocket/websockettest"
+)
+
+type frame struct {
+	fin     bool
+	op      byte
+	payload string
+	masked  bool
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		frames  []frame
+		maxSize int64
+		op      byte
+		want    string
+		err     error
+		pong    bool // the ping is answered
+	}{
+		{
+			name:   "text",
+			frames: []frame{{fin: true, op: websocket.OpText, payload: "hello"}},
+			op:     websocket.OpText,
+			want:   "hello",
+		},
+		{
+			name:   "binary",
+			frames: []frame{{fin: true, op: websocket.OpBinary, payload: "\x00\x01"}},
+			op:     websocket.OpBinary,
+			want:   "\x00\x01",
+		},
+		{
+			name: "fragmented",
+			frames: []frame{
+				{op: websocket.OpText, payload: "hel"},
+				{op: websocket.OpContinuation, payload: "lo "},
+				{fin: true, op: websocket.OpContinuation, payload: "world"},
+			},
+			op:   websocket.OpText,
+			want: "hello world",
+		},
+		{
+			name: "ping between fragments",
+			frames: []frame{
+				{op: websocket.OpText, payload: "hel"},
+				{fin: true, op: websocket.OpPing, payload: "p"},
+				{fin: true, op: websocket.OpContinuation, payload: "lo"},
+			},
+			op:   websocket.OpText,
+			want: "hello",
+			pong: true,
+		},
+		{
+			name:    "frame is too large",
+			frames:  []frame{{fin: true, op: websocket.OpText, payload: "0123456789a"}},
+			maxSize: 10,
+			err:     websocket.ErrTooLarge,
+		},
+		{
+			name: "fragmented message is too large",
+			frames: []frame{
+				{op: websocket.OpText, payload: "012345"},
+				{fin: true, op: websocket.OpContinuation, payload: "6789a"},
+			},
+			maxSize: 10,
+			err:     websocket.ErrTooLarge,
+		},
+		{
+			name: "fragmented message at the limit",
+			frames: []frame{
+				{op: websocket.OpText, payload: "012345"},
+				{fin: true, op: websocket.OpContinuation, payload: "6789"},
+			},
+			maxSize: 10,
+			op:      websocket.OpText,
+			want:    "0123456789",
+		},
+		{
+			name:   "continuation without message",
+			frames: []frame{{fin: true, op: websocket.OpContinuation, payload: "lo"}},
+			err:    websocket.ErrProtocol,
+		},
+		{
+			name: "new message inside fragmented message",
+			frames: []frame{
+				{op: websocket.OpText, payload: "hel"},
+				{fin: true, op: websocket.OpText, payload: "lo"},
+			},
+			err: websocket.ErrProtocol,
+		},
+		{
+			name:   "masked server frame",
+			frames: []frame{{fin: true, op: websocket.OpText, payload: "hello", masked: true}},
+			err:    websocket.ErrProtocol,
+		},
+		{
+			name:   "fragmented control frame",
+			frames: []frame{{op: websocket.OpPing, payload: "p"}},
+			err:    websocket.ErrProtocol,
+		},
+		{
+			name:   "unknown opcode",
+			frames: []frame{{fin: true, op: 0x3, payload: "x"}},
+			err:    websocket.ErrProtocol,
+		},
+		{
+			name:   "close",
+			frames: []frame{{fin: true, op: websocket.OpClose}},
+			err:    websocket.ErrClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pong := make(chan string, 1)
+			srv := websockettest.NewServer(func(c *websockettest.Conn) {
+				for _, f := range tt.frames {
+					write := c.WriteFrame
+					if f.masked {
+						write = c.WriteMaskedFrame
+					}
+					if err := write(f.fin, f.op, []byte(f.payload)); err != nil {
+						return
+					}
+				}
+				for {
+					_, op, payload, err := c.ReadFrame()
+					if err != nil {
+						return
+					}
+					if op == websocket.OpPong {
+						pong <- string(payload)
+					}
+				}
+			})
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ws, err := (&websocket.Dialer{MaxMessageSize: tt.maxSize}).Dial(ctx, srv.URL, http.Header{}) //nolint:exhaustruct // default net dialer
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer ws.Close()
+
+			op, data, err := ws.ReadMessage()
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if op != tt.op || string(data) != tt.want {
+				t.Errorf("message = %d %q, want %d %q", op, data, tt.op, tt.want)
+			}
+			if tt.pong {
+				select {
+				case p := <-pong:
+					if p != "p" {
+						t.Errorf("pong = %q, want %q", p, "p")
+					}
+				case <-time.After(time.Second):
+					t.Error("ping is not answered")
+				}
+			}
+		})
+	}
+}
+
+// echo returns the server echoing the messages of the client.
+func echo() func(c *websockettest.Conn) {
+	return func(c *websockettest.Conn) {
+		for {
+			fin, op, payload, err := c.ReadFrame()
+			if err != nil || op == websocket.OpClose {
+				return
+			}
+			if err := c.WriteFrame(fin, op, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	srv := websockettest.NewServer(echo())
+	defer srv.Close()
+
+	ws, err := websocket.Dial(context.Background(), srv.URL, http.Header{})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+
+	for _, size := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		msg := bytes.Repeat([]byte("a"), size)
+		if err := ws.WriteMessage(websocket.OpBinary, msg); err != nil {
+			t.Fatalf("write %d bytes: %v", size, err)
+		}
+		op, data, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %d bytes: %v", size, err)
+		}
+		if op != websocket.OpBinary || !bytes.Equal(data, msg) {
+			t.Errorf("echo of %d bytes = %d, %d bytes", size, op, len(data))
+		}
+	}
+}
+
+func TestDialNetDial(t *testing.T) {
+	srv := websockettest.NewServer(echo())
+	defer srv.Close()
+
+	// The host is resolved by NetDial only.
+	var dials atomic.Int64
+	d := &websocket.Dialer{ //nolint:exhaustruct // default max message size
+		NetDial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String()) //nolint:exhaustruct // default dialer
+		},
+	}
+	ws, err := d.Dial(context.Background(), "ws://endpoint.invalid/path", http.Header{})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	ws.Close()
+	if n := dials.Load(); n != 1 {
+		t.Errorf("net dials = %d, want 1", n)
+	}
+}
+
+func TestDialUnix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ws.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	paths := make(chan string, 1)
+	srv := &http.Server{ //nolint:exhaustruct,gosec // test server
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths <- r.URL.Path
+			websockettest.Handler(echo()).ServeHTTP(w, r)
+		}),
+	}
+	go srv.Serve(l) //nolint:errcheck // closed by the test
+	defer srv.Close()
+
+	ws, err := websocket.Dial(context.Background(), "unix://"+socket+":/functions/echo", http.Header{})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+
+	if p := <-paths; p != "/functions/echo" {
+		t.Errorf("request path = %q, want %q", p, "/functions/echo")
+	}
+	if err := ws.WriteMessage(websocket.OpText, []byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, data, err := ws.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Errorf("echo = %q, %v, want %q", data, err, "hello")
+	}
+}
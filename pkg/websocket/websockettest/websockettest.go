@@ -0,0 +1,134 @@
+// Package websockettest provides a WebSocket server for tests which sends raw frames, e.g. fragmented or malformed ones.
+package websockettest
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by RFC 6455
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Conn is the server side of a test connection.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// NewServer starts the server running the handler for every upgraded connection. The connection is closed
+// when the handler returns. The URL of the server has 'ws' scheme.
+func NewServer(handler func(c *Conn)) *httptest.Server {
+	srv := httptest.NewServer(Handler(handler))
+	srv.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return srv
+}
+
+// Handler upgrades the requests to WebSocket connections and runs the handler for them.
+func Handler(handler func(c *Conn)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Sec-WebSocket-Key")
+		if r.Header.Get("Upgrade") != "websocket" || key == "" {
+			http.Error(w, "websocket upgrade is expected", http.StatusBadRequest)
+			return
+		}
+		nc, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer nc.Close()
+
+		h := sha1.Sum([]byte(key + acceptGUID)) //nolint:gosec // required by RFC 6455
+		fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			base64.StdEncoding.EncodeToString(h[:]))
+		if err := brw.Flush(); err != nil {
+			return
+		}
+		handler(&Conn{conn: nc, br: brw.Reader})
+	})
+}
+
+// WriteFrame writes the unmasked frame.
+func (c *Conn) WriteFrame(fin bool, op byte, payload []byte) error {
+	return c.write(fin, op, payload, false)
+}
+
+// WriteMaskedFrame writes the frame masked with a zero mask, which is not allowed for the server frames.
+func (c *Conn) WriteMaskedFrame(fin bool, op byte, payload []byte) error {
+	return c.write(fin, op, payload, true)
+}
+
+func (c *Conn) write(fin bool, op byte, payload []byte, masked bool) error {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	var m byte
+	if masked {
+		m = 0x80
+	}
+
+	hdr := []byte{b0}
+	switch l := len(payload); {
+	case l < 126:
+		hdr = append(hdr, m|byte(l))
+	case l <= 0xFFFF:
+		hdr = append(hdr, m|126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(l))
+	default:
+		hdr = append(hdr, m|127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(l))
+	}
+	if masked {
+		hdr = append(hdr, 0, 0, 0, 0)
+	}
+	_, err := c.conn.Write(append(hdr, payload...))
+	return err //nolint:wrapcheck // test server
+}
+
+// ReadFrame reads the next frame of the client and unmasks it.
+func (c *Conn) ReadFrame() (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return false, 0, nil, err //nolint:wrapcheck // test server
+	}
+	fin, op = hdr[0]&0x80 != 0, hdr[0]&0x0F
+
+	l := uint64(hdr[1] & 0x7F)
+	switch l {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, err //nolint:wrapcheck // test server
+		}
+		l = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, err //nolint:wrapcheck // test server
+		}
+		l = binary.BigEndian.Uint64(b[:])
+	}
+
+	var mask [4]byte
+	if hdr[1]&0x80 != 0 {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err //nolint:wrapcheck // test server
+		}
+	}
+	payload = make([]byte, l)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err //nolint:wrapcheck // test server
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}